@@ -8,6 +8,7 @@ import (
 
 	"standalone-stream-server/internal/models"
 
+	"github.com/robfig/cron/v3"
 	"github.com/spf13/viper"
 )
 
@@ -71,6 +72,10 @@ func setDefaults() {
 	viper.SetDefault("server.max_connections", 100)
 	viper.SetDefault("server.tokens_per_second", 0) // 0 means auto-calculate (max_connections/4)
 	viper.SetDefault("server.graceful_timeout", "30s")
+	viper.SetDefault("server.admission.enabled", true)
+	viper.SetDefault("server.admission.max_queue", 100)
+	viper.SetDefault("server.admission.max_wait", "5s")
+	viper.SetDefault("server.admission.priorities", map[string]int{"anonymous": 0, "viewer": 1, "uploader": 2, "admin": 3})
 
 	// 视频默认值
 	viper.SetDefault("video.directories", []models.VideoDirectory{
@@ -88,6 +93,57 @@ func setDefaults() {
 	viper.SetDefault("video.streaming.range_support", true)
 	viper.SetDefault("video.streaming.chunk_size", 1024*1024) // 1MB
 	viper.SetDefault("video.streaming.connection_timeout", "60s")
+	viper.SetDefault("video.streaming.bandwidth.enabled", false)
+	viper.SetDefault("video.streaming.bandwidth.global_limit", 0)
+	viper.SetDefault("video.streaming.bandwidth.per_client_limit", 0)
+	viper.SetDefault("video.streaming.bandwidth.realtime_factor", 1.5)
+	viper.SetDefault("video.streaming.bandwidth.initial_burst", "10s")
+	viper.SetDefault("video.catalog.enabled", true)
+	viper.SetDefault("video.catalog.path", "./data/catalog.db")
+	viper.SetDefault("video.catalog.refresh_interval", "5m")
+	viper.SetDefault("video.watcher.enabled", true)
+	viper.SetDefault("video.watcher.debounce", "2s")
+	viper.SetDefault("video.ffmpeg_path", "ffmpeg")
+	viper.SetDefault("video.packaging.segment_duration", "6s")
+	viper.SetDefault("video.packaging.cache_dir", "./data/segments")
+	viper.SetDefault("video.packaging.cache_max_size", 10*1024*1024*1024) // 10GB
+	viper.SetDefault("video.packaging.workers", 2)
+	viper.SetDefault("video.packaging.queue_timeout", "5s")
+	viper.SetDefault("video.packaging.hls.enabled", true)
+	viper.SetDefault("video.packaging.hls.segment_format", "mpegts")
+	viper.SetDefault("video.packaging.dash.enabled", true)
+	viper.SetDefault("video.resumable_upload.enabled", true)
+	viper.SetDefault("video.resumable_upload.dir", "./data/uploads")
+	viper.SetDefault("video.resumable_upload.expiration", "24h")
+	viper.SetDefault("video.trash.enabled", true)
+	viper.SetDefault("video.trash.grace_period", "168h")
+	viper.SetDefault("video.trash.purge_schedule", "@hourly")
+	viper.SetDefault("video.sprites.enabled", true)
+	viper.SetDefault("video.sprites.frames", 100)
+	viper.SetDefault("video.sprites.columns", 10)
+	viper.SetDefault("video.sprites.width", 160)
+	viper.SetDefault("video.sprites.height", 90)
+	viper.SetDefault("video.thumbnails.max_width", 1920)
+	viper.SetDefault("video.thumbnails.max_height", 1080)
+	viper.SetDefault("video.thumbnails.quality", 85)
+	viper.SetDefault("video.thumbnails.cache_max_age", "24h")
+	viper.SetDefault("video.thumbnails.dir", "./thumbnails")
+	viper.SetDefault("video.thumbnails.max_cache_size", 1024*1024*1024) // 1GB
+	viper.SetDefault("video.thumbnails.workers", 2)
+	viper.SetDefault("video.thumbnails.timeout", "60s")
+	viper.SetDefault("video.metadata.cache_enabled", true)
+	viper.SetDefault("video.metadata.cache_path", "./data/metadata.db")
+	viper.SetDefault("video.metadata.workers", 2)
+	viper.SetDefault("video.metadata.timeout", "30s")
+	viper.SetDefault("video.metadata.warmup", true)
+	viper.SetDefault("video.transcoding.enabled", true)
+	viper.SetDefault("video.transcoding.auto_on_upload", true)
+	viper.SetDefault("video.transcoding.renditions", []models.RenditionConfig{
+		{Name: "1080p", Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k"},
+		{Name: "720p", Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+		{Name: "480p", Height: 480, VideoBitrate: "1400k", AudioBitrate: "128k"},
+		{Name: "audio", Height: 0, AudioBitrate: "128k"},
+	})
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
@@ -97,18 +153,41 @@ func setDefaults() {
 	viper.SetDefault("logging.error_log", true)
 
 	// 安全默认值
+	viper.SetDefault("scheduler.queue.path", "./data/tasks/tasks.db")
+	viper.SetDefault("scheduler.queue.max_attempts", 5)
+	viper.SetDefault("scheduler.queue.visibility_timeout", "10m")
+	viper.SetDefault("scheduler.queue.backoff_base", "30s")
+	viper.SetDefault("scheduler.queue.backoff_max", "1h")
+	viper.SetDefault("scheduler.queue.retention", "24h")
+	viper.SetDefault("scheduler.retention_schedule", "@hourly")
+
 	viper.SetDefault("security.cors.enabled", true)
 	viper.SetDefault("security.cors.allowed_origins", []string{"*"})
-	viper.SetDefault("security.cors.allowed_methods", []string{"GET", "POST", "OPTIONS"})
-	viper.SetDefault("security.cors.allowed_headers", []string{"Content-Type", "Range", "Authorization"})
+	viper.SetDefault("security.cors.allowed_methods", []string{"GET", "POST", "HEAD", "PATCH", "DELETE", "OPTIONS"})
+	viper.SetDefault("security.cors.allowed_headers", []string{"Content-Type", "Range", "If-Range", "If-None-Match", "If-Modified-Since", "Authorization", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"})
 
 	viper.SetDefault("security.rate_limit.enabled", true)
 	viper.SetDefault("security.rate_limit.requests_per_minute", 60)
 	viper.SetDefault("security.rate_limit.burst_size", 10)
 	viper.SetDefault("security.rate_limit.cleanup_time", "5m")
 
+	viper.SetDefault("security.limiter.backend", "memory")
+	viper.SetDefault("security.limiter.redis.addr", "localhost:6379")
+	viper.SetDefault("security.limiter.redis.db", 0)
+	viper.SetDefault("security.limiter.redis.key_prefix", "sss:")
+	viper.SetDefault("security.limiter.lease_ttl", "10m")
+
 	viper.SetDefault("security.auth.enabled", false)
 	viper.SetDefault("security.auth.type", "none")
+	viper.SetDefault("security.auth.users_db", "./data/users.db")
+	viper.SetDefault("security.auth.api_keys_db", "./data/api_keys.db")
+	viper.SetDefault("security.auth.jwt.issuer", "standalone-stream-server")
+	viper.SetDefault("security.auth.jwt.access_ttl", "15m")
+	viper.SetDefault("security.auth.jwt.refresh_ttl", "168h")
+
+	viper.SetDefault("security.signed_urls.enabled", false)
+	viper.SetDefault("security.signed_urls.default_ttl", "1h")
+	viper.SetDefault("security.signed_urls.max_ttl", "24h")
 }
 
 // validateConfig validates the loaded configuration
@@ -134,6 +213,17 @@ func validateConfig(config *models.Config) error {
 			if dir.Path == "" {
 				return fmt.Errorf("video directory path cannot be empty for directory: %s", dir.Name)
 			}
+			switch dir.Deduplication {
+			case "", "off", "reject", "link":
+			default:
+				return fmt.Errorf("invalid deduplication policy for directory %s: %s", dir.Name, dir.Deduplication)
+			}
+			if err := validateDirectoryAccess(config, dir); err != nil {
+				return err
+			}
+			if err := validateRetention(dir); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -145,6 +235,78 @@ func validateConfig(config *models.Config) error {
 		return fmt.Errorf("max_upload_size must be positive: %d", config.Video.MaxUploadSize)
 	}
 
+	// Validate admission queue
+	if config.Server.Admission.Enabled && (config.Server.Admission.MaxQueue < 0 || config.Server.Admission.MaxWait < 0) {
+		return fmt.Errorf("admission max_queue and max_wait cannot be negative")
+	}
+
+	// Validate bandwidth shaping
+	if err := validateBandwidth(config.Video.StreamingSettings.Bandwidth); err != nil {
+		return err
+	}
+
+	// Validate trash
+	if err := validateTrash(config.Video.Trash); err != nil {
+		return err
+	}
+
+	// Validate sprite sheets
+	if err := validateSprites(config.Video.Sprites); err != nil {
+		return err
+	}
+
+	// Validate thumbnail variants
+	if err := validateThumbnails(config.Video.Thumbnails); err != nil {
+		return err
+	}
+
+	// Validate metadata probing
+	if err := validateMetadata(config.Video.Metadata); err != nil {
+		return err
+	}
+
+	// Validate segment packaging
+	if err := validatePackaging(config.Video.Packaging); err != nil {
+		return err
+	}
+
+	// Validate limiter backend
+	if err := validateLimiter(config.Security.Limiter); err != nil {
+		return err
+	}
+
+	// Validate task queue
+	if err := validateTaskQueue(config.Scheduler.Queue); err != nil {
+		return err
+	}
+	if err := validateTaskTypes(config.Scheduler.Tasks); err != nil {
+		return err
+	}
+	if err := validateCronJobs(config.Scheduler.Jobs); err != nil {
+		return err
+	}
+	if err := validateRetentionSchedule(config.Scheduler.RetentionSchedule); err != nil {
+		return err
+	}
+
+	// Validate authentication
+	if config.Security.Auth.Enabled {
+		switch config.Security.Auth.Type {
+		case "", "none", "api_key", "basic":
+		case "jwt":
+			if len(config.Security.Auth.JWT.Secret) < 32 {
+				return fmt.Errorf("jwt authentication requires a secret of at least 32 bytes")
+			}
+		default:
+			return fmt.Errorf("invalid auth type: %s", config.Security.Auth.Type)
+		}
+	}
+
+	// Validate signed URLs
+	if config.Security.SignedURLs.Enabled && len(config.Security.SignedURLs.Keys) == 0 {
+		return fmt.Errorf("signed_urls requires at least one signing key")
+	}
+
 	// Validate timeouts
 	if config.Server.ReadTimeout <= 0 {
 		config.Server.ReadTimeout = 30 * time.Second
@@ -159,6 +321,215 @@ func validateConfig(config *models.Config) error {
 	return nil
 }
 
+// validateDirectoryAccess validates a directory ACL; non-public modes only make sense with authentication
+func validateDirectoryAccess(config *models.Config, dir models.VideoDirectory) error {
+	switch dir.Access.Mode {
+	case "", "public":
+	case "authenticated", "restricted":
+		auth := config.Security.Auth
+		if !auth.Enabled || auth.Type == "" || auth.Type == "none" {
+			return fmt.Errorf("%s access for directory %s requires authentication to be enabled", dir.Access.Mode, dir.Name)
+		}
+	default:
+		return fmt.Errorf("invalid access mode for directory %s: %s", dir.Name, dir.Access.Mode)
+	}
+
+	for _, grant := range dir.Access.Grants {
+		for _, right := range grant.Rights {
+			switch right {
+			case "read", "upload", "delete":
+			default:
+				return fmt.Errorf("invalid access right for directory %s: %s", dir.Name, right)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateRetention validates a directory's retention policy; exclude patterns must be valid globs
+func validateRetention(dir models.VideoDirectory) error {
+	policy := dir.Retention
+	if policy.MaxAge < 0 || policy.MaxTotalSize < 0 || policy.KeepLast < 0 {
+		return fmt.Errorf("retention limits for directory %s cannot be negative", dir.Name)
+	}
+	switch policy.Eviction {
+	case "", "oldest", "lru":
+	default:
+		return fmt.Errorf("invalid retention eviction order for directory %s: %s", dir.Name, policy.Eviction)
+	}
+	for _, pattern := range policy.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid retention exclude pattern for directory %s: %q", dir.Name, pattern)
+		}
+	}
+	return nil
+}
+
+// validateTrash validates the grace period and purge schedule of the trash
+func validateTrash(trash models.TrashConfig) error {
+	if !trash.Enabled {
+		return nil
+	}
+	if trash.GracePeriod < 0 {
+		return fmt.Errorf("trash grace_period cannot be negative: %s", trash.GracePeriod)
+	}
+	if trash.PurgeSchedule != "" {
+		if _, err := cron.ParseStandard(trash.PurgeSchedule); err != nil {
+			return fmt.Errorf("invalid trash purge_schedule %q: %w", trash.PurgeSchedule, err)
+		}
+	}
+	return nil
+}
+
+// validateSprites validates the layout of seek-preview sprite sheets
+func validateSprites(sprites models.SpriteConfig) error {
+	if !sprites.Enabled {
+		return nil
+	}
+	if sprites.Frames <= 0 || sprites.Columns <= 0 || sprites.Width <= 0 || sprites.Height <= 0 {
+		return fmt.Errorf("sprite frames, columns, width and height must be positive")
+	}
+	if sprites.Frames > 1000 {
+		return fmt.Errorf("sprite frames cannot exceed 1000: %d", sprites.Frames)
+	}
+	return nil
+}
+
+// validateThumbnails validates the limits of thumbnail variants; zero values fall back to the defaults
+func validateThumbnails(thumbnails models.ThumbnailConfig) error {
+	if thumbnails.MaxWidth < 0 || thumbnails.MaxHeight < 0 {
+		return fmt.Errorf("thumbnail max_width and max_height cannot be negative")
+	}
+	if thumbnails.Quality < 0 || thumbnails.Quality > 100 {
+		return fmt.Errorf("thumbnail quality must be between 1 and 100: %d", thumbnails.Quality)
+	}
+	if thumbnails.CacheMaxAge < 0 {
+		return fmt.Errorf("thumbnail cache_max_age cannot be negative")
+	}
+	if thumbnails.MaxCacheSize < 0 {
+		return fmt.Errorf("thumbnail max_cache_size cannot be negative")
+	}
+	if thumbnails.Workers < 0 || thumbnails.Timeout < 0 {
+		return fmt.Errorf("thumbnail workers and timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateMetadata validates the probe pool and the metadata cache location
+func validateMetadata(metadata models.MetadataConfig) error {
+	if metadata.CacheEnabled && metadata.CachePath == "" {
+		return fmt.Errorf("metadata cache_path cannot be empty when the cache is enabled")
+	}
+	if metadata.Workers < 0 || metadata.Timeout < 0 {
+		return fmt.Errorf("metadata workers and timeout cannot be negative")
+	}
+	return nil
+}
+
+// validatePackaging validates the segment encoder pool
+func validatePackaging(packaging models.PackagingConfig) error {
+	if packaging.Workers < 0 || packaging.QueueTimeout < 0 {
+		return fmt.Errorf("packaging workers and queue_timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateBandwidth validates stream shaping limits; a realtime factor below 1 would stall playback
+func validateBandwidth(bw models.BandwidthConfig) error {
+	if !bw.Enabled {
+		return nil
+	}
+	if bw.GlobalLimit < 0 || bw.PerClientLimit < 0 {
+		return fmt.Errorf("bandwidth limits cannot be negative")
+	}
+	if bw.RealtimeFactor != 0 && bw.RealtimeFactor < 1 {
+		return fmt.Errorf("bandwidth realtime_factor must be 0 (disabled) or at least 1: %g", bw.RealtimeFactor)
+	}
+	if bw.InitialBurst < 0 {
+		return fmt.Errorf("bandwidth initial_burst cannot be negative")
+	}
+	return nil
+}
+
+// validateLimiter validates the backend that stores rate limit counters and connection slots
+func validateLimiter(limiter models.LimiterConfig) error {
+	switch limiter.Backend {
+	case "", "memory":
+	case "redis":
+		if limiter.Redis.Addr == "" {
+			return fmt.Errorf("redis limiter backend requires an address")
+		}
+	default:
+		return fmt.Errorf("invalid limiter backend: %s", limiter.Backend)
+	}
+	if limiter.LeaseTTL < 0 {
+		return fmt.Errorf("limiter lease_ttl cannot be negative")
+	}
+	return nil
+}
+
+// validateTaskQueue validates the retry policy of the scheduler's task queue
+func validateTaskQueue(queue models.TaskQueueConfig) error {
+	if queue.MaxAttempts < 0 {
+		return fmt.Errorf("scheduler queue max_attempts cannot be negative: %d", queue.MaxAttempts)
+	}
+	if queue.VisibilityTimeout < 0 || queue.BackoffBase < 0 || queue.BackoffMax < 0 || queue.Retention < 0 {
+		return fmt.Errorf("scheduler queue durations cannot be negative")
+	}
+	if queue.BackoffMax > 0 && queue.BackoffMax < queue.BackoffBase {
+		return fmt.Errorf("scheduler queue backoff_max (%s) must not be below backoff_base (%s)", queue.BackoffMax, queue.BackoffBase)
+	}
+	return nil
+}
+
+// validateCronJobs validates the names, schedules and timezones of recurring jobs; actions and
+// their parameters are checked by the scheduler
+func validateCronJobs(jobs []models.CronJobConfig) error {
+	names := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if job.Name == "" {
+			return fmt.Errorf("scheduler job name cannot be empty")
+		}
+		if names[job.Name] {
+			return fmt.Errorf("duplicate scheduler job name: %s", job.Name)
+		}
+		names[job.Name] = true
+
+		if job.Action == "" {
+			return fmt.Errorf("scheduler job %s: action is required", job.Name)
+		}
+		if _, err := cron.ParseStandard(job.Schedule); err != nil {
+			return fmt.Errorf("scheduler job %s: invalid schedule %q: %w", job.Name, job.Schedule, err)
+		}
+		if _, err := time.LoadLocation(job.Timezone); err != nil {
+			return fmt.Errorf("scheduler job %s: invalid timezone %q: %w", job.Name, job.Timezone, err)
+		}
+	}
+	return nil
+}
+
+// validateRetentionSchedule validates the schedule of the built-in retention job; empty uses the default
+func validateRetentionSchedule(schedule string) error {
+	if schedule == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return fmt.Errorf("invalid scheduler retention_schedule %q: %w", schedule, err)
+	}
+	return nil
+}
+
+// validateTaskTypes validates the per-type overrides of the scheduler's task types
+func validateTaskTypes(tasks map[string]models.TaskTypeConfig) error {
+	for name, task := range tasks {
+		if task.Concurrency < 0 || task.PollInterval < 0 {
+			return fmt.Errorf("scheduler task %s: concurrency and poll_interval cannot be negative", name)
+		}
+	}
+	return nil
+}
+
 // ensureVideoDirectories creates video directories if they don't exist
 func ensureVideoDirectories(config *models.Config) error {
 	for _, dir := range config.Video.Directories {
@@ -191,6 +562,16 @@ server:
   max_connections: 100
   tokens_per_second: 25  # Flow control tokens per second (0 = auto-calculate as max_connections/4)
   graceful_timeout: "30s"
+  admission:  # queue stream requests instead of answering 429 immediately when saturated
+    enabled: true
+    max_queue: 100  # queued requests beyond this are rejected with Retry-After
+    max_wait: "5s"
+    priorities:  # higher is admitted first
+      anonymous: 0
+      viewer: 1
+      uploader: 2
+      admin: 3
+    key_priorities: {}  # API key id -> priority (key tier), overrides the role priority
 
 video:
   directories:
@@ -198,10 +579,22 @@ video:
       path: "./videos/movies"
       description: "Movie collection"
       enabled: true
+      deduplication: "reject"  # off, reject (409), link (hard link to existing file)
+      retention:  # evaluated by the scheduler; matching videos are queued as video_deletion tasks
+        max_age: "2160h"  # delete videos last modified more than 90 days ago
+        max_total_size: 536870912000  # 500GB; beyond this, evict in eviction order
+        eviction: "oldest"  # oldest (modification time), lru (least recently streamed)
+        keep_last: 0  # keep only the newest N videos of every subfolder (0 = off)
+        exclude: ["favorites/*", "*.keep.mp4"]  # globs on the relative path or file name, never deleted
     - name: "series"
       path: "./videos/series"
       description: "TV series collection"
       enabled: true
+      access:  # directory ACL, enforced only when security.auth is enabled
+        mode: "authenticated"  # public (anonymous read), authenticated (default), restricted (grants only)
+        grants:  # when present, upload/delete also require a matching grant
+          - groups: ["editors"]
+            rights: ["read", "upload", "delete"]
     - name: "documentaries"
       path: "./videos/docs"
       description: "Documentary collection"
@@ -214,6 +607,121 @@ video:
     range_support: true
     chunk_size: 1048576  # 1MB
     connection_timeout: "60s"
+    bandwidth:  # egress shaping for /stream, /hls and /dash (bytes per second, 0 = unlimited)
+      enabled: false
+      global_limit: 0  # total across all streams
+      per_client_limit: 0  # per client IP, or per API key
+      realtime_factor: 1.5  # per-stream cap as a multiple of the video bitrate (0 = off)
+      initial_burst: "10s"  # playback time sent at full speed before the per-stream cap applies
+  catalog:
+    enabled: true  # Persistent video index instead of rescanning disk on every request
+    path: "./data/catalog.db"
+    refresh_interval: "5m"  # Incremental refresh interval
+  watcher:
+    enabled: true  # Keep the catalog live via filesystem events (requires catalog)
+    debounce: "2s"  # Wait for files to stop changing before indexing
+  ffmpeg_path: "ffmpeg"
+  packaging:
+    segment_duration: "6s"
+    cache_dir: "./data/segments"  # Lazily generated segments
+    cache_max_size: 10737418240  # 10GB, least recently used segments are evicted
+    workers: 2  # Concurrent ffmpeg segment encodes shared by HLS and DASH
+    queue_timeout: "5s"  # Wait for a free encoder, then respond 503 with Retry-After
+    hls:
+      enabled: true  # GET /hls/:directory/*/index.m3u8
+      segment_format: "mpegts"  # mpegts, fmp4
+    dash:
+      enabled: true  # GET /dash/:directory/*/manifest.mpd (fMP4 segments, shared cache with HLS)
+  resumable_upload:
+    enabled: true  # tus 1.0 uploads under /upload/resumable/:directory
+    dir: "./data/uploads"  # Staging area for incomplete uploads
+    expiration: "24h"  # Abandoned uploads are removed by a scheduler task
+  trash:  # deleted videos (with thumbnails and renditions) are moved to <directory>/.trash and can be restored
+    enabled: true
+    grace_period: "168h"  # trashed videos are deleted permanently after this
+    purge_schedule: "@hourly"  # used unless a job uses the purge_trash action
+  sprites:  # seek-preview sprite sheets with WebVTT tracks: /api/thumbnail/:videoid/sprite.jpg and sprite.vtt
+    enabled: true
+    frames: 100  # evenly spaced frames per video
+    columns: 10  # frames per sprite row
+    width: 160  # frame size in pixels; frames are scaled to fit and padded
+    height: 90
+  thumbnails:  # variants requested with /api/thumbnail/:videoid?w=320&h=180&fit=fill&format=webp&q=80&t=30
+    max_width: 1920  # largest width and height a client may request
+    max_height: 1080
+    quality: 85  # JPEG quality when q is not given
+    cache_max_age: "24h"  # Cache-Control max-age of thumbnail responses (private: access depends on the caller)
+    dir: "./thumbnails"  # Cache for thumbnails, sprite sheets and variants
+    max_cache_size: 1073741824  # 1GB; least recently used files are evicted above this, 0 for no limit
+    workers: 2  # Concurrent ffmpeg frame extractions; requests for the same thumbnail share one generation
+    timeout: "60s"  # Per thumbnail, including the wait for a free worker (sprite sheets allow 10m)
+  metadata:
+    cache_enabled: true  # Reuse probe results across scans and restarts while path, size, mtime and inode match
+    cache_path: "./data/metadata.db"
+    workers: 2  # Concurrent probes (ffprobe processes)
+    timeout: "30s"  # Per ffprobe run; the pure-Go container prober is used when ffprobe fails
+    warmup: true  # Probe uncached videos in the background after startup
+  transcoding:
+    enabled: true  # Run "transcode" scheduler tasks with ffmpeg
+    auto_on_upload: true  # Queue a transcode task after every successful upload
+    renditions:  # Stored in <video dir>/.renditions/<file name>/, picked with ?quality=<name>
+      - name: "1080p"
+        height: 1080
+        video_bitrate: "5000k"
+        audio_bitrate: "192k"
+      - name: "720p"
+        height: 720
+        video_bitrate: "2800k"
+        audio_bitrate: "128k"
+      - name: "480p"
+        height: 480
+        video_bitrate: "1400k"
+        audio_bitrate: "128k"
+      - name: "audio"
+        height: 0  # Audio only
+        audio_bitrate: "128k"
+
+scheduler:
+  queue:
+    path: "./data/tasks/tasks.db"  # Durable task queue; legacy *.json task files next to it are imported
+    max_attempts: 5  # Failed tasks are retried, then kept as dead letters
+    visibility_timeout: "10m"  # Processing tasks not renewed within this time (e.g. after a crash) are reclaimed
+    backoff_base: "30s"  # Delay before the first retry, doubled for every further attempt
+    backoff_max: "1h"
+    retention: "24h"  # Completed tasks are removed after this
+  tasks:  # Per task type overrides of concurrency and poll interval
+    video_deletion:
+      concurrency: 3
+      poll_interval: "30s"
+    transcode:
+      concurrency: 1
+      poll_interval: "10s"
+  jobs:  # Recurring jobs; schedule is a 5-field cron expression or a descriptor such as @daily or @every 6h
+    - name: "purge-old-movies"
+      enabled: false
+      schedule: "0 3 * * *"
+      timezone: "Asia/Shanghai"  # IANA name, defaults to the server's local time zone
+      action: "purge_videos"  # Queues deletion of videos older than older_than_days in directory
+      params:
+        directory: "movies"
+        older_than_days: "30"
+    - name: "nightly-thumbnails"
+      enabled: true
+      schedule: "30 2 * * *"
+      action: "regenerate_thumbnails"  # Generates thumbnails missing for any video; optional param: directory
+    - name: "nightly-sprites"
+      enabled: true
+      schedule: "0 3 * * *"
+      action: "generate_sprites"  # Queues sprite tasks for videos whose sprite sheet is missing or stale; optional param: directory
+    - name: "compact-tasks"
+      enabled: true
+      schedule: "@weekly"
+      action: "compact_tasks"  # Purges old completed tasks and rewrites the task queue file
+    - name: "rescan-catalog"
+      enabled: false
+      schedule: "0 4 * * *"
+      action: "rescan_catalog"  # Refreshes the video catalog; params: full: "true" rebuilds it
+  retention_schedule: "@hourly"  # Evaluates directory retention policies unless a job uses the apply_retention action
 
 logging:
   level: "info"  # debug, info, warn, error
@@ -226,22 +734,44 @@ security:
   cors:
     enabled: true
     allowed_origins: ["*"]
-    allowed_methods: ["GET", "POST", "OPTIONS"]
-    allowed_headers: ["Content-Type", "Range", "Authorization"]
+    allowed_methods: ["GET", "POST", "HEAD", "PATCH", "DELETE", "OPTIONS"]
+    allowed_headers: ["Content-Type", "Range", "If-Range", "If-None-Match", "If-Modified-Since", "Authorization", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"]
   
   rate_limit:
     enabled: true
     requests_per_minute: 60
     burst_size: 10
     cleanup_time: "5m"
+
+  limiter:
+    backend: "memory"  # memory (per process), redis (shared by all replicas)
+    redis:
+      addr: "localhost:6379"
+      password: ""
+      db: 0
+      key_prefix: "sss:"
+    lease_ttl: "10m"  # connection slots held by a crashed replica are reclaimed after this
   
   auth:
     enabled: false
-    type: "none"  # none, api_key, basic
-    api_key: ""
-    basic_auth:
+    type: "none"  # none, api_key, basic (RFC 7617, local users), jwt (local users, bearer tokens)
+    api_key: ""  # imported as admin key "default" when the API key store is empty
+    basic_auth:  # initial admin account, created when the user store is empty
       username: ""
       password: ""
+    users_db: "./data/users.db"  # local user store (basic and jwt modes, bcrypt-hashed passwords)
+    api_keys_db: "./data/api_keys.db"  # API key store (hashed keys, managed via /api/auth/keys)
+    jwt:
+      secret: ""  # HS256 signing secret, at least 32 bytes
+      issuer: "standalone-stream-server"
+      access_ttl: "15m"
+      refresh_ttl: "168h"
+
+  signed_urls:
+    enabled: false
+    keys: []  # first key signs, all keys verify (rotate by prepending a new key)
+    default_ttl: "1h"
+    max_ttl: "24h"
 `
 }
 
@@ -280,8 +810,55 @@ func Validate(config *models.Config) error {
 			if _, err := os.Stat(dir.Path); os.IsNotExist(err) {
 				return fmt.Errorf("directory does not exist: %s", dir.Path)
 			}
+			if err := validateDirectoryAccess(config, dir); err != nil {
+				return err
+			}
+			if err := validateRetention(dir); err != nil {
+				return err
+			}
 		}
 	}
 
+	if err := validateBandwidth(config.Video.StreamingSettings.Bandwidth); err != nil {
+		return err
+	}
+
+	if err := validateTrash(config.Video.Trash); err != nil {
+		return err
+	}
+
+	if err := validateSprites(config.Video.Sprites); err != nil {
+		return err
+	}
+
+	if err := validateThumbnails(config.Video.Thumbnails); err != nil {
+		return err
+	}
+
+	if err := validateMetadata(config.Video.Metadata); err != nil {
+		return err
+	}
+
+	if err := validatePackaging(config.Video.Packaging); err != nil {
+		return err
+	}
+
+	if err := validateLimiter(config.Security.Limiter); err != nil {
+		return err
+	}
+
+	if err := validateTaskQueue(config.Scheduler.Queue); err != nil {
+		return err
+	}
+	if err := validateTaskTypes(config.Scheduler.Tasks); err != nil {
+		return err
+	}
+	if err := validateCronJobs(config.Scheduler.Jobs); err != nil {
+		return err
+	}
+	if err := validateRetentionSchedule(config.Scheduler.RetentionSchedule); err != nil {
+		return err
+	}
+
 	return nil
 }
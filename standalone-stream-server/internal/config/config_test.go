@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"standalone-stream-server/internal/models"
 )
@@ -392,6 +393,126 @@ func TestValidate(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for invalid upload size")
 	}
+
+	// 测试目录访问控制需要启用认证
+	restrictedConfig := *validConfig
+	restrictedConfig.Video.Directories = []models.VideoDirectory{
+		{Name: "internal", Path: testVideosDir, Enabled: true, Access: models.DirectoryAccess{Mode: "restricted"}},
+	}
+	if err := Validate(&restrictedConfig); err == nil {
+		t.Error("Expected error for restricted directory without authentication")
+	}
+	restrictedConfig.Security.Auth = models.AuthConfig{Enabled: true, Type: "basic"}
+	if err := Validate(&restrictedConfig); err != nil {
+		t.Errorf("Restricted directory with authentication should be valid: %v", err)
+	}
+	restrictedConfig.Video.Directories[0].Access.Grants = []models.AccessGrant{{Users: []string{"alice"}, Rights: []string{"write"}}}
+	if err := Validate(&restrictedConfig); err == nil {
+		t.Error("Expected error for invalid access right")
+	}
+
+	// 测试带宽整形配置
+	bandwidthConfig := *validConfig
+	bandwidthConfig.Video.StreamingSettings.Bandwidth = models.BandwidthConfig{Enabled: true, PerClientLimit: 1 << 20, RealtimeFactor: 1.5}
+	if err := Validate(&bandwidthConfig); err != nil {
+		t.Errorf("Bandwidth shaping config should be valid: %v", err)
+	}
+	bandwidthConfig.Video.StreamingSettings.Bandwidth.RealtimeFactor = 0.5
+	if err := Validate(&bandwidthConfig); err == nil {
+		t.Error("Expected error for realtime_factor below 1")
+	}
+
+	queueConfig := *validConfig
+	queueConfig.Scheduler.Queue = models.TaskQueueConfig{MaxAttempts: 3, BackoffBase: time.Minute, BackoffMax: time.Second}
+	if err := Validate(&queueConfig); err == nil {
+		t.Error("Expected error for backoff_max below backoff_base")
+	}
+
+	jobsConfig := *validConfig
+	jobsConfig.Scheduler.Jobs = []models.CronJobConfig{{Name: "nightly", Schedule: "30 2 * * *", Timezone: "Asia/Shanghai", Action: "compact_tasks"}}
+	if err := Validate(&jobsConfig); err != nil {
+		t.Errorf("Cron job config should be valid: %v", err)
+	}
+	jobsConfig.Scheduler.Jobs = []models.CronJobConfig{{Name: "nightly", Schedule: "every night", Action: "compact_tasks"}}
+	if err := Validate(&jobsConfig); err == nil {
+		t.Error("Expected error for invalid cron schedule")
+	}
+
+	// 测试保留规则配置
+	retentionConfig := *validConfig
+	retentionConfig.Video.Directories = []models.VideoDirectory{
+		{Name: "movies", Path: testVideosDir, Enabled: true, Retention: models.RetentionPolicy{MaxAge: 90 * 24 * time.Hour, MaxTotalSize: 1 << 30, Eviction: "lru", Exclude: []string{"favorites/*"}}},
+	}
+	if err := Validate(&retentionConfig); err != nil {
+		t.Errorf("Retention policy should be valid: %v", err)
+	}
+	retentionConfig.Video.Directories[0].Retention.Eviction = "largest"
+	if err := Validate(&retentionConfig); err == nil {
+		t.Error("Expected error for invalid eviction order")
+	}
+	retentionConfig.Video.Directories[0].Retention = models.RetentionPolicy{Exclude: []string{"[unclosed"}}
+	if err := Validate(&retentionConfig); err == nil {
+		t.Error("Expected error for invalid exclude pattern")
+	}
+	retentionConfig.Video.Directories[0].Retention = models.RetentionPolicy{KeepLast: -1}
+	if err := Validate(&retentionConfig); err == nil {
+		t.Error("Expected error for negative keep_last")
+	}
+
+	trashConfig := *validConfig
+	trashConfig.Video.Trash = models.TrashConfig{Enabled: true, GracePeriod: 168 * time.Hour, PurgeSchedule: "@hourly"}
+	if err := Validate(&trashConfig); err != nil {
+		t.Errorf("Trash config should be valid: %v", err)
+	}
+	trashConfig.Video.Trash.PurgeSchedule = "hourly"
+	if err := Validate(&trashConfig); err == nil {
+		t.Error("Expected error for invalid trash purge schedule")
+	}
+
+	spriteConfig := *validConfig
+	spriteConfig.Video.Sprites = models.SpriteConfig{Enabled: true, Frames: 100, Columns: 10, Width: 160, Height: 90}
+	if err := Validate(&spriteConfig); err != nil {
+		t.Errorf("Sprite config should be valid: %v", err)
+	}
+	spriteConfig.Video.Sprites.Frames = 5000
+	if err := Validate(&spriteConfig); err == nil {
+		t.Error("Expected error for too many sprite frames")
+	}
+
+	thumbnailConfig := *validConfig
+	thumbnailConfig.Video.Thumbnails = models.ThumbnailConfig{MaxWidth: 1920, MaxHeight: 1080, Quality: 85, CacheMaxAge: 24 * time.Hour}
+	if err := Validate(&thumbnailConfig); err != nil {
+		t.Errorf("Thumbnail config should be valid: %v", err)
+	}
+	thumbnailConfig.Video.Thumbnails.Quality = 101
+	if err := Validate(&thumbnailConfig); err == nil {
+		t.Error("Expected error for invalid thumbnail quality")
+	}
+	thumbnailConfig.Video.Thumbnails.Quality = 85
+	thumbnailConfig.Video.Thumbnails.MaxCacheSize = -1
+	if err := Validate(&thumbnailConfig); err == nil {
+		t.Error("Expected error for negative thumbnail cache size")
+	}
+
+	metadataConfig := *validConfig
+	metadataConfig.Video.Metadata = models.MetadataConfig{CacheEnabled: true, CachePath: "./data/metadata.db", Workers: 2, Timeout: 30 * time.Second}
+	if err := Validate(&metadataConfig); err != nil {
+		t.Errorf("Metadata config should be valid: %v", err)
+	}
+	metadataConfig.Video.Metadata.CachePath = ""
+	if err := Validate(&metadataConfig); err == nil {
+		t.Error("Expected error for an enabled metadata cache without a path")
+	}
+	metadataConfig.Video.Metadata = models.MetadataConfig{Workers: -1}
+	if err := Validate(&metadataConfig); err == nil {
+		t.Error("Expected error for negative metadata workers")
+	}
+
+	packagingConfig := *validConfig
+	packagingConfig.Video.Packaging.Workers = -1
+	if err := Validate(&packagingConfig); err == nil {
+		t.Error("Expected error for negative packaging workers")
+	}
 }
 
 func TestGetExampleConfig(t *testing.T) {
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"path"
+	"strings"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// canAccessDirectory 检查请求方对目录的权限；通过签名验证的请求可以读取签名覆盖的资源
+func canAccessDirectory(c *fiber.Ctx, videoService *services.VideoService, directory, right string) bool {
+	if right == services.RightRead && middleware.IsSignedURL(c) {
+		return true
+	}
+	return videoService.CanAccess(middleware.PrincipalFromContext(c), directory, right)
+}
+
+// accessDenied 返回 401（匿名请求）或 403（已认证但没有权限）
+func accessDenied(c *fiber.Ctx, directory, right string) error {
+	if middleware.PrincipalFromContext(c) == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "Authentication required",
+			"directory": directory,
+		})
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":     "Access denied",
+		"directory": directory,
+		"right":     right,
+	})
+}
+
+// directoryForPath 返回可签名路径所属的视频目录
+func directoryForPath(config *models.Config, p string) string {
+	p = path.Clean("/" + p)
+
+	if filename, ok := strings.CutPrefix(p, "/api/thumbnail/file/"); ok {
+		return thumbnailDirectory(config, filename)
+	}
+
+	for _, prefix := range []string{"/stream/", "/hls/", "/dash/", "/api/thumbnail/"} {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+		// /stream/:videoid 和 /api/thumbnail/:videoid 使用 "directory:path" 形式的视频 ID
+		segment, _, _ := strings.Cut(rest, "/")
+		directory, _, _ := strings.Cut(segment, ":")
+		return directory
+	}
+	return ""
+}
+
+// thumbnailDirectory 从 "<目录>_<文件名>.jpg" 形式的缩略图文件名中找出所属目录（取最长匹配的目录名）
+func thumbnailDirectory(config *models.Config, filename string) string {
+	directory := ""
+	for _, dir := range config.Video.Directories {
+		if strings.HasPrefix(filename, dir.Name+"_") && len(dir.Name) > len(directory) {
+			directory = dir.Name
+		}
+	}
+	return directory
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/scheduler"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDirectoryForPath(t *testing.T) {
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{{Name: "movies"}, {Name: "movies_4k"}},
+		},
+	}
+
+	tests := map[string]string{
+		"/stream/movies/action/avatar":        "movies",
+		"/stream/movies:avatar":               "movies",
+		"/hls/movies_4k/avatar/index.m3u8":    "movies_4k",
+		"/dash/movies/avatar/manifest.mpd":    "movies",
+		"/api/thumbnail/movies:avatar":        "movies",
+		"/api/thumbnail/file/movies_4k_a.jpg": "movies_4k",
+		"/api/thumbnail/file/movies_a.jpg":    "movies",
+		"/api/videos":                         "",
+	}
+
+	for p, expected := range tests {
+		if got := directoryForPath(config, p); got != expected {
+			t.Errorf("directoryForPath(%q) = %q, expected %q", p, got, expected)
+		}
+	}
+}
+
+func TestListVideosInDirectory_ACL(t *testing.T) {
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{
+				{Name: "internal", Path: t.TempDir(), Enabled: true, Access: models.DirectoryAccess{
+					Mode:   services.AccessRestricted,
+					Grants: []models.AccessGrant{{Users: []string{"alice"}, Rights: []string{services.RightRead}}},
+				}},
+			},
+			SupportedFormats: []string{".mp4"},
+		},
+	}
+	config.Server.MaxConns = 10
+	config.Security.Auth.Enabled = true
+	config.Security.Auth.Type = "jwt"
+	handler := NewVideoHandler(config, services.NewVideoService(config))
+
+	var principal *services.Principal
+	app := fiber.New()
+	app.Get("/api/videos/:directory", func(c *fiber.Ctx) error {
+		if principal != nil {
+			c.Locals("principal", principal)
+		}
+		return c.Next()
+	}, handler.ListVideosInDirectory)
+
+	tests := []struct {
+		principal *services.Principal
+		expected  int
+	}{
+		{nil, fiber.StatusUnauthorized},
+		{&services.Principal{Username: "bob", Role: services.RoleViewer}, fiber.StatusForbidden},
+		{&services.Principal{Username: "alice", Role: services.RoleViewer}, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		principal = tt.principal
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/videos/internal", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.expected {
+			t.Errorf("Principal %v: expected status %d, got %d", tt.principal, tt.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestAddVideoDeletionTask_ACL(t *testing.T) {
+	tempDir := t.TempDir()
+	videoDir := filepath.Join(tempDir, "movies")
+	if err := os.MkdirAll(videoDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(videoDir, "avatar.mp4"), []byte("video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{
+				{Name: "movies", Path: videoDir, Enabled: true, Access: models.DirectoryAccess{
+					Grants: []models.AccessGrant{{Users: []string{"alice"}, Rights: []string{services.RightDelete}}},
+				}},
+			},
+			SupportedFormats: []string{".mp4"},
+		},
+	}
+	config.Security.Auth.Enabled = true
+	config.Security.Auth.Type = "jwt"
+	config.Scheduler.Queue.Path = filepath.Join(tempDir, "tasks.db")
+
+	schedulerService, err := scheduler.NewSchedulerService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer schedulerService.Close()
+	handler := NewSchedulerHandler(config, schedulerService, services.NewVideoService(config))
+
+	var principal *services.Principal
+	app := fiber.New()
+	app.Post("/api/scheduler/video-delete/:videoid", func(c *fiber.Ctx) error {
+		if principal != nil {
+			c.Locals("principal", principal)
+		}
+		return c.Next()
+	}, middleware.RequireRole(config, services.RoleViewer), handler.AddVideoDeletionTask)
+
+	tests := []struct {
+		principal *services.Principal
+		expected  int
+	}{
+		{nil, fiber.StatusUnauthorized},
+		{&services.Principal{Username: "bob", Role: services.RoleUploader}, fiber.StatusForbidden},
+		{&services.Principal{Username: "alice", Role: services.RoleViewer}, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		principal = tt.principal
+		resp, err := app.Test(httptest.NewRequest("POST", "/api/scheduler/video-delete/movies:avatar", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.expected {
+			t.Errorf("Principal %v: expected status %d, got %d", tt.principal, tt.expected, resp.StatusCode)
+		}
+	}
+}
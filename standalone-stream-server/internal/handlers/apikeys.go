@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyHandler 处理 API 密钥的管理请求
+type APIKeyHandler struct {
+	config *models.Config
+	store  *services.APIKeyStore
+}
+
+// NewAPIKeyHandler 创建新的 API 密钥处理器
+func NewAPIKeyHandler(config *models.Config, store *services.APIKeyStore) *APIKeyHandler {
+	return &APIKeyHandler{
+		config: config,
+		store:  store,
+	}
+}
+
+// apiKeyRequest 是创建或修改密钥的请求体；指针字段省略时不修改
+type apiKeyRequest struct {
+	Name        string    `json:"name"`
+	Scopes      *[]string `json:"scopes"`
+	Directories *[]string `json:"directories"`
+	ExpiresIn   string    `json:"expires_in"` // 有效期，如 "720h"
+	ExpiresAt   *string   `json:"expires_at"` // RFC3339 时间；空字符串表示永不过期
+}
+
+// expiry 解析请求中的过期时间；nil 表示不修改
+func (r *apiKeyRequest) expiry() (*time.Time, error) {
+	if r.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(r.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid expires_in: %s", r.ExpiresIn)
+		}
+		t := time.Now().Add(ttl)
+		return &t, nil
+	}
+	if r.ExpiresAt != nil {
+		if *r.ExpiresAt == "" {
+			return &time.Time{}, nil
+		}
+		t, err := time.Parse(time.RFC3339, *r.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires_at: %s", *r.ExpiresAt)
+		}
+		return &t, nil
+	}
+	return nil, nil
+}
+
+// apiKeyResponse 返回不含哈希的密钥信息
+func apiKeyResponse(key *services.APIKey) fiber.Map {
+	return fiber.Map{
+		"id":            key.ID,
+		"name":          key.Name,
+		"scopes":        key.Scopes,
+		"directories":   key.Directories,
+		"role":          key.Role(),
+		"status":        key.Status(),
+		"created_by":    key.CreatedBy,
+		"created_at":    formatUnix(key.CreatedAt),
+		"expires_at":    formatUnix(key.ExpiresAt),
+		"revoked_at":    formatUnix(key.RevokedAt),
+		"last_used_at":  formatUnix(key.LastUsedAt),
+		"request_count": key.RequestCount,
+	}
+}
+
+// formatUnix 把 Unix 时间格式化为 RFC3339，0 返回 nil
+func formatUnix(ts int64) interface{} {
+	if ts == 0 {
+		return nil
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+// validateDirectories 检查目录是否存在于配置中
+func (kh *APIKeyHandler) validateDirectories(directories []string) error {
+	for _, name := range directories {
+		found := false
+		for _, dir := range kh.config.Video.Directories {
+			if dir.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown directory: %s", name)
+		}
+	}
+	return nil
+}
+
+// ListKeys 列出全部 API 密钥
+func (kh *APIKeyHandler) ListKeys(c *fiber.Ctx) error {
+	keys, err := kh.store.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list API keys",
+			"details": err.Error(),
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(keys))
+	for i := range keys {
+		result = append(result, apiKeyResponse(&keys[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"keys":  result,
+		"count": len(result),
+	})
+}
+
+// GetKey 返回单个 API 密钥
+func (kh *APIKeyHandler) GetKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	key, err := kh.store.Get(id)
+	if err != nil {
+		return kh.keyError(c, id, "Failed to get API key", err)
+	}
+	return c.JSON(apiKeyResponse(key))
+}
+
+// CreateKey 创建新的 API 密钥；密钥明文只在响应中返回一次
+func (kh *APIKeyHandler) CreateKey(c *fiber.Ctx) error {
+	var req apiKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	spec := services.APIKeySpec{Name: req.Name, Scopes: []string{services.RightRead}}
+	if req.Scopes != nil {
+		spec.Scopes = *req.Scopes
+	}
+	if req.Directories != nil {
+		spec.Directories = *req.Directories
+	}
+	expiresAt, err := req.expiry()
+	if err == nil && expiresAt != nil {
+		spec.ExpiresAt = *expiresAt
+	}
+	if err == nil {
+		err = kh.validateDirectories(spec.Directories)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+	}
+
+	createdBy := ""
+	if principal := middleware.PrincipalFromContext(c); principal != nil {
+		createdBy = principal.Username
+	}
+
+	key, secret, err := kh.store.Create(spec, createdBy)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = secret
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// UpdateKey 修改 API 密钥的作用域、目录或过期时间
+func (kh *APIKeyHandler) UpdateKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req apiKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	expiresAt, err := req.expiry()
+	if err == nil && req.Directories != nil {
+		err = kh.validateDirectories(*req.Directories)
+	}
+	if err == nil && req.Scopes == nil && req.Directories == nil && expiresAt == nil {
+		err = errors.New("at least one of scopes, directories, expires_in or expires_at is required")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to update API key",
+			"details": err.Error(),
+		})
+	}
+
+	key, err := kh.store.Update(id, req.Scopes, req.Directories, expiresAt)
+	if err != nil {
+		return kh.keyError(c, id, "Failed to update API key", err)
+	}
+	return c.JSON(apiKeyResponse(key))
+}
+
+// RevokeKey 撤销 API 密钥，撤销后立即失效
+func (kh *APIKeyHandler) RevokeKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	key, err := kh.store.Revoke(id)
+	if err != nil {
+		return kh.keyError(c, id, "Failed to revoke API key", err)
+	}
+	return c.JSON(apiKeyResponse(key))
+}
+
+// keyError 把密钥库错误转换为响应
+func (kh *APIKeyHandler) keyError(c *fiber.Ctx, id, message string, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API key not found",
+			"id":    id,
+		})
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "API key has been revoked",
+			"id":    id,
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthHandler 处理登录、令牌刷新和用户管理请求
+type AuthHandler struct {
+	config      *models.Config
+	authService *services.AuthService
+}
+
+// NewAuthHandler 创建新的认证处理器
+func NewAuthHandler(config *models.Config, authService *services.AuthService) *AuthHandler {
+	return &AuthHandler{
+		config:      config,
+		authService: authService,
+	}
+}
+
+// userRequest 是创建或修改用户的请求体
+type userRequest struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Role     string    `json:"role"`
+	Groups   *[]string `json:"groups"` // 省略时不修改
+}
+
+// userResponse 返回不含密码哈希的用户信息
+func userResponse(user *services.User) fiber.Map {
+	return fiber.Map{
+		"username":   user.Username,
+		"role":       user.Role,
+		"groups":     user.Groups,
+		"created_at": time.Unix(user.CreatedAt, 0).UTC().Format(time.RFC3339),
+		"updated_at": time.Unix(user.UpdatedAt, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+// tokensDisabled 在非 jwt 认证模式下拒绝签发令牌
+func (ah *AuthHandler) tokensDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": "Token authentication is disabled",
+		"type":  ah.config.Security.Auth.Type,
+	})
+}
+
+// Login 校验用户名和密码并签发访问令牌和刷新令牌
+func (ah *AuthHandler) Login(c *fiber.Ctx) error {
+	if ah.config.Security.Auth.Type != "jwt" {
+		return ah.tokensDisabled(c)
+	}
+
+	var req userRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	tokens, err := ah.authService.Login(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid username or password",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to issue tokens",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+// Refresh 用刷新令牌换取新的令牌对
+func (ah *AuthHandler) Refresh(c *fiber.Ctx) error {
+	if ah.config.Security.Auth.Type != "jwt" {
+		return ah.tokensDisabled(c)
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "refresh_token is required",
+		})
+	}
+
+	tokens, err := ah.authService.Refresh(req.RefreshToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Invalid refresh token",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+// Me 返回当前请求方
+func (ah *AuthHandler) Me(c *fiber.Ctx) error {
+	principal := middleware.PrincipalFromContext(c)
+	if principal == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+	return c.JSON(principal)
+}
+
+// Logout 撤销当前用户已签发的全部令牌
+func (ah *AuthHandler) Logout(c *fiber.Ctx) error {
+	principal := middleware.PrincipalFromContext(c)
+	if principal == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+	if principal.KeyID != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "API keys cannot log out; revoke the key instead",
+		})
+	}
+
+	if err := ah.authService.Logout(principal.Username); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to revoke tokens",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "All tokens revoked",
+		"username": principal.Username,
+	})
+}
+
+// ListUsers 列出全部用户
+func (ah *AuthHandler) ListUsers(c *fiber.Ctx) error {
+	users, err := ah.authService.Users().List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list users",
+			"details": err.Error(),
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(users))
+	for i := range users {
+		result = append(result, userResponse(&users[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"users": result,
+		"count": len(result),
+	})
+}
+
+// CreateUser 创建新用户
+func (ah *AuthHandler) CreateUser(c *fiber.Ctx) error {
+	var req userRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+	if req.Role == "" {
+		req.Role = services.RoleViewer
+	}
+
+	user, err := ah.authService.Users().Create(req.Username, req.Password, req.Role)
+	if err == nil && req.Groups != nil {
+		user, err = ah.authService.Users().SetGroups(user.Username, *req.Groups)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrUserExists) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":    "User already exists",
+				"username": req.Username,
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to create user",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(userResponse(user))
+}
+
+// UpdateUser 修改用户的密码、角色或组，已签发的令牌随之失效
+func (ah *AuthHandler) UpdateUser(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	var req userRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+	if req.Password == "" && req.Role == "" && req.Groups == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one of password, role or groups is required",
+		})
+	}
+
+	var user *services.User
+	var err error
+	if req.Password != "" || req.Role != "" {
+		user, err = ah.authService.Users().Update(username, req.Password, req.Role)
+	}
+	if err == nil && req.Groups != nil {
+		user, err = ah.authService.Users().SetGroups(username, *req.Groups)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":    "User not found",
+				"username": username,
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to update user",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(userResponse(user))
+}
+
+// DeleteUser 删除用户
+func (ah *AuthHandler) DeleteUser(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	if err := ah.authService.Users().Delete(username); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":    "User not found",
+				"username": username,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to delete user",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "User deleted",
+		"username": username,
+	})
+}
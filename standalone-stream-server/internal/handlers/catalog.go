@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CatalogHandler 处理视频索引管理请求
+type CatalogHandler struct {
+	config  *models.Config
+	indexer *services.CatalogIndexer
+	watcher *services.CatalogWatcher
+}
+
+// NewCatalogHandler 创建新的索引管理处理器；indexer 为 nil 表示索引已禁用，watcher 可为 nil
+func NewCatalogHandler(config *models.Config, indexer *services.CatalogIndexer, watcher *services.CatalogWatcher) *CatalogHandler {
+	return &CatalogHandler{
+		config:  config,
+		indexer: indexer,
+		watcher: watcher,
+	}
+}
+
+// Status 返回索引状态
+func (ch *CatalogHandler) Status(c *fiber.Ctx) error {
+	if ch.indexer == nil {
+		return c.JSON(fiber.Map{
+			"enabled": false,
+		})
+	}
+
+	response := fiber.Map{
+		"enabled": true,
+		"catalog": ch.indexer.Status(),
+	}
+	if ch.watcher != nil {
+		response["watcher"] = ch.watcher.GetStats()
+	}
+
+	return c.JSON(response)
+}
+
+// Rebuild 在后台触发完整重建
+func (ch *CatalogHandler) Rebuild(c *fiber.Ctx) error {
+	return ch.trigger(c, true)
+}
+
+// Refresh 在后台触发增量刷新
+func (ch *CatalogHandler) Refresh(c *fiber.Ctx) error {
+	return ch.trigger(c, false)
+}
+
+func (ch *CatalogHandler) trigger(c *fiber.Ctx, full bool) error {
+	if ch.indexer == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Catalog is disabled",
+		})
+	}
+
+	mode := "refresh"
+	if full {
+		mode = "rebuild"
+	}
+
+	if err := ch.indexer.Trigger(full); err != nil {
+		if errors.Is(err, services.ErrIndexingInProgress) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Catalog indexing already in progress",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to start catalog indexing",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Catalog " + mode + " started",
+		"mode":    mode,
+	})
+}
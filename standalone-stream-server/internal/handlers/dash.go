@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"path"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DASHHandler 处理 MPEG-DASH 清单和片段请求
+type DASHHandler struct {
+	config       *models.Config
+	videoService *services.VideoService
+	dashService  *services.DASHService
+}
+
+// NewDASHHandler 创建新的 DASH 处理器
+func NewDASHHandler(config *models.Config, videoService *services.VideoService, dashService *services.DASHService) *DASHHandler {
+	return &DASHHandler{
+		config:       config,
+		videoService: videoService,
+		dashService:  dashService,
+	}
+}
+
+// Serve 处理 /dash/:directory/* 请求，通配符部分为 "<视频路径>/<文件名>"
+func (dh *DASHHandler) Serve(c *fiber.Ctx) error {
+	directory := c.Params("directory")
+	rest := c.Params("*")
+
+	videoPath := path.Dir(rest)
+	file := path.Base(rest)
+	if directory == "" || videoPath == "." || videoPath == "/" || file == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Expected /dash/:directory/<video path>/<manifest or segment>",
+		})
+	}
+
+	if !canAccessDirectory(c, dh.videoService, directory, services.RightRead) {
+		return accessDenied(c, directory, services.RightRead)
+	}
+
+	videoID := directory + ":" + videoPath
+	video, err := dh.videoService.FindVideoByID(videoID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":    "Video not found",
+			"video_id": videoID,
+			"details":  err.Error(),
+		})
+	}
+
+	switch file {
+	case services.DASHManifest:
+		manifest, err := dh.dashService.Manifest(video, middleware.SignatureQuery(c))
+		if err != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":   "Failed to generate manifest",
+				"details": err.Error(),
+			})
+		}
+
+		c.Set("Content-Type", "application/dash+xml")
+		c.Set("Cache-Control", dh.config.Video.StreamingSettings.CacheControl)
+		return c.SendString(manifest)
+
+	case services.DASHInitSegment:
+		segmentPath, err := dh.dashService.InitSegment(video)
+		return sendPackagedSegment(c, dh.config, video, segmentPath, "video/mp4", err)
+	}
+
+	index, ok := dh.dashService.ParseSegmentName(file)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown DASH resource",
+			"file":  file,
+		})
+	}
+
+	segmentPath, err := dh.dashService.Segment(video, index)
+	return sendPackagedSegment(c, dh.config, video, segmentPath, "video/iso.segment", err)
+}
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"standalone-stream-server/internal/models"
 	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
 )
 
 // TestNewVideoHandler_TokensPerSecondConfig tests the configurable tokens per second feature
@@ -101,4 +108,88 @@ func TestNewVideoHandler_ConfigValidation(t *testing.T) {
 	if handler.streamingFlowController == nil {
 		t.Error("Handler should have a streaming flow controller")
 	}
-}
\ No newline at end of file
+}
+// TestStreamVideo_HoldsSlotUntilBodyClosed tests that a stream keeps its connection slot while its body is being sent
+func TestStreamVideo_HoldsSlotUntilBodyClosed(t *testing.T) {
+	videoDir := t.TempDir()
+	file, err := os.Create(filepath.Join(videoDir, "movie.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Larger than the socket buffers, so the first response cannot be written out while its client is not reading
+	if err := file.Truncate(64 << 20); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	config := &models.Config{
+		Server: models.ServerConfig{
+			MaxConns:        1,
+			TokensPerSecond: 10,
+			Admission:       models.AdmissionConfig{Enabled: true, MaxQueue: 5, MaxWait: 5 * time.Second},
+		},
+		Video: models.VideoConfig{
+			Directories:      []models.VideoDirectory{{Name: "test", Path: videoDir, Enabled: true}},
+			SupportedFormats: []string{".mp4"},
+		},
+	}
+	handler := NewVideoHandler(config, services.NewVideoService(config))
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/stream/:videoid", handler.StreamVideo)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+	url := "http://" + ln.Addr().String() + "/stream/test:movie"
+
+	first, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Body.Close()
+	if first.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected the first stream to be admitted, got %d", first.StatusCode)
+	}
+
+	second := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Error(err)
+			close(second)
+			return
+		}
+		second <- resp
+	}()
+
+	select {
+	case resp := <-second:
+		if resp != nil {
+			resp.Body.Close()
+			t.Fatalf("Expected the second stream to be queued while the first is open, got %d", resp.StatusCode)
+		}
+		return
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Closing the first stream frees its slot for the queued request
+	first.Body.Close()
+	select {
+	case resp := <-second:
+		if resp == nil {
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("Expected the queued stream to be admitted, got %d", resp.StatusCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Queued stream was not admitted after the first stream closed")
+	}
+	if queued := handler.streamingFlowController.GetStats().Queued; queued != 1 {
+		t.Errorf("Expected one queued admission, got %d", queued)
+	}
+}
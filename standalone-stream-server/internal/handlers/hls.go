@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+	"standalone-stream-server/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// HLSHandler 处理 HLS 播放列表和片段请求
+type HLSHandler struct {
+	config       *models.Config
+	videoService *services.VideoService
+	hlsService   *services.HLSService
+}
+
+// NewHLSHandler 创建新的 HLS 处理器
+func NewHLSHandler(config *models.Config, videoService *services.VideoService, hlsService *services.HLSService) *HLSHandler {
+	return &HLSHandler{
+		config:       config,
+		videoService: videoService,
+		hlsService:   hlsService,
+	}
+}
+
+// Serve 处理 /hls/:directory/* 请求，通配符部分为 "<视频路径>/<文件名>"
+func (hh *HLSHandler) Serve(c *fiber.Ctx) error {
+	directory := c.Params("directory")
+	rest := c.Params("*")
+
+	videoPath := path.Dir(rest)
+	file := path.Base(rest)
+	if directory == "" || videoPath == "." || videoPath == "/" || file == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Expected /hls/:directory/<video path>/<playlist or segment>",
+		})
+	}
+
+	if !canAccessDirectory(c, hh.videoService, directory, services.RightRead) {
+		return accessDenied(c, directory, services.RightRead)
+	}
+
+	videoID := directory + ":" + videoPath
+	video, err := hh.videoService.FindVideoByID(videoID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":    "Video not found",
+			"video_id": videoID,
+			"details":  err.Error(),
+		})
+	}
+
+	switch {
+	case file == services.HLSMasterPlaylist:
+		playlist, err := hh.hlsService.MasterPlaylist(video, middleware.SignatureQuery(c))
+		return hh.sendPlaylist(c, playlist, err)
+
+	case file == services.HLSMediaPlaylist:
+		playlist, err := hh.hlsService.MediaPlaylist(video, middleware.SignatureQuery(c))
+		return hh.sendPlaylist(c, playlist, err)
+
+	case file == services.HLSInitSegment:
+		segmentPath, err := hh.hlsService.InitSegment(video)
+		return sendPackagedSegment(c, hh.config, video, segmentPath, "video/mp4", err)
+	}
+
+	index, ok := hh.hlsService.ParseSegmentName(file)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown HLS resource",
+			"file":  file,
+		})
+	}
+
+	contentType := "video/mp2t"
+	if strings.HasSuffix(file, ".m4s") {
+		contentType = "video/iso.segment"
+	}
+
+	segmentPath, err := hh.hlsService.Segment(video, index)
+	return sendPackagedSegment(c, hh.config, video, segmentPath, contentType, err)
+}
+
+// sendPlaylist 发送 m3u8 播放列表
+func (hh *HLSHandler) sendPlaylist(c *fiber.Ctx, playlist string, err error) error {
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "Failed to generate playlist",
+			"details": err.Error(),
+		})
+	}
+
+	c.Set("Content-Type", "application/vnd.apple.mpegurl")
+	c.Set("Cache-Control", hh.config.Video.StreamingSettings.CacheControl)
+	return c.SendString(playlist)
+}
+
+// sendPackagedSegment 发送已生成（或已缓存）的 HLS/DASH 片段，支持条件请求和范围请求
+func sendPackagedSegment(c *fiber.Ctx, config *models.Config, video *services.VideoInfo, segmentPath, contentType string, err error) error {
+	if errors.Is(err, services.ErrSegmentOutOfRange) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Segment not found",
+			"details": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrSegmenterBusy) {
+		retryAfter := max(1, int(math.Ceil(config.Video.Packaging.QueueTimeout.Seconds())))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":       "Segment encoders are busy",
+			"retry_after": retryAfter,
+		})
+	}
+	if err != nil {
+		utils.LogError("packaged_segment", err,
+			zap.String("video_id", video.ID),
+			zap.String("path", c.Path()),
+		)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "Failed to generate segment",
+			"details": err.Error(),
+		})
+	}
+
+	c.Set("Cache-Control", config.Video.StreamingSettings.CacheControl)
+	middleware.SetStreamBitrate(c, video.EstimatedBitrate())
+	return serveFile(c, segmentPath, contentType, "", true)
+}
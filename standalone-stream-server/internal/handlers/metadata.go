@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetadataHandler 处理元数据缓存的状态查询和重新探测请求
+type MetadataHandler struct {
+	config *models.Config
+	warmer *services.MetadataWarmer
+}
+
+// NewMetadataHandler 创建新的元数据处理器
+func NewMetadataHandler(config *models.Config, warmer *services.MetadataWarmer) *MetadataHandler {
+	return &MetadataHandler{
+		config: config,
+		warmer: warmer,
+	}
+}
+
+// Status 返回元数据缓存、探测池和各目录最近一次探测的状态
+func (mh *MetadataHandler) Status(c *fiber.Ctx) error {
+	status := mh.warmer.Status()
+	status["cache_enabled"] = mh.config.Video.Metadata.CacheEnabled
+	return c.JSON(status)
+}
+
+// Reprobe 在后台忽略缓存重新探测目录中的全部视频
+func (mh *MetadataHandler) Reprobe(c *fiber.Ctx) error {
+	directory := c.Params("directory")
+
+	if err := mh.warmer.Reprobe(directory); err != nil {
+		switch {
+		case errors.Is(err, services.ErrDirectoryUnavailable):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "Directory not found",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrMetadataProbeInProgress):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Metadata probe already in progress for this directory",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to start metadata probe",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":   "Metadata reprobe started",
+		"directory": directory,
+	})
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"standalone-stream-server/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 范围解析错误
+var (
+	errInvalidRange = errors.New("invalid range")
+	errNoOverlap    = errors.New("requested range not satisfiable")
+)
+
+// maxRanges 是单个请求允许的最大范围数，超过时按普通请求返回完整内容
+const maxRanges = 32
+
+// byteRange 是文件中的一个字节区间
+type byteRange struct {
+	start, length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange 按 RFC 7233 解析 Range 头，支持 "a-b"、"a-"、"-n" 以及逗号分隔的多个范围。
+// 语法错误返回 errInvalidRange（调用方应忽略该头），没有任何范围与文件重叠时返回 errNoOverlap
+func parseRange(header string, size int64) ([]byteRange, error) {
+	unit, spec, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, errInvalidRange
+	}
+
+	var ranges []byteRange
+	noOverlap := false
+	for _, part := range strings.Split(spec, ",") {
+		part = textproto.TrimString(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, errInvalidRange
+		}
+		first, last = textproto.TrimString(first), textproto.TrimString(last)
+
+		var r byteRange
+		if first == "" {
+			// 后缀范围：最后 n 个字节
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errInvalidRange
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r = byteRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errInvalidRange
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, errInvalidRange
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			r = byteRange{start: start, length: end - start + 1}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, errNoOverlap
+		}
+		return nil, errInvalidRange
+	}
+	return ranges, nil
+}
+
+// fileETag 返回文件的强 ETag：已知内容哈希时使用哈希，否则由大小和修改时间生成
+func fileETag(hash string, stat os.FileInfo) string {
+	if hash != "" {
+		return `"` + hash + `"`
+	}
+	return fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+}
+
+// serveFile 发送文件内容，按 RFC 7232 处理 If-None-Match/If-Modified-Since，
+// 按 RFC 7233 处理单个或多个范围（multipart/byteranges）和 If-Range；
+// 调用方负责设置 Cache-Control 等其它响应头
+func serveFile(c *fiber.Ctx, path, contentType, hash string, rangeSupport bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to open video file",
+			"details": err.Error(),
+		})
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to get file information",
+			"details": err.Error(),
+		})
+	}
+
+	size := stat.Size()
+	modTime := stat.ModTime().UTC().Truncate(time.Second)
+	etag := fileETag(hash, stat)
+
+	c.Set("ETag", etag)
+	c.Set("Last-Modified", modTime.Format(http.TimeFormat))
+	if rangeSupport {
+		c.Set("Accept-Ranges", "bytes")
+	} else {
+		c.Set("Accept-Ranges", "none")
+	}
+
+	if notModified(c, etag, modTime) {
+		file.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set("Content-Type", contentType)
+
+	rangeHeader := c.Get("Range")
+	if !rangeSupport || rangeHeader == "" || !(c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead) || !ifRangeMatches(c, etag, modTime) {
+		return sendBody(c, fiber.StatusOK, file, size)
+	}
+
+	ranges, err := parseRange(rangeHeader, size)
+	switch {
+	case errors.Is(err, errNoOverlap):
+		file.Close()
+		c.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
+			"error": "Requested range not satisfiable",
+			"size":  size,
+		})
+	case err != nil, len(ranges) > maxRanges, sumRanges(ranges) > size:
+		// 无效的 Range 头被忽略；范围过多或总长超过文件本身的请求按普通请求处理
+		return sendBody(c, fiber.StatusOK, file, size)
+	}
+
+	if len(ranges) == 1 {
+		r := ranges[0]
+		c.Set("Content-Range", r.contentRange(size))
+		return sendBody(c, fiber.StatusPartialContent, &sectionReadCloser{io.NewSectionReader(file, r.start, r.length), file}, r.length)
+	}
+
+	return sendMultipartRanges(c, file, contentType, size, ranges)
+}
+
+// notModified 判断条件请求是否应返回 304；If-None-Match 存在时忽略 If-Modified-Since
+func notModified(c *fiber.Ctx, etag string, modTime time.Time) bool {
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return false
+	}
+
+	if inm := c.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag, false)
+	}
+
+	if ims := c.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modTime.After(t)
+	}
+
+	return false
+}
+
+// ifRangeMatches 判断 If-Range 是否允许返回部分内容：ETag 必须强匹配，日期必须与 Last-Modified 完全一致
+func ifRangeMatches(c *fiber.Ctx, etag string, modTime time.Time) bool {
+	ifRange := textproto.TrimString(c.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagMatches(ifRange, etag, true)
+	}
+
+	t, err := http.ParseTime(ifRange)
+	return err == nil && t.Equal(modTime)
+}
+
+// etagListMatches 检查逗号分隔的 ETag 列表（或 "*"）是否包含当前 ETag
+func etagListMatches(list, etag string, strong bool) bool {
+	if textproto.TrimString(list) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		if etagMatches(textproto.TrimString(candidate), etag, strong) {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatches 比较两个 ETag；强比较时弱 ETag 永不匹配
+func etagMatches(candidate, etag string, strong bool) bool {
+	if strong && strings.HasPrefix(candidate, "W/") {
+		return false
+	}
+	return strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/")
+}
+
+func sumRanges(ranges []byteRange) int64 {
+	var total int64
+	for _, r := range ranges {
+		total += r.length
+	}
+	return total
+}
+
+// sendBody 以流的方式发送响应体，发送完成后由 fasthttp 关闭 body；
+// 路由启用了带宽整形时按限速发送
+func sendBody(c *fiber.Ctx, status int, body io.ReadCloser, length int64) error {
+	c.Status(status)
+	c.Context().SetBodyStream(middleware.ShapedBody(c, body), int(length))
+	return nil
+}
+
+// sendMultipartRanges 以 multipart/byteranges 发送多个范围，边写边读以避免把内容放入内存
+func sendMultipartRanges(c *fiber.Ctx, file *os.File, contentType string, size int64, ranges []byteRange) error {
+	boundary, err := randomBoundary()
+	if err != nil {
+		file.Close()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to prepare multipart response",
+			"details": err.Error(),
+		})
+	}
+
+	partHeader := func(r byteRange) textproto.MIMEHeader {
+		return textproto.MIMEHeader{
+			"Content-Type":  {contentType},
+			"Content-Range": {r.contentRange(size)},
+		}
+	}
+
+	// 先写入计数器计算 Content-Length
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	mw.SetBoundary(boundary)
+	for _, r := range ranges {
+		mw.CreatePart(partHeader(r))
+		counter += countingWriter(r.length)
+	}
+	mw.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer file.Close()
+
+		mw := multipart.NewWriter(pw)
+		mw.SetBoundary(boundary)
+		for _, r := range ranges {
+			part, err := mw.CreatePart(partHeader(r))
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(part, io.NewSectionReader(file, r.start, r.length)); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+
+	c.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	return sendBody(c, fiber.StatusPartialContent, pr, int64(counter))
+}
+
+func randomBoundary() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// countingWriter 只统计写入的字节数
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// sectionReadCloser 读取文件的一段，关闭时关闭底层文件
+type sectionReadCloser struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (s *sectionReadCloser) Close() error {
+	return s.file.Close()
+}
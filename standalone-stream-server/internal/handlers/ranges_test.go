@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = 1000
+
+	tests := []struct {
+		header   string
+		expected []byteRange
+		err      error
+	}{
+		{"bytes=0-499", []byteRange{{0, 500}}, nil},
+		{"bytes=500-", []byteRange{{500, 500}}, nil},
+		{"bytes=-200", []byteRange{{800, 200}}, nil},
+		{"bytes=-5000", []byteRange{{0, 1000}}, nil},
+		{"bytes=900-1999", []byteRange{{900, 100}}, nil},
+		{"bytes=0-0, -1", []byteRange{{0, 1}, {999, 1}}, nil},
+		{"bytes= 0-9 ,20-29", []byteRange{{0, 10}, {20, 10}}, nil},
+		{"bytes=1000-", nil, errNoOverlap},
+		{"bytes=-0", nil, errNoOverlap},
+		{"bytes=2000-, 0-9", []byteRange{{0, 10}}, nil},
+		{"bytes=5-1", nil, errInvalidRange},
+		{"bytes=abc", nil, errInvalidRange},
+		{"items=0-9", nil, errInvalidRange},
+		{"bytes=", nil, errInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			ranges, err := parseRange(tt.header, size)
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(ranges, tt.expected) {
+				t.Errorf("Expected ranges %v, got %v", tt.expected, ranges)
+			}
+		})
+	}
+}
+
+func newServeFileTestApp(t *testing.T) (*fiber.App, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, []byte("0123456789abcdefghij"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/file", func(c *fiber.Ctx) error {
+		return serveFile(c, path, "video/mp4", "cafebabe", true)
+	})
+	return app, path
+}
+
+func serveFileRequest(t *testing.T, app *fiber.App, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestServeFile_Ranges(t *testing.T) {
+	app, _ := newServeFileTestApp(t)
+
+	// 完整内容带强 ETag
+	resp, body := serveFileRequest(t, app, nil)
+	if resp.StatusCode != fiber.StatusOK || body != "0123456789abcdefghij" {
+		t.Fatalf("Expected full content, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("ETag") != `"cafebabe"` || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Unexpected headers: %v", resp.Header)
+	}
+
+	// 后缀范围
+	resp, body = serveFileRequest(t, app, map[string]string{"Range": "bytes=-5"})
+	if resp.StatusCode != fiber.StatusPartialContent || body != "fghij" || resp.Header.Get("Content-Range") != "bytes 15-19/20" {
+		t.Errorf("Unexpected suffix range response: %d %q %s", resp.StatusCode, body, resp.Header.Get("Content-Range"))
+	}
+
+	// 无法满足的范围
+	resp, _ = serveFileRequest(t, app, map[string]string{"Range": "bytes=50-"})
+	if resp.StatusCode != fiber.StatusRequestedRangeNotSatisfiable || resp.Header.Get("Content-Range") != "bytes */20" {
+		t.Errorf("Expected 416 with Content-Range, got %d %s", resp.StatusCode, resp.Header.Get("Content-Range"))
+	}
+
+	// 语法错误的 Range 被忽略
+	resp, body = serveFileRequest(t, app, map[string]string{"Range": "bytes=x-y"})
+	if resp.StatusCode != fiber.StatusOK || len(body) != 20 {
+		t.Errorf("Expected invalid range to be ignored, got %d", resp.StatusCode)
+	}
+
+	// 多个范围
+	resp, body = serveFileRequest(t, app, map[string]string{"Range": "bytes=0-1,10-12"})
+	if resp.StatusCode != fiber.StatusPartialContent {
+		t.Fatalf("Expected 206 for multiple ranges, got %d", resp.StatusCode)
+	}
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Expected multipart/byteranges, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(part)
+		parts = append(parts, part.Header.Get("Content-Range")+"="+string(data))
+	}
+	if strings.Join(parts, ";") != "bytes 0-1/20=01;bytes 10-12/20=abc" {
+		t.Errorf("Unexpected multipart parts: %v", parts)
+	}
+}
+
+func TestServeFile_Conditionals(t *testing.T) {
+	app, path := newServeFileTestApp(t)
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastModified := stat.ModTime().UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+	}{
+		{"if-none-match hit", map[string]string{"If-None-Match": `"other", "cafebabe"`}, fiber.StatusNotModified},
+		{"if-none-match weak hit", map[string]string{"If-None-Match": `W/"cafebabe"`}, fiber.StatusNotModified},
+		{"if-none-match star", map[string]string{"If-None-Match": "*"}, fiber.StatusNotModified},
+		{"if-none-match miss", map[string]string{"If-None-Match": `"other"`}, fiber.StatusOK},
+		{"if-modified-since hit", map[string]string{"If-Modified-Since": lastModified}, fiber.StatusNotModified},
+		{"if-modified-since old", map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, fiber.StatusOK},
+		{"if-none-match overrides if-modified-since", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, fiber.StatusOK},
+		{"if-range etag match", map[string]string{"Range": "bytes=0-1", "If-Range": `"cafebabe"`}, fiber.StatusPartialContent},
+		{"if-range weak etag", map[string]string{"Range": "bytes=0-1", "If-Range": `W/"cafebabe"`}, fiber.StatusOK},
+		{"if-range etag mismatch", map[string]string{"Range": "bytes=0-1", "If-Range": `"other"`}, fiber.StatusOK},
+		{"if-range date match", map[string]string{"Range": "bytes=0-1", "If-Range": lastModified}, fiber.StatusPartialContent},
+		{"if-range date mismatch", map[string]string{"Range": "bytes=0-1", "If-Range": "Mon, 02 Jan 2006 15:04:05 GMT"}, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serveFileRequest(t, app, tt.headers)
+			if resp.StatusCode != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+			if tt.expected == fiber.StatusNotModified && body != "" {
+				t.Errorf("304 response should not have a body, got %q", body)
+			}
+			if tt.expected == fiber.StatusOK && len(body) != 20 {
+				t.Errorf("Expected full body, got %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"standalone-stream-server/internal/services"
+	"standalone-stream-server/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// tus 协议常量
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+)
+
+// SetResumableStore 设置可续传上传存储，启用 /upload/resumable 端点
+func (uh *UploadHandler) SetResumableStore(store *services.ResumableUploadStore) {
+	uh.resumable = store
+}
+
+// ResumableOptions 返回服务器支持的 tus 版本和扩展
+func (uh *UploadHandler) ResumableOptions(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(uh.config.Video.MaxUploadSize, 10))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateResumableUpload 创建新的可续传上传（tus creation 扩展）
+func (uh *UploadHandler) CreateResumableUpload(c *fiber.Ctx) error {
+	if ok, err := uh.checkTusRequest(c); !ok {
+		return err
+	}
+
+	directory := c.Params("directory")
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Valid Upload-Length header is required",
+		})
+	}
+	if length > uh.config.Video.MaxUploadSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":     "File size exceeds limit",
+			"max_size":  uh.config.Video.MaxUploadSize,
+			"file_size": length,
+		})
+	}
+
+	metadata, err := parseUploadMetadata(c.Get("Upload-Metadata"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid Upload-Metadata header",
+			"details": err.Error(),
+		})
+	}
+
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if filename == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Upload-Metadata must include filename",
+		})
+	}
+
+	upload, err := uh.resumable.Create(directory, filename, metadata["video_id"], length, metadata)
+	if errors.Is(err, services.ErrUploadExists) || errors.Is(err, services.ErrDuplicateContent) {
+		return uh.uploadErrorResponse(c, err)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+	if upload.Completed {
+		uh.queueTranscode(upload.Directory, upload.VideoID)
+	}
+
+	c.Set("Location", c.BaseURL()+"/upload/resumable/"+directory+"/"+upload.ID)
+	c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// ResumableUploadOffset 返回上传的当前偏移（HEAD 请求）
+func (uh *UploadHandler) ResumableUploadOffset(c *fiber.Ctx) error {
+	if ok, err := uh.checkTusRequest(c); !ok {
+		return err
+	}
+
+	upload, err := uh.findResumableUpload(c)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Set("Cache-Control", "no-store")
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// AppendResumableUpload 在当前偏移处追加数据块（PATCH 请求），完成后移动到视频目录
+func (uh *UploadHandler) AppendResumableUpload(c *fiber.Ctx) error {
+	if ok, err := uh.checkTusRequest(c); !ok {
+		return err
+	}
+
+	if c.Get("Content-Type") != "application/offset+octet-stream" {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": "Content-Type must be application/offset+octet-stream",
+		})
+	}
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Valid Upload-Offset header is required",
+		})
+	}
+
+	upload, err := uh.findResumableUpload(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload not found",
+		})
+	}
+
+	// 启用 StreamRequestBody 时直接从连接读取，避免把整个数据块放入内存
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	upload, err = uh.resumable.Append(upload.ID, offset, body)
+	if upload != nil {
+		c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
+	switch {
+	case errors.Is(err, services.ErrUploadOffsetMismatch):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":           "Upload-Offset does not match current offset",
+			"expected_offset": upload.Offset,
+		})
+	case errors.Is(err, services.ErrUploadLocked):
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrUploadExceedsLength):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrUploadExists), errors.Is(err, services.ErrDuplicateContent):
+		return uh.uploadErrorResponse(c, err)
+	case err != nil:
+		utils.LogError("resumable_upload", err, zap.String("upload_id", c.Params("id")))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to store upload data",
+			"details": err.Error(),
+		})
+	}
+
+	if upload.Completed {
+		uh.queueTranscode(upload.Directory, upload.VideoID)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// TerminateResumableUpload 取消上传并删除已接收的数据（tus termination 扩展）
+func (uh *UploadHandler) TerminateResumableUpload(c *fiber.Ctx) error {
+	if ok, err := uh.checkTusRequest(c); !ok {
+		return err
+	}
+
+	upload, err := uh.findResumableUpload(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload not found",
+		})
+	}
+
+	if err := uh.resumable.Terminate(upload.ID); err != nil {
+		if errors.Is(err, services.ErrUploadLocked) {
+			return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to terminate upload",
+			"details": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// checkTusRequest 设置 Tus-Resumable 响应头，校验客户端协议版本和目录的上传权限；
+// 返回 false 时已写入错误响应
+func (uh *UploadHandler) checkTusRequest(c *fiber.Ctx) (bool, error) {
+	c.Set("Tus-Resumable", tusVersion)
+
+	if c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return false, c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": "Unsupported tus protocol version",
+		})
+	}
+
+	if uh.resumable == nil {
+		return false, c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Resumable uploads are disabled",
+		})
+	}
+
+	if directory := c.Params("directory"); !canAccessDirectory(c, uh.videoService, directory, services.RightUpload) {
+		return false, accessDenied(c, directory, services.RightUpload)
+	}
+
+	return true, nil
+}
+
+// findResumableUpload 查找请求路径中的上传，并确认其属于路径中的目录
+func (uh *UploadHandler) findResumableUpload(c *fiber.Ctx) (*services.ResumableUpload, error) {
+	upload, err := uh.resumable.Get(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	if upload.Directory != c.Params("directory") {
+		return nil, services.ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// parseUploadMetadata 解析 Upload-Metadata 头："key base64value,key2 base64value2"
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, errors.New("malformed metadata pair: " + pair)
+		}
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newResumableTestApp(t *testing.T) (*fiber.App, string) {
+	t.Helper()
+
+	videoDir := t.TempDir()
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories:      []models.VideoDirectory{{Name: "test", Path: videoDir, Enabled: true}},
+			MaxUploadSize:    1024,
+			SupportedFormats: []string{".mp4"},
+			ResumableUpload: models.ResumableUploadConfig{
+				Enabled:    true,
+				Dir:        t.TempDir(),
+				Expiration: time.Hour,
+			},
+		},
+	}
+
+	videoService := services.NewVideoService(config)
+	store, err := services.NewResumableUploadStore(config, videoService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewUploadHandler(config, videoService)
+	handler.SetResumableStore(store)
+
+	app := fiber.New()
+	app.Options("/upload/resumable/:directory", handler.ResumableOptions)
+	app.Post("/upload/resumable/:directory", handler.CreateResumableUpload)
+	app.Head("/upload/resumable/:directory/:id", handler.ResumableUploadOffset)
+	app.Patch("/upload/resumable/:directory/:id", handler.AppendResumableUpload)
+	app.Delete("/upload/resumable/:directory/:id", handler.TerminateResumableUpload)
+
+	return app, videoDir
+}
+
+func TestUploadHandler_ResumableUpload(t *testing.T) {
+	app, videoDir := newResumableTestApp(t)
+
+	// 创建上传
+	req := httptest.NewRequest("POST", "/upload/resumable/test", nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Length", "10")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("clip.mp4")))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	uploadPath := location[strings.Index(location, "/upload/resumable/"):]
+	if resp.Header.Get("Upload-Expires") == "" {
+		t.Error("Expected Upload-Expires header")
+	}
+
+	patch := func(offset, body string) *http.Response {
+		req := httptest.NewRequest("PATCH", uploadPath, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", offset)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// 第一个数据块
+	if resp := patch("0", "01234"); resp.StatusCode != fiber.StatusNoContent || resp.Header.Get("Upload-Offset") != "5" {
+		t.Fatalf("Expected 204 with offset 5, got %d offset %s", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+
+	// 偏移不匹配
+	if resp := patch("3", "34567"); resp.StatusCode != fiber.StatusConflict {
+		t.Errorf("Expected 409 for offset mismatch, got %d", resp.StatusCode)
+	}
+
+	// 查询偏移
+	req = httptest.NewRequest("HEAD", uploadPath, nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Upload-Offset") != "5" || resp.Header.Get("Upload-Length") != "10" {
+		t.Errorf("Unexpected HEAD headers: offset=%s length=%s", resp.Header.Get("Upload-Offset"), resp.Header.Get("Upload-Length"))
+	}
+
+	// 最后一个数据块完成上传
+	if resp := patch("5", "56789"); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", resp.StatusCode)
+	}
+
+	data, err := os.ReadFile(filepath.Join(videoDir, "clip.mp4"))
+	if err != nil {
+		t.Fatalf("Completed upload should be moved to the video directory: %v", err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("Unexpected file content: %q", data)
+	}
+}
+
+func TestUploadHandler_ResumableUploadValidation(t *testing.T) {
+	app, _ := newResumableTestApp(t)
+	filename := base64.StdEncoding.EncodeToString([]byte("clip.mp4"))
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+	}{
+		{"missing tus version", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename " + filename}, fiber.StatusPreconditionFailed},
+		{"missing length", map[string]string{"Tus-Resumable": "1.0.0", "Upload-Metadata": "filename " + filename}, fiber.StatusBadRequest},
+		{"too large", map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "4096", "Upload-Metadata": "filename " + filename}, fiber.StatusRequestEntityTooLarge},
+		{"unsupported extension", map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "10", "Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("notes.txt"))}, fiber.StatusBadRequest},
+		{"missing filename", map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "10"}, fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/upload/resumable/test", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+
+	// 未知目录
+	req := httptest.NewRequest("POST", "/upload/resumable/missing", nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Length", "10")
+	req.Header.Set("Upload-Metadata", "filename "+filename)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown directory, got %d", resp.StatusCode)
+	}
+
+	// OPTIONS 返回协议能力
+	req = httptest.NewRequest("OPTIONS", "/upload/resumable/test", nil)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Tus-Version") != "1.0.0" || !strings.Contains(resp.Header.Get("Tus-Extension"), "creation") {
+		t.Errorf("Unexpected OPTIONS headers: %v", resp.Header)
+	}
+}
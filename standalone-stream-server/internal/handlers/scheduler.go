@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"standalone-stream-server/internal/middleware"
 	"standalone-stream-server/internal/models"
 	"standalone-stream-server/internal/scheduler"
+	"standalone-stream-server/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -11,13 +17,15 @@ import (
 type SchedulerHandler struct {
 	config           *models.Config
 	schedulerService *scheduler.SchedulerService
+	videoService     *services.VideoService
 }
 
 // NewSchedulerHandler creates a new scheduler handler
-func NewSchedulerHandler(config *models.Config, schedulerService *scheduler.SchedulerService) *SchedulerHandler {
+func NewSchedulerHandler(config *models.Config, schedulerService *scheduler.SchedulerService, videoService *services.VideoService) *SchedulerHandler {
 	return &SchedulerHandler{
 		config:           config,
 		schedulerService: schedulerService,
+		videoService:     videoService,
 	}
 }
 
@@ -36,18 +44,31 @@ func (sh *SchedulerHandler) GetStats(c *fiber.Ctx) error {
 
 // AddVideoDeletionTask schedules a video for deletion
 func (sh *SchedulerHandler) AddVideoDeletionTask(c *fiber.Ctx) error {
-	videoID := c.Params("video-id")
+	videoID := c.Params("videoid")
 	if videoID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Video ID is required",
 		})
 	}
 	
-	// For now, we'll use the video ID as the path
-	// In a real implementation, you'd look up the actual file path
-	videoPath := videoID
+	video, err := sh.videoService.FindVideoByID(videoID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":    "Video not found",
+			"video_id": videoID,
+			"details":  err.Error(),
+		})
+	}
 	
-	if err := sh.schedulerService.AddVideoDeletionTask(videoPath); err != nil {
+	if !canAccessDirectory(c, sh.videoService, video.Directory, services.RightDelete) {
+		return accessDenied(c, video.Directory, services.RightDelete)
+	}
+	
+	job := scheduler.VideoDeletionJob{Path: video.Path, Reason: c.Query("reason")}
+	if principal := middleware.PrincipalFromContext(c); principal != nil {
+		job.DeletedBy = principal.Username
+	}
+	if err := sh.schedulerService.QueueVideoDeletion(job); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to schedule video deletion",
 			"details": err.Error(),
@@ -60,6 +81,196 @@ func (sh *SchedulerHandler) AddVideoDeletionTask(c *fiber.Ctx) error {
 	})
 }
 
+// AddTranscodeTask schedules the rendition ladder for a video
+func (sh *SchedulerHandler) AddTranscodeTask(c *fiber.Ctx) error {
+	if !sh.config.Video.Transcoding.Enabled {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Transcoding is disabled",
+		})
+	}
+	
+	videoID := c.Params("videoid")
+	video, err := sh.videoService.FindVideoByID(videoID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":    "Video not found",
+			"video_id": videoID,
+			"details":  err.Error(),
+		})
+	}
+	
+	task, err := sh.schedulerService.AddTranscodeTask(video)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to schedule transcoding",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":  "Transcoding scheduled successfully",
+		"video_id": video.ID,
+		"task":     task,
+	})
+}
+
+// EnqueueTaskRequest is the body of POST /api/scheduler/tasks
+type EnqueueTaskRequest struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// EnqueueTask queues a task of any registered type; the payload is validated against the type's schema
+func (sh *SchedulerHandler) EnqueueTask(c *fiber.Ctx) error {
+	var req EnqueueTaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+	if req.Type == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Task type is required",
+		})
+	}
+	
+	task, err := sh.schedulerService.EnqueueTask(req.Type, req.Payload)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, scheduler.ErrUnknownTaskType) || errors.Is(err, scheduler.ErrInvalidPayload) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Failed to enqueue task",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Task queued",
+		"task":    task,
+	})
+}
+
+// ListTaskTypes returns the registered task types with their payload schemas
+func (sh *SchedulerHandler) ListTaskTypes(c *fiber.Ctx) error {
+	types := sh.schedulerService.TaskTypes()
+	
+	return c.JSON(fiber.Map{
+		"task_types": types,
+		"count":      len(types),
+	})
+}
+
+// GetTask returns a task with its progress and result
+func (sh *SchedulerHandler) GetTask(c *fiber.Ctx) error {
+	task, err := sh.schedulerService.GetTask(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Task not found",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.JSON(task)
+}
+
+// ListTasks returns queued and finished tasks, newest first, optionally filtered by status and type
+func (sh *SchedulerHandler) ListTasks(c *fiber.Ctx) error {
+	filter := scheduler.TaskFilter{
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		Limit:  c.QueryInt("limit", 100),
+	}
+	
+	tasks, err := sh.schedulerService.ListTasks(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list tasks",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"tasks": tasks,
+		"count": len(tasks),
+	})
+}
+
+// RetryTask requeues a task (typically a dead letter) with a fresh set of attempts
+func (sh *SchedulerHandler) RetryTask(c *fiber.Ctx) error {
+	task, err := sh.schedulerService.RetryTask(c.Params("id"))
+	if err != nil {
+		return taskError(c, "Failed to retry task", err)
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Task queued for retry",
+		"task":    task,
+	})
+}
+
+// DeleteTask removes a task that is not being processed
+func (sh *SchedulerHandler) DeleteTask(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := sh.schedulerService.GetTask(id); err != nil {
+		return taskError(c, "Failed to delete task", err)
+	}
+	if err := sh.schedulerService.RemoveTask(id); err != nil {
+		return taskError(c, "Failed to delete task", err)
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Task deleted",
+		"id":      id,
+	})
+}
+
+// PurgeTasks removes completed and/or dead tasks, optionally only those finished before older_than
+func (sh *SchedulerHandler) PurgeTasks(c *fiber.Ctx) error {
+	var olderThan time.Duration
+	if value := c.Query("older_than"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid older_than duration",
+				"details": value,
+			})
+		}
+		olderThan = parsed
+	}
+	
+	removed, err := sh.schedulerService.PurgeTasks(c.Query("status"), olderThan)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to purge tasks",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Tasks purged",
+		"removed": removed,
+	})
+}
+
+// taskError converts a task queue error into a response
+func taskError(c *fiber.Ctx, message string, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, scheduler.ErrTaskNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, scheduler.ErrTaskProcessing):
+		status = fiber.StatusConflict
+	}
+	
+	return c.Status(status).JSON(fiber.Map{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
 // Start starts the scheduler service
 func (sh *SchedulerHandler) Start(c *fiber.Ctx) error {
 	if err := sh.schedulerService.Start(); err != nil {
@@ -93,5 +304,55 @@ func (sh *SchedulerHandler) Status(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"running": sh.schedulerService.IsRunning(),
 		"stats":   sh.schedulerService.GetStats(),
+		"jobs":    sh.schedulerService.Jobs(),
+	})
+}
+
+// ListJobs returns the recurring jobs with their last and next runs
+func (sh *SchedulerHandler) ListJobs(c *fiber.Ctx) error {
+	jobs := sh.schedulerService.Jobs()
+	
+	return c.JSON(fiber.Map{
+		"jobs":  jobs,
+		"count": len(jobs),
 	})
-}
\ No newline at end of file
+}
+
+// TriggerJob runs a recurring job now; the run is reported by the job's status once it finishes
+func (sh *SchedulerHandler) TriggerJob(c *fiber.Ctx) error {
+	job, err := sh.schedulerService.TriggerJob(c.Params("name"))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, scheduler.ErrJobNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, scheduler.ErrJobRunning):
+			status = fiber.StatusConflict
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Failed to run job",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Job started",
+		"job":     job,
+	})
+}
+// RetentionDryRun lists the videos the retention policies would delete now, without queueing anything
+func (sh *SchedulerHandler) RetentionDryRun(c *fiber.Ctx) error {
+	result, err := sh.schedulerService.PlanRetention(c.Query("directory"))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, scheduler.ErrNoRetentionPolicy) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Failed to evaluate retention policies",
+			"details": err.Error(),
+		})
+	}
+	
+	return c.JSON(result)
+}
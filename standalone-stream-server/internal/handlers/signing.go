@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"time"
+
+	"standalone-stream-server/internal/middleware"
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SigningHandler 生成带签名的过期流媒体 URL
+type SigningHandler struct {
+	config       *models.Config
+	videoService *services.VideoService
+	signer       *middleware.URLSigner
+}
+
+// NewSigningHandler 创建新的签名处理器
+func NewSigningHandler(config *models.Config, videoService *services.VideoService, signer *middleware.URLSigner) *SigningHandler {
+	return &SigningHandler{
+		config:       config,
+		videoService: videoService,
+		signer:       signer,
+	}
+}
+
+// signURLRequest 是签名请求体；path 与 video_id 二选一
+type signURLRequest struct {
+	Path     string `json:"path"`
+	VideoID  string `json:"video_id"`
+	TTL      string `json:"ttl"`       // 有效期，如 "30m"，默认使用 default_ttl
+	BindIP   bool   `json:"bind_ip"`   // 绑定到请求方 IP
+	ClientIP string `json:"client_ip"` // 绑定到指定 IP（代替请求方 IP）
+	Prefix   bool   `json:"prefix"`    // 签名覆盖视频目录，只用于 HLS/DASH 播放列表及其片段
+}
+
+// SignURL 为流媒体、HLS/DASH 或缩略图路径生成签名 URL
+func (sh *SigningHandler) SignURL(c *fiber.Ctx) error {
+	if !sh.config.Security.SignedURLs.Enabled {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Signed URLs are disabled",
+		})
+	}
+
+	var req signURLRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	path := req.Path
+	if req.VideoID != "" {
+		video, err := sh.videoService.FindVideoByID(req.VideoID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":    "Video not found",
+				"video_id": req.VideoID,
+				"details":  err.Error(),
+			})
+		}
+		path = video.StreamURL
+	}
+	if path == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Either path or video_id is required",
+		})
+	}
+
+	// 只能为自己可读的目录签名
+	if directory := directoryForPath(sh.config, path); !canAccessDirectory(c, sh.videoService, directory, services.RightRead) {
+		return accessDenied(c, directory, services.RightRead)
+	}
+
+	opts := middleware.SignOptions{Prefix: req.Prefix, ClientIP: req.ClientIP}
+	if req.BindIP && opts.ClientIP == "" {
+		opts.ClientIP = c.IP()
+	}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid ttl",
+				"ttl":   req.TTL,
+			})
+		}
+		opts.TTL = ttl
+	}
+
+	signedURL, expires, err := sh.signer.Sign(path, opts)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to sign URL",
+			"details": err.Error(),
+		})
+	}
+
+	response := fiber.Map{
+		"url":        signedURL,
+		"expires_at": expires.UTC().Format(time.RFC3339),
+	}
+	if opts.ClientIP != "" {
+		response["client_ip"] = opts.ClientIP
+	}
+	return c.JSON(response)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -57,6 +58,10 @@ func (th *ThumbnailHandler) GetThumbnail(c *fiber.Ctx) error {
 	directory := parts[0]
 	filename := parts[1]
 
+	if !canAccessDirectory(c, th.videoService, directory, services.RightRead) {
+		return accessDenied(c, directory, services.RightRead)
+	}
+
 	// Find the video file
 	videoInfo, err := th.videoService.FindVideoByID(videoID)
 	if err != nil {
@@ -81,49 +86,99 @@ func (th *ThumbnailHandler) GetThumbnail(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate thumbnail path
-	thumbnailDir := "./thumbnails"
-	thumbnailFilename := fmt.Sprintf("%s_%s.jpg", directory, filename)
-	thumbnailPath := filepath.Join(thumbnailDir, thumbnailFilename)
-
-	// Check if thumbnail already exists
-	if _, err := os.Stat(thumbnailPath); err == nil {
-		// Serve existing thumbnail
-		return c.SendFile(thumbnailPath)
+	// Size, format and timestamp variants are cached separately from the default thumbnail
+	if services.HasThumbnailOptions(func(key string) string { return c.Query(key) }) {
+		return th.serveThumbnailVariant(c, *videoInfo)
 	}
+	th.setCacheControl(c)
 
-	// Extract video metadata to get optimal thumbnail timestamp
-	metadata, err := th.metadataService.ExtractMetadata(videoPath)
+	// Generate the thumbnail unless it is cached; concurrent requests share one ffmpeg run
+	thumbnailPath := services.ThumbnailPath(videoID)
+	generated, err := th.metadataService.EnsureThumbnail(*videoInfo)
 	if err != nil {
-		utils.LogError("thumbnail_extract_metadata", err,
+		utils.LogError("thumbnail_generation", err,
 			zap.String("video_path", videoPath),
+			zap.String("thumbnail_path", thumbnailPath),
 		)
-		// Continue with default timestamp
+		return c.Status(thumbnailErrorStatus(err)).JSON(fiber.Map{
+			"error":   "Failed to generate thumbnail",
+			"details": err.Error(),
+		})
 	}
 
-	// Determine thumbnail timestamp
-	timestamp := th.metadataService.GetOptimalThumbnailTimestamp(metadata.Duration)
-
-	// Generate thumbnail
-	if err := th.metadataService.GenerateThumbnail(videoPath, thumbnailPath, timestamp); err != nil {
-		utils.LogError("thumbnail_generation", err,
-			zap.String("video_path", videoPath),
+	if generated {
+		utils.Logger.Info("Thumbnail generated and served",
+			zap.String("video_id", videoID),
 			zap.String("thumbnail_path", thumbnailPath),
+			zap.Duration("generation_time", time.Since(start)),
 		)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	}
+
+	// Serve the generated thumbnail
+	return c.SendFile(thumbnailPath)
+}
+
+// serveThumbnailVariant serves the variant described by the w, h, fit, format, q and t query
+// parameters; variants are keyed on the options and the source file version, so the key doubles as ETag
+func (th *ThumbnailHandler) serveThumbnailVariant(c *fiber.Ctx, video services.VideoInfo) error {
+	options, err := services.ParseThumbnailOptions(func(key string) string { return c.Query(key) }, th.config.Video.Thumbnails)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid thumbnail options",
+			"details": err.Error(),
+		})
+	}
+
+	etag := fmt.Sprintf("%q", services.ThumbnailVariantKey(video, options))
+	th.setCacheControl(c)
+	c.Set(fiber.HeaderETag, etag)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	start := time.Now()
+	variantPath, _, err := th.metadataService.EnsureThumbnailVariant(video, options)
+	if err != nil {
+		if errors.Is(err, services.ErrTimestampOutOfRange) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid thumbnail timestamp",
+				"details": err.Error(),
+			})
+		}
+		utils.LogError("thumbnail_variant_generation", err, zap.String("video_id", video.ID))
+		return c.Status(thumbnailErrorStatus(err)).JSON(fiber.Map{
 			"error":   "Failed to generate thumbnail",
 			"details": err.Error(),
 		})
 	}
 
-	utils.Logger.Info("Thumbnail generated and served",
-		zap.String("video_id", videoID),
-		zap.String("thumbnail_path", thumbnailPath),
-		zap.Duration("generation_time", time.Since(start)),
+	utils.Logger.Debug("Thumbnail variant served",
+		zap.String("video_id", video.ID),
+		zap.String("variant_path", variantPath),
+		zap.Duration("duration", time.Since(start)),
 	)
 
-	// Serve the generated thumbnail
-	return c.SendFile(thumbnailPath)
+	if err := c.SendFile(variantPath); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, services.ThumbnailContentType(options.Format))
+	return nil
+}
+
+// thumbnailErrorStatus maps generation errors to a status; a timeout means all workers were busy or ffmpeg
+// was too slow, which clients may retry
+func thumbnailErrorStatus(err error) int {
+	if errors.Is(err, services.ErrThumbnailTimeout) {
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusInternalServerError
+}
+
+// setCacheControl lets clients cache thumbnails for the configured max age; responses may depend on
+// the caller's directory access, so shared caches must not store them
+func (th *ThumbnailHandler) setCacheControl(c *fiber.Ctx) {
+	maxAge := services.ThumbnailLimits(th.config.Video.Thumbnails).CacheMaxAge
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
 }
 
 // ListThumbnails returns a list of available thumbnails
@@ -133,8 +188,8 @@ func (th *ThumbnailHandler) ListThumbnails(c *fiber.Ctx) error {
 		utils.RecordHTTPRequest(c.Method(), "/api/thumbnails", fmt.Sprintf("%d", c.Response().StatusCode()), time.Since(start))
 	}()
 
-	thumbnailDir := "./thumbnails"
-	
+	thumbnailDir := services.ThumbnailDir
+
 	// Create thumbnails directory if it doesn't exist
 	if err := os.MkdirAll(thumbnailDir, 0755); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -154,6 +209,9 @@ func (th *ThumbnailHandler) ListThumbnails(c *fiber.Ctx) error {
 		if file.IsDir() || !strings.HasSuffix(strings.ToLower(file.Name()), ".jpg") {
 			continue
 		}
+		if !canAccessDirectory(c, th.videoService, thumbnailDirectory(th.config, file.Name()), services.RightRead) {
+			continue
+		}
 
 		info, err := file.Info()
 		if err != nil {
@@ -161,16 +219,17 @@ func (th *ThumbnailHandler) ListThumbnails(c *fiber.Ctx) error {
 		}
 
 		thumbnails = append(thumbnails, map[string]interface{}{
-			"filename":  file.Name(),
-			"size":      info.Size(),
-			"modified":  info.ModTime().Unix(),
-			"url":       fmt.Sprintf("/api/thumbnail/file/%s", file.Name()),
+			"filename": file.Name(),
+			"size":     info.Size(),
+			"modified": info.ModTime().Unix(),
+			"url":      fmt.Sprintf("/api/thumbnail/file/%s", file.Name()),
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"thumbnails": thumbnails,
 		"count":      len(thumbnails),
+		"cache":      th.metadataService.ThumbnailCache().Stats(),
 	})
 }
 
@@ -195,8 +254,12 @@ func (th *ThumbnailHandler) ServeThumbnailFile(c *fiber.Ctx) error {
 		})
 	}
 
-	thumbnailPath := filepath.Join("./thumbnails", filename)
-	
+	if directory := thumbnailDirectory(th.config, filename); !canAccessDirectory(c, th.videoService, directory, services.RightRead) {
+		return accessDenied(c, directory, services.RightRead)
+	}
+
+	thumbnailPath := filepath.Join(services.ThumbnailDir, filename)
+
 	// Check if file exists
 	if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -205,4 +268,74 @@ func (th *ThumbnailHandler) ServeThumbnailFile(c *fiber.Ctx) error {
 	}
 
 	return c.SendFile(thumbnailPath)
-}
\ No newline at end of file
+}
+
+// GetSpriteImage serves the seek-preview sprite sheet of a video, generating it on demand
+func (th *ThumbnailHandler) GetSpriteImage(c *fiber.Ctx) error {
+	videoID, ok, err := th.ensureSprite(c)
+	if !ok {
+		return err
+	}
+	return c.SendFile(services.SpriteImagePath(videoID))
+}
+
+// GetSpriteVTT serves the WebVTT thumbnail track that maps playback time to sprite regions
+func (th *ThumbnailHandler) GetSpriteVTT(c *fiber.Ctx) error {
+	videoID, ok, err := th.ensureSprite(c)
+	if !ok {
+		return err
+	}
+	if err := c.SendFile(services.SpriteVTTPath(videoID)); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "text/vtt; charset=utf-8")
+	return nil
+}
+
+// ensureSprite resolves :videoid and makes sure its sprite sheet is up to date;
+// when ok is false the error response has already been written
+func (th *ThumbnailHandler) ensureSprite(c *fiber.Ctx) (string, bool, error) {
+	videoID := c.Params("videoid")
+	directory, _, found := strings.Cut(videoID, ":")
+	if !found {
+		return videoID, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid video ID format",
+			"details": "Video ID should be in format 'directory:filename'",
+		})
+	}
+
+	if !canAccessDirectory(c, th.videoService, directory, services.RightRead) {
+		return videoID, false, accessDenied(c, directory, services.RightRead)
+	}
+
+	videoInfo, err := th.videoService.FindVideoByID(videoID)
+	if err != nil {
+		return videoID, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Video not found",
+			"details": err.Error(),
+		})
+	}
+
+	start := time.Now()
+	generated, err := th.metadataService.EnsureSprite(*videoInfo)
+	if err != nil {
+		if errors.Is(err, services.ErrSpritesDisabled) {
+			return videoID, false, c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Sprite sheets are disabled",
+			})
+		}
+		utils.LogError("sprite_generation", err, zap.String("video_id", videoID))
+		return videoID, false, c.Status(thumbnailErrorStatus(err)).JSON(fiber.Map{
+			"error":   "Failed to generate sprite sheet",
+			"details": err.Error(),
+		})
+	}
+
+	if generated {
+		utils.Logger.Info("Sprite sheet generated",
+			zap.String("video_id", videoID),
+			zap.Duration("generation_time", time.Since(start)),
+		)
+	}
+	return videoID, true, nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TrashHandler 处理回收站的列出、恢复和永久删除请求
+type TrashHandler struct {
+	config       *models.Config
+	trash        *services.Trash
+	videoService *services.VideoService
+}
+
+// NewTrashHandler 创建新的回收站处理器；trash 为 nil 表示回收站已禁用
+func NewTrashHandler(config *models.Config, trash *services.Trash, videoService *services.VideoService) *TrashHandler {
+	return &TrashHandler{
+		config:       config,
+		trash:        trash,
+		videoService: videoService,
+	}
+}
+
+// ListTrash 列出回收站中的视频，可用 ?directory= 限定目录；只返回请求方有删除权限的目录
+func (th *TrashHandler) ListTrash(c *fiber.Ctx) error {
+	if th.trash == nil {
+		return trashDisabled(c)
+	}
+
+	entries, err := th.trash.List(c.Query("directory"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list trash",
+			"details": err.Error(),
+		})
+	}
+
+	visible := make([]services.TrashEntry, 0, len(entries))
+	for _, entry := range entries {
+		if canAccessDirectory(c, th.videoService, entry.Directory, services.RightDelete) {
+			visible = append(visible, entry)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"entries":      visible,
+		"count":        len(visible),
+		"grace_period": th.config.Video.Trash.GracePeriod.String(),
+	})
+}
+
+// GetTrashEntry 返回回收站条目
+func (th *TrashHandler) GetTrashEntry(c *fiber.Ctx) error {
+	entry, ok, err := th.lookup(c)
+	if !ok {
+		return err
+	}
+	return c.JSON(entry)
+}
+
+// RestoreTrashEntry 将视频恢复到原位置；原位置已有文件时返回 409
+func (th *TrashHandler) RestoreTrashEntry(c *fiber.Ctx) error {
+	if _, ok, err := th.lookup(c); !ok {
+		return err
+	}
+
+	entry, err := th.trash.Restore(c.Params("id"))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrTrashEntryNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, services.ErrRestoreConflict):
+			status = fiber.StatusConflict
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Failed to restore video",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Video restored successfully",
+		"entry":   entry,
+	})
+}
+
+// DeleteTrashEntry 立即永久删除回收站条目
+func (th *TrashHandler) DeleteTrashEntry(c *fiber.Ctx) error {
+	if _, ok, err := th.lookup(c); !ok {
+		return err
+	}
+
+	entry, err := th.trash.Delete(c.Params("id"))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrTrashEntryNotFound) {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Failed to delete trash entry",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Trash entry deleted permanently",
+		"entry":   entry,
+	})
+}
+
+// lookup 查找 :id 对应的条目并检查请求方对其目录的删除权限；ok 为 false 时 err 是已写入的响应
+func (th *TrashHandler) lookup(c *fiber.Ctx) (services.TrashEntry, bool, error) {
+	if th.trash == nil {
+		return services.TrashEntry{}, false, trashDisabled(c)
+	}
+
+	entry, err := th.trash.Get(c.Params("id"))
+	if err != nil {
+		return entry, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Trash entry not found",
+			"details": err.Error(),
+		})
+	}
+
+	if !canAccessDirectory(c, th.videoService, entry.Directory, services.RightDelete) {
+		return entry, false, accessDenied(c, entry.Directory, services.RightDelete)
+	}
+	return entry, true, nil
+}
+
+// trashDisabled 在回收站未启用时返回 503
+func trashDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": "Trash is disabled",
+	})
+}
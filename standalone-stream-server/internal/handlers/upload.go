@@ -1,23 +1,28 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/scheduler"
 	"standalone-stream-server/internal/services"
+	"standalone-stream-server/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
 )
 
 // UploadHandler 处理视频上传请求
 type UploadHandler struct {
 	config       *models.Config
 	videoService *services.VideoService
+	scheduler    *scheduler.SchedulerService
+	resumable    *services.ResumableUploadStore
 }
 
 // NewUploadHandler 创建新的上传处理器
@@ -28,6 +33,11 @@ func NewUploadHandler(config *models.Config, videoService *services.VideoService
 	}
 }
 
+// SetScheduler 设置调度器，上传成功后自动加入转码任务
+func (uh *UploadHandler) SetScheduler(schedulerService *scheduler.SchedulerService) {
+	uh.scheduler = schedulerService
+}
+
 // UploadVideo 处理视频文件上传到指定目录
 func (uh *UploadHandler) UploadVideo(c *fiber.Ctx) error {
 	directory := c.Params("directory")
@@ -45,6 +55,10 @@ func (uh *UploadHandler) UploadVideo(c *fiber.Ctx) error {
 		})
 	}
 
+	if !canAccessDirectory(c, uh.videoService, directory, services.RightUpload) {
+		return accessDenied(c, directory, services.RightUpload)
+	}
+
 	// Parse multipart form
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -141,26 +155,12 @@ func (uh *UploadHandler) UploadVideo(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create target file
-	dst, err := os.Create(targetPath)
+	// 流式写入并计算内容哈希，按目录的去重策略提交
+	stored, err := uh.videoService.WriteUpload(directory, targetPath, src)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to create target file",
-			"details": err.Error(),
-		})
-	}
-	defer dst.Close()
-
-	// Copy file data
-	bytesWritten, err := io.Copy(dst, src)
-	if err != nil {
-		// Clean up partially written file
-		os.Remove(targetPath)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to save file",
-			"details": err.Error(),
-		})
+		return uh.uploadErrorResponse(c, err)
 	}
+	bytesWritten := stored.Size
 
 	// Verify file size
 	if bytesWritten != file.Size {
@@ -173,7 +173,7 @@ func (uh *UploadHandler) UploadVideo(c *fiber.Ctx) error {
 	}
 
 	// Get file info for response
-	stat, err := dst.Stat()
+	stat, err := os.Stat(targetPath)
 	if err != nil {
 		stat = nil // Continue without detailed file info
 	}
@@ -188,12 +188,20 @@ func (uh *UploadHandler) UploadVideo(c *fiber.Ctx) error {
 		"bytes_written":     bytesWritten,
 		"content_type":      uh.getContentType(ext),
 		"path":              targetPath,
+		"sha256":            stored.Hash,
+	}
+	if linkedTo := uh.visibleVideoID(c, stored.LinkedTo); linkedTo != "" {
+		response["linked_to"] = linkedTo
 	}
 
 	if stat != nil {
 		response["modified"] = stat.ModTime().Unix()
 	}
 
+	if taskID := uh.queueTranscode(directory, videoID); taskID != "" {
+		response["transcode_task_id"] = taskID
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
@@ -207,6 +215,10 @@ func (uh *UploadHandler) UploadMultipleVideos(c *fiber.Ctx) error {
 		})
 	}
 
+	if !canAccessDirectory(c, uh.videoService, directory, services.RightUpload) {
+		return accessDenied(c, directory, services.RightUpload)
+	}
+
 	// Parse multipart form
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -234,12 +246,9 @@ func (uh *UploadHandler) UploadMultipleVideos(c *fiber.Ctx) error {
 		videoID := strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
 
 		// Validate and process each file
-		result, err := uh.processUploadedFile(file, directory, videoID)
+		result, err := uh.processUploadedFile(c, file, directory, videoID)
 		if err != nil {
-			errors = append(errors, fiber.Map{
-				"filename": file.Filename,
-				"error":    err.Error(),
-			})
+			errors = append(errors, uh.uploadFailure(c, file.Filename, err))
 		} else {
 			results = append(results, result)
 			successCount++
@@ -270,7 +279,7 @@ func (uh *UploadHandler) UploadMultipleVideos(c *fiber.Ctx) error {
 }
 
 // processUploadedFile processes a single uploaded file
-func (uh *UploadHandler) processUploadedFile(file *multipart.FileHeader, directory, videoID string) (fiber.Map, error) {
+func (uh *UploadHandler) processUploadedFile(c *fiber.Ctx, file *multipart.FileHeader, directory, videoID string) (fiber.Map, error) {
 	// Validate file size
 	if file.Size > uh.config.Video.MaxUploadSize {
 		return nil, fmt.Errorf("file size exceeds limit: %d > %d", file.Size, uh.config.Video.MaxUploadSize)
@@ -317,32 +326,109 @@ func (uh *UploadHandler) processUploadedFile(file *multipart.FileHeader, directo
 	}
 	defer src.Close()
 
-	// Create target file
-	dst, err := os.Create(targetPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create target file: %w", err)
-	}
-	defer dst.Close()
-
-	// Copy data
-	bytesWritten, err := io.Copy(dst, src)
+	stored, err := uh.videoService.WriteUpload(directory, targetPath, src)
 	if err != nil {
-		os.Remove(targetPath)
-		return nil, fmt.Errorf("failed to copy file data: %w", err)
+		return nil, err
 	}
 
-	if bytesWritten != file.Size {
+	if stored.Size != file.Size {
 		os.Remove(targetPath)
-		return nil, fmt.Errorf("file size mismatch: expected %d, got %d", file.Size, bytesWritten)
+		return nil, fmt.Errorf("file size mismatch: expected %d, got %d", file.Size, stored.Size)
 	}
 
-	return fiber.Map{
+	result := fiber.Map{
 		"video_id":          videoID,
 		"filename":          filename,
 		"original_filename": file.Filename,
 		"size":              file.Size,
 		"path":              targetPath,
-	}, nil
+		"sha256":            stored.Hash,
+	}
+	if linkedTo := uh.visibleVideoID(c, stored.LinkedTo); linkedTo != "" {
+		result["linked_to"] = linkedTo
+	}
+
+	if taskID := uh.queueTranscode(directory, videoID); taskID != "" {
+		result["transcode_task_id"] = taskID
+	}
+
+	return result, nil
+}
+
+// queueTranscode 在启用自动转码时为新上传的视频加入转码任务，返回任务 ID
+func (uh *UploadHandler) queueTranscode(directory, videoID string) string {
+	transcoding := uh.config.Video.Transcoding
+	if uh.scheduler == nil || !transcoding.Enabled || !transcoding.AutoOnUpload {
+		return ""
+	}
+
+	video, err := uh.videoService.FindVideoByID(directory + ":" + videoID)
+	if err != nil {
+		utils.LogError("transcode_enqueue", err, zap.String("video_id", directory+":"+videoID))
+		return ""
+	}
+
+	task, err := uh.scheduler.AddTranscodeTask(video)
+	if err != nil {
+		utils.LogError("transcode_enqueue", err, zap.String("video_id", video.ID))
+		return ""
+	}
+
+	return task.ID
+}
+
+// uploadFailure 返回批量上传中单个文件的失败说明
+func (uh *UploadHandler) uploadFailure(c *fiber.Ctx, filename string, err error) fiber.Map {
+	var duplicate *services.DuplicateContentError
+	if errors.As(err, &duplicate) {
+		failure := uh.duplicateContent(c, duplicate)
+		failure["filename"] = filename
+		return failure
+	}
+	return fiber.Map{
+		"filename": filename,
+		"error":    err.Error(),
+	}
+}
+
+// duplicateContent 返回重复内容的说明；已有视频所在目录对请求方不可读时不返回其 ID
+func (uh *UploadHandler) duplicateContent(c *fiber.Ctx, duplicate *services.DuplicateContentError) fiber.Map {
+	response := fiber.Map{
+		"error":  "Duplicate content",
+		"sha256": duplicate.Hash,
+	}
+	if existingID := uh.visibleVideoID(c, duplicate.ExistingID); existingID != "" {
+		response["existing_video_id"] = existingID
+	}
+	return response
+}
+
+// visibleVideoID 返回请求方可读的视频 ID；不可读目录中的视频 ID 返回空字符串
+func (uh *UploadHandler) visibleVideoID(c *fiber.Ctx, videoID string) string {
+	directory, _, found := strings.Cut(videoID, ":")
+	if !found || !canAccessDirectory(c, uh.videoService, directory, services.RightRead) {
+		return ""
+	}
+	return videoID
+}
+
+// uploadErrorResponse 把写入上传文件的错误映射为响应；重复内容返回 409 并指向请求方可读的已有视频
+func (uh *UploadHandler) uploadErrorResponse(c *fiber.Ctx, err error) error {
+	var duplicate *services.DuplicateContentError
+	switch {
+	case errors.As(err, &duplicate):
+		return c.Status(fiber.StatusConflict).JSON(uh.duplicateContent(c, duplicate))
+	case errors.Is(err, services.ErrUploadExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "File already exists",
+			"details": err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to save file",
+			"details": err.Error(),
+		})
+	}
 }
 
 // 辅助方法
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUploadHandler_RejectsDuplicateContent(t *testing.T) {
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{
+				{Name: "test", Path: t.TempDir(), Enabled: true, Deduplication: services.DedupReject},
+			},
+			MaxUploadSize:    1024,
+			SupportedFormats: []string{".mp4"},
+		},
+	}
+
+	handler := NewUploadHandler(config, services.NewVideoService(config))
+	app := fiber.New()
+	app.Post("/upload/:directory/:videoid", handler.UploadVideo)
+
+	upload := func(videoID string) (int, map[string]interface{}) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", videoID+".mp4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("identical bytes"))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload/test/"+videoID, &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, first := upload("original")
+	if status != fiber.StatusCreated || first["sha256"] == "" {
+		t.Fatalf("Expected 201 with sha256, got %d %v", status, first)
+	}
+
+	status, second := upload("copy")
+	if status != fiber.StatusConflict {
+		t.Fatalf("Expected 409 for duplicate content, got %d", status)
+	}
+	if second["existing_video_id"] != "test:original" || second["sha256"] != first["sha256"] {
+		t.Errorf("Unexpected duplicate response: %v", second)
+	}
+}
+
+func TestUploadHandler_HidesUnreadableDuplicates(t *testing.T) {
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{
+				{Name: "inbox", Path: t.TempDir(), Enabled: true, Deduplication: services.DedupReject, Access: models.DirectoryAccess{
+					Mode:   services.AccessRestricted,
+					Grants: []models.AccessGrant{{Users: []string{"bob"}, Rights: []string{services.RightUpload}}},
+				}},
+			},
+			MaxUploadSize:    1024,
+			SupportedFormats: []string{".mp4"},
+		},
+	}
+	config.Security.Auth.Enabled = true
+	config.Security.Auth.Type = "jwt"
+
+	handler := NewUploadHandler(config, services.NewVideoService(config))
+	var principal *services.Principal
+	app := fiber.New()
+	app.Post("/upload/:directory/:videoid", func(c *fiber.Ctx) error {
+		c.Locals("principal", principal)
+		return c.Next()
+	}, handler.UploadVideo)
+
+	upload := func(directory, videoID string) (int, map[string]interface{}) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", videoID+".mp4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("identical bytes"))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload/"+directory+"/"+videoID, &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	principal = &services.Principal{Username: "root", Role: services.RoleAdmin}
+	if status, _ := upload("inbox", "original"); status != fiber.StatusCreated {
+		t.Fatalf("Expected 201 for the admin upload, got %d", status)
+	}
+
+	// 只有上传权限的用户不能从重复响应中得知已有视频的 ID
+	principal = &services.Principal{Username: "bob", Role: services.RoleUploader}
+	status, duplicate := upload("inbox", "copy")
+	if status != fiber.StatusConflict {
+		t.Fatalf("Expected 409 for duplicate content, got %d", status)
+	}
+	if _, leaked := duplicate["existing_video_id"]; leaked {
+		t.Errorf("Duplicate response must not reveal videos in unreadable directories: %v", duplicate)
+	}
+
+	principal = &services.Principal{Username: "root", Role: services.RoleAdmin}
+	if _, duplicate := upload("inbox", "copy"); duplicate["existing_video_id"] != "inbox:original" {
+		t.Errorf("Expected the existing video for a reader of its directory, got %v", duplicate)
+	}
+}
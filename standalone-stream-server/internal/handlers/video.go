@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
@@ -18,10 +17,20 @@ type VideoHandler struct {
 	config             *models.Config
 	videoService       *services.VideoService
 	streamingFlowController *middleware.StreamingFlowController
+	bandwidthShaper         *middleware.BandwidthShaper
 }
 
 // NewVideoHandler 创建新的视频处理器
 func NewVideoHandler(config *models.Config, videoService *services.VideoService) *VideoHandler {
+	return &VideoHandler{
+		config:                  config,
+		videoService:            videoService,
+		streamingFlowController: newStreamingFlowController(config, middleware.NewMemoryLimiterBackend()),
+	}
+}
+
+// newStreamingFlowController creates the streaming flow controller based on config
+func newStreamingFlowController(config *models.Config, backend middleware.LimiterBackend) *middleware.StreamingFlowController {
 	// Use configurable tokens per second, fallback to 1/4 of max connections if not set
 	tokensPerSecond := config.Server.TokensPerSecond
 	if tokensPerSecond == 0 {
@@ -29,22 +38,31 @@ func NewVideoHandler(config *models.Config, videoService *services.VideoService)
 		tokensPerSecond = config.Server.MaxConns / 4
 	}
 	
-	// Create streaming flow controller based on config
-	streamingFlowController := middleware.NewStreamingFlowController(
+	streamingFlowController := middleware.NewSharedStreamingFlowController(
+		backend,
 		config.Server.MaxConns, // max connections
 		tokensPerSecond,        // tokens per second
 	)
-	
-	return &VideoHandler{
-		config:                  config,
-		videoService:            videoService,
-		streamingFlowController: streamingFlowController,
+	if config.Server.Admission.Enabled {
+		streamingFlowController.EnableQueue(config.Server.Admission.MaxQueue, config.Server.Admission.MaxWait)
 	}
+	return streamingFlowController
+}
+
+// SetLimiterBackend 把流媒体令牌和连接槽位保存到指定后端；共享后端使所有副本共同遵守流控限制
+func (vh *VideoHandler) SetLimiterBackend(backend middleware.LimiterBackend) {
+	vh.streamingFlowController = newStreamingFlowController(vh.config, backend)
+}
+
+// SetBandwidthShaper 设置带宽整形器，用于在 /api/streaming/stats 中报告整形统计
+func (vh *VideoHandler) SetBandwidthShaper(shaper *middleware.BandwidthShaper) {
+	vh.bandwidthShaper = shaper
 }
 
 // ListAllVideos 返回所有启用目录中的所有视频
 func (vh *VideoHandler) ListAllVideos(c *fiber.Ctx) error {
-	videos, err := vh.videoService.ListAllVideos()
+	principal := middleware.PrincipalFromContext(c)
+	videos, err := vh.videoService.ListVisibleVideos(principal)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to list videos",
@@ -58,7 +76,7 @@ func (vh *VideoHandler) ListAllVideos(c *fiber.Ctx) error {
 		"directories": func() []string {
 			var dirs []string
 			for _, dir := range vh.config.Video.Directories {
-				if dir.Enabled {
+				if dir.Enabled && vh.videoService.CanAccess(principal, dir.Name, services.RightRead) {
 					dirs = append(dirs, dir.Name)
 				}
 			}
@@ -78,6 +96,10 @@ func (vh *VideoHandler) ListVideosInDirectory(c *fiber.Ctx) error {
 		})
 	}
 
+	if !canAccessDirectory(c, vh.videoService, directory, services.RightRead) {
+		return accessDenied(c, directory, services.RightRead)
+	}
+
 	videos, err := vh.videoService.ListVideosInDirectory(directory)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -97,7 +119,7 @@ func (vh *VideoHandler) ListVideosInDirectory(c *fiber.Ctx) error {
 
 // ListDirectories 返回所有视频目录的信息
 func (vh *VideoHandler) ListDirectories(c *fiber.Ctx) error {
-	directories := vh.videoService.GetDirectoriesInfo()
+	directories := vh.videoService.VisibleDirectories(middleware.PrincipalFromContext(c))
 
 	// 添加查询参数以在响应中包含视频
 	includeVideos := c.Query("include_videos", "false")
@@ -153,147 +175,68 @@ func (vh *VideoHandler) StreamVideo(c *fiber.Ctx) error {
 
 // streamVideoFile handles the actual streaming logic for both streaming methods
 func (vh *VideoHandler) streamVideoFile(c *fiber.Ctx, video *services.VideoInfo) error {
-	// Apply flow control for streaming requests
-	allowed, reason := vh.streamingFlowController.CheckAccess()
-	if !allowed {
+	if !canAccessDirectory(c, vh.videoService, video.Directory, services.RightRead) {
+		return accessDenied(c, video.Directory, services.RightRead)
+	}
+
+	// 通过 ?quality= 选择转码版本
+	if quality := c.Query("quality"); quality != "" {
+		rendition, ok := video.Rendition(quality)
+		if !ok {
+			available := make([]string, 0, len(video.Renditions))
+			for _, r := range video.Renditions {
+				available = append(available, r.Quality)
+			}
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":     "Rendition not found",
+				"video_id":  video.ID,
+				"quality":   quality,
+				"available": available,
+			})
+		}
+
+		selected := *video
+		selected.Path = rendition.Path
+		selected.ContentType = rendition.ContentType
+		selected.Size = rendition.Size
+		selected.Hash = ""
+		// 转码版本的码率按其文件大小和时长估算
+		selected.Metadata.Bitrate = 0
+		video = &selected
+	}
+
+	// Apply flow control for streaming requests; saturated requests wait in the priority queue
+	admission := vh.streamingFlowController.Admit(middleware.AdmissionPriority(c, vh.config.Server.Admission))
+	if !admission.Allowed {
 		errorMsg := "Server busy"
-		if reason == "rate_limited" {
+		switch admission.Reason {
+		case "rate_limited":
 			errorMsg = "Rate limit exceeded"
-		} else if reason == "connection_limited" {
+		case "connection_limited":
 			errorMsg = "Too many concurrent connections"
+		case "queue_full":
+			errorMsg = "Stream queue is full"
+		case "queue_timeout":
+			errorMsg = "Timed out waiting for a stream slot"
 		}
 		
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(admission.RetryAfter.Seconds())))
 		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-			"error":  errorMsg,
-			"reason": reason,
+			"error":       errorMsg,
+			"reason":      admission.Reason,
+			"retry_after": int(admission.RetryAfter.Seconds()),
 		})
 	}
 	
-	// Ensure connection is released when streaming completes
-	defer vh.streamingFlowController.ReleaseConnection()
+	// The connection slot is held until the response body has been sent and closed
+	defer vh.streamingFlowController.ReleaseAfterBody(c, admission.Lease)()
+	
+	// 记录播放时间，保留规则按 lru 顺序淘汰时使用
+	vh.videoService.RecordAccess(video.ID)
 	
-	// 首先获取文件信息
-	stat, err := os.Stat(video.Path)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to get file information",
-			"details": err.Error(),
-		})
-	}
-
-	// 设置头
-	c.Set("Content-Type", video.ContentType)
-	c.Set("Accept-Ranges", "bytes")
-	c.Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
 	c.Set("Cache-Control", vh.config.Video.StreamingSettings.CacheControl)
-	c.Set("Last-Modified", stat.ModTime().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
-
-	// 处理范围请求
-	rangeHeader := c.Get("Range")
-	if rangeHeader != "" && vh.config.Video.StreamingSettings.RangeSupport {
-		// 对于范围请求，我们仍需要手动打开文件
-		file, err := os.Open(video.Path)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to open video file",
-				"details": err.Error(),
-			})
-		}
-		defer file.Close()
-		return vh.handleRangeRequest(c, file, stat.Size(), rangeHeader)
-	}
-
-	// 发送整个文件 - 使用 SendFile 以获得更好的兼容性
-	return c.SendFile(video.Path)
-}
-
-// handleRangeRequest handles HTTP range requests for video seeking
-func (vh *VideoHandler) handleRangeRequest(c *fiber.Ctx, file *os.File, fileSize int64, rangeHeader string) error {
-	// Parse range header (format: "bytes=start-end")
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
-			"error": "Invalid range format",
-		})
-	}
-
-	rangeSpec := strings.TrimPrefix(rangeHeader, "bytes=")
-	rangeParts := strings.Split(rangeSpec, "-")
-
-	if len(rangeParts) != 2 {
-		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
-			"error": "Invalid range specification",
-		})
-	}
-
-	var start, end int64
-	var err error
-
-	// Parse start
-	if rangeParts[0] != "" {
-		start, err = strconv.ParseInt(rangeParts[0], 10, 64)
-		if err != nil || start < 0 {
-			return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
-				"error": "Invalid start range",
-			})
-		}
-	}
-
-	// Parse end
-	if rangeParts[1] != "" {
-		end, err = strconv.ParseInt(rangeParts[1], 10, 64)
-		if err != nil || end >= fileSize {
-			end = fileSize - 1
-		}
-	} else {
-		end = fileSize - 1
-	}
-
-	// Validate range
-	if start > end || start >= fileSize {
-		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
-			"error": "Invalid range values",
-		})
-	}
-
-	// Calculate content length
-	contentLength := end - start + 1
-
-	// 设置头 for partial content
-	c.Status(fiber.StatusPartialContent)
-	c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
-	c.Set("Content-Length", strconv.FormatInt(contentLength, 10))
-
-	// Seek to start position
-	if _, err := file.Seek(start, 0); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to seek in file",
-			"details": err.Error(),
-		})
-	}
-
-	// Send the requested range
-	buffer := make([]byte, vh.config.Video.StreamingSettings.ChunkSize)
-	remaining := contentLength
-
-	for remaining > 0 {
-		chunkSize := vh.config.Video.StreamingSettings.ChunkSize
-		if remaining < int64(chunkSize) {
-			chunkSize = int(remaining)
-		}
-
-		n, err := file.Read(buffer[:chunkSize])
-		if err != nil {
-			break
-		}
-
-		if _, err := c.Response().BodyWriter().Write(buffer[:n]); err != nil {
-			break
-		}
-
-		remaining -= int64(n)
-	}
-
-	return nil
+	middleware.SetStreamBitrate(c, video.EstimatedBitrate())
+	return serveFile(c, video.Path, video.ContentType, video.Hash, vh.config.Video.StreamingSettings.RangeSupport)
 }
 
 // GetVideoInfo 返回特定视频的详细信息
@@ -314,6 +257,10 @@ func (vh *VideoHandler) GetVideoInfo(c *fiber.Ctx) error {
 		})
 	}
 
+	if !canAccessDirectory(c, vh.videoService, video.Directory, services.RightRead) {
+		return accessDenied(c, video.Directory, services.RightRead)
+	}
+
 	return c.JSON(video)
 }
 
@@ -326,7 +273,7 @@ func (vh *VideoHandler) SearchVideos(c *fiber.Ctx) error {
 		})
 	}
 
-	allVideos, err := vh.videoService.ListAllVideos()
+	allVideos, err := vh.videoService.ListVisibleVideos(middleware.PrincipalFromContext(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to search videos",
@@ -408,6 +355,10 @@ func (vh *VideoHandler) ValidateVideo(c *fiber.Ctx) error {
 		})
 	}
 
+	if !canAccessDirectory(c, vh.videoService, video.Directory, services.RightRead) {
+		return accessDenied(c, video.Directory, services.RightRead)
+	}
+
 	// Validate the video file
 	if err := vh.videoService.ValidateVideoFile(video.Path); err != nil {
 		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
@@ -432,6 +383,7 @@ func (vh *VideoHandler) GetFlowControlStats(c *fiber.Ctx) error {
 	
 	return c.JSON(fiber.Map{
 		"flow_control": stats,
+		"bandwidth":    vh.bandwidthShaper.Stats(),
 		"timestamp":    c.Context().Time().Unix(),
 	})
 }
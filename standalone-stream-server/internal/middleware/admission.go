@@ -0,0 +1,333 @@
+package middleware
+
+import (
+	"container/heap"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// admissionPollInterval is how often queued requests retry while no connection is released,
+// so that token bucket refills are noticed
+const admissionPollInterval = 20 * time.Millisecond
+
+// admissionWaiter is a stream request waiting for a token and a connection slot
+type admissionWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+	lease    string // connection slot lease, set when granted
+	index    int
+}
+
+// waiterHeap orders waiters by priority (highest first), then by arrival
+type waiterHeap []*admissionWaiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*admissionWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	w.index = -1
+	return w
+}
+
+// admissionQueue holds stream requests that could not be admitted immediately
+type admissionQueue struct {
+	maxQueue int
+	maxWait  time.Duration
+
+	mu      sync.Mutex
+	waiters waiterHeap
+	seq     uint64
+	running bool
+	wake    chan struct{}
+}
+
+// EnableQueue makes Admit queue requests for up to maxWait (at most maxQueue of them)
+// instead of rejecting them as soon as the server is saturated
+func (sfc *StreamingFlowController) EnableQueue(maxQueue int, maxWait time.Duration) {
+	if maxQueue <= 0 || maxWait <= 0 {
+		sfc.queue = nil
+		return
+	}
+	sfc.queue = &admissionQueue{
+		maxQueue: maxQueue,
+		maxWait:  maxWait,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Admission is the outcome of Admit. An admitted request must pass Lease to ReleaseConnection
+// when it completes; a rejected one carries the reason and how long the client should wait.
+type Admission struct {
+	Allowed    bool
+	Reason     string
+	RetryAfter time.Duration
+	Lease      string
+}
+
+// Admit admits a stream request, waiting in the priority queue when the server is saturated
+func (sfc *StreamingFlowController) Admit(priority int) Admission {
+	q := sfc.queue
+	if q == nil {
+		allowed, reason, lease := sfc.CheckAccess()
+		if allowed {
+			return Admission{Allowed: true, Reason: reason, Lease: lease}
+		}
+		return Admission{Reason: reason, RetryAfter: sfc.retryAfter(0)}
+	}
+
+	sfc.mu.Lock()
+	sfc.stats.TotalRequests++
+	sfc.mu.Unlock()
+
+	q.mu.Lock()
+	// Requests only bypass the queue when nobody is waiting, so queued requests are not overtaken
+	if len(q.waiters) == 0 {
+		if lease, ok := sfc.tryAdmit(); ok {
+			q.mu.Unlock()
+			sfc.countOutcome("accepted")
+			return Admission{Allowed: true, Reason: "accepted", Lease: lease}
+		}
+	}
+
+	depth := len(q.waiters)
+	if depth >= q.maxQueue {
+		q.mu.Unlock()
+		sfc.countOutcome("queue_full")
+		utils.RecordStreamAdmission("rejected", 0)
+		return Admission{Reason: "queue_full", RetryAfter: sfc.retryAfter(depth)}
+	}
+
+	q.seq++
+	w := &admissionWaiter{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	utils.StreamQueueDepth.Observe(float64(depth + 1))
+	utils.UpdateStreamQueueLength(len(q.waiters))
+	if !q.running {
+		q.running = true
+		go sfc.dispatch(q)
+	}
+	q.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+	case <-timer.C:
+		q.mu.Lock()
+		if !w.granted {
+			heap.Remove(&q.waiters, w.index)
+			depth = len(q.waiters)
+			utils.UpdateStreamQueueLength(depth)
+			q.mu.Unlock()
+
+			sfc.countOutcome("queue_timeout")
+			utils.RecordStreamAdmission("timeout", time.Since(start))
+			return Admission{Reason: "queue_timeout", RetryAfter: sfc.retryAfter(depth)}
+		}
+		q.mu.Unlock()
+	}
+
+	sfc.countOutcome("accepted")
+	sfc.mu.Lock()
+	sfc.stats.Queued++
+	sfc.mu.Unlock()
+	utils.RecordStreamAdmission("admitted", time.Since(start))
+	return Admission{Allowed: true, Reason: "accepted", Lease: w.lease}
+}
+
+// tryAdmit takes a connection slot and a token, or neither
+func (sfc *StreamingFlowController) tryAdmit() (string, bool) {
+	lease, ok := sfc.connectionLimiter.Acquire()
+	if !ok {
+		return "", false
+	}
+	if !sfc.takeToken() {
+		sfc.connectionLimiter.Release(lease)
+		return "", false
+	}
+	return lease, true
+}
+
+// dispatch admits queued requests in priority order until the queue is empty
+func (sfc *StreamingFlowController) dispatch(q *admissionQueue) {
+	ticker := time.NewTicker(admissionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		}
+
+		q.mu.Lock()
+		for len(q.waiters) > 0 {
+			lease, ok := sfc.tryAdmit()
+			if !ok {
+				break
+			}
+			w := heap.Pop(&q.waiters).(*admissionWaiter)
+			w.granted = true
+			w.lease = lease
+			close(w.ready)
+		}
+		utils.UpdateStreamQueueLength(len(q.waiters))
+		if len(q.waiters) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
+
+// notifyRelease wakes the dispatcher after a connection slot is released
+func (q *admissionQueue) notifyRelease() {
+	if q == nil {
+		return
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// queueLength returns the number of waiting requests
+func (q *admissionQueue) queueLength() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
+
+// queueStats describes the admission queue for the flow control statistics
+func (sfc *StreamingFlowController) queueStats() map[string]interface{} {
+	q := sfc.queue
+	if q == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled":          true,
+		"length":           q.queueLength(),
+		"max_queue":        q.maxQueue,
+		"max_wait_seconds": q.maxWait.Seconds(),
+	}
+}
+
+// countOutcome updates the request counters for an admission decision
+func (sfc *StreamingFlowController) countOutcome(outcome string) {
+	sfc.mu.Lock()
+	defer sfc.mu.Unlock()
+
+	switch outcome {
+	case "accepted":
+		sfc.stats.Accepted++
+	case "queue_full":
+		sfc.stats.QueueFull++
+	case "queue_timeout":
+		sfc.stats.QueueTimeouts++
+	}
+}
+
+// retryAfter estimates when a rejected client will be admitted: everyone queued ahead of it
+// plus itself, drained at the token refill rate
+func (sfc *StreamingFlowController) retryAfter(depth int) time.Duration {
+	if sfc.tokensPerSecond <= 0 {
+		return time.Second
+	}
+	seconds := math.Ceil(float64(depth+1) / float64(sfc.tokensPerSecond))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// AdmissionPriority returns the queue priority of a request: the API key's tier when one is
+// configured, otherwise the priority of the caller's role ("anonymous" for unauthenticated callers)
+func AdmissionPriority(c *fiber.Ctx, config models.AdmissionConfig) int {
+	principal := PrincipalFromContext(c)
+	if principal == nil {
+		return config.Priorities["anonymous"]
+	}
+	if principal.KeyID != "" {
+		if priority, ok := config.KeyPriorities[principal.KeyID]; ok {
+			return priority
+		}
+	}
+	return config.Priorities[principal.Role]
+}
+
+// localsBodyRelease holds the release of an admitted stream until a response body takes it over
+const localsBodyRelease = "stream_body_release"
+
+// ReleaseAfterBody keeps the connection slot of an admitted stream until the streamed response body
+// is closed, since fasthttp sends the body after the handler has returned. The handler must defer
+// the returned function, which releases the slot immediately when no body took it over.
+func (sfc *StreamingFlowController) ReleaseAfterBody(c *fiber.Ctx, lease string) func() {
+	var once sync.Once
+	release := func() { once.Do(func() { sfc.ReleaseConnection(lease) }) }
+	c.Locals(localsBodyRelease, release)
+
+	return func() {
+		if _, pending := c.Locals(localsBodyRelease).(func()); pending {
+			c.Locals(localsBodyRelease, nil)
+			release()
+		}
+	}
+}
+
+// releasingBody runs release after the wrapped body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (rb *releasingBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.release()
+	return err
+}
+
+// holdUntilClosed hands a pending stream release over to body
+func holdUntilClosed(c *fiber.Ctx, body io.ReadCloser) io.ReadCloser {
+	release, ok := c.Locals(localsBodyRelease).(func())
+	if !ok {
+		return body
+	}
+	c.Locals(localsBodyRelease, nil)
+	return &releasingBody{ReadCloser: body, release: release}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdmit_QueuesUntilRelease(t *testing.T) {
+	sfc := NewStreamingFlowController(1, 1000)
+	sfc.EnableQueue(10, time.Second)
+
+	first := sfc.Admit(0)
+	if !first.Allowed {
+		t.Fatal("Expected first request to be admitted immediately")
+	}
+
+	admitted := make(chan bool)
+	go func() {
+		admitted <- sfc.Admit(0).Allowed
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	sfc.ReleaseConnection(first.Lease)
+
+	select {
+	case ok := <-admitted:
+		if !ok {
+			t.Error("Expected queued request to be admitted after release")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queued request was not admitted")
+	}
+
+	if stats := sfc.GetStats(); stats.Queued != 1 || stats.Accepted != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestAdmit_PriorityOrder(t *testing.T) {
+	sfc := NewStreamingFlowController(1, 1000)
+	sfc.EnableQueue(10, 2*time.Second)
+	held := sfc.Admit(0)
+
+	type grant struct {
+		priority int
+		lease    string
+	}
+	order := make(chan grant, 2)
+	for _, priority := range []int{1, 3} {
+		go func(priority int) {
+			if admission := sfc.Admit(priority); admission.Allowed {
+				order <- grant{priority, admission.Lease}
+			}
+		}(priority)
+		time.Sleep(30 * time.Millisecond) // low priority enqueues first
+	}
+
+	sfc.ReleaseConnection(held.Lease)
+	first := <-order
+	if first.priority != 3 {
+		t.Errorf("Expected higher priority to be admitted first, got %d", first.priority)
+	}
+	sfc.ReleaseConnection(first.lease)
+	if second := <-order; second.priority != 1 {
+		t.Errorf("Expected lower priority second, got %d", second.priority)
+	}
+}
+
+func TestAdmit_TimeoutAndQueueFull(t *testing.T) {
+	sfc := NewStreamingFlowController(1, 1)
+	sfc.EnableQueue(1, 50*time.Millisecond)
+	sfc.Admit(0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		admission := sfc.Admit(0)
+		if admission.Allowed || admission.Reason != "queue_timeout" {
+			t.Errorf("Expected queue_timeout, got %+v", admission)
+		}
+		if admission.RetryAfter < time.Second {
+			t.Errorf("Expected Retry-After of at least 1s, got %v", admission.RetryAfter)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// A full queue rejects immediately; Retry-After grows with the queue depth
+	admission := sfc.Admit(0)
+	if admission.Allowed || admission.Reason != "queue_full" {
+		t.Errorf("Expected queue_full, got %+v", admission)
+	}
+	if admission.RetryAfter != 2*time.Second {
+		t.Errorf("Expected 2s Retry-After with one request queued at 1/s, got %v", admission.RetryAfter)
+	}
+	<-done
+
+	if stats := sfc.GetStats(); stats.QueueFull != 1 || stats.QueueTimeouts != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestAdmit_WithoutQueue(t *testing.T) {
+	sfc := NewStreamingFlowController(1, 1000)
+	sfc.Admit(0)
+
+	admission := sfc.Admit(0)
+	if admission.Allowed || admission.Reason != "connection_limited" || admission.RetryAfter != time.Second {
+		t.Errorf("Expected immediate connection_limited rejection, got %+v", admission)
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// localsPrincipal 保存通过认证中间件的请求方
+const localsPrincipal = "principal"
+
+// publicPaths 是认证启用时仍无需凭据的端点
+var publicPaths = map[string]bool{
+	"/health":           true,
+	"/api/info":         true,
+	"/api/auth/login":   true,
+	"/api/auth/refresh": true,
+}
+
+func isPublicPath(p string) bool {
+	return publicPaths[strings.TrimSuffix(p, "/")]
+}
+
+// anonymousReadPaths 是按目录访问控制列表授权的只读端点；
+// 未携带凭据的请求以匿名身份继续，由处理器决定是否允许访问（public 目录）
+var anonymousReadPaths = []string{
+	"/api/videos",
+	"/api/directories",
+	"/api/search",
+	"/api/video/",
+	"/api/thumbnail/",
+	"/api/thumbnails",
+	"/stream/",
+	"/hls/",
+	"/dash/",
+}
+
+func isAnonymousReadPath(c *fiber.Ctx) bool {
+	// tus 客户端用 OPTIONS 发现服务器能力，不携带凭据
+	if c.Method() == fiber.MethodOptions && strings.HasPrefix(c.Path(), "/upload/resumable/") {
+		return true
+	}
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return false
+	}
+
+	p := c.Path()
+	for _, prefix := range anonymousReadPaths {
+		if strings.HasSuffix(prefix, "/") {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+		} else if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// setPrincipal 记录已认证的请求方
+func setPrincipal(c *fiber.Ctx, principal *services.Principal) {
+	c.Locals(localsPrincipal, principal)
+}
+
+// PrincipalFromContext 返回已认证的请求方；未认证（或认证未启用）时返回 nil
+func PrincipalFromContext(c *fiber.Ctx) *services.Principal {
+	principal, _ := c.Locals(localsPrincipal).(*services.Principal)
+	return principal
+}
+
+// isAuthenticated 返回请求是否已通过认证中间件
+func isAuthenticated(c *fiber.Ctx) bool {
+	return PrincipalFromContext(c) != nil
+}
+
+// RequireRole 要求请求方至少拥有指定角色；认证未启用时放行
+func RequireRole(config *models.Config, role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !services.AuthEnforced(config) {
+			return c.Next()
+		}
+
+		principal := PrincipalFromContext(c)
+		if principal == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+		if !services.RoleAllows(principal.Role, role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":         "Insufficient role",
+				"role":          principal.Role,
+				"required_role": role,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// authenticateAPIKey 通过密钥库校验 API 密钥，成功时以密钥的作用域继续处理请求
+func authenticateAPIKey(c *fiber.Ctx, keys *services.APIKeyStore, apiKey string) error {
+	if keys == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "API key store is not available",
+		})
+	}
+
+	key, err := keys.Authenticate(apiKey)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Invalid or missing API key",
+			"details": err.Error(),
+		})
+	}
+
+	setPrincipal(c, services.PrincipalForAPIKey(key))
+	return c.Next()
+}
+
+// parseBasicAuth 按 RFC 7617 解析 Authorization 头：方案名不区分大小写，
+// 凭据为 base64 编码的 "user-id:password"，用户名不能包含冒号而密码可以
+func parseBasicAuth(header string) (username, password string, ok bool) {
+	scheme, credentials, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", "", false
+	}
+
+	username, password, ok = strings.Cut(string(decoded), ":")
+	if !ok || username == "" {
+		return "", "", false
+	}
+	return username, password, true
+}
+
+// parseBearerToken 解析 "Authorization: Bearer <token>" 头
+func parseBearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
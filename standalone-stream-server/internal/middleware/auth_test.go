@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseBasicAuth(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		header   string
+		username string
+		password string
+		ok       bool
+	}{
+		{"Basic " + encode("admin:secret"), "admin", "secret", true},
+		{"basic " + encode("admin:secret"), "admin", "secret", true},
+		{"Basic " + encode("admin:pa:ss:word"), "admin", "pa:ss:word", true},
+		{"Basic " + encode("admin:"), "admin", "", true},
+		{"Basic " + encode("admin"), "", "", false},
+		{"Basic " + encode(":secret"), "", "", false},
+		{"Basic admin:secret", "", "", false},
+		{"Bearer " + encode("admin:secret"), "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		username, password, ok := parseBasicAuth(tt.header)
+		if username != tt.username || password != tt.password || ok != tt.ok {
+			t.Errorf("parseBasicAuth(%q) = %q, %q, %v", tt.header, username, password, ok)
+		}
+	}
+}
+
+func newAuthTestApp(t *testing.T, authType string) (*fiber.App, *services.AuthService, *services.APIKeyStore) {
+	t.Helper()
+
+	users, err := services.OpenUserStore(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { users.Close() })
+
+	keys, err := services.OpenAPIKeyStore(filepath.Join(t.TempDir(), "api_keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+
+	for _, u := range []struct{ name, role string }{
+		{"viewer", services.RoleViewer},
+		{"uploader", services.RoleUploader},
+		{"admin", services.RoleAdmin},
+	} {
+		if _, err := users.Create(u.name, u.name+"-password", u.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &models.Config{}
+	config.Security.Auth.Enabled = true
+	config.Security.Auth.Type = authType
+	config.Security.Auth.JWT.Secret = "0123456789abcdef0123456789abcdef"
+	auth := services.NewAuthService(config, users)
+
+	app := fiber.New()
+	setupAuth(app, config, auth, keys)
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/health", ok)
+	app.Get("/api/videos", ok)
+	app.Get("/api/system/stats", ok)
+	app.Post("/upload/movies/avatar", RequireRole(config, services.RoleUploader), ok)
+	app.Options("/upload/resumable/:directory", ok)
+	app.Post("/upload/resumable/:directory", RequireRole(config, services.RoleUploader), ok)
+	app.Get("/api/scheduler/status", RequireRole(config, services.RoleAdmin), ok)
+	return app, auth, keys
+}
+
+func authStatus(t *testing.T, app *fiber.App, method, path, authorization string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestBasicAuthAndRoles(t *testing.T) {
+	app, _, _ := newAuthTestApp(t, "basic")
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		expected      int
+	}{
+		{"public endpoint", "GET", "/health", "", fiber.StatusOK},
+		{"missing credentials", "GET", "/api/system/stats", "", fiber.StatusUnauthorized},
+		{"anonymous catalog read", "GET", "/api/videos", "", fiber.StatusOK},
+		{"anonymous tus discovery", "OPTIONS", "/upload/resumable/movies", "", fiber.StatusOK},
+		{"anonymous tus creation", "POST", "/upload/resumable/movies", "", fiber.StatusUnauthorized},
+		{"invalid credentials on catalog read", "GET", "/api/videos", basic("viewer", "nope"), fiber.StatusUnauthorized},
+		{"legacy plain header", "GET", "/api/system/stats", "Basic viewer:viewer-password", fiber.StatusUnauthorized},
+		{"wrong password", "GET", "/api/system/stats", basic("viewer", "nope"), fiber.StatusUnauthorized},
+		{"viewer reads", "GET", "/api/system/stats", basic("viewer", "viewer-password"), fiber.StatusOK},
+		{"viewer cannot upload", "POST", "/upload/movies/avatar", basic("viewer", "viewer-password"), fiber.StatusForbidden},
+		{"uploader uploads", "POST", "/upload/movies/avatar", basic("uploader", "uploader-password"), fiber.StatusOK},
+		{"uploader cannot schedule", "GET", "/api/scheduler/status", basic("uploader", "uploader-password"), fiber.StatusForbidden},
+		{"admin schedules", "GET", "/api/scheduler/status", basic("admin", "admin-password"), fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := authStatus(t, app, tt.method, tt.path, tt.authorization); status != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	app, auth, _ := newAuthTestApp(t, "jwt")
+
+	tokens, err := auth.Login("uploader", "uploader-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := "Bearer " + tokens.AccessToken
+
+	if status := authStatus(t, app, "GET", "/api/system/stats", ""); status != fiber.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", status)
+	}
+	if status := authStatus(t, app, "GET", "/api/videos", "Bearer garbage"); status != fiber.StatusUnauthorized {
+		t.Errorf("Expected 401 with invalid token, got %d", status)
+	}
+	if status := authStatus(t, app, "GET", "/api/system/stats", "Bearer "+tokens.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("Expected 401 with refresh token, got %d", status)
+	}
+	if status := authStatus(t, app, "POST", "/upload/movies/avatar", bearer); status != fiber.StatusOK {
+		t.Errorf("Expected uploader token to upload, got %d", status)
+	}
+	if status := authStatus(t, app, "GET", "/api/scheduler/status", bearer); status != fiber.StatusForbidden {
+		t.Errorf("Expected 403 for uploader on scheduler, got %d", status)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	app, _, keys := newAuthTestApp(t, "api_key")
+
+	_, readKey, err := keys.Create(services.APIKeySpec{Name: "reader", Scopes: []string{services.RightRead}}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadRecord, uploadKey, err := keys.Create(services.APIKeySpec{Name: "ingest", Scopes: []string{services.RightUpload}}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, adminKey, err := keys.Create(services.APIKeySpec{Name: "ops", Scopes: []string{services.ScopeAdmin}}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := func(method, path, key string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		key          string
+		expected     int
+	}{
+		{"missing key", "GET", "/api/system/stats", "", fiber.StatusUnauthorized},
+		{"unknown key", "GET", "/api/system/stats", "ssk_unknown", fiber.StatusUnauthorized},
+		{"read key reads", "GET", "/api/system/stats", readKey, fiber.StatusOK},
+		{"read key cannot upload", "POST", "/upload/movies/avatar", readKey, fiber.StatusForbidden},
+		{"upload key uploads", "POST", "/upload/movies/avatar", uploadKey, fiber.StatusOK},
+		{"upload key cannot schedule", "GET", "/api/scheduler/status", uploadKey, fiber.StatusForbidden},
+		{"admin key schedules", "GET", "/api/scheduler/status", adminKey, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status(tt.method, tt.path, tt.key); got != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := keys.Revoke(uploadRecord.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := status("POST", "/upload/movies/avatar", uploadKey); got != fiber.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be rejected, got %d", got)
+	}
+}
+
+func TestAPIKeyAlongsideJWT(t *testing.T) {
+	app, _, keys := newAuthTestApp(t, "jwt")
+
+	_, key, err := keys.Create(services.APIKeySpec{Name: "reader", Scopes: []string{services.RightRead}}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/system/stats", nil)
+	req.Header.Set("X-API-Key", key)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected API key to authenticate in jwt mode, got %d", resp.StatusCode)
+	}
+}
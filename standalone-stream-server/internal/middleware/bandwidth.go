@@ -0,0 +1,278 @@
+package middleware
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	localsBandwidthShaper = "bandwidth_shaper"
+	localsStreamBitrate   = "stream_bitrate"
+
+	// shapedChunkSize bounds each read so concurrent streams interleave instead of one stream draining a bucket
+	shapedChunkSize = 64 * 1024
+
+	// idleClientTTL is how long an idle client's bucket is kept so reconnecting does not reset its budget
+	idleClientTTL = time.Minute
+)
+
+// byteBucket is a token bucket measured in bytes that refills continuously.
+// Reservations may drive the balance negative; the reserving reader then waits until the
+// debt is repaid, so concurrent readers share the rate in the order they arrive.
+type byteBucket struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newByteBucket creates a bucket that starts full; a rate of 0 returns nil (unlimited)
+func newByteBucket(rate, burst float64) *byteBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < shapedChunkSize {
+		burst = shapedChunkSize
+	}
+	return &byteBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes n bytes from the bucket and returns how long the caller must wait before sending them
+func (b *byteBucket) reserve(n int, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// clientState tracks the shared bucket and counters of one client
+type clientState struct {
+	bucket     *byteBucket
+	streams    int
+	bytesSent  int64
+	lastActive time.Time
+}
+
+// BandwidthShaper throttles streaming response bodies against a global egress cap,
+// a per-client cap (client IP, or API key) and a per-stream cap derived from the video bitrate
+type BandwidthShaper struct {
+	config models.BandwidthConfig
+	global *byteBucket
+
+	mu        sync.Mutex
+	clients   map[string]*clientState
+	lastPrune time.Time
+
+	activeStreams atomic.Int64
+	totalStreams  atomic.Int64
+	bytesSent     atomic.Int64
+	throttled     atomic.Int64 // nanoseconds spent waiting
+}
+
+// NewBandwidthShaper creates a shaper; it returns nil when shaping is disabled
+func NewBandwidthShaper(config models.BandwidthConfig) *BandwidthShaper {
+	if !config.Enabled {
+		return nil
+	}
+	return &BandwidthShaper{
+		config:    config,
+		global:    newByteBucket(float64(config.GlobalLimit), float64(config.GlobalLimit)),
+		clients:   make(map[string]*clientState),
+		lastPrune: time.Now(),
+	}
+}
+
+// ShapeStreams returns a handler that enables shaping for the streaming routes it is mounted on
+func ShapeStreams(shaper *BandwidthShaper) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if shaper != nil {
+			c.Locals(localsBandwidthShaper, shaper)
+		}
+		return c.Next()
+	}
+}
+
+// SetStreamBitrate records the bitrate (bps) of the video being streamed, used for the per-stream cap
+func SetStreamBitrate(c *fiber.Ctx, bitrate int64) {
+	c.Locals(localsStreamBitrate, bitrate)
+}
+
+// ShapedBody wraps a response body with the request's bandwidth limits and makes it release the
+// stream's connection slot when closed (see ReleaseAfterBody); without either it returns body unchanged
+func ShapedBody(c *fiber.Ctx, body io.ReadCloser) io.ReadCloser {
+	body = holdUntilClosed(c, body)
+	shaper, ok := c.Locals(localsBandwidthShaper).(*BandwidthShaper)
+	if !ok || shaper == nil {
+		return body
+	}
+	bitrate, _ := c.Locals(localsStreamBitrate).(int64)
+	return shaper.Wrap(body, clientKey(c), bitrate)
+}
+
+// clientKey identifies the client for the per-client cap: API keys are limited per key, everyone else per IP
+func clientKey(c *fiber.Ctx) string {
+	if principal := PrincipalFromContext(c); principal != nil && principal.KeyID != "" {
+		return "key:" + principal.KeyID
+	}
+	return "ip:" + c.IP()
+}
+
+// Wrap returns a reader that paces body; bitrate is the video bitrate in bps (0 if unknown)
+func (bs *BandwidthShaper) Wrap(body io.ReadCloser, client string, bitrate int64) io.ReadCloser {
+	sb := &shapedBody{
+		body:   body,
+		shaper: bs,
+		client: bs.acquireClient(client),
+	}
+
+	// Per-stream cap: realtime_factor x bitrate after an initial burst of initial_burst worth of playback
+	if bitrate > 0 && bs.config.RealtimeFactor > 0 {
+		bytesPerSecond := float64(bitrate) / 8
+		sb.stream = newByteBucket(bytesPerSecond*bs.config.RealtimeFactor, bytesPerSecond*bs.config.InitialBurst.Seconds())
+	}
+
+	bs.activeStreams.Add(1)
+	bs.totalStreams.Add(1)
+	return sb
+}
+
+// acquireClient returns the state for a client and counts a new stream against it
+func (bs *BandwidthShaper) acquireClient(key string) *clientState {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(bs.lastPrune) > idleClientTTL {
+		for k, state := range bs.clients {
+			if state.streams == 0 && now.Sub(state.lastActive) > idleClientTTL {
+				delete(bs.clients, k)
+			}
+		}
+		bs.lastPrune = now
+	}
+
+	state, ok := bs.clients[key]
+	if !ok {
+		limit := float64(bs.config.PerClientLimit)
+		state = &clientState{bucket: newByteBucket(limit, limit)}
+		bs.clients[key] = state
+	}
+	state.streams++
+	state.lastActive = now
+	return state
+}
+
+// releaseClient ends a stream of a client
+func (bs *BandwidthShaper) releaseClient(state *clientState) {
+	bs.mu.Lock()
+	state.streams--
+	state.lastActive = time.Now()
+	bs.mu.Unlock()
+
+	bs.activeStreams.Add(-1)
+}
+
+// Stats returns shaping configuration, totals and the currently active clients
+func (bs *BandwidthShaper) Stats() map[string]interface{} {
+	if bs == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+
+	bs.mu.Lock()
+	clients := make([]map[string]interface{}, 0, len(bs.clients))
+	for key, state := range bs.clients {
+		if state.streams == 0 {
+			continue
+		}
+		clients = append(clients, map[string]interface{}{
+			"client":         key,
+			"active_streams": state.streams,
+			"bytes_sent":     state.bytesSent,
+		})
+	}
+	bs.mu.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i]["bytes_sent"].(int64) > clients[j]["bytes_sent"].(int64)
+	})
+
+	return map[string]interface{}{
+		"enabled":               true,
+		"global_limit":          bs.config.GlobalLimit,
+		"per_client_limit":      bs.config.PerClientLimit,
+		"realtime_factor":       bs.config.RealtimeFactor,
+		"initial_burst_seconds": bs.config.InitialBurst.Seconds(),
+		"active_streams":        bs.activeStreams.Load(),
+		"total_streams":         bs.totalStreams.Load(),
+		"bytes_sent":            bs.bytesSent.Load(),
+		"throttled_seconds":     time.Duration(bs.throttled.Load()).Seconds(),
+		"clients":               clients,
+	}
+}
+
+// shapedBody is a response body whose reads are paced by the global, client and stream buckets
+type shapedBody struct {
+	body   io.ReadCloser
+	shaper *BandwidthShaper
+	client *clientState
+	stream *byteBucket
+	once   sync.Once
+}
+
+func (sb *shapedBody) Read(p []byte) (int, error) {
+	if len(p) > shapedChunkSize {
+		p = p[:shapedChunkSize]
+	}
+
+	n, err := sb.body.Read(p)
+	if n > 0 {
+		now := time.Now()
+		delay := sb.shaper.global.reserve(n, now)
+		if d := sb.client.bucket.reserve(n, now); d > delay {
+			delay = d
+		}
+		if d := sb.stream.reserve(n, now); d > delay {
+			delay = d
+		}
+
+		sb.shaper.bytesSent.Add(int64(n))
+		sb.shaper.mu.Lock()
+		sb.client.bytesSent += int64(n)
+		sb.shaper.mu.Unlock()
+
+		if delay > 0 {
+			sb.shaper.throttled.Add(int64(delay))
+			time.Sleep(delay)
+		}
+	}
+	return n, err
+}
+
+func (sb *shapedBody) Close() error {
+	sb.once.Do(func() { sb.shaper.releaseClient(sb.client) })
+	return sb.body.Close()
+}
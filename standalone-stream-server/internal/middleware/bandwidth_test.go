@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestByteBucket_Reserve(t *testing.T) {
+	now := time.Now()
+	bucket := &byteBucket{rate: 100_000, burst: 100_000, tokens: 100_000, last: now}
+
+	if delay := bucket.reserve(100_000, now); delay != 0 {
+		t.Errorf("Expected burst to be sent immediately, got delay %v", delay)
+	}
+	if delay := bucket.reserve(50_000, now); delay != 500*time.Millisecond {
+		t.Errorf("Expected 500ms delay for 50KB at 100KB/s, got %v", delay)
+	}
+	// One second later the debt is repaid and the bucket is half full
+	if delay := bucket.reserve(50_000, now.Add(time.Second)); delay != 0 {
+		t.Errorf("Expected refilled bucket to allow 50KB, got delay %v", delay)
+	}
+
+	var unlimited *byteBucket
+	if delay := unlimited.reserve(1<<30, now); delay != 0 {
+		t.Errorf("A nil bucket must not throttle, got %v", delay)
+	}
+}
+
+func TestBandwidthShaper_PerStreamRate(t *testing.T) {
+	shaper := NewBandwidthShaper(models.BandwidthConfig{Enabled: true, RealtimeFactor: 1})
+
+	// An 8 Mbps video is 1 MB/s; without an initial burst only the first chunk is free
+	body := shaper.Wrap(io.NopCloser(bytes.NewReader(make([]byte, shapedChunkSize+256*1024))), "ip:10.0.0.1", 8_000_000)
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected 256KB at 1MB/s to take ~250ms, took %v", elapsed)
+	}
+
+	stats := shaper.Stats()
+	if stats["bytes_sent"].(int64) != shapedChunkSize+256*1024 || stats["active_streams"].(int64) != 0 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	if stats["throttled_seconds"].(float64) <= 0 {
+		t.Error("Expected throttled time to be recorded")
+	}
+}
+
+func TestBandwidthShaper_ClientsShareBucket(t *testing.T) {
+	shaper := NewBandwidthShaper(models.BandwidthConfig{Enabled: true, PerClientLimit: 1_000_000})
+
+	first := shaper.Wrap(io.NopCloser(bytes.NewReader(nil)), "key:ingest", 0)
+	second := shaper.Wrap(io.NopCloser(bytes.NewReader(nil)), "key:ingest", 0)
+	other := shaper.Wrap(io.NopCloser(bytes.NewReader(nil)), "ip:10.0.0.2", 0)
+
+	if first.(*shapedBody).client != second.(*shapedBody).client {
+		t.Error("Streams of the same client must share a bucket")
+	}
+	if first.(*shapedBody).client == other.(*shapedBody).client {
+		t.Error("Different clients must not share a bucket")
+	}
+	if first.(*shapedBody).stream != nil {
+		t.Error("Streams without a known bitrate must not have a per-stream cap")
+	}
+
+	clients := shaper.Stats()["clients"].([]map[string]interface{})
+	if len(clients) != 2 {
+		t.Fatalf("Expected 2 active clients, got %v", clients)
+	}
+
+	first.Close()
+	first.Close() // closing twice releases once
+	second.Close()
+	other.Close()
+	if active := shaper.Stats()["active_streams"].(int64); active != 0 {
+		t.Errorf("Expected no active streams after close, got %d", active)
+	}
+}
+
+func TestShapeStreams_Disabled(t *testing.T) {
+	if shaper := NewBandwidthShaper(models.BandwidthConfig{}); shaper != nil {
+		t.Fatal("Expected nil shaper when shaping is disabled")
+	}
+
+	app := fiber.New()
+	app.Get("/stream/:id", ShapeStreams(nil), func(c *fiber.Ctx) error {
+		body := io.NopCloser(bytes.NewReader([]byte("video")))
+		if ShapedBody(c, body) != body {
+			t.Error("Body must be passed through when shaping is disabled")
+		}
+		return c.SendString("ok")
+	})
+
+	if _, err := app.Test(httptest.NewRequest("GET", "/stream/1", nil)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -85,8 +85,11 @@ func (tb *TokenBucket) refill() {
 
 // StreamingFlowController manages flow control for video streaming
 type StreamingFlowController struct {
-	tokenBucket   *TokenBucket
+	backend       LimiterBackend // holds the token bucket and connection slots
+	tokenCapacity int
+	tokensPerSecond int
 	connectionLimiter *ConnectionLimiter
+	queue         *admissionQueue // nil when requests are rejected immediately
 	mu            sync.RWMutex
 	stats         FlowControlStats
 }
@@ -97,49 +100,72 @@ type FlowControlStats struct {
 	RateLimited      int64 `json:"rate_limited"`
 	ConnectionLimited int64 `json:"connection_limited"`
 	Accepted         int64 `json:"accepted"`
+	Queued           int64 `json:"queued"`         // accepted after waiting in the admission queue
+	QueueFull        int64 `json:"queue_full"`     // rejected because the queue was full
+	QueueTimeouts    int64 `json:"queue_timeouts"` // rejected after waiting max_wait
 }
 
-// NewStreamingFlowController creates a new flow controller
+// NewStreamingFlowController creates a flow controller that limits this process only
 func NewStreamingFlowController(maxConnections, tokensPerSecond int) *StreamingFlowController {
+	return NewSharedStreamingFlowController(NewMemoryLimiterBackend(), maxConnections, tokensPerSecond)
+}
+
+// NewSharedStreamingFlowController creates a flow controller whose tokens and connection slots live in
+// backend; with a shared backend the limits apply across all replicas
+func NewSharedStreamingFlowController(backend LimiterBackend, maxConnections, tokensPerSecond int) *StreamingFlowController {
 	return &StreamingFlowController{
-		tokenBucket:      NewTokenBucket(tokensPerSecond*2, tokensPerSecond, time.Second),
-		connectionLimiter: NewConnectionLimiter(maxConnections),
+		backend:          backend,
+		tokenCapacity:    tokensPerSecond*2,
+		tokensPerSecond:  tokensPerSecond,
+		connectionLimiter: NewSharedConnectionLimiter(backend, "stream:connections", maxConnections),
 		stats:            FlowControlStats{},
 	}
 }
 
-// CheckAccess checks if a request can proceed
-func (sfc *StreamingFlowController) CheckAccess() (bool, string) {
+// takeToken takes a stream token; requests are allowed when the backend is unavailable
+func (sfc *StreamingFlowController) takeToken() bool {
+	ok, err := sfc.backend.TakeToken("stream:tokens", sfc.tokenCapacity, sfc.tokensPerSecond)
+	if err != nil {
+		backendError(sfc.backend, "take stream token", err)
+		return true
+	}
+	return ok
+}
+
+// CheckAccess checks if a request can proceed; the returned lease releases its connection slot
+func (sfc *StreamingFlowController) CheckAccess() (bool, string, string) {
 	sfc.mu.Lock()
 	sfc.stats.TotalRequests++
 	sfc.mu.Unlock()
 	
 	// Check rate limiting first (cheaper check)
-	if !sfc.tokenBucket.TakeToken() {
+	if !sfc.takeToken() {
 		sfc.mu.Lock()
 		sfc.stats.RateLimited++
 		sfc.mu.Unlock()
-		return false, "rate_limited"
+		return false, "rate_limited", ""
 	}
 	
 	// Check connection limiting
-	if !sfc.connectionLimiter.Acquire() {
+	lease, ok := sfc.connectionLimiter.Acquire()
+	if !ok {
 		sfc.mu.Lock()
 		sfc.stats.ConnectionLimited++
 		sfc.mu.Unlock()
-		return false, "connection_limited"
+		return false, "connection_limited", ""
 	}
 	
 	sfc.mu.Lock()
 	sfc.stats.Accepted++
 	sfc.mu.Unlock()
 	
-	return true, "accepted"
+	return true, "accepted", lease
 }
 
-// ReleaseConnection releases a connection slot
-func (sfc *StreamingFlowController) ReleaseConnection() {
-	sfc.connectionLimiter.Release()
+// ReleaseConnection releases the connection slot held by lease
+func (sfc *StreamingFlowController) ReleaseConnection(lease string) {
+	sfc.connectionLimiter.Release(lease)
+	sfc.queue.notifyRelease()
 }
 
 // GetStats returns current flow control statistics
@@ -152,6 +178,9 @@ func (sfc *StreamingFlowController) GetStats() FlowControlStats {
 		RateLimited:       sfc.stats.RateLimited,
 		ConnectionLimited: sfc.stats.ConnectionLimited,
 		Accepted:          sfc.stats.Accepted,
+		Queued:            sfc.stats.Queued,
+		QueueFull:         sfc.stats.QueueFull,
+		QueueTimeouts:     sfc.stats.QueueTimeouts,
 	}
 }
 
@@ -161,13 +190,25 @@ func (sfc *StreamingFlowController) GetDetailedStats() map[string]interface{} {
 	
 	return map[string]interface{}{
 		"requests": stats,
+		"backend": sfc.backend.Name(),
 		"tokens": map[string]interface{}{
-			"available": sfc.tokenBucket.AvailableTokens(),
-			"capacity":  sfc.tokenBucket.capacity,
+			"available": sfc.availableTokens(),
+			"capacity":  sfc.tokenCapacity,
 		},
 		"connections": map[string]interface{}{
 			"active":    sfc.connectionLimiter.GetActiveConnections(),
 			"max":       sfc.connectionLimiter.GetMaxConnections(),
 		},
+		"queue": sfc.queueStats(),
+	}
+}
+
+// availableTokens returns the number of stream tokens left, or 0 when the backend is unavailable
+func (sfc *StreamingFlowController) availableTokens() int {
+	tokens, err := sfc.backend.AvailableTokens("stream:tokens", sfc.tokenCapacity, sfc.tokensPerSecond)
+	if err != nil {
+		backendError(sfc.backend, "count stream tokens", err)
+		return 0
 	}
+	return tokens
 }
\ No newline at end of file
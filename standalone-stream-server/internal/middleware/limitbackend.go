@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/utils"
+)
+
+// ErrUnknownLease is returned when releasing a slot that is not held, e.g. one whose lease expired
+var ErrUnknownLease = errors.New("unknown or expired lease")
+
+// LimiterBackend stores the counters behind request rate limiting and connection/stream flow
+// control. The memory backend limits a single process; a shared backend makes every replica
+// enforce the same global limits.
+type LimiterBackend interface {
+	// Allow counts a request against key and reports whether it is within limit requests per
+	// sliding window; when it is not, retryAfter is how long until a request would be allowed
+	Allow(key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+
+	// TakeToken takes a token from the bucket key, which holds up to capacity tokens refilled at rate per second
+	TakeToken(key string, capacity, rate int) (bool, error)
+
+	// AvailableTokens returns the number of tokens left in the bucket key
+	AvailableTokens(key string, capacity, rate int) (int, error)
+
+	// Acquire takes one of max slots for key and returns the lease that releases it
+	Acquire(key string, max int) (lease string, ok bool, err error)
+
+	// Release frees a slot taken by Acquire
+	Release(key, lease string) error
+
+	// Active returns the number of slots held for key
+	Active(key string) (int, error)
+
+	// Name identifies the backend in statistics and metrics
+	Name() string
+
+	// Close releases the backend's resources
+	Close() error
+}
+
+// NewLimiterBackend creates the backend selected by the configuration
+func NewLimiterBackend(config models.LimiterConfig) (LimiterBackend, error) {
+	switch config.Backend {
+	case "", "memory":
+		return NewMemoryLimiterBackend(), nil
+	case "redis":
+		return NewRedisLimiterBackend(config)
+	default:
+		return nil, fmt.Errorf("unknown limiter backend: %s", config.Backend)
+	}
+}
+
+// backendError records a failed backend operation. Callers fail open: an unavailable backend must
+// not take the whole server down with it.
+func backendError(backend LimiterBackend, operation string, err error) {
+	utils.RecordLimiterBackendError(backend.Name(), operation)
+	log.Printf("Warning: limiter backend %s failed to %s: %v", backend.Name(), operation, err)
+}
+
+// slidingWindowRetry returns how long until one more request fits into a sliding window whose
+// previous and current fixed windows hold prev and cur requests, elapsed into the current window
+func slidingWindowRetry(prev, cur int64, limit int, elapsed, window time.Duration) time.Duration {
+	budget := float64(limit - 1)
+	w := float64(window)
+
+	var wait float64
+	if float64(cur) <= budget && prev > 0 {
+		// The request fits once enough of the previous window has slid out
+		wait = w*(1-(budget-float64(cur))/float64(prev)) - float64(elapsed)
+	} else if cur > 0 {
+		// The current window alone is over the limit: wait for it to become the previous window and slide out
+		wait = w - float64(elapsed) + w*(1-budget/float64(cur))
+	}
+
+	retry := time.Duration(math.Ceil(wait/float64(time.Second))) * time.Second
+	if retry < time.Second {
+		retry = time.Second
+	}
+	return retry
+}
+
+// windowCounter holds the request counts of the current and previous fixed windows of a key
+type windowCounter struct {
+	window   time.Duration
+	index    int64
+	current  int64
+	previous int64
+}
+
+// MemoryLimiterBackend keeps all counters in process memory
+type MemoryLimiterBackend struct {
+	mu        sync.Mutex
+	windows   map[string]*windowCounter
+	buckets   map[string]*TokenBucket
+	slots     map[string]map[string]struct{}
+	nextLease uint64
+	lastPrune time.Time
+}
+
+// NewMemoryLimiterBackend creates an in-process backend
+func NewMemoryLimiterBackend() *MemoryLimiterBackend {
+	return &MemoryLimiterBackend{
+		windows:   make(map[string]*windowCounter),
+		buckets:   make(map[string]*TokenBucket),
+		slots:     make(map[string]map[string]struct{}),
+		lastPrune: time.Now(),
+	}
+}
+
+// Name implements LimiterBackend
+func (m *MemoryLimiterBackend) Name() string {
+	return "memory"
+}
+
+// Allow implements LimiterBackend
+func (m *MemoryLimiterBackend) Allow(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	if window <= 0 {
+		return true, 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.pruneWindows(now)
+
+	index := now.UnixNano() / int64(window)
+	elapsed := time.Duration(now.UnixNano() - index*int64(window))
+
+	wc, ok := m.windows[key]
+	if !ok {
+		wc = &windowCounter{window: window, index: index}
+		m.windows[key] = wc
+	}
+	switch {
+	case index == wc.index+1:
+		wc.previous, wc.current = wc.current, 0
+	case index != wc.index:
+		wc.previous, wc.current = 0, 0
+	}
+	wc.index = index
+
+	estimate := float64(wc.previous)*float64(window-elapsed)/float64(window) + float64(wc.current)
+	if estimate+1 > float64(limit) {
+		return false, slidingWindowRetry(wc.previous, wc.current, limit, elapsed, window), nil
+	}
+	wc.current++
+	return true, 0, nil
+}
+
+// pruneWindows drops counters that no longer affect any decision, so one-off clients do not accumulate
+func (m *MemoryLimiterBackend) pruneWindows(now time.Time) {
+	if now.Sub(m.lastPrune) < time.Minute {
+		return
+	}
+	for key, wc := range m.windows {
+		if now.UnixNano()/int64(wc.window) > wc.index+1 {
+			delete(m.windows, key)
+		}
+	}
+	m.lastPrune = now
+}
+
+// bucket returns the token bucket for key, creating a full one on first use
+func (m *MemoryLimiterBackend) bucket(key string, capacity, rate int) *TokenBucket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tb, ok := m.buckets[key]
+	if !ok {
+		tb = NewTokenBucket(capacity, rate, time.Second)
+		m.buckets[key] = tb
+	}
+	return tb
+}
+
+// TakeToken implements LimiterBackend
+func (m *MemoryLimiterBackend) TakeToken(key string, capacity, rate int) (bool, error) {
+	return m.bucket(key, capacity, rate).TakeToken(), nil
+}
+
+// AvailableTokens implements LimiterBackend
+func (m *MemoryLimiterBackend) AvailableTokens(key string, capacity, rate int) (int, error) {
+	return m.bucket(key, capacity, rate).AvailableTokens(), nil
+}
+
+// Acquire implements LimiterBackend
+func (m *MemoryLimiterBackend) Acquire(key string, max int) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.slots[key]
+	if !ok {
+		held = make(map[string]struct{})
+		m.slots[key] = held
+	}
+	if len(held) >= max {
+		return "", false, nil
+	}
+
+	m.nextLease++
+	lease := strconv.FormatUint(m.nextLease, 10)
+	held[lease] = struct{}{}
+	return lease, true, nil
+}
+
+// Release implements LimiterBackend
+func (m *MemoryLimiterBackend) Release(key, lease string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.slots[key][lease]; !ok {
+		return ErrUnknownLease
+	}
+	delete(m.slots[key], lease)
+	return nil
+}
+
+// Active implements LimiterBackend
+func (m *MemoryLimiterBackend) Active(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.slots[key]), nil
+}
+
+// Close implements LimiterBackend
+func (m *MemoryLimiterBackend) Close() error {
+	return nil
+}
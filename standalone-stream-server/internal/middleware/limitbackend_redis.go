@@ -0,0 +1,324 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisOpTimeout bounds every backend call so a slow Redis delays requests by at most this much
+	redisOpTimeout = 500 * time.Millisecond
+
+	// defaultLeaseTTL is used when no lease TTL is configured
+	defaultLeaseTTL = 10 * time.Minute
+)
+
+// All scripts read the clock from the Redis server, so replicas with skewed clocks still agree on windows.
+
+// slidingWindowScript counts a request in the current fixed window unless the weighted sum of the
+// previous and current windows is already at the limit. Returns {allowed, previous, current, elapsed ms}.
+var slidingWindowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local index = math.floor(now / window)
+local elapsed = now - index * window
+local curKey = KEYS[1] .. ':' .. index
+local prev = tonumber(redis.call('GET', KEYS[1] .. ':' .. (index - 1)) or '0')
+local cur = tonumber(redis.call('GET', curKey) or '0')
+if prev * (window - elapsed) / window + cur + 1 > limit then
+  return {0, prev, cur, elapsed}
+end
+cur = redis.call('INCR', curKey)
+redis.call('PEXPIRE', curKey, window * 2)
+return {1, prev, cur, elapsed}
+`)
+
+// tokenBucketScript refills the bucket for the time since it was last used and optionally takes a
+// token. Returns {taken, remaining tokens}.
+var tokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate / 1000)
+local taken = 0
+if ARGV[3] == '1' and tokens >= 1 then
+  tokens = tokens - 1
+  taken = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+if rate > 0 then
+  redis.call('PEXPIRE', KEYS[1], math.ceil(capacity * 1000 / rate) + 1000)
+end
+return {taken, math.floor(tokens)}
+`)
+
+// acquireScript adds a lease to the slot set (scored by expiry) unless max unexpired leases are held
+var acquireScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], tonumber(ARGV[2]))
+return 1
+`)
+
+// activeScript drops expired leases and counts the rest
+var activeScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// renewScript extends the expiry of leases that are still held
+var renewScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+for i = 2, #ARGV do
+  redis.call('ZADD', KEYS[1], 'XX', now + tonumber(ARGV[1]), ARGV[i])
+end
+redis.call('PEXPIRE', KEYS[1], tonumber(ARGV[1]))
+return 1
+`)
+
+// RedisLimiterBackend keeps counters in a Redis-protocol server shared by all replicas.
+// Connection slots are leases that expire after the lease TTL, so slots held by a crashed replica
+// are reclaimed; leases held by this process are renewed in the background while in use.
+type RedisLimiterBackend struct {
+	client   *redis.Client
+	prefix   string
+	leaseTTL time.Duration
+
+	mu       sync.Mutex
+	held     map[string]map[string]struct{} // key -> leases held by this process
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRedisLimiterBackend connects to the configured server and starts lease renewal
+func NewRedisLimiterBackend(config models.LimiterConfig) (*RedisLimiterBackend, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Redis.Addr,
+		Password: config.Redis.Password,
+		DB:       config.Redis.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", config.Redis.Addr, err)
+	}
+
+	leaseTTL := config.LeaseTTL
+	if leaseTTL <= 0 {
+		leaseTTL = defaultLeaseTTL
+	}
+
+	rb := &RedisLimiterBackend{
+		client:   client,
+		prefix:   config.Redis.KeyPrefix,
+		leaseTTL: leaseTTL,
+		held:     make(map[string]map[string]struct{}),
+		stopChan: make(chan struct{}),
+	}
+	go rb.renewLoop()
+	return rb, nil
+}
+
+// Name implements LimiterBackend
+func (rb *RedisLimiterBackend) Name() string {
+	return "redis"
+}
+
+// context returns a context bounded by the operation timeout
+func (rb *RedisLimiterBackend) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), redisOpTimeout)
+}
+
+// Allow implements LimiterBackend
+func (rb *RedisLimiterBackend) Allow(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	if window <= 0 {
+		return true, 0, nil
+	}
+
+	ctx, cancel := rb.context()
+	defer cancel()
+
+	result, err := slidingWindowScript.Run(ctx, rb.client, []string{rb.prefix + "rate:" + key}, limit, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 4 {
+		return false, 0, fmt.Errorf("unexpected sliding window result: %v", result)
+	}
+	if result[0] == 1 {
+		return true, 0, nil
+	}
+	elapsed := time.Duration(result[3]) * time.Millisecond
+	return false, slidingWindowRetry(result[1], result[2], limit, elapsed, window), nil
+}
+
+// bucket runs the token bucket script, taking a token when take is set
+func (rb *RedisLimiterBackend) bucket(key string, capacity, rate int, take bool) (bool, int, error) {
+	ctx, cancel := rb.context()
+	defer cancel()
+
+	flag := "0"
+	if take {
+		flag = "1"
+	}
+	result, err := tokenBucketScript.Run(ctx, rb.client, []string{rb.prefix + "tokens:" + key}, capacity, rate, flag).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket result: %v", result)
+	}
+	return result[0] == 1, int(result[1]), nil
+}
+
+// TakeToken implements LimiterBackend
+func (rb *RedisLimiterBackend) TakeToken(key string, capacity, rate int) (bool, error) {
+	taken, _, err := rb.bucket(key, capacity, rate, true)
+	return taken, err
+}
+
+// AvailableTokens implements LimiterBackend
+func (rb *RedisLimiterBackend) AvailableTokens(key string, capacity, rate int) (int, error) {
+	_, tokens, err := rb.bucket(key, capacity, rate, false)
+	return tokens, err
+}
+
+// Acquire implements LimiterBackend
+func (rb *RedisLimiterBackend) Acquire(key string, max int) (string, bool, error) {
+	lease, err := newLease()
+	if err != nil {
+		return "", false, err
+	}
+
+	ctx, cancel := rb.context()
+	defer cancel()
+
+	ok, err := acquireScript.Run(ctx, rb.client, []string{rb.prefix + "slots:" + key}, max, rb.leaseTTL.Milliseconds(), lease).Int()
+	if err != nil || ok != 1 {
+		return "", false, err
+	}
+
+	rb.mu.Lock()
+	if rb.held[key] == nil {
+		rb.held[key] = make(map[string]struct{})
+	}
+	rb.held[key][lease] = struct{}{}
+	rb.mu.Unlock()
+
+	return lease, true, nil
+}
+
+// Release implements LimiterBackend
+func (rb *RedisLimiterBackend) Release(key, lease string) error {
+	rb.mu.Lock()
+	delete(rb.held[key], lease)
+	rb.mu.Unlock()
+
+	ctx, cancel := rb.context()
+	defer cancel()
+
+	removed, err := rb.client.ZRem(ctx, rb.prefix+"slots:"+key, lease).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrUnknownLease
+	}
+	return nil
+}
+
+// Active implements LimiterBackend
+func (rb *RedisLimiterBackend) Active(key string) (int, error) {
+	ctx, cancel := rb.context()
+	defer cancel()
+
+	return activeScript.Run(ctx, rb.client, []string{rb.prefix + "slots:" + key}).Int()
+}
+
+// renewLoop periodically extends the leases held by this process so long streams keep their slots
+func (rb *RedisLimiterBackend) renewLoop() {
+	ticker := time.NewTicker(rb.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := rb.renewLeases(); err != nil {
+				backendError(rb, "renew leases", err)
+			}
+		case <-rb.stopChan:
+			return
+		}
+	}
+}
+
+// renewLeases extends every lease held by this process
+func (rb *RedisLimiterBackend) renewLeases() error {
+	rb.mu.Lock()
+	held := make(map[string][]interface{}, len(rb.held))
+	for key, leases := range rb.held {
+		if len(leases) == 0 {
+			delete(rb.held, key)
+			continue
+		}
+		args := []interface{}{rb.leaseTTL.Milliseconds()}
+		for lease := range leases {
+			args = append(args, lease)
+		}
+		held[key] = args
+	}
+	rb.mu.Unlock()
+
+	for key, args := range held {
+		ctx, cancel := rb.context()
+		err := renewScript.Run(ctx, rb.client, []string{rb.prefix + "slots:" + key}, args...).Err()
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements LimiterBackend
+func (rb *RedisLimiterBackend) Close() error {
+	rb.stopOnce.Do(func() { close(rb.stopChan) })
+	return rb.client.Close()
+}
+
+// newLease returns a lease ID that is unique across replicas
+func newLease() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newTestRedisBackend starts a miniredis server and returns a backend connected to it
+func newTestRedisBackend(t *testing.T, mr *miniredis.Miniredis) *RedisLimiterBackend {
+	t.Helper()
+	backend, err := NewRedisLimiterBackend(models.LimiterConfig{
+		Backend:  "redis",
+		Redis:    models.RedisLimiterConfig{Addr: mr.Addr(), KeyPrefix: "test:"},
+		LeaseTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+// testBackends returns a memory backend and a Redis backend for behaviour both must share
+func testBackends(t *testing.T) map[string]LimiterBackend {
+	return map[string]LimiterBackend{
+		"memory": NewMemoryLimiterBackend(),
+		"redis":  newTestRedisBackend(t, miniredis.RunT(t)),
+	}
+}
+
+func TestLimiterBackend_Allow(t *testing.T) {
+	for name, backend := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				if ok, _, err := backend.Allow("ip:10.0.0.1", 3, time.Minute); err != nil || !ok {
+					t.Fatalf("Expected request %d to be allowed, got %v %v", i+1, ok, err)
+				}
+			}
+
+			ok, retryAfter, err := backend.Allow("ip:10.0.0.1", 3, time.Minute)
+			if err != nil || ok {
+				t.Fatalf("Expected fourth request to be limited, got %v %v", ok, err)
+			}
+			if retryAfter < time.Second || retryAfter > 2*time.Minute {
+				t.Errorf("Unexpected retry after: %v", retryAfter)
+			}
+
+			if ok, _, _ := backend.Allow("ip:10.0.0.2", 3, time.Minute); !ok {
+				t.Error("Other clients must have their own window")
+			}
+		})
+	}
+}
+
+func TestLimiterBackend_Slots(t *testing.T) {
+	for name, backend := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			first, ok, err := backend.Acquire("connections", 2)
+			if err != nil || !ok {
+				t.Fatalf("Expected slot, got %v %v", ok, err)
+			}
+			second, ok, _ := backend.Acquire("connections", 2)
+			if !ok || second == first {
+				t.Fatalf("Expected a second, distinct lease, got %q %v", second, ok)
+			}
+			if _, ok, _ := backend.Acquire("connections", 2); ok {
+				t.Fatal("Expected acquire beyond max to fail")
+			}
+			if active, _ := backend.Active("connections"); active != 2 {
+				t.Errorf("Expected 2 active slots, got %d", active)
+			}
+
+			if err := backend.Release("connections", first); err != nil {
+				t.Fatalf("Release failed: %v", err)
+			}
+			if err := backend.Release("connections", first); err != ErrUnknownLease {
+				t.Errorf("Expected ErrUnknownLease for a double release, got %v", err)
+			}
+			if _, ok, _ := backend.Acquire("connections", 2); !ok {
+				t.Error("Expected released slot to be reusable")
+			}
+		})
+	}
+}
+
+func TestLimiterBackend_TakeToken(t *testing.T) {
+	for name, backend := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				if ok, err := backend.TakeToken("stream:tokens", 2, 1); err != nil || !ok {
+					t.Fatalf("Expected token %d, got %v %v", i+1, ok, err)
+				}
+			}
+			if ok, _ := backend.TakeToken("stream:tokens", 2, 1); ok {
+				t.Error("Expected empty bucket to refuse a token")
+			}
+			if available, _ := backend.AvailableTokens("stream:tokens", 2, 1); available != 0 {
+				t.Errorf("Expected no tokens available, got %d", available)
+			}
+		})
+	}
+}
+
+func TestRedisBackend_LeasesExpire(t *testing.T) {
+	mr := miniredis.RunT(t)
+	backend := newTestRedisBackend(t, mr)
+
+	now := time.Now()
+	mr.SetTime(now)
+	if _, ok, _ := backend.Acquire("connections", 1); !ok {
+		t.Fatal("Expected slot")
+	}
+
+	// Leases in use are renewed, so long streams keep their slot past the lease TTL
+	mr.SetTime(now.Add(50 * time.Second))
+	if err := backend.renewLeases(); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	mr.SetTime(now.Add(100 * time.Second))
+	if active, _ := backend.Active("connections"); active != 1 {
+		t.Fatalf("Expected renewed lease to be active, got %d", active)
+	}
+
+	// A replica that crashed never releases or renews its slot; the lease expires instead
+	mr.SetTime(now.Add(3 * time.Minute))
+	if active, _ := backend.Active("connections"); active != 0 {
+		t.Errorf("Expected expired lease to be dropped, got %d active", active)
+	}
+	if _, ok, _ := backend.Acquire("connections", 1); !ok {
+		t.Error("Expected expired slot to be reclaimed")
+	}
+}
+
+func TestRedisBackend_SharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	first := NewSharedStreamingFlowController(newTestRedisBackend(t, mr), 2, 1000)
+	second := NewSharedStreamingFlowController(newTestRedisBackend(t, mr), 2, 1000)
+
+	a := first.Admit(0)
+	b := second.Admit(0)
+	if !a.Allowed || !b.Allowed {
+		t.Fatalf("Expected both replicas to admit one stream, got %+v %+v", a, b)
+	}
+	if c := first.Admit(0); c.Allowed || c.Reason != "connection_limited" {
+		t.Errorf("Expected global connection limit to apply across replicas, got %+v", c)
+	}
+
+	second.ReleaseConnection(b.Lease)
+	if c := first.Admit(0); !c.Allowed {
+		t.Errorf("Expected slot released by the other replica to be usable, got %+v", c)
+	}
+}
+
+func TestRedisBackend_FailsOpen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	backend := newTestRedisBackend(t, mr)
+	limiter := NewSharedConnectionLimiter(backend, "connections", 1)
+	mr.Close()
+
+	lease, ok := limiter.Acquire()
+	if !ok || lease != "" {
+		t.Errorf("Expected an unavailable backend to admit without a lease, got %q %v", lease, ok)
+	}
+	limiter.Release(lease)
+}
+
+func TestSetupRateLimit(t *testing.T) {
+	config := &models.Config{}
+	config.Security.RateLimit.RequestsPerMin = 2
+
+	app := fiber.New()
+	setupRateLimit(app, config, NewMemoryLimiterBackend())
+	app.Get("/api/videos", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	for i, expected := range []int{200, 200, 429} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/videos", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != expected {
+			t.Errorf("Request %d: expected %d, got %d", i+1, expected, resp.StatusCode)
+		}
+		if expected == 429 && resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+			t.Error("Expected Retry-After header on rate limited response")
+		}
+	}
+}
@@ -1,20 +1,24 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
-// Setup 为 Fiber 应用配置所有中间件
-func Setup(app *fiber.App, config *models.Config) {
+// Setup 为 Fiber 应用配置所有中间件；auth 仅在 basic 和 jwt 认证模式下需要，
+// keys 为 API 密钥库（api_key 模式必需，basic 和 jwt 模式下可选），limits 为限流计数后端
+func Setup(app *fiber.App, config *models.Config, auth *services.AuthService, keys *services.APIKeyStore, limits LimiterBackend) {
 	// 恢复中间件 - 应该放在第一位
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: true,
@@ -32,12 +36,12 @@ func Setup(app *fiber.App, config *models.Config) {
 
 	// 速率限制中间件
 	if config.Security.RateLimit.Enabled {
-		setupRateLimit(app, config)
+		setupRateLimit(app, config, limits)
 	}
 
 	// 认证中间件(如果启用)
 	if config.Security.Auth.Enabled {
-		setupAuth(app, config)
+		setupAuth(app, config, auth, keys)
 	}
 
 	// 自定义头和安全
@@ -66,41 +70,51 @@ func setupCORS(app *fiber.App, config *models.Config) {
 		AllowMethods:     joinStringSlice(config.Security.CORS.AllowedMethods, ","),
 		AllowHeaders:     joinStringSlice(config.Security.CORS.AllowedHeaders, ","),
 		AllowCredentials: true,
-		ExposeHeaders:    "Content-Length,Content-Range,Accept-Ranges",
+		ExposeHeaders:    "Content-Length,Content-Range,Accept-Ranges,ETag,Last-Modified,Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Upload-Offset,Upload-Length,Upload-Expires",
 	}
 
 	app.Use(cors.New(corsConfig))
 }
 
-// setupRateLimit 配置速率限制中间件
-func setupRateLimit(app *fiber.App, config *models.Config) {
-	rateLimitConfig := limiter.Config{
-		Max:               config.Security.RateLimit.RequestsPerMin,
-		Expiration:        time.Minute,
-		LimiterMiddleware: limiter.SlidingWindow{},
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
+// setupRateLimit 配置速率限制中间件，按客户端 IP 在滑动窗口内计数；计数保存在限流后端中
+func setupRateLimit(app *fiber.App, config *models.Config, backend LimiterBackend) {
+	limit := config.Security.RateLimit.RequestsPerMin
+
+	app.Use(func(c *fiber.Ctx) error {
+		allowed, retryAfter, err := backend.Allow("ip:"+c.IP(), limit, time.Minute)
+		if err != nil {
+			// 后端不可用时放行，避免限流故障导致整个服务不可用
+			backendError(backend, "check rate limit", err)
+			return c.Next()
+		}
+		if !allowed {
+			seconds := int(retryAfter.Seconds())
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"error":       "Rate limit exceeded",
-				"retry_after": "60 seconds",
+				"retry_after": fmt.Sprintf("%d seconds", seconds),
 			})
-		},
-		SkipFailedRequests:     false,
-		SkipSuccessfulRequests: false,
-	}
-
-	app.Use(limiter.New(rateLimitConfig))
+		}
+		return c.Next()
+	})
 }
 
 // setupAuth 配置认证中间件
-func setupAuth(app *fiber.App, config *models.Config) {
+func setupAuth(app *fiber.App, config *models.Config, auth *services.AuthService, keys *services.APIKeyStore) {
+	// 携带有效签名的流媒体请求无需再提供凭据
+	signer := NewURLSigner(config.Security.SignedURLs)
+	app.Use(func(c *fiber.Ctx) error {
+		if config.Security.SignedURLs.Enabled && c.Query("sig") != "" && signer.VerifyRequest(c) == nil {
+			c.Locals(localsSignedURL, true)
+		}
+		return c.Next()
+	})
+
 	switch config.Security.Auth.Type {
 	case "api_key":
 		app.Use(func(c *fiber.Ctx) error {
 			// 跳过认证健康检查和 info 端点
-			if c.Path() == "/health" || c.Path() == "/api/info" {
+			if isPublicPath(c.Path()) || IsSignedURL(c) {
 				return c.Next()
 			}
 
@@ -109,37 +123,90 @@ func setupAuth(app *fiber.App, config *models.Config) {
 				apiKey = c.Query("api_key")
 			}
 
-			if apiKey != config.Security.Auth.ApiKey {
+			if apiKey == "" && isAnonymousReadPath(c) {
+				return c.Next()
+			}
+
+			if apiKey == "" {
 				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 					"error": "Invalid or missing API key",
 				})
 			}
 
-			return c.Next()
+			return authenticateAPIKey(c, keys, apiKey)
 		})
 
 	case "basic":
 		app.Use(func(c *fiber.Ctx) error {
 			// 跳过健康检查和信息端点的认证
-			if c.Path() == "/health" || c.Path() == "/api/info" {
+			if isPublicPath(c.Path()) || IsSignedURL(c) {
 				return c.Next()
 			}
 
-			// 获取 Authorization 头
-			auth := c.Get("Authorization")
-			if auth == "" {
+			// 也接受 API 密钥，便于脚本和服务间调用
+			if apiKey := c.Get("X-API-Key"); apiKey != "" && keys != nil {
+				return authenticateAPIKey(c, keys, apiKey)
+			}
+
+			header := c.Get(fiber.HeaderAuthorization)
+			if header == "" && isAnonymousReadPath(c) {
+				return c.Next()
+			}
+
+			username, password, ok := parseBasicAuth(header)
+			if !ok {
+				c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Restricted", charset="UTF-8"`)
 				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 					"error": "Authorization required",
 				})
 			}
 
-			// Simple basic auth check (in a real implementation, parse the header properly)
-			if auth != "Basic "+config.Security.Auth.BasicAuth.Username+":"+config.Security.Auth.BasicAuth.Password {
+			principal, err := auth.AuthenticatePassword(username, password)
+			if err != nil {
+				c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Restricted", charset="UTF-8"`)
 				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 					"error": "Invalid credentials",
 				})
 			}
 
+			setPrincipal(c, principal)
+			return c.Next()
+		})
+
+	case "jwt":
+		app.Use(func(c *fiber.Ctx) error {
+			// 跳过健康检查、信息端点以及登录和刷新端点的认证
+			if isPublicPath(c.Path()) || IsSignedURL(c) {
+				return c.Next()
+			}
+
+			if apiKey := c.Get("X-API-Key"); apiKey != "" && keys != nil {
+				return authenticateAPIKey(c, keys, apiKey)
+			}
+
+			header := c.Get(fiber.HeaderAuthorization)
+			if header == "" && isAnonymousReadPath(c) {
+				return c.Next()
+			}
+
+			token, ok := parseBearerToken(header)
+			if !ok {
+				c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="Restricted"`)
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Authorization required",
+				})
+			}
+
+			principal, err := auth.VerifyAccessToken(token)
+			if err != nil {
+				c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="Restricted", error="invalid_token"`)
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":   "Invalid token",
+					"details": err.Error(),
+				})
+			}
+
+			setPrincipal(c, principal)
 			return c.Next()
 		})
 	}
@@ -161,42 +228,60 @@ func setupSecurity(app *fiber.App, config *models.Config) {
 	})
 }
 
-// ConnectionLimiter 提供连接限制功能
+// ConnectionLimiter 提供连接限制功能，计数保存在限流后端中
 type ConnectionLimiter struct {
-	semaphore chan struct{}
-	maxConns  int
+	backend  LimiterBackend
+	key      string
+	maxConns int
 }
 
-// NewConnectionLimiter 创建新的连接限制器
+// NewConnectionLimiter 创建仅限制本进程连接数的连接限制器
 func NewConnectionLimiter(maxConns int) *ConnectionLimiter {
+	return NewSharedConnectionLimiter(NewMemoryLimiterBackend(), "connections", maxConns)
+}
+
+// NewSharedConnectionLimiter 创建在后端中按 key 计数的连接限制器；使用共享后端时所有副本共同遵守 maxConns
+func NewSharedConnectionLimiter(backend LimiterBackend, key string, maxConns int) *ConnectionLimiter {
 	return &ConnectionLimiter{
-		semaphore: make(chan struct{}, maxConns),
-		maxConns:  maxConns,
+		backend:  backend,
+		key:      key,
+		maxConns: maxConns,
 	}
 }
 
-// Acquire 尝试获取连接槽位
-func (cl *ConnectionLimiter) Acquire() bool {
-	select {
-	case cl.semaphore <- struct{}{}:
-		return true
-	default:
-		return false
+// Acquire 尝试获取连接槽位，成功时返回释放槽位所需的租约；后端不可用时放行
+func (cl *ConnectionLimiter) Acquire() (string, bool) {
+	lease, ok, err := cl.backend.Acquire(cl.key, cl.maxConns)
+	if err != nil {
+		backendError(cl.backend, "acquire connection", err)
+		return "", true
 	}
+	return lease, ok
 }
 
 // Release 释放连接槽位
-func (cl *ConnectionLimiter) Release() {
-	select {
-	case <-cl.semaphore:
-	default:
-		log.Printf("Warning: Attempted to release more connections than acquired")
+func (cl *ConnectionLimiter) Release(lease string) {
+	if lease == "" {
+		// 后端不可用时放行的请求没有租约
+		return
+	}
+	if err := cl.backend.Release(cl.key, lease); err != nil {
+		if errors.Is(err, ErrUnknownLease) {
+			log.Printf("Warning: Attempted to release more connections than acquired")
+			return
+		}
+		backendError(cl.backend, "release connection", err)
 	}
 }
 
-// GetActiveConnections 返回活跃连接数
+// GetActiveConnections 返回活跃连接数；使用共享后端时为所有副本的总数
 func (cl *ConnectionLimiter) GetActiveConnections() int {
-	return len(cl.semaphore)
+	active, err := cl.backend.Active(cl.key)
+	if err != nil {
+		backendError(cl.backend, "count connections", err)
+		return 0
+	}
+	return active
 }
 
 // GetMaxConnections 返回最大连接数
@@ -205,11 +290,12 @@ func (cl *ConnectionLimiter) GetMaxConnections() int {
 }
 
 // SetupConnectionLimiting 添加连接限制中间件
-func SetupConnectionLimiting(app *fiber.App, config *models.Config) *ConnectionLimiter {
-	limiter := NewConnectionLimiter(config.Server.MaxConns)
+func SetupConnectionLimiting(app *fiber.App, config *models.Config, backend LimiterBackend) *ConnectionLimiter {
+	limiter := NewSharedConnectionLimiter(backend, "connections", config.Server.MaxConns)
 
 	app.Use(func(c *fiber.Ctx) error {
-		if !limiter.Acquire() {
+		lease, ok := limiter.Acquire()
+		if !ok {
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"error":              "Server is at maximum capacity",
 				"max_connections":    limiter.GetMaxConnections(),
@@ -218,7 +304,7 @@ func SetupConnectionLimiting(app *fiber.App, config *models.Config) *ConnectionL
 		}
 
 		// 确保连接在请求完成时释放
-		defer limiter.Release()
+		defer limiter.Release(lease)
 
 		return c.Next()
 	})
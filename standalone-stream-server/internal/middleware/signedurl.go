@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 签名 URL 的错误
+var (
+	ErrSignatureMissing = errors.New("missing URL signature")
+	ErrSignatureExpired = errors.New("URL signature expired")
+	ErrSignatureInvalid = errors.New("invalid URL signature")
+)
+
+// SignedPathPrefixes 是可以签名的路由前缀
+var SignedPathPrefixes = []string{"/stream/", "/hls/", "/dash/", "/api/thumbnail/"}
+
+// prefixSignedRoutes 是允许前缀签名的路由；前缀只能覆盖单个视频的播放列表和片段
+var prefixSignedRoutes = []string{"/hls/", "/dash/"}
+
+// localsSignedURL 标记请求已通过签名验证
+const localsSignedURL = "signed_url"
+
+// URLSigner 生成和验证带 HMAC-SHA256 签名的过期 URL。
+// 签名覆盖路径（或路径前缀）、过期时间以及可选的客户端 IP
+type URLSigner struct {
+	keys       [][]byte
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// SignOptions 控制签名 URL 的范围
+type SignOptions struct {
+	TTL      time.Duration
+	ClientIP string // 非空时只允许该 IP 使用
+	Prefix   bool   // 为 true 时签名覆盖 HLS/DASH 视频目录下的播放列表和片段，其它路径不允许
+}
+
+// NewURLSigner 创建新的 URL 签名器
+func NewURLSigner(config models.SignedURLConfig) *URLSigner {
+	keys := make([][]byte, 0, len(config.Keys))
+	for _, key := range config.Keys {
+		if key != "" {
+			keys = append(keys, []byte(key))
+		}
+	}
+
+	defaultTTL := config.DefaultTTL
+	if defaultTTL <= 0 {
+		defaultTTL = time.Hour
+	}
+	maxTTL := config.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = 24 * time.Hour
+	}
+
+	return &URLSigner{
+		keys:       keys,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+	}
+}
+
+// Sign 为路径生成签名 URL，返回 URL 和过期时间
+func (s *URLSigner) Sign(rawPath string, opts SignOptions) (string, time.Time, error) {
+	if len(s.keys) == 0 {
+		return "", time.Time{}, errors.New("no signing key configured")
+	}
+
+	cleaned := path.Clean("/" + rawPath)
+	scope := cleaned
+	if opts.Prefix {
+		scope = path.Dir(cleaned) + "/"
+		if !isPrefixScope(scope) {
+			return "", time.Time{}, fmt.Errorf("prefix signing is only allowed for HLS/DASH video paths: %s", rawPath)
+		}
+	}
+	if !isSignablePath(scope) {
+		return "", time.Time{}, fmt.Errorf("path cannot be signed: %s", rawPath)
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl > s.maxTTL {
+		return "", time.Time{}, fmt.Errorf("ttl exceeds maximum of %s", s.maxTTL)
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	query := url.Values{}
+	if opts.Prefix {
+		query.Set("prefix", scope)
+	}
+	if opts.ClientIP != "" {
+		query.Set("ip", opts.ClientIP)
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query.Set("exp", exp)
+	query.Set("sig", s.signature(s.keys[0], scope, exp, opts.ClientIP))
+
+	u := url.URL{Path: cleaned, RawQuery: query.Encode()}
+	return u.String(), expires, nil
+}
+
+// Verify 验证请求路径和查询参数中的签名；任一有效密钥签名的 URL 都被接受
+func (s *URLSigner) Verify(requestPath string, query func(string) string, clientIP string) error {
+	sig := query("sig")
+	exp := query("exp")
+	if sig == "" || exp == "" {
+		return ErrSignatureMissing
+	}
+
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+
+	scope := path.Clean(requestPath)
+	if prefix := query("prefix"); prefix != "" {
+		// 前缀只覆盖视频目录下的文件，不覆盖更深的子目录
+		rest, ok := strings.CutPrefix(scope, prefix)
+		if !ok || !isPrefixScope(prefix) || strings.Contains(rest, "/") {
+			return ErrSignatureInvalid
+		}
+		scope = prefix
+	}
+	if !isSignablePath(scope) {
+		return ErrSignatureInvalid
+	}
+
+	ip := query("ip")
+	if ip != "" && ip != clientIP {
+		return ErrSignatureInvalid
+	}
+
+	for _, key := range s.keys {
+		if hmac.Equal([]byte(sig), []byte(s.signature(key, scope, exp, ip))) {
+			return nil
+		}
+	}
+	return ErrSignatureInvalid
+}
+
+// VerifyRequest 验证 Fiber 请求的签名
+func (s *URLSigner) VerifyRequest(c *fiber.Ctx) error {
+	requestPath, err := url.PathUnescape(c.Path())
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	return s.Verify(requestPath, func(key string) string { return c.Query(key) }, c.IP())
+}
+
+// SignatureQuery 返回请求中的签名参数，用于把签名传递给播放列表中的片段 URL
+func SignatureQuery(c *fiber.Ctx) string {
+	if c.Query("sig") == "" || c.Query("prefix") == "" {
+		return ""
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"prefix", "ip", "exp", "sig"} {
+		if value := c.Query(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query.Encode()
+}
+
+// RequireSignedURL 要求请求携带有效签名；签名 URL 未启用或请求已通过其它方式认证时放行
+func RequireSignedURL(config *models.Config, signer *URLSigner) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !config.Security.SignedURLs.Enabled || isAuthenticated(c) || IsSignedURL(c) {
+			return c.Next()
+		}
+
+		if err := signer.VerifyRequest(c); err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "Valid signed URL required",
+				"details": err.Error(),
+			})
+		}
+
+		c.Locals(localsSignedURL, true)
+		return c.Next()
+	}
+}
+
+// IsSignedURL 返回请求是否已通过签名验证
+func IsSignedURL(c *fiber.Ctx) bool {
+	signed, _ := c.Locals(localsSignedURL).(bool)
+	return signed
+}
+
+// signature 计算 base64url 编码的 HMAC-SHA256
+func (s *URLSigner) signature(key []byte, scope, exp, ip string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(scope + "\n" + exp + "\n" + ip))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// isPrefixScope 检查前缀是否为单个 HLS/DASH 视频目录，即 "/hls/<目录>/<视频路径>/" 形式
+func isPrefixScope(prefix string) bool {
+	if !strings.HasSuffix(prefix, "/") || path.Clean(prefix)+"/" != prefix {
+		return false
+	}
+	for _, route := range prefixSignedRoutes {
+		rest, ok := strings.CutPrefix(prefix, route)
+		if !ok {
+			continue
+		}
+		directory, videoPath, _ := strings.Cut(strings.TrimSuffix(rest, "/"), "/")
+		return directory != "" && videoPath != ""
+	}
+	return false
+}
+
+// isSignablePath 检查路径是否属于可签名的路由
+func isSignablePath(p string) bool {
+	for _, prefix := range SignedPathPrefixes {
+		if strings.HasPrefix(p, prefix) && len(p) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
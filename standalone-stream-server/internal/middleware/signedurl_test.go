@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func verifySignedURL(t *testing.T, signer *URLSigner, signedURL, requestPath, clientIP string) error {
+	t.Helper()
+
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requestPath == "" {
+		requestPath = u.Path
+	}
+	return signer.Verify(requestPath, u.Query().Get, clientIP)
+}
+
+func TestURLSigner_SignAndVerify(t *testing.T) {
+	signer := NewURLSigner(models.SignedURLConfig{Keys: []string{"current"}, MaxTTL: time.Hour})
+
+	signed, expires, err := signer.Sign("/stream/movies/avatar", SignOptions{TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expires) > time.Minute {
+		t.Errorf("Unexpected expiry: %v", expires)
+	}
+	if err := verifySignedURL(t, signer, signed, "", "10.0.0.1"); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := verifySignedURL(t, signer, signed, "/stream/movies/other", ""); err != ErrSignatureInvalid {
+		t.Errorf("Signature must not cover other paths, got %v", err)
+	}
+
+	// 绑定客户端 IP
+	signed, _, err = signer.Sign("/stream/movies/avatar", SignOptions{ClientIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignedURL(t, signer, signed, "", "10.0.0.2"); err != ErrSignatureInvalid {
+		t.Errorf("Expected IP mismatch to be rejected, got %v", err)
+	}
+
+	// 前缀签名覆盖 HLS 片段，但不能越过所在目录
+	signed, _, err = signer.Sign("/hls/movies/avatar/index.m3u8", SignOptions{Prefix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignedURL(t, signer, signed, "/hls/movies/avatar/segment_00001.ts", ""); err != nil {
+		t.Errorf("Prefix signature should cover segments, got %v", err)
+	}
+	if err := verifySignedURL(t, signer, signed, "/hls/movies/avatar2/index.m3u8", ""); err != ErrSignatureInvalid {
+		t.Errorf("Prefix signature must not cover sibling directories, got %v", err)
+	}
+	if err := verifySignedURL(t, signer, signed, "/hls/movies/avatar/extras/index.m3u8", ""); err != ErrSignatureInvalid {
+		t.Errorf("Prefix signature must not cover nested videos, got %v", err)
+	}
+
+	// 不可签名的路径和过长的有效期
+	if _, _, err := signer.Sign("/api/videos", SignOptions{}); err == nil {
+		t.Error("Expected error for non-stream path")
+	}
+	if _, _, err := signer.Sign("/stream/movie", SignOptions{Prefix: true}); err == nil {
+		t.Error("Prefix covering the whole /stream/ route must be rejected")
+	}
+	if _, _, err := signer.Sign("/stream/movies/avatar", SignOptions{Prefix: true}); err == nil {
+		t.Error("Prefix covering a /stream/ library directory must be rejected")
+	}
+	if _, _, err := signer.Sign("/hls/movies/index.m3u8", SignOptions{Prefix: true}); err == nil {
+		t.Error("Prefix covering a whole HLS directory must be rejected")
+	}
+	if _, _, err := signer.Sign("/stream/movies/avatar", SignOptions{TTL: 2 * time.Hour}); err == nil {
+		t.Error("Expected error for ttl above maximum")
+	}
+}
+
+func TestURLSigner_KeyRotationAndExpiry(t *testing.T) {
+	oldSigner := NewURLSigner(models.SignedURLConfig{Keys: []string{"old"}})
+	signed, _, err := oldSigner.Sign("/stream/movies/avatar", SignOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := NewURLSigner(models.SignedURLConfig{Keys: []string{"new", "old"}})
+	if err := verifySignedURL(t, rotated, signed, "", ""); err != nil {
+		t.Errorf("URLs signed with a retired key should verify while it is listed, got %v", err)
+	}
+
+	removed := NewURLSigner(models.SignedURLConfig{Keys: []string{"new"}})
+	if err := verifySignedURL(t, removed, signed, "", ""); err != ErrSignatureInvalid {
+		t.Errorf("Expected removed key to be rejected, got %v", err)
+	}
+
+	query := url.Values{"exp": {"1"}, "sig": {"x"}}
+	if err := rotated.Verify("/stream/movies/avatar", query.Get, ""); err != ErrSignatureExpired {
+		t.Errorf("Expected ErrSignatureExpired, got %v", err)
+	}
+	if err := rotated.Verify("/stream/movies/avatar", url.Values{}.Get, ""); err != ErrSignatureMissing {
+		t.Errorf("Expected ErrSignatureMissing, got %v", err)
+	}
+}
+
+func TestRequireSignedURL(t *testing.T) {
+	config := &models.Config{
+		Security: models.SecurityConfig{
+			SignedURLs: models.SignedURLConfig{Enabled: true, Keys: []string{"secret"}},
+		},
+	}
+	signer := NewURLSigner(config.Security.SignedURLs)
+
+	app := fiber.New()
+	app.Get("/stream/:directory/*", RequireSignedURL(config, signer), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/stream/movies/avatar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("Expected 403 without signature, got %d", resp.StatusCode)
+	}
+
+	signed, _, err := signer.Sign("/stream/movies/avatar", SignOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = app.Test(httptest.NewRequest("GET", signed, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected 200 with valid signature, got %d", resp.StatusCode)
+	}
+}
@@ -4,29 +4,50 @@ import "time"
 
 // Config 保存完整的服务器配置
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server" yaml:"server"`
-	Video    VideoConfig    `mapstructure:"video" yaml:"video"`
-	Logging  LoggingConfig  `mapstructure:"logging" yaml:"logging"`
-	Security SecurityConfig `mapstructure:"security" yaml:"security"`
+	Server    ServerConfig    `mapstructure:"server" yaml:"server"`
+	Video     VideoConfig     `mapstructure:"video" yaml:"video"`
+	Logging   LoggingConfig   `mapstructure:"logging" yaml:"logging"`
+	Security  SecurityConfig  `mapstructure:"security" yaml:"security"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler" yaml:"scheduler"`
 }
 
 // ServerConfig 保存服务器特定的配置
 type ServerConfig struct {
-	Port            int           `mapstructure:"port" yaml:"port"`
-	Host            string        `mapstructure:"host" yaml:"host"`
-	ReadTimeout     time.Duration `mapstructure:"read_timeout" yaml:"read_timeout"`
-	WriteTimeout    time.Duration `mapstructure:"write_timeout" yaml:"write_timeout"`
-	MaxConns        int           `mapstructure:"max_connections" yaml:"max_connections"`
-	TokensPerSecond int           `mapstructure:"tokens_per_second" yaml:"tokens_per_second"`
-	GracefulTimeout time.Duration `mapstructure:"graceful_timeout" yaml:"graceful_timeout"`
+	Port            int             `mapstructure:"port" yaml:"port"`
+	Host            string          `mapstructure:"host" yaml:"host"`
+	ReadTimeout     time.Duration   `mapstructure:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout    time.Duration   `mapstructure:"write_timeout" yaml:"write_timeout"`
+	MaxConns        int             `mapstructure:"max_connections" yaml:"max_connections"`
+	TokensPerSecond int             `mapstructure:"tokens_per_second" yaml:"tokens_per_second"`
+	GracefulTimeout time.Duration   `mapstructure:"graceful_timeout" yaml:"graceful_timeout"`
+	Admission       AdmissionConfig `mapstructure:"admission" yaml:"admission"`
+}
+
+// AdmissionConfig 保存流媒体准入队列配置：服务器饱和时请求按优先级排队等待，而不是立即返回 429
+type AdmissionConfig struct {
+	Enabled       bool           `mapstructure:"enabled" yaml:"enabled"`
+	MaxQueue      int            `mapstructure:"max_queue" yaml:"max_queue"`           // 最大排队请求数，超出时立即拒绝
+	MaxWait       time.Duration  `mapstructure:"max_wait" yaml:"max_wait"`             // 最长等待时间
+	Priorities    map[string]int `mapstructure:"priorities" yaml:"priorities"`         // 角色（及 anonymous）对应的优先级，越大越先准入
+	KeyPriorities map[string]int `mapstructure:"key_priorities" yaml:"key_priorities"` // API 密钥 ID 对应的优先级（密钥等级），优先于角色
 }
 
 // VideoConfig 保存视频相关的配置
 type VideoConfig struct {
-	Directories       []VideoDirectory `mapstructure:"directories" yaml:"directories"`
-	MaxUploadSize     int64            `mapstructure:"max_upload_size" yaml:"max_upload_size"`
-	SupportedFormats  []string         `mapstructure:"supported_formats" yaml:"supported_formats"`
-	StreamingSettings StreamSettings   `mapstructure:"streaming" yaml:"streaming"`
+	Directories       []VideoDirectory      `mapstructure:"directories" yaml:"directories"`
+	MaxUploadSize     int64                 `mapstructure:"max_upload_size" yaml:"max_upload_size"`
+	SupportedFormats  []string              `mapstructure:"supported_formats" yaml:"supported_formats"`
+	StreamingSettings StreamSettings        `mapstructure:"streaming" yaml:"streaming"`
+	Catalog           CatalogConfig         `mapstructure:"catalog" yaml:"catalog"`
+	Watcher           WatcherConfig         `mapstructure:"watcher" yaml:"watcher"`
+	Packaging         PackagingConfig       `mapstructure:"packaging" yaml:"packaging"`
+	Transcoding       TranscodingConfig     `mapstructure:"transcoding" yaml:"transcoding"`
+	ResumableUpload   ResumableUploadConfig `mapstructure:"resumable_upload" yaml:"resumable_upload"`
+	Trash             TrashConfig           `mapstructure:"trash" yaml:"trash"`
+	Sprites           SpriteConfig          `mapstructure:"sprites" yaml:"sprites"`
+	Thumbnails        ThumbnailConfig       `mapstructure:"thumbnails" yaml:"thumbnails"`
+	Metadata          MetadataConfig        `mapstructure:"metadata" yaml:"metadata"`
+	FFmpegPath        string                `mapstructure:"ffmpeg_path" yaml:"ffmpeg_path"`
 }
 
 // VideoDirectory 表示视频源目录
@@ -35,15 +56,186 @@ type VideoDirectory struct {
 	Path        string `mapstructure:"path" yaml:"path"`
 	Description string `mapstructure:"description" yaml:"description"`
 	Enabled     bool   `mapstructure:"enabled" yaml:"enabled"`
+	// Deduplication 是上传内容重复时的处理策略：off（默认）、reject 拒绝、link 硬链接到已有视频
+	Deduplication string `mapstructure:"deduplication" yaml:"deduplication"`
+	// Access 是目录的访问控制列表，仅在启用认证时生效
+	Access DirectoryAccess `mapstructure:"access" yaml:"access"`
+	// Retention 是目录的保留规则，由调度器定期评估并将超出规则的视频加入删除队列
+	Retention RetentionPolicy `mapstructure:"retention" yaml:"retention"`
+}
+
+// RetentionPolicy 描述目录的保留规则，各规则可组合使用，全部为零值时不自动删除任何视频
+type RetentionPolicy struct {
+	MaxAge       time.Duration `mapstructure:"max_age" yaml:"max_age"`               // 删除修改时间早于此的视频
+	MaxTotalSize int64         `mapstructure:"max_total_size" yaml:"max_total_size"` // 目录总大小上限（字节），超出时按 eviction 顺序删除
+	Eviction     string        `mapstructure:"eviction" yaml:"eviction"`             // 超出总大小时的删除顺序：oldest（默认，按修改时间）、lru（按最近播放时间）
+	KeepLast     int           `mapstructure:"keep_last" yaml:"keep_last"`           // 每个子目录只保留最新的 N 个视频
+	Exclude      []string      `mapstructure:"exclude" yaml:"exclude"`               // 匹配相对路径或文件名的 glob 模式，匹配的视频永不删除
+}
+
+// DirectoryAccess 描述目录的访问控制：
+// public 任何人（包括匿名请求）可读，authenticated（默认）需要登录，restricted 只有 grants 中的用户、组或 API 密钥可读；
+// grants 非空或模式为 restricted 时，上传和删除也需要相应授权
+type DirectoryAccess struct {
+	Mode   string        `mapstructure:"mode" yaml:"mode"`
+	Grants []AccessGrant `mapstructure:"grants" yaml:"grants"`
+}
+
+// AccessGrant 为一组用户、组或 API 密钥授予 read、upload、delete 权限
+type AccessGrant struct {
+	Users   []string `mapstructure:"users" yaml:"users"`
+	Groups  []string `mapstructure:"groups" yaml:"groups"`
+	APIKeys []string `mapstructure:"api_keys" yaml:"api_keys"`
+	Rights  []string `mapstructure:"rights" yaml:"rights"`
 }
 
 // StreamSettings 保存流媒体特定的设置
 type StreamSettings struct {
-	CacheControl string        `mapstructure:"cache_control" yaml:"cache_control"`
-	BufferSize   int           `mapstructure:"buffer_size" yaml:"buffer_size"`
-	RangeSupport bool          `mapstructure:"range_support" yaml:"range_support"`
-	ChunkSize    int           `mapstructure:"chunk_size" yaml:"chunk_size"`
-	ConnTimeout  time.Duration `mapstructure:"connection_timeout" yaml:"connection_timeout"`
+	CacheControl string          `mapstructure:"cache_control" yaml:"cache_control"`
+	BufferSize   int             `mapstructure:"buffer_size" yaml:"buffer_size"`
+	RangeSupport bool            `mapstructure:"range_support" yaml:"range_support"`
+	ChunkSize    int             `mapstructure:"chunk_size" yaml:"chunk_size"`
+	ConnTimeout  time.Duration   `mapstructure:"connection_timeout" yaml:"connection_timeout"`
+	Bandwidth    BandwidthConfig `mapstructure:"bandwidth" yaml:"bandwidth"`
+}
+
+// BandwidthConfig 保存流媒体带宽整形配置；限速单位为字节/秒，0 表示不限制
+type BandwidthConfig struct {
+	Enabled        bool          `mapstructure:"enabled" yaml:"enabled"`
+	GlobalLimit    int64         `mapstructure:"global_limit" yaml:"global_limit"`         // 全部流的总出口带宽
+	PerClientLimit int64         `mapstructure:"per_client_limit" yaml:"per_client_limit"` // 每个客户端（IP 或 API 密钥）的带宽
+	RealtimeFactor float64       `mapstructure:"realtime_factor" yaml:"realtime_factor"`   // 单个流的速率为视频码率的倍数，0 表示不按码率限速
+	InitialBurst   time.Duration `mapstructure:"initial_burst" yaml:"initial_burst"`       // 每个流开始时不受码率限制的播放时长
+}
+
+// CatalogConfig 保存持久化视频目录索引的配置
+type CatalogConfig struct {
+	Enabled         bool          `mapstructure:"enabled" yaml:"enabled"`
+	Path            string        `mapstructure:"path" yaml:"path"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval" yaml:"refresh_interval"`
+}
+
+// WatcherConfig 保存文件系统监听的配置
+type WatcherConfig struct {
+	Enabled  bool          `mapstructure:"enabled" yaml:"enabled"`
+	Debounce time.Duration `mapstructure:"debounce" yaml:"debounce"`
+}
+
+// PackagingConfig 保存自适应流打包的配置，片段按需生成并缓存在磁盘上
+type PackagingConfig struct {
+	SegmentDuration time.Duration `mapstructure:"segment_duration" yaml:"segment_duration"`
+	CacheDir        string        `mapstructure:"cache_dir" yaml:"cache_dir"`
+	CacheMaxSize    int64         `mapstructure:"cache_max_size" yaml:"cache_max_size"`
+	Workers         int           `mapstructure:"workers" yaml:"workers"`             // 同时运行的片段编码 ffmpeg 进程数
+	QueueTimeout    time.Duration `mapstructure:"queue_timeout" yaml:"queue_timeout"` // 等待空闲编码进程的时间，超时返回 503
+	HLS             HLSConfig     `mapstructure:"hls" yaml:"hls"`
+	DASH            DASHConfig    `mapstructure:"dash" yaml:"dash"`
+}
+
+// HLSConfig 保存 HLS 打包的配置
+type HLSConfig struct {
+	Enabled       bool   `mapstructure:"enabled" yaml:"enabled"`
+	SegmentFormat string `mapstructure:"segment_format" yaml:"segment_format"` // mpegts, fmp4
+}
+
+// DASHConfig 保存 MPEG-DASH 打包的配置，片段固定为 fMP4
+type DASHConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+}
+
+// TranscodingConfig 保存多码率转码的配置，转码作为调度器任务在后台执行
+type TranscodingConfig struct {
+	Enabled      bool              `mapstructure:"enabled" yaml:"enabled"`
+	AutoOnUpload bool              `mapstructure:"auto_on_upload" yaml:"auto_on_upload"`
+	Renditions   []RenditionConfig `mapstructure:"renditions" yaml:"renditions"`
+}
+
+// ResumableUploadConfig 保存可续传（tus）上传的配置
+type ResumableUploadConfig struct {
+	Enabled    bool          `mapstructure:"enabled" yaml:"enabled"`
+	Dir        string        `mapstructure:"dir" yaml:"dir"`               // 未完成上传的暂存目录
+	Expiration time.Duration `mapstructure:"expiration" yaml:"expiration"` // 超过该时间未继续的上传会被清理
+}
+
+// TrashConfig 保存回收站配置：启用后删除的视频连同缩略图和转码版本移动到所在目录的 .trash 子目录，
+// 可在宽限期内恢复，过期后由调度器永久删除
+type TrashConfig struct {
+	Enabled       bool          `mapstructure:"enabled" yaml:"enabled"`
+	GracePeriod   time.Duration `mapstructure:"grace_period" yaml:"grace_period"`     // 视频在回收站中的保留时长
+	PurgeSchedule string        `mapstructure:"purge_schedule" yaml:"purge_schedule"` // 清除过期视频的 cron 表达式，未定义 purge_trash 作业时使用
+}
+
+// ThumbnailConfig 保存缩略图缓存和变体（?w=&h=&fit=&format=&q=&t=）的配置
+type ThumbnailConfig struct {
+	MaxWidth     int           `mapstructure:"max_width" yaml:"max_width"`           // 请求的最大宽度（像素）
+	MaxHeight    int           `mapstructure:"max_height" yaml:"max_height"`         // 请求的最大高度（像素）
+	Quality      int           `mapstructure:"quality" yaml:"quality"`               // 未指定 q 时的 JPEG 质量（1-100）
+	CacheMaxAge  time.Duration `mapstructure:"cache_max_age" yaml:"cache_max_age"`   // 响应 Cache-Control 的 max-age
+	Dir          string        `mapstructure:"dir" yaml:"dir"`                       // 缩略图、预览图集和变体的缓存目录
+	MaxCacheSize int64         `mapstructure:"max_cache_size" yaml:"max_cache_size"` // 缓存目录的大小上限（字节），超出时按最近最少使用淘汰，0 表示不限制
+	Workers      int           `mapstructure:"workers" yaml:"workers"`               // 同时运行的 ffmpeg 截帧任务数
+	Timeout      time.Duration `mapstructure:"timeout" yaml:"timeout"`               // 单个缩略图的生成超时（含等待空闲工作线程的时间）
+}
+
+// MetadataConfig 保存视频元数据探测和持久化缓存的配置
+type MetadataConfig struct {
+	CacheEnabled bool          `mapstructure:"cache_enabled" yaml:"cache_enabled"` // 按文件身份（路径、大小、修改时间、inode）缓存探测结果，重启后复用
+	CachePath    string        `mapstructure:"cache_path" yaml:"cache_path"`       // 缓存数据库文件
+	Workers      int           `mapstructure:"workers" yaml:"workers"`             // 同时运行的探测数（ffprobe 进程数）
+	Timeout      time.Duration `mapstructure:"timeout" yaml:"timeout"`             // 单次 ffprobe 的超时
+	Warmup       bool          `mapstructure:"warmup" yaml:"warmup"`               // 启动后在后台探测所有启用目录中未缓存的视频
+}
+
+// SpriteConfig 保存拖动预览图集（sprite sheet）和配套 WebVTT 缩略图轨道的配置
+type SpriteConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	Frames  int  `mapstructure:"frames" yaml:"frames"`   // 每个视频均匀截取的帧数
+	Columns int  `mapstructure:"columns" yaml:"columns"` // 图集每行的帧数
+	Width   int  `mapstructure:"width" yaml:"width"`     // 单帧宽度（像素）
+	Height  int  `mapstructure:"height" yaml:"height"`   // 单帧高度（像素），画面按比例缩放后补边
+}
+
+// RenditionConfig 描述转码阶梯中的一个清晰度
+type RenditionConfig struct {
+	Name         string `mapstructure:"name" yaml:"name"`                   // 例如 "720p"，用于 ?quality= 参数
+	Height       int    `mapstructure:"height" yaml:"height"`               // 0 表示仅音频
+	VideoBitrate string `mapstructure:"video_bitrate" yaml:"video_bitrate"` // 例如 "2800k"
+	AudioBitrate string `mapstructure:"audio_bitrate" yaml:"audio_bitrate"` // 例如 "128k"
+}
+
+// SchedulerConfig 保存后台任务调度器配置
+type SchedulerConfig struct {
+	Queue TaskQueueConfig           `mapstructure:"queue" yaml:"queue"`
+	Tasks map[string]TaskTypeConfig `mapstructure:"tasks" yaml:"tasks"` // 按任务类型名覆盖并发数和轮询间隔
+	Jobs  []CronJobConfig           `mapstructure:"jobs" yaml:"jobs"`   // 按 cron 表达式周期执行的作业
+	// RetentionSchedule 是评估目录保留规则的 cron 表达式，未定义 apply_retention 作业时使用
+	RetentionSchedule string `mapstructure:"retention_schedule" yaml:"retention_schedule"`
+}
+
+// CronJobConfig 保存一个周期作业的定义
+type CronJobConfig struct {
+	Name     string            `mapstructure:"name" yaml:"name"`
+	Enabled  bool              `mapstructure:"enabled" yaml:"enabled"`
+	Schedule string            `mapstructure:"schedule" yaml:"schedule"` // 5 段 cron 表达式，或 @daily、@every 6h 等
+	Timezone string            `mapstructure:"timezone" yaml:"timezone"` // IANA 时区名，如 Asia/Shanghai，默认为服务器本地时区
+	Action   string            `mapstructure:"action" yaml:"action"`     // purge_videos, regenerate_thumbnails, generate_sprites, compact_tasks, rescan_catalog, apply_retention, purge_trash, cleanup_tasks, expire_uploads
+	Params   map[string]string `mapstructure:"params" yaml:"params"`     // 作业参数，取决于 action
+}
+
+// TaskTypeConfig 保存单个任务类型的执行配置
+type TaskTypeConfig struct {
+	Concurrency  int           `mapstructure:"concurrency" yaml:"concurrency"`     // 同时执行的最大任务数
+	PollInterval time.Duration `mapstructure:"poll_interval" yaml:"poll_interval"` // 检查到期任务（如重试）的间隔
+}
+
+// TaskQueueConfig 保存持久化任务队列配置
+type TaskQueueConfig struct {
+	Path              string        `mapstructure:"path" yaml:"path"`                             // 队列数据库路径，同目录下旧的 JSON 任务文件在启动时导入
+	MaxAttempts       int           `mapstructure:"max_attempts" yaml:"max_attempts"`             // 最大尝试次数，用尽后任务进入死信（dead）状态
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout" yaml:"visibility_timeout"` // 处理中的任务超过此时间未续期（如进程崩溃）则重新入队
+	BackoffBase       time.Duration `mapstructure:"backoff_base" yaml:"backoff_base"`             // 第一次重试前的等待时间，此后每次翻倍
+	BackoffMax        time.Duration `mapstructure:"backoff_max" yaml:"backoff_max"`               // 重试等待时间上限
+	Retention         time.Duration `mapstructure:"retention" yaml:"retention"`                   // 已完成任务的保留时间；死信任务保留到手动重试或清除
 }
 
 // LoggingConfig 保存日志配置
@@ -57,9 +249,11 @@ type LoggingConfig struct {
 
 // SecurityConfig 保存安全相关的配置
 type SecurityConfig struct {
-	CORS      CORSConfig `mapstructure:"cors" yaml:"cors"`
-	RateLimit RateConfig `mapstructure:"rate_limit" yaml:"rate_limit"`
-	Auth      AuthConfig `mapstructure:"auth" yaml:"auth"`
+	CORS       CORSConfig      `mapstructure:"cors" yaml:"cors"`
+	RateLimit  RateConfig      `mapstructure:"rate_limit" yaml:"rate_limit"`
+	Auth       AuthConfig      `mapstructure:"auth" yaml:"auth"`
+	SignedURLs SignedURLConfig `mapstructure:"signed_urls" yaml:"signed_urls"`
+	Limiter    LimiterConfig   `mapstructure:"limiter" yaml:"limiter"`
 }
 
 // CORSConfig 保存 CORS 配置
@@ -78,6 +272,31 @@ type RateConfig struct {
 	CleanupTime    time.Duration `mapstructure:"cleanup_time" yaml:"cleanup_time"`
 }
 
+// LimiterConfig 保存限流计数后端配置；多副本部署时使用 redis 共享请求计数和连接数
+type LimiterConfig struct {
+	Backend string             `mapstructure:"backend" yaml:"backend"` // memory 或 redis
+	Redis   RedisLimiterConfig `mapstructure:"redis" yaml:"redis"`
+	// LeaseTTL 是连接槽位租约的有效期，副本崩溃后其占用的槽位在到期后自动回收
+	LeaseTTL time.Duration `mapstructure:"lease_ttl" yaml:"lease_ttl"`
+}
+
+// RedisLimiterConfig 保存 Redis 协议后端的连接配置
+type RedisLimiterConfig struct {
+	Addr      string `mapstructure:"addr" yaml:"addr"`
+	Password  string `mapstructure:"password" yaml:"password"`
+	DB        int    `mapstructure:"db" yaml:"db"`
+	KeyPrefix string `mapstructure:"key_prefix" yaml:"key_prefix"` // 多个部署共用一个 Redis 时用于区分键空间
+}
+
+// SignedURLConfig 保存签名 URL 配置
+type SignedURLConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Keys 是当前有效的签名密钥：第一个用于签名，其余仅用于验证，便于轮换
+	Keys       []string      `mapstructure:"keys" yaml:"keys"`
+	DefaultTTL time.Duration `mapstructure:"default_ttl" yaml:"default_ttl"`
+	MaxTTL     time.Duration `mapstructure:"max_ttl" yaml:"max_ttl"`
+}
+
 // AuthConfig 保存认证配置
 type AuthConfig struct {
 	Enabled   bool   `mapstructure:"enabled" yaml:"enabled"`
@@ -87,4 +306,15 @@ type AuthConfig struct {
 		Username string `mapstructure:"username" yaml:"username"`
 		Password string `mapstructure:"password" yaml:"password"`
 	} `mapstructure:"basic_auth" yaml:"basic_auth"`
+	UsersDB   string    `mapstructure:"users_db" yaml:"users_db"`       // 本地用户数据库路径（basic 和 jwt 模式）
+	APIKeysDB string    `mapstructure:"api_keys_db" yaml:"api_keys_db"` // API 密钥数据库路径
+	JWT       JWTConfig `mapstructure:"jwt" yaml:"jwt"`
+}
+
+// JWTConfig 保存 JWT 令牌配置
+type JWTConfig struct {
+	Secret     string        `mapstructure:"secret" yaml:"secret"` // HS256 签名密钥
+	Issuer     string        `mapstructure:"issuer" yaml:"issuer"`
+	AccessTTL  time.Duration `mapstructure:"access_ttl" yaml:"access_ttl"`
+	RefreshTTL time.Duration `mapstructure:"refresh_ttl" yaml:"refresh_ttl"`
 }
@@ -0,0 +1,336 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Recurring job errors
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// JobAction is a kind of work recurring jobs can run
+type JobAction struct {
+	// Validate checks a job's parameters when the job is added (optional)
+	Validate func(params map[string]string) error
+
+	// Run does the work and returns a summary of what it did; ctx is cancelled when the scheduler stops
+	Run func(ctx context.Context, params map[string]string) (interface{}, error)
+}
+
+// JobRun describes one run of a recurring job
+type JobRun struct {
+	Trigger    string      `json:"trigger"` // schedule, manual
+	StartedAt  time.Time   `json:"started_at"`
+	DurationMs int64       `json:"duration_ms"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// JobStatus describes a recurring job, its last run and its next scheduled run
+type JobStatus struct {
+	Name     string            `json:"name"`
+	Action   string            `json:"action"`
+	Schedule string            `json:"schedule"`
+	Timezone string            `json:"timezone"`
+	Enabled  bool              `json:"enabled"`
+	Params   map[string]string `json:"params,omitempty"`
+	Running  bool              `json:"running"`
+	Runs     int               `json:"runs"`
+	NextRun  *time.Time        `json:"next_run,omitempty"` // unset while the job is disabled or the scheduler is stopped
+	LastRun  *JobRun           `json:"last_run,omitempty"`
+}
+
+// cronJob is a parsed job definition with its run state
+type cronJob struct {
+	config   models.CronJobConfig
+	schedule cron.Schedule
+	action   JobAction
+	entryID  cron.EntryID // zero while not scheduled
+	running  bool
+	runs     int
+	lastRun  *JobRun
+}
+
+// CronScheduler runs recurring jobs on cron schedules. A job never overlaps with itself: a run
+// that is due while the previous one is still going is skipped.
+type CronScheduler struct {
+	mu      sync.Mutex
+	actions map[string]JobAction
+	jobs    []*cronJob
+	cron    *cron.Cron
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewCronScheduler creates a scheduler without actions or jobs
+func NewCronScheduler() *CronScheduler {
+	return &CronScheduler{
+		actions: make(map[string]JobAction),
+	}
+}
+
+// RegisterAction makes an action available to jobs; register actions before adding jobs that use them
+func (cs *CronScheduler) RegisterAction(name string, action JobAction) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.actions[name] = action
+}
+
+// AddJob parses a job definition and schedules it if the scheduler is running
+func (cs *CronScheduler) AddJob(config models.CronJobConfig) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	action, ok := cs.actions[config.Action]
+	if !ok {
+		return fmt.Errorf("job %s: unknown action %q", config.Name, config.Action)
+	}
+	if action.Validate != nil {
+		if err := action.Validate(config.Params); err != nil {
+			return fmt.Errorf("job %s: %w", config.Name, err)
+		}
+	}
+	schedule, err := parseJobSchedule(config.Schedule, config.Timezone)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", config.Name, err)
+	}
+	for _, job := range cs.jobs {
+		if job.config.Name == config.Name {
+			return fmt.Errorf("job %s is already defined", config.Name)
+		}
+	}
+
+	job := &cronJob{config: config, schedule: schedule, action: action}
+	cs.jobs = append(cs.jobs, job)
+	if cs.cron != nil && config.Enabled {
+		cs.scheduleJob(job)
+	}
+	return nil
+}
+
+// parseJobSchedule parses a cron expression or descriptor evaluated in timezone (local time when empty)
+func parseJobSchedule(spec, timezone string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	if timezone == "" {
+		return schedule, nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	if specSchedule, ok := schedule.(*cron.SpecSchedule); ok && !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		specSchedule.Location = location
+	}
+	return schedule, nil
+}
+
+// Start schedules every enabled job
+func (cs *CronScheduler) Start() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.cron != nil {
+		return
+	}
+	cs.cron = cron.New()
+	cs.ctx, cs.cancel = context.WithCancel(context.Background())
+	for _, job := range cs.jobs {
+		if job.config.Enabled {
+			cs.scheduleJob(job)
+		}
+	}
+	cs.cron.Start()
+}
+
+// Stop unschedules all jobs and cancels the context of running ones
+func (cs *CronScheduler) Stop() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.cron == nil {
+		return
+	}
+	cs.cron.Stop()
+	cs.cancel()
+	cs.cron = nil
+	cs.ctx = nil
+	for _, job := range cs.jobs {
+		job.entryID = 0
+	}
+}
+
+// scheduleJob adds a job to the running cron; the caller holds the mutex
+func (cs *CronScheduler) scheduleJob(job *cronJob) {
+	job.entryID = cs.cron.Schedule(job.schedule, cron.FuncJob(func() {
+		if !cs.begin(job) {
+			log.Printf("Skipping scheduled run of job %s: previous run still in progress", job.config.Name)
+			return
+		}
+		cs.execute(job, "schedule")
+	}))
+}
+
+// Trigger starts a job in the background now, whether or not it is enabled
+func (cs *CronScheduler) Trigger(name string) (JobStatus, error) {
+	job, err := cs.startManual(name)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	go cs.execute(job, "manual")
+	return cs.Job(name)
+}
+
+// Run runs a job now and waits for it to finish
+func (cs *CronScheduler) Run(name string) (JobRun, error) {
+	job, err := cs.startManual(name)
+	if err != nil {
+		return JobRun{}, err
+	}
+	return cs.execute(job, "manual"), nil
+}
+
+// startManual marks a job as running for a manual run
+func (cs *CronScheduler) startManual(name string) (*cronJob, error) {
+	job := cs.find(name)
+	if job == nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if !cs.begin(job) {
+		return nil, fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+	return job, nil
+}
+
+// Job returns the status of a job
+func (cs *CronScheduler) Job(name string) (JobStatus, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, job := range cs.jobs {
+		if job.config.Name == name {
+			return cs.status(job), nil
+		}
+	}
+	return JobStatus{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+}
+
+// Jobs returns the status of all jobs in definition order
+func (cs *CronScheduler) Jobs() []JobStatus {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(cs.jobs))
+	for _, job := range cs.jobs {
+		statuses = append(statuses, cs.status(job))
+	}
+	return statuses
+}
+
+// status builds a job's status; the caller holds the mutex
+func (cs *CronScheduler) status(job *cronJob) JobStatus {
+	status := JobStatus{
+		Name:     job.config.Name,
+		Action:   job.config.Action,
+		Schedule: job.config.Schedule,
+		Timezone: job.config.Timezone,
+		Enabled:  job.config.Enabled,
+		Params:   job.config.Params,
+		Running:  job.running,
+		Runs:     job.runs,
+		LastRun:  job.lastRun,
+	}
+	if status.Timezone == "" {
+		status.Timezone = time.Local.String()
+	}
+	if cs.cron != nil && job.entryID != 0 {
+		if next := cs.cron.Entry(job.entryID).Next; !next.IsZero() {
+			status.NextRun = &next
+		} else {
+			// The cron loop has not computed the first run yet
+			next := job.schedule.Next(time.Now())
+			status.NextRun = &next
+		}
+	}
+	return status
+}
+
+// find returns a job by name or nil
+func (cs *CronScheduler) find(name string) *cronJob {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, job := range cs.jobs {
+		if job.config.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// begin marks a job as running unless it already is
+func (cs *CronScheduler) begin(job *cronJob) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if job.running {
+		return false
+	}
+	job.running = true
+	return true
+}
+
+// execute runs a job marked as running by begin and records the run
+func (cs *CronScheduler) execute(job *cronJob, trigger string) JobRun {
+	cs.mu.Lock()
+	ctx := cs.ctx
+	cs.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	run := JobRun{Trigger: trigger, StartedAt: time.Now()}
+	result, err := invokeJob(ctx, job)
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+	run.Result = result
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("Job %s failed: %v", job.config.Name, err)
+	} else {
+		log.Printf("Job %s completed in %dms", job.config.Name, run.DurationMs)
+	}
+
+	cs.mu.Lock()
+	job.running = false
+	job.runs++
+	job.lastRun = &run
+	cs.mu.Unlock()
+
+	return run
+}
+
+// invokeJob calls a job's action, turning a panic into an error
+func invokeJob(ctx context.Context, job *cronJob) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return job.action.Run(ctx, job.config.Params)
+}
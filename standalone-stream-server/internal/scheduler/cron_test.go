@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+func TestParseJobSchedule_Timezone(t *testing.T) {
+	schedule, err := parseJobSchedule("0 3 * * *", "Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 03:00 in Shanghai is 19:00 UTC on the previous day
+	from := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if next := schedule.Next(from).UTC(); !next.Equal(time.Date(2024, 6, 1, 19, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected next run at 19:00 UTC, got %v", next)
+	}
+
+	if _, err := parseJobSchedule("@every 6h", "Europe/Berlin"); err != nil {
+		t.Errorf("Expected descriptor to be accepted: %v", err)
+	}
+	for spec, timezone := range map[string]string{"61 * * * *": "", "0 3 * * *": "Mars/Olympus"} {
+		if _, err := parseJobSchedule(spec, timezone); err == nil {
+			t.Errorf("Expected %q in %q to be rejected", spec, timezone)
+		}
+	}
+}
+
+func TestCronScheduler_RunAndStatus(t *testing.T) {
+	cs := NewCronScheduler()
+	release := make(chan struct{})
+	cs.RegisterAction("count", JobAction{
+		Validate: func(params map[string]string) error {
+			if params["fail"] == "invalid" {
+				return errors.New("invalid params")
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, params map[string]string) (interface{}, error) {
+			if params["block"] == "true" {
+				<-release
+			}
+			if params["fail"] == "true" {
+				return nil, errors.New("count failed")
+			}
+			return map[string]int{"counted": 3}, nil
+		},
+	})
+
+	if err := cs.AddJob(models.CronJobConfig{Name: "unknown", Schedule: "@daily", Action: "missing"}); err == nil {
+		t.Error("Expected unknown action to be rejected")
+	}
+	if err := cs.AddJob(models.CronJobConfig{Name: "invalid", Schedule: "@daily", Action: "count", Params: map[string]string{"fail": "invalid"}}); err == nil {
+		t.Error("Expected invalid params to be rejected")
+	}
+	for _, job := range []models.CronJobConfig{
+		{Name: "ok", Enabled: true, Schedule: "@hourly", Action: "count"},
+		{Name: "failing", Schedule: "@daily", Action: "count", Params: map[string]string{"fail": "true"}},
+		{Name: "slow", Schedule: "@daily", Action: "count", Params: map[string]string{"block": "true"}},
+	} {
+		if err := cs.AddJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run, err := cs.Run("ok")
+	if err != nil || run.Error != "" || run.Trigger != "manual" {
+		t.Fatalf("Expected successful manual run, got %+v %v", run, err)
+	}
+	if run, _ := cs.Run("failing"); run.Error != "count failed" {
+		t.Errorf("Expected run error to be recorded, got %+v", run)
+	}
+	if _, err := cs.Run("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+
+	// A running job cannot be started again
+	if _, err := cs.Trigger("slow"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.Trigger("slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning, got %v", err)
+	}
+	close(release)
+	waitFor(t, func() bool {
+		status, _ := cs.Job("slow")
+		return !status.Running && status.Runs == 1
+	})
+
+	status, _ := cs.Job("ok")
+	if status.Runs != 1 || status.LastRun == nil || status.NextRun != nil {
+		t.Errorf("Expected one run and no next run while stopped, got %+v", status)
+	}
+
+	cs.Start()
+	defer cs.Stop()
+	for _, status := range cs.Jobs() {
+		if (status.NextRun != nil) != status.Enabled {
+			t.Errorf("Job %s: only enabled jobs have a next run, got %+v", status.Name, status.NextRun)
+		}
+	}
+	if status, _ := cs.Job("ok"); status.NextRun == nil || time.Until(*status.NextRun) > time.Hour {
+		t.Errorf("Expected hourly job to run within the hour, got %v", status.NextRun)
+	}
+}
+
+func TestSchedulerService_Jobs(t *testing.T) {
+	tempDir := t.TempDir()
+	videoDir := filepath.Join(tempDir, "videos")
+	os.MkdirAll(videoDir, 0755)
+
+	old := filepath.Join(videoDir, "old.mp4")
+	recent := filepath.Join(videoDir, "recent.mp4")
+	for _, path := range []string{old, recent} {
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aMonthAgo := time.Now().AddDate(0, 0, -31)
+	os.Chtimes(old, aMonthAgo, aMonthAgo)
+
+	config := &models.Config{}
+	config.Video.SupportedFormats = []string{".mp4"}
+	config.Video.Directories = []models.VideoDirectory{{Name: "movies", Path: videoDir, Enabled: true}}
+	config.Scheduler.Queue.Path = filepath.Join(tempDir, "tasks", "tasks.db")
+	config.Scheduler.Jobs = []models.CronJobConfig{
+		{Name: "purge", Enabled: true, Schedule: "0 3 * * *", Action: ActionPurgeVideos, Params: map[string]string{"directory": "movies", "older_than_days": "30"}},
+		{Name: "compact", Schedule: "@weekly", Action: ActionCompactTasks},
+		{Name: "rescan", Schedule: "@daily", Action: ActionRescanCatalog},
+	}
+
+	ss, err := NewSchedulerService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+	ss.SetVideoServices(services.NewVideoService(config), services.NewMetadataService(config))
+
+	run, err := ss.cron.Run("purge")
+	if err != nil || run.Error != "" {
+		t.Fatalf("Purge failed: %+v %v", run, err)
+	}
+	tasks, _ := ss.ListTasks(TaskFilter{Type: VideoDeletionTaskType})
+	if len(tasks) != 1 || tasks[0].Data != `{"path":"`+old+`","deleted_by":"scheduler","reason":"older than 30 days"}` {
+		t.Fatalf("Expected only the old video to be queued for deletion, got %+v", tasks)
+	}
+
+	// A second run while the deletion is still pending does not queue it again
+	run, err = ss.cron.Run("purge")
+	if err != nil || run.Error != "" {
+		t.Fatalf("Purge failed: %+v %v", run, err)
+	}
+	if result := run.Result.(map[string]interface{}); result["queued"] != 0 || result["pending"] != 1 {
+		t.Errorf("Expected the pending deletion to be skipped, got %+v", result)
+	}
+	if tasks, _ := ss.ListTasks(TaskFilter{Type: VideoDeletionTaskType}); len(tasks) != 1 {
+		t.Fatalf("Expected a single deletion task, got %d", len(tasks))
+	}
+
+	if run, _ := ss.cron.Run("compact"); run.Error != "" || run.Result == nil {
+		t.Errorf("Compact failed: %+v", run)
+	}
+	if tasks, _ := ss.ListTasks(TaskFilter{}); len(tasks) != 1 {
+		t.Errorf("Expected queued tasks to survive compaction, got %d", len(tasks))
+	}
+
+	if run, _ := ss.cron.Run("rescan"); run.Error == "" {
+		t.Error("Expected rescan to fail without a catalog")
+	}
+
+	bad := *config
+	bad.Scheduler.Queue.Path = filepath.Join(tempDir, "other", "tasks.db")
+	bad.Scheduler.Jobs = []models.CronJobConfig{{Name: "purge", Schedule: "@daily", Action: ActionPurgeVideos, Params: map[string]string{"directory": "missing", "older_than_days": "30"}}}
+	if _, err := NewSchedulerService(&bad); err == nil {
+		t.Error("Expected purge job for an unknown directory to be rejected")
+	}
+}
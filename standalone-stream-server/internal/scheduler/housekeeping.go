@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+
+	"standalone-stream-server/internal/models"
+)
+
+// Housekeeping actions the scheduler runs as built-in jobs
+const (
+	// ActionCleanupTasks removes completed tasks older than the queue's retention
+	ActionCleanupTasks = "cleanup_tasks"
+
+	// ActionExpireUploads removes resumable uploads that have not been continued before they expired
+	ActionExpireUploads = "expire_uploads"
+)
+
+// Names of the built-in housekeeping jobs, added when no configured job uses their action
+const (
+	TaskCleanupJobName      = "task-cleanup"
+	UploadExpirationJobName = "upload-expiration"
+)
+
+// Schedules of the built-in housekeeping jobs
+const (
+	taskCleanupSchedule      = "@hourly"
+	uploadExpirationSchedule = "@every 15m"
+)
+
+// taskCleanupJob returns the built-in task cleanup job, unless the configuration schedules
+// cleanup_tasks itself
+func taskCleanupJob(config *models.Config) (models.CronJobConfig, bool) {
+	if jobUsesAction(config, ActionCleanupTasks) {
+		return models.CronJobConfig{}, false
+	}
+	return models.CronJobConfig{Name: TaskCleanupJobName, Enabled: true, Schedule: taskCleanupSchedule, Action: ActionCleanupTasks}, true
+}
+
+// uploadExpirationJob returns the built-in upload expiration job, if resumable uploads are enabled
+// and the configuration does not schedule expire_uploads itself
+func uploadExpirationJob(config *models.Config) (models.CronJobConfig, bool) {
+	if !config.Video.ResumableUpload.Enabled || jobUsesAction(config, ActionExpireUploads) {
+		return models.CronJobConfig{}, false
+	}
+	return models.CronJobConfig{Name: UploadExpirationJobName, Enabled: true, Schedule: uploadExpirationSchedule, Action: ActionExpireUploads}, true
+}
+
+// jobUsesAction reports whether a configured job runs the action
+func jobUsesAction(config *models.Config, action string) bool {
+	for _, job := range config.Scheduler.Jobs {
+		if job.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupTasks removes old completed tasks; dead letters are kept for inspection until retried or purged
+func (ss *SchedulerService) cleanupTasks(ctx context.Context, params map[string]string) (interface{}, error) {
+	removed, err := ss.storage.PurgeTasks(StatusCompleted, ss.storage.config.Retention)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"removed": removed}, nil
+}
+
+// expireUploads removes resumable uploads past their expiration time
+func (ss *SchedulerService) expireUploads(ctx context.Context, params map[string]string) (interface{}, error) {
+	if ss.resumableUploads == nil {
+		return nil, errors.New("resumable uploads are disabled")
+	}
+	removed, err := ss.resumableUploads.ExpireStale()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"removed": removed}, nil
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+func TestSchedulerService_HousekeepingJobs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := &models.Config{}
+	config.Video.Directories = []models.VideoDirectory{{Name: "movies", Path: filepath.Join(tempDir, "videos"), Enabled: true}}
+	config.Video.MaxUploadSize = 1024
+	config.Video.SupportedFormats = []string{".mp4"}
+	config.Video.ResumableUpload = models.ResumableUploadConfig{Enabled: true, Dir: filepath.Join(tempDir, "uploads"), Expiration: time.Nanosecond}
+	config.Scheduler.Queue.Path = filepath.Join(tempDir, "tasks", "tasks.db")
+
+	ss, err := NewSchedulerService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+
+	if job, err := ss.cron.Job(TaskCleanupJobName); err != nil || job.Schedule != taskCleanupSchedule {
+		t.Fatalf("Expected the built-in task cleanup job, got %+v %v", job, err)
+	}
+	if run, err := ss.cron.Run(TaskCleanupJobName); err != nil || run.Error != "" {
+		t.Fatalf("Task cleanup failed: %+v %v", run, err)
+	}
+
+	// Until the store is set the expiration job fails instead of doing nothing silently
+	if run, err := ss.cron.Run(UploadExpirationJobName); err != nil || run.Error == "" {
+		t.Fatalf("Expected upload expiration to fail without a store, got %+v %v", run, err)
+	}
+
+	store, err := services.NewResumableUploadStore(config, services.NewVideoService(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.SetResumableUploadStore(store)
+	if _, err := store.Create("movies", "stale.mp4", "", 8, nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	run, err := ss.cron.Run(UploadExpirationJobName)
+	if err != nil || run.Error != "" {
+		t.Fatalf("Upload expiration failed: %+v %v", run, err)
+	}
+	if result := run.Result.(map[string]int); result["removed"] != 1 {
+		t.Errorf("Expected one expired upload to be removed, got %+v", result)
+	}
+
+	// Without resumable uploads there is no expiration job, and a configured job replaces the built-in one
+	disabled := *config
+	disabled.Video.ResumableUpload = models.ResumableUploadConfig{}
+	disabled.Scheduler.Jobs = []models.CronJobConfig{{Name: "nightly-cleanup", Enabled: true, Schedule: "0 4 * * *", Action: ActionCleanupTasks}}
+	disabled.Scheduler.Queue.Path = filepath.Join(tempDir, "other", "tasks.db")
+	other, err := NewSchedulerService(&disabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.cron.Job(UploadExpirationJobName); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected no upload expiration job, got %v", err)
+	}
+	if _, err := other.cron.Job(TaskCleanupJobName); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected the configured job to replace the built-in task cleanup, got %v", err)
+	}
+}
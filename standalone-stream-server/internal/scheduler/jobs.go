@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"standalone-stream-server/internal/services"
+)
+
+// Built-in recurring job actions
+const (
+	ActionPurgeVideos          = "purge_videos"
+	ActionRegenerateThumbnails = "regenerate_thumbnails"
+	ActionCompactTasks         = "compact_tasks"
+	ActionRescanCatalog        = "rescan_catalog"
+)
+
+// registerJobActions makes the built-in actions available to recurring jobs. Actions that need a
+// service fail at run time until the service is set.
+func (ss *SchedulerService) registerJobActions() {
+	ss.cron.RegisterAction(ActionPurgeVideos, JobAction{Validate: ss.validatePurgeVideos, Run: ss.purgeVideos})
+	ss.cron.RegisterAction(ActionRegenerateThumbnails, JobAction{Validate: ss.validateDirectoryParam, Run: ss.regenerateThumbnails})
+	ss.cron.RegisterAction(ActionGenerateSprites, JobAction{Validate: ss.validateDirectoryParam, Run: ss.generateSprites})
+	ss.cron.RegisterAction(ActionCompactTasks, JobAction{Run: ss.compactTasks})
+	ss.cron.RegisterAction(ActionRescanCatalog, JobAction{Validate: validateRescanCatalog, Run: ss.rescanCatalog})
+	ss.cron.RegisterAction(ActionApplyRetention, JobAction{Validate: ss.validateApplyRetention, Run: ss.applyRetention})
+	ss.cron.RegisterAction(ActionPurgeTrash, JobAction{Run: ss.purgeTrash})
+	ss.cron.RegisterAction(ActionCleanupTasks, JobAction{Run: ss.cleanupTasks})
+	ss.cron.RegisterAction(ActionExpireUploads, JobAction{Run: ss.expireUploads})
+}
+
+// validatePurgeVideos requires a configured directory and a positive older_than_days
+func (ss *SchedulerService) validatePurgeVideos(params map[string]string) error {
+	if params["directory"] == "" {
+		return errors.New("purge_videos requires a directory")
+	}
+	if err := ss.validateDirectoryParam(params); err != nil {
+		return err
+	}
+	if days, err := strconv.Atoi(params["older_than_days"]); err != nil || days <= 0 {
+		return fmt.Errorf("purge_videos requires a positive older_than_days, got %q", params["older_than_days"])
+	}
+	return nil
+}
+
+// validateDirectoryParam checks that an optional directory parameter names a configured directory
+func (ss *SchedulerService) validateDirectoryParam(params map[string]string) error {
+	name := params["directory"]
+	if name == "" {
+		return nil
+	}
+	for _, dir := range ss.config.Video.Directories {
+		if dir.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown video directory %q", name)
+}
+
+// validateRescanCatalog checks the optional full parameter
+func validateRescanCatalog(params map[string]string) error {
+	if full, ok := params["full"]; ok {
+		if _, err := strconv.ParseBool(full); err != nil {
+			return fmt.Errorf("rescan_catalog full must be true or false, got %q", full)
+		}
+	}
+	return nil
+}
+
+// listVideos returns the videos of a directory, or of all directories when directory is empty
+func (ss *SchedulerService) listVideos(directory string) ([]services.VideoInfo, error) {
+	if ss.videoService == nil {
+		return nil, errors.New("video service is not configured")
+	}
+	if directory == "" {
+		return ss.videoService.ListAllVideos()
+	}
+	return ss.videoService.ListVideosInDirectory(directory)
+}
+
+// purgeVideos queues deletion of the videos in a directory last modified more than older_than_days ago,
+// skipping videos that already have a deletion queued
+func (ss *SchedulerService) purgeVideos(ctx context.Context, params map[string]string) (interface{}, error) {
+	days, _ := strconv.Atoi(params["older_than_days"])
+	videos, err := ss.listVideos(params["directory"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Videos whose deletion is still waiting or running are not queued again
+	pending, err := ss.queuedDeletions()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days).Unix()
+	result := map[string]interface{}{
+		"directory": params["directory"],
+		"checked":   len(videos),
+		"queued":    0,
+		"pending":   0,
+	}
+	queued, skipped := 0, 0
+	for _, video := range videos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if video.Modified >= cutoff {
+			continue
+		}
+		if pending[video.Path] {
+			skipped++
+			result["pending"] = skipped
+			continue
+		}
+		job := VideoDeletionJob{Path: video.Path, DeletedBy: "scheduler", Reason: fmt.Sprintf("older than %d days", days)}
+		if err := ss.QueueVideoDeletion(job); err != nil {
+			return result, fmt.Errorf("failed to queue deletion of %s: %w", video.ID, err)
+		}
+		queued++
+		result["queued"] = queued
+	}
+
+	return result, nil
+}
+
+// regenerateThumbnails generates the thumbnails missing for the videos of a directory (all directories by default)
+func (ss *SchedulerService) regenerateThumbnails(ctx context.Context, params map[string]string) (interface{}, error) {
+	if ss.metadataService == nil {
+		return nil, errors.New("metadata service is not configured")
+	}
+	videos, err := ss.listVideos(params["directory"])
+	if err != nil {
+		return nil, err
+	}
+
+	generated, failed := 0, 0
+	for _, video := range videos {
+		if err := ctx.Err(); err != nil {
+			return map[string]int{"checked": len(videos), "generated": generated, "failed": failed}, err
+		}
+		created, err := ss.metadataService.EnsureThumbnail(video)
+		if err != nil {
+			log.Printf("Failed to generate thumbnail for %s: %v", video.ID, err)
+			failed++
+		} else if created {
+			generated++
+		}
+	}
+
+	result := map[string]int{"checked": len(videos), "generated": generated, "failed": failed}
+	if failed > 0 {
+		return result, fmt.Errorf("failed to generate %d thumbnails", failed)
+	}
+	return result, nil
+}
+
+// compactTasks purges old completed tasks and rewrites the task queue file
+func (ss *SchedulerService) compactTasks(ctx context.Context, params map[string]string) (interface{}, error) {
+	return ss.storage.Compact()
+}
+
+// rescanCatalog refreshes the video catalog, or rebuilds it when full is true
+func (ss *SchedulerService) rescanCatalog(ctx context.Context, params map[string]string) (interface{}, error) {
+	if ss.catalogIndexer == nil {
+		return nil, errors.New("video catalog is disabled")
+	}
+	if full, _ := strconv.ParseBool(params["full"]); full {
+		return ss.catalogIndexer.Rebuild()
+	}
+	return ss.catalogIndexer.Refresh()
+}
@@ -0,0 +1,494 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// Task registry errors
+var (
+	ErrUnknownTaskType = errors.New("unknown task type")
+	ErrInvalidPayload  = errors.New("invalid task payload")
+)
+
+const (
+	defaultTaskConcurrency  = 1
+	defaultTaskPollInterval = 30 * time.Second
+)
+
+// TaskType registers a named task type whose payload is decoded from JSON into P.
+// Packages describe their background work with a TaskType and register it with RegisterTaskType;
+// the registry claims tasks of the type from the queue and records each outcome.
+type TaskType[P any] struct {
+	Name         string
+	Concurrency  int           // at most this many tasks of the type run at once (default 1)
+	PollInterval time.Duration // how often the queue is checked for tasks that became due, e.g. retries (default 30s)
+
+	// Validate checks a decoded payload; it runs when a task is enqueued and again before it runs
+	Validate func(payload P) error
+
+	// Handle runs one task. A returned error retries the task with backoff unless it is wrapped
+	// with Permanent; the result is stored with the task either way.
+	Handle func(tc *TaskContext, payload P) (interface{}, error)
+}
+
+// TaskContext is passed to task handlers; it is cancelled when the scheduler stops
+type TaskContext struct {
+	context.Context
+	Task TaskRecord
+
+	storage *TaskStorage
+}
+
+// Progress reports the progress (0-1) of the running task and renews its lease
+func (tc *TaskContext) Progress(progress float64) {
+	if err := tc.storage.UpdateTaskProgress(tc.Task, progress); err != nil {
+		log.Printf("Failed to update progress of task %s: %v", tc.Task.ID, err)
+	}
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a handler error so the task becomes a dead letter without further attempts
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// legacyPayload is implemented by payloads that can also be decoded from the plain strings stored
+// by tasks queued before their type was registered with a JSON payload
+type legacyPayload interface {
+	decodeLegacy(data string) error
+}
+
+// TaskTypeInfo describes a registered task type
+type TaskTypeInfo struct {
+	Name         string                 `json:"name"`
+	Concurrency  int                    `json:"concurrency"`
+	PollInterval string                 `json:"poll_interval"`
+	Running      int                    `json:"running"`
+	Schema       map[string]interface{} `json:"schema"` // JSON schema of the payload
+}
+
+// registeredType is a TaskType with its payload type erased
+type registeredType struct {
+	name         string
+	concurrency  int
+	pollInterval time.Duration
+	schema       map[string]interface{}
+	parse        func(data string, strict bool) (interface{}, error)
+	handle       func(tc *TaskContext, payload interface{}) (interface{}, error)
+	wake         chan struct{}
+	running      int // guarded by the registry's mutex
+}
+
+// signal wakes the type's worker loop without blocking
+func (rt *registeredType) signal() {
+	select {
+	case rt.wake <- struct{}{}:
+	default:
+	}
+}
+
+// TaskRegistry holds the registered task types and runs a worker pool per type against the queue
+type TaskRegistry struct {
+	storage   *TaskStorage
+	overrides map[string]models.TaskTypeConfig
+
+	mu       sync.Mutex
+	types    map[string]*registeredType
+	stopChan chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	loops    sync.WaitGroup
+}
+
+// NewTaskRegistry creates a registry for the queue; overrides replace the concurrency and poll
+// interval of the named task types
+func NewTaskRegistry(storage *TaskStorage, overrides map[string]models.TaskTypeConfig) *TaskRegistry {
+	r := &TaskRegistry{
+		storage:   storage,
+		overrides: overrides,
+		types:     make(map[string]*registeredType),
+	}
+	storage.SetNotifier(r.wake)
+	return r
+}
+
+// RegisterTaskType adds a task type to the registry; registering after Start starts its workers immediately
+func RegisterTaskType[P any](r *TaskRegistry, spec TaskType[P]) error {
+	if spec.Name == "" || spec.Handle == nil {
+		return errors.New("task type requires a name and a handler")
+	}
+
+	rt := &registeredType{
+		name:         spec.Name,
+		concurrency:  spec.Concurrency,
+		pollInterval: spec.PollInterval,
+		schema:       payloadSchema(reflect.TypeOf((*P)(nil)).Elem()),
+		wake:         make(chan struct{}, 1),
+	}
+	if override, ok := r.overrides[spec.Name]; ok {
+		if override.Concurrency > 0 {
+			rt.concurrency = override.Concurrency
+		}
+		if override.PollInterval > 0 {
+			rt.pollInterval = override.PollInterval
+		}
+	}
+	if rt.concurrency <= 0 {
+		rt.concurrency = defaultTaskConcurrency
+	}
+	if rt.pollInterval <= 0 {
+		rt.pollInterval = defaultTaskPollInterval
+	}
+
+	rt.parse = func(data string, strict bool) (interface{}, error) {
+		var payload P
+		decoder := json.NewDecoder(strings.NewReader(data))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(&payload); err != nil {
+			legacy, ok := any(&payload).(legacyPayload)
+			if strict || !ok || legacy.decodeLegacy(data) != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+			}
+		} else if strict {
+			if err := checkRequired(data, rt.schema); err != nil {
+				return nil, err
+			}
+		}
+		if spec.Validate != nil {
+			if err := spec.Validate(payload); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+			}
+		}
+		return payload, nil
+	}
+	rt.handle = func(tc *TaskContext, payload interface{}) (interface{}, error) {
+		return spec.Handle(tc, payload.(P))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.types[spec.Name]; exists {
+		return fmt.Errorf("task type %s is already registered", spec.Name)
+	}
+	r.types[spec.Name] = rt
+	if r.stopChan != nil {
+		r.startLoop(rt)
+	}
+	return nil
+}
+
+// Enqueue validates a JSON payload against the task type and queues a task
+func (r *TaskRegistry) Enqueue(name string, payload json.RawMessage) (TaskRecord, error) {
+	rt := r.lookup(name)
+	if rt == nil {
+		return TaskRecord{}, fmt.Errorf("%w: %s", ErrUnknownTaskType, name)
+	}
+
+	if len(bytes.TrimSpace(payload)) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	if _, err := rt.parse(string(payload), true); err != nil {
+		return TaskRecord{}, err
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, payload); err != nil {
+		return TaskRecord{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return r.storage.CreateTask(name, compact.String())
+}
+
+// EnqueueTask queues a task with a typed payload
+func EnqueueTask[P any](r *TaskRegistry, name string, payload P) (TaskRecord, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return TaskRecord{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return r.Enqueue(name, data)
+}
+
+// Types returns the registered task types sorted by name
+func (r *TaskRegistry) Types() []TaskTypeInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]TaskTypeInfo, 0, len(r.types))
+	for _, rt := range r.types {
+		infos = append(infos, TaskTypeInfo{
+			Name:         rt.name,
+			Concurrency:  rt.concurrency,
+			PollInterval: rt.pollInterval.String(),
+			Running:      rt.running,
+			Schema:       rt.schema,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Start starts a worker loop for every registered task type
+func (r *TaskRegistry) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopChan != nil {
+		return
+	}
+	r.stopChan = make(chan struct{})
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	for _, rt := range r.types {
+		r.startLoop(rt)
+	}
+}
+
+// Stop stops claiming tasks and cancels the context of running ones. Tasks still running finish
+// in the background; tasks that never record their outcome are reclaimed after the visibility timeout.
+func (r *TaskRegistry) Stop() {
+	r.mu.Lock()
+	if r.stopChan == nil {
+		r.mu.Unlock()
+		return
+	}
+	close(r.stopChan)
+	r.cancel()
+	r.stopChan = nil
+	r.mu.Unlock()
+
+	r.loops.Wait()
+}
+
+// RunPending claims and runs every due task of a type until none is left, returning how many ran.
+// It is meant for tests and tools; running workers claim tasks on their own.
+func (r *TaskRegistry) RunPending(name string) (int, error) {
+	rt := r.lookup(name)
+	if rt == nil {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownTaskType, name)
+	}
+
+	ran := 0
+	for {
+		tasks, err := r.storage.Claim(name, rt.concurrency)
+		if err != nil || len(tasks) == 0 {
+			return ran, err
+		}
+
+		var wg sync.WaitGroup
+		for _, task := range tasks {
+			wg.Add(1)
+			go func(task TaskRecord) {
+				defer wg.Done()
+				r.execute(context.Background(), rt, task)
+			}(task)
+		}
+		wg.Wait()
+		ran += len(tasks)
+	}
+}
+
+// lookup returns a registered type or nil
+func (r *TaskRegistry) lookup(name string) *registeredType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.types[name]
+}
+
+// wake is the queue notifier: a task of the type became runnable
+func (r *TaskRegistry) wake(taskType string) {
+	if rt := r.lookup(taskType); rt != nil {
+		rt.signal()
+	}
+}
+
+// startLoop starts the worker loop of a type; the caller holds the mutex
+func (r *TaskRegistry) startLoop(rt *registeredType) {
+	r.loops.Add(1)
+	go r.loop(rt, r.stopChan, r.ctx)
+}
+
+// loop claims tasks whenever the type has free workers: on every poll, when a task is queued and
+// when a running task finishes
+func (r *TaskRegistry) loop(rt *registeredType, stopChan chan struct{}, ctx context.Context) {
+	defer r.loops.Done()
+
+	ticker := time.NewTicker(rt.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.dispatch(ctx, rt)
+
+		select {
+		case <-ticker.C:
+		case <-rt.wake:
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// dispatch claims up to the type's free capacity and runs the tasks in the background
+func (r *TaskRegistry) dispatch(ctx context.Context, rt *registeredType) {
+	r.mu.Lock()
+	free := rt.concurrency - rt.running
+	r.mu.Unlock()
+	if free <= 0 {
+		return
+	}
+
+	tasks, err := r.storage.Claim(rt.name, free)
+	if err != nil {
+		log.Printf("Failed to claim %s tasks: %v", rt.name, err)
+		return
+	}
+
+	for _, task := range tasks {
+		r.mu.Lock()
+		rt.running++
+		r.mu.Unlock()
+
+		go func(task TaskRecord) {
+			defer func() {
+				r.mu.Lock()
+				rt.running--
+				r.mu.Unlock()
+				rt.signal()
+			}()
+			r.execute(ctx, rt, task)
+		}(task)
+	}
+}
+
+// execute runs a claimed task and records its outcome in the queue
+func (r *TaskRegistry) execute(ctx context.Context, rt *registeredType, task TaskRecord) {
+	result, err := r.run(ctx, rt, task)
+
+	var permanent permanentError
+	switch {
+	case err == nil:
+		err = r.storage.Ack(task, result)
+	case errors.As(err, &permanent):
+		log.Printf("Task %s failed permanently: %v", task.ID, err)
+		err = r.storage.DeadLetter(task, result, err.Error())
+	default:
+		log.Printf("Task %s failed (attempt %d of %d): %v", task.ID, task.Attempts, task.MaxAttempts, err)
+		err = r.storage.Nack(task, result, err)
+	}
+	if err != nil {
+		log.Printf("Failed to record outcome of task %s: %v", task.ID, err)
+	}
+}
+
+// run decodes the payload and calls the handler, turning a panic into a failed attempt
+func (r *TaskRegistry) run(ctx context.Context, rt *registeredType, task TaskRecord) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("task handler panicked: %v", p)
+		}
+	}()
+
+	payload, err := rt.parse(task.Data, false)
+	if err != nil {
+		// Retrying cannot fix an invalid payload
+		return nil, Permanent(err)
+	}
+	return rt.handle(&TaskContext{Context: ctx, Task: task, storage: r.storage}, payload)
+}
+
+// checkRequired reports the required payload fields missing from a JSON object
+func checkRequired(data string, schema map[string]interface{}) error {
+	required, _ := schema["required"].([]string)
+	if len(required) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	var missing []string
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing required fields: %s", ErrInvalidPayload, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// payloadSchema describes the JSON encoding of a payload type. Struct fields without omitempty are required.
+func payloadSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = payloadSchema(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": payloadSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// echoJob is the payload of the test task type
+type echoJob struct {
+	Message string `json:"message"`
+	Repeat  int    `json:"repeat,omitempty"`
+}
+
+func TestTaskRegistry_EnqueueValidatesPayload(t *testing.T) {
+	ts, _ := newTestQueue(t, models.TaskQueueConfig{})
+	registry := NewTaskRegistry(ts, nil)
+	err := RegisterTaskType(registry, TaskType[echoJob]{
+		Name: "echo",
+		Validate: func(job echoJob) error {
+			if job.Repeat < 0 {
+				return errors.New("repeat cannot be negative")
+			}
+			return nil
+		},
+		Handle: func(tc *TaskContext, job echoJob) (interface{}, error) { return nil, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterTaskType(registry, TaskType[echoJob]{Name: "echo", Handle: func(*TaskContext, echoJob) (interface{}, error) { return nil, nil }}); err == nil {
+		t.Error("Expected duplicate registration to fail")
+	}
+
+	if _, err := registry.Enqueue("missing", nil); !errors.Is(err, ErrUnknownTaskType) {
+		t.Errorf("Expected ErrUnknownTaskType, got %v", err)
+	}
+	for _, payload := range []string{`{}`, `{"message":"hi","color":"red"}`, `{"message":"hi","repeat":-1}`, `[1]`} {
+		if _, err := registry.Enqueue("echo", json.RawMessage(payload)); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Expected ErrInvalidPayload for %s, got %v", payload, err)
+		}
+	}
+
+	task, err := registry.Enqueue("echo", json.RawMessage(`{ "message": "hi" }`))
+	if err != nil {
+		t.Fatalf("Expected valid payload to be queued: %v", err)
+	}
+	if task.Type != "echo" || task.Data != `{"message":"hi"}` || task.Status != StatusPending {
+		t.Errorf("Unexpected queued task: %+v", task)
+	}
+
+	types := registry.Types()
+	if len(types) != 1 || types[0].Concurrency != 1 || types[0].PollInterval != "30s" {
+		t.Fatalf("Expected defaults for the registered type, got %+v", types)
+	}
+	if required, _ := types[0].Schema["required"].([]string); len(required) != 1 || required[0] != "message" {
+		t.Errorf("Expected message to be the only required field, got %v", types[0].Schema)
+	}
+}
+
+func TestTaskRegistry_Outcomes(t *testing.T) {
+	ts, _ := newTestQueue(t, models.TaskQueueConfig{MaxAttempts: 3})
+	registry := NewTaskRegistry(ts, nil)
+	RegisterTaskType(registry, TaskType[echoJob]{
+		Name:        "echo",
+		Concurrency: 4,
+		Handle: func(tc *TaskContext, job echoJob) (interface{}, error) {
+			switch job.Message {
+			case "fail":
+				return nil, errors.New("temporary failure")
+			case "reject":
+				return nil, Permanent(errors.New("cannot be done"))
+			case "panic":
+				panic("boom")
+			}
+			tc.Progress(0.5)
+			return map[string]string{"echo": job.Message}, nil
+		},
+	})
+
+	ids := make(map[string]string)
+	for _, message := range []string{"hello", "fail", "reject", "panic"} {
+		task, err := EnqueueTask(registry, "echo", echoJob{Message: message})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[message] = task.ID
+	}
+	// Stored payloads are decoded leniently, but still validated
+	broken, _ := ts.CreateTask("echo", "not json")
+
+	if ran, err := registry.RunPending("echo"); err != nil || ran != 5 {
+		t.Fatalf("Expected 5 tasks to run, got %d: %v", ran, err)
+	}
+
+	expected := map[string]string{
+		ids["hello"]:  StatusCompleted,
+		ids["fail"]:   StatusPending,
+		ids["reject"]: StatusDead,
+		ids["panic"]:  StatusPending,
+		broken.ID:     StatusDead,
+	}
+	for id, status := range expected {
+		record, _ := ts.GetTask(id)
+		if record.Status != status {
+			t.Errorf("Task %s: expected %s, got %s (error %q)", id, status, record.Status, record.Error)
+		}
+	}
+	if record, _ := ts.GetTask(ids["hello"]); string(record.Result) != `{"echo":"hello"}` {
+		t.Errorf("Expected handler result to be stored, got %s", record.Result)
+	}
+}
+
+func TestTaskRegistry_WorkersRespectConcurrency(t *testing.T) {
+	ts, _ := newTestQueue(t, models.TaskQueueConfig{})
+	// The override raises the concurrency; the long poll interval shows that enqueueing wakes the workers
+	registry := NewTaskRegistry(ts, map[string]models.TaskTypeConfig{"echo": {Concurrency: 2}})
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+	RegisterTaskType(registry, TaskType[echoJob]{
+		Name:         "echo",
+		Concurrency:  1,
+		PollInterval: time.Hour,
+		Handle: func(tc *TaskContext, job echoJob) (interface{}, error) {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil, nil
+		},
+	})
+
+	registry.Start()
+	defer registry.Stop()
+	for i := 0; i < 5; i++ {
+		EnqueueTask(registry, "echo", echoJob{Message: "hi"})
+	}
+
+	// Two tasks run at once; each freed worker picks up the next task without waiting for a poll
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return running == 2
+	})
+	for i := 0; i < 5; i++ {
+		select {
+		case release <- struct{}{}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for task %d to run", i+1)
+		}
+	}
+
+	waitFor(t, func() bool {
+		stats, _ := ts.GetTaskStats()
+		return stats[StatusCompleted] == 5
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 2 {
+		t.Errorf("Expected exactly 2 concurrent tasks, peak was %d", peak)
+	}
+}
+
+func TestVideoCleanupService_TaskType(t *testing.T) {
+	videoDir := t.TempDir()
+	ts, _ := newTestQueue(t, models.TaskQueueConfig{})
+	vcs := NewVideoCleanupService(ts, []string{videoDir})
+	registry := NewTaskRegistry(ts, nil)
+	if err := RegisterTaskType(registry, vcs.TaskType()); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "other.mp4")
+	if _, err := EnqueueTask(registry, VideoDeletionTaskType, VideoDeletionJob{Path: outside}); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Expected deletion outside the video directories to be refused, got %v", err)
+	}
+	if _, err := EnqueueTask(registry, VideoDeletionTaskType, VideoDeletionJob{Path: videoDir + "/../escape.mp4"}); err == nil {
+		t.Error("Expected relative escape from the video directory to be refused")
+	}
+
+	queued := filepath.Join(videoDir, "queued.mp4")
+	legacy := filepath.Join(videoDir, "legacy.mp4")
+	for _, path := range []string{queued, legacy} {
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := EnqueueTask(registry, VideoDeletionTaskType, VideoDeletionJob{Path: queued}); err != nil {
+		t.Fatal(err)
+	}
+	// Tasks queued by older versions stored the bare path
+	ts.CreateTask(VideoDeletionTaskType, legacy)
+
+	if ran, _ := registry.RunPending(VideoDeletionTaskType); ran != 2 {
+		t.Fatalf("Expected 2 deletion tasks to run, ran %d", ran)
+	}
+	for _, path := range []string{queued, legacy} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted", path)
+		}
+	}
+}
+
+// waitFor polls condition until it holds or the test times out
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+// ActionApplyRetention queues deletion of the videos selected by the directories' retention policies
+const ActionApplyRetention = "apply_retention"
+
+// ErrNoRetentionPolicy is returned when retention is requested for a directory without an enabled policy
+var ErrNoRetentionPolicy = errors.New("no retention policy")
+
+// RetentionJobName is the name of the job added when a directory has a retention policy and no
+// configured job uses the apply_retention action
+const RetentionJobName = "directory-retention"
+
+// defaultRetentionSchedule is used when scheduler.retention_schedule is empty
+const defaultRetentionSchedule = "@hourly"
+
+// RetentionResult summarizes one evaluation of the retention policies
+type RetentionResult struct {
+	DryRun     bool                     `json:"dry_run"`
+	Plans      []services.RetentionPlan `json:"plans"`
+	Candidates int                      `json:"candidates"`
+	Freed      int64                    `json:"freed"`             // bytes the candidates occupy
+	Queued     int                      `json:"queued"`            // deletion tasks created
+	Pending    int                      `json:"pending,omitempty"` // candidates whose deletion was already queued
+}
+
+// retentionJob returns the built-in retention job, if any enabled directory has a policy and the
+// configuration does not schedule apply_retention itself
+func retentionJob(config *models.Config) (models.CronJobConfig, bool) {
+	for _, job := range config.Scheduler.Jobs {
+		if job.Action == ActionApplyRetention {
+			return models.CronJobConfig{}, false
+		}
+	}
+	if len(retentionDirectories(config, "")) == 0 {
+		return models.CronJobConfig{}, false
+	}
+
+	schedule := config.Scheduler.RetentionSchedule
+	if schedule == "" {
+		schedule = defaultRetentionSchedule
+	}
+	return models.CronJobConfig{Name: RetentionJobName, Enabled: true, Schedule: schedule, Action: ActionApplyRetention}, true
+}
+
+// retentionDirectories returns the enabled directories with a retention policy, restricted to name when set
+func retentionDirectories(config *models.Config, name string) []models.VideoDirectory {
+	var dirs []models.VideoDirectory
+	for _, dir := range config.Video.Directories {
+		if dir.Enabled && services.HasRetentionPolicy(dir.Retention) && (name == "" || dir.Name == name) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// validateApplyRetention checks that an optional directory parameter names an enabled directory with a retention policy
+func (ss *SchedulerService) validateApplyRetention(params map[string]string) error {
+	if name := params["directory"]; name != "" && len(retentionDirectories(ss.config, name)) == 0 {
+		return fmt.Errorf("%w for video directory %q", ErrNoRetentionPolicy, name)
+	}
+	return nil
+}
+
+// PlanRetention evaluates the retention policies of every directory, or of one directory, without
+// deleting or queueing anything
+func (ss *SchedulerService) PlanRetention(directory string) (RetentionResult, error) {
+	if err := ss.validateApplyRetention(map[string]string{"directory": directory}); err != nil {
+		return RetentionResult{}, err
+	}
+	if ss.videoService == nil {
+		return RetentionResult{}, errors.New("video service is not configured")
+	}
+
+	result := RetentionResult{DryRun: true, Plans: []services.RetentionPlan{}}
+	now := time.Now()
+	for _, dir := range retentionDirectories(ss.config, directory) {
+		plan, err := ss.videoService.PlanRetention(dir.Name, now)
+		if err != nil {
+			return result, err
+		}
+		result.Plans = append(result.Plans, plan)
+		result.Candidates += len(plan.Candidates)
+		result.Freed += plan.TotalSize - plan.SizeAfter
+	}
+	return result, nil
+}
+
+// applyRetention queues a video_deletion task for every retention candidate not already queued
+func (ss *SchedulerService) applyRetention(ctx context.Context, params map[string]string) (interface{}, error) {
+	result, err := ss.PlanRetention(params["directory"])
+	if err != nil {
+		return nil, err
+	}
+	result.DryRun = false
+
+	queued, err := ss.queuedDeletions()
+	if err != nil {
+		return result, err
+	}
+	for _, plan := range result.Plans {
+		for _, candidate := range plan.Candidates {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			if queued[candidate.Path] {
+				result.Pending++
+				continue
+			}
+			job := VideoDeletionJob{Path: candidate.Path, DeletedBy: "scheduler", Reason: "retention: " + candidate.Reason}
+			if err := ss.QueueVideoDeletion(job); err != nil {
+				return result, fmt.Errorf("failed to queue deletion of %s: %w", candidate.VideoID, err)
+			}
+			queued[candidate.Path] = true
+			result.Queued++
+		}
+	}
+	return result, nil
+}
+
+// queuedDeletions returns the paths of deletion tasks that are waiting or running, so that a
+// candidate is not queued again on every evaluation
+func (ss *SchedulerService) queuedDeletions() (map[string]bool, error) {
+	paths := make(map[string]bool)
+	for _, status := range []string{StatusPending, StatusProcessing} {
+		tasks, err := ss.storage.ListTasks(TaskFilter{Type: VideoDeletionTaskType, Status: status, Limit: math.MaxInt32})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			var job VideoDeletionJob
+			if err := json.Unmarshal([]byte(task.Data), &job); err != nil {
+				job.decodeLegacy(task.Data)
+			}
+			paths[job.Path] = true
+		}
+	}
+	return paths, nil
+}
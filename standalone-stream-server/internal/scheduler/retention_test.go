@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+func TestSchedulerService_Retention(t *testing.T) {
+	tempDir := t.TempDir()
+	videoDir := filepath.Join(tempDir, "videos")
+	os.MkdirAll(filepath.Join(videoDir, "favorites"), 0755)
+
+	old := filepath.Join(videoDir, "old.mp4")
+	favorite := filepath.Join(videoDir, "favorites", "classic.mp4")
+	recent := filepath.Join(videoDir, "recent.mp4")
+	for _, path := range []string{old, favorite, recent} {
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aYearAgo := time.Now().AddDate(-1, 0, 0)
+	os.Chtimes(old, aYearAgo, aYearAgo)
+	os.Chtimes(favorite, aYearAgo, aYearAgo)
+
+	config := &models.Config{}
+	config.Video.SupportedFormats = []string{".mp4"}
+	config.Video.Directories = []models.VideoDirectory{
+		{Name: "movies", Path: videoDir, Enabled: true, Retention: models.RetentionPolicy{MaxAge: 30 * 24 * time.Hour, Exclude: []string{"favorites/*"}}},
+		{Name: "series", Path: videoDir, Enabled: true},
+	}
+	config.Scheduler.Queue.Path = filepath.Join(tempDir, "tasks", "tasks.db")
+	config.Scheduler.RetentionSchedule = "@daily"
+
+	ss, err := NewSchedulerService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+	ss.SetVideoServices(services.NewVideoService(config), services.NewMetadataService(config))
+
+	job, err := ss.cron.Job(RetentionJobName)
+	if err != nil || !job.Enabled || job.Schedule != "@daily" || job.Action != ActionApplyRetention {
+		t.Fatalf("Expected the built-in retention job, got %+v %v", job, err)
+	}
+
+	// The dry run lists the candidates without queueing anything
+	plan, err := ss.PlanRetention("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.DryRun || len(plan.Plans) != 1 || plan.Candidates != 1 || plan.Plans[0].Candidates[0].Path != old {
+		t.Fatalf("Expected only the old movie to be a candidate, got %+v", plan)
+	}
+	if tasks, _ := ss.ListTasks(TaskFilter{}); len(tasks) != 0 {
+		t.Fatalf("Expected the dry run not to queue tasks, got %d", len(tasks))
+	}
+	if _, err := ss.PlanRetention("series"); !errors.Is(err, ErrNoRetentionPolicy) {
+		t.Errorf("Expected ErrNoRetentionPolicy for a directory without a policy, got %v", err)
+	}
+
+	// Applying queues the deletion once; a second evaluation finds it already pending
+	run, err := ss.cron.Run(RetentionJobName)
+	if err != nil || run.Error != "" {
+		t.Fatalf("Retention failed: %+v %v", run, err)
+	}
+	if result := run.Result.(RetentionResult); result.DryRun || result.Queued != 1 {
+		t.Errorf("Expected one deletion to be queued, got %+v", result)
+	}
+	run, _ = ss.cron.Run(RetentionJobName)
+	if result := run.Result.(RetentionResult); result.Queued != 0 || result.Pending != 1 {
+		t.Errorf("Expected the queued deletion not to be queued again, got %+v", result)
+	}
+
+	tasks, _ := ss.ListTasks(TaskFilter{Type: VideoDeletionTaskType})
+	if len(tasks) != 1 || tasks[0].Data != `{"path":"`+old+`","deleted_by":"scheduler","reason":"retention: max_age"}` {
+		t.Fatalf("Expected one deletion task for the old movie, got %+v", tasks)
+	}
+
+	// A configured apply_retention job replaces the built-in one
+	custom := *config
+	custom.Scheduler.Queue.Path = filepath.Join(tempDir, "other", "tasks.db")
+	custom.Scheduler.Jobs = []models.CronJobConfig{{Name: "movies-retention", Schedule: "0 4 * * *", Action: ActionApplyRetention, Params: map[string]string{"directory": "movies"}}}
+	other, err := NewSchedulerService(&custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.cron.Job(RetentionJobName); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected no built-in retention job, got %v", err)
+	}
+}
@@ -1,30 +1,38 @@
 package scheduler
 
 import (
+	"encoding/json"
 	"log"
-	"path/filepath"
 	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
 	"sync"
 	"time"
 )
 
 // SchedulerService manages all background tasks and workers
 type SchedulerService struct {
-	config             *models.Config
-	storage            *TaskStorage
+	config              *models.Config
+	storage             *TaskStorage
+	registry            *TaskRegistry
 	videoCleanupService *VideoCleanupService
-	workers            map[string]*Worker
-	taskRunners        map[string]*TaskRunner
-	mu                 sync.RWMutex
-	running            bool
-}
-
-// NewSchedulerService creates a new scheduler service
-func NewSchedulerService(config *models.Config) *SchedulerService {
-	// Create task storage directory
-	dataDir := filepath.Join(".", "data", "tasks")
-	storage := NewTaskStorage(dataDir)
-	
+	transcodeService    *TranscodeService
+	resumableUploads    *services.ResumableUploadStore
+	cron                *CronScheduler
+	videoService        *services.VideoService
+	metadataService     *services.MetadataService
+	catalogIndexer      *services.CatalogIndexer
+	trash               *services.Trash
+	mu                  sync.RWMutex
+	running             bool
+}
+
+// NewSchedulerService creates a new scheduler service backed by the durable task queue
+func NewSchedulerService(config *models.Config) (*SchedulerService, error) {
+	storage, err := OpenTaskStorage(config.Scheduler.Queue)
+	if err != nil {
+		return nil, err
+	}
+
 	// Extract video directories from config
 	var videoDirs []string
 	for _, dir := range config.Video.Directories {
@@ -32,63 +40,86 @@ func NewSchedulerService(config *models.Config) *SchedulerService {
 			videoDirs = append(videoDirs, dir.Path)
 		}
 	}
-	
+
 	videoCleanupService := NewVideoCleanupService(storage, videoDirs)
-	
-	return &SchedulerService{
+
+	// Deleted videos go to the trash when it is enabled
+	var trash *services.Trash
+	if config.Video.Trash.Enabled {
+		trash = services.NewTrash(config)
+		videoCleanupService.SetTrash(trash)
+	}
+	transcodeService := NewTranscodeService(storage, config.Video.FFmpegPath, config.Video.Transcoding.Renditions)
+	transcodeService.RestrictToDirectories(videoDirs)
+
+	// Queued work is run by the task registry; other packages may register more types before Start
+	registry := NewTaskRegistry(storage, config.Scheduler.Tasks)
+	if err := RegisterTaskType(registry, videoCleanupService.TaskType()); err != nil {
+		storage.Close()
+		return nil, err
+	}
+	if config.Video.Transcoding.Enabled {
+		if err := RegisterTaskType(registry, transcodeService.TaskType()); err != nil {
+			storage.Close()
+			return nil, err
+		}
+	}
+
+	ss := &SchedulerService{
 		config:              config,
 		storage:             storage,
+		registry:            registry,
 		videoCleanupService: videoCleanupService,
-		workers:             make(map[string]*Worker),
-		taskRunners:         make(map[string]*TaskRunner),
+		transcodeService:    transcodeService,
+		cron:                NewCronScheduler(),
+		trash:               trash,
+	}
+
+	if config.Video.Sprites.Enabled {
+		if err := RegisterTaskType(registry, ss.spriteTaskType()); err != nil {
+			storage.Close()
+			return nil, err
+		}
 	}
+
+	// Recurring jobs from the configuration
+	ss.registerJobActions()
+	for _, job := range config.Scheduler.Jobs {
+		if err := ss.cron.AddJob(job); err != nil {
+			storage.Close()
+			return nil, err
+		}
+	}
+	for _, builtin := range []func(*models.Config) (models.CronJobConfig, bool){retentionJob, trashPurgeJob, taskCleanupJob, uploadExpirationJob} {
+		if job, ok := builtin(config); ok {
+			if err := ss.cron.AddJob(job); err != nil {
+				storage.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return ss, nil
 }
 
 // Start initializes and starts all background services
 func (ss *SchedulerService) Start() error {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
-	
+
 	if ss.running {
 		return nil
 	}
-	
+
 	log.Println("Starting scheduler service...")
-	
-	// Create video cleanup task runner
-	videoCleanupRunner := NewTaskRunner(
-		3,    // buffer size
-		true, // long-lived
-		ss.videoCleanupService.VideoClearDispatcher,
-		ss.videoCleanupService.VideoClearExecutor,
-	)
-	ss.taskRunners["video_cleanup"] = videoCleanupRunner
-	
-	// Create worker for video cleanup (runs every 30 seconds)
-	videoCleanupWorker := NewWorker(30*time.Second, videoCleanupRunner)
-	ss.workers["video_cleanup"] = videoCleanupWorker
-	
-	// Create cleanup worker for old tasks (runs every hour)
-	cleanupTaskRunner := NewTaskRunner(
-		1,    // buffer size
-		true, // long-lived
-		ss.cleanupDispatcher,
-		ss.cleanupExecutor,
-	)
-	ss.taskRunners["cleanup"] = cleanupTaskRunner
-	
-	cleanupWorker := NewWorker(1*time.Hour, cleanupTaskRunner)
-	ss.workers["cleanup"] = cleanupWorker
-	
-	// Start all workers
-	for name, worker := range ss.workers {
-		worker.Start()
-		log.Printf("Started %s worker", name)
-	}
-	
+
+	// Start the worker pools of the registered task types and the recurring jobs
+	ss.registry.Start()
+	ss.cron.Start()
+
 	ss.running = true
 	log.Println("Scheduler service started successfully")
-	
+
 	return nil
 }
 
@@ -96,22 +127,19 @@ func (ss *SchedulerService) Start() error {
 func (ss *SchedulerService) Stop() error {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
-	
+
 	if !ss.running {
 		return nil
 	}
-	
+
 	log.Println("Stopping scheduler service...")
-	
-	// Stop all workers
-	for name, worker := range ss.workers {
-		worker.Stop()
-		log.Printf("Stopped %s worker", name)
-	}
-	
+
+	ss.registry.Stop()
+	ss.cron.Stop()
+
 	ss.running = false
 	log.Println("Scheduler service stopped successfully")
-	
+
 	return nil
 }
 
@@ -124,64 +152,122 @@ func (ss *SchedulerService) IsRunning() bool {
 
 // AddVideoDeletionTask schedules a video for deletion
 func (ss *SchedulerService) AddVideoDeletionTask(videoPath string) error {
-	return ss.videoCleanupService.AddVideoDeletionTask(videoPath)
+	return ss.QueueVideoDeletion(VideoDeletionJob{Path: videoPath})
+}
+
+// QueueVideoDeletion schedules a video for deletion, recording who requested it and why
+func (ss *SchedulerService) QueueVideoDeletion(job VideoDeletionJob) error {
+	_, err := EnqueueTask(ss.registry, VideoDeletionTaskType, job)
+	return err
+}
+
+// Trash returns the trash deleted videos are moved to, or nil when it is disabled
+func (ss *SchedulerService) Trash() *services.Trash {
+	return ss.trash
+}
+
+// AddTranscodeTask schedules the rendition ladder for a video
+func (ss *SchedulerService) AddTranscodeTask(video *services.VideoInfo) (TaskRecord, error) {
+	return EnqueueTask(ss.registry, TranscodeTaskType, NewTranscodeJob(video))
+}
+
+// Registry returns the task registry, for registering task types and enqueueing tasks
+func (ss *SchedulerService) Registry() *TaskRegistry {
+	return ss.registry
+}
+
+// EnqueueTask queues a task of any registered type, validating its JSON payload
+func (ss *SchedulerService) EnqueueTask(taskType string, payload json.RawMessage) (TaskRecord, error) {
+	return ss.registry.Enqueue(taskType, payload)
+}
+
+// TaskTypes returns the registered task types with their payload schemas
+func (ss *SchedulerService) TaskTypes() []TaskTypeInfo {
+	return ss.registry.Types()
+}
+
+// SetResumableUploadStore enables expiration of abandoned resumable uploads; call before Start
+func (ss *SchedulerService) SetResumableUploadStore(store *services.ResumableUploadStore) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.resumableUploads = store
+}
+
+// SetVideoServices provides the services used by the purge_videos and regenerate_thumbnails jobs; call before Start
+func (ss *SchedulerService) SetVideoServices(videoService *services.VideoService, metadataService *services.MetadataService) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.videoService = videoService
+	ss.metadataService = metadataService
+}
+
+// SetCatalogIndexer enables the rescan_catalog job; call before Start
+func (ss *SchedulerService) SetCatalogIndexer(indexer *services.CatalogIndexer) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.catalogIndexer = indexer
+}
+
+// Jobs returns the recurring jobs with their last and next runs
+func (ss *SchedulerService) Jobs() []JobStatus {
+	return ss.cron.Jobs()
+}
+
+// TriggerJob starts a recurring job now in the background
+func (ss *SchedulerService) TriggerJob(name string) (JobStatus, error) {
+	return ss.cron.Trigger(name)
+}
+
+// Close stops the scheduler and closes the task queue
+func (ss *SchedulerService) Close() error {
+	ss.Stop()
+	return ss.storage.Close()
+}
+
+// GetTask returns a stored task by ID
+func (ss *SchedulerService) GetTask(taskID string) (TaskRecord, error) {
+	return ss.storage.GetTask(taskID)
+}
+
+// ListTasks returns queued and finished tasks, newest first
+func (ss *SchedulerService) ListTasks(filter TaskFilter) ([]TaskRecord, error) {
+	return ss.storage.ListTasks(filter)
+}
+
+// RetryTask requeues a task (typically a dead letter) with a fresh set of attempts
+func (ss *SchedulerService) RetryTask(taskID string) (TaskRecord, error) {
+	return ss.storage.RetryTask(taskID)
+}
+
+// RemoveTask deletes a task that is not being processed
+func (ss *SchedulerService) RemoveTask(taskID string) error {
+	return ss.storage.RemoveTask(taskID)
+}
+
+// PurgeTasks removes completed and/or dead tasks that finished more than olderThan ago
+func (ss *SchedulerService) PurgeTasks(status string, olderThan time.Duration) (int, error) {
+	return ss.storage.PurgeTasks(status, olderThan)
 }
 
 // GetStats returns statistics about the scheduler service
 func (ss *SchedulerService) GetStats() map[string]interface{} {
 	ss.mu.RLock()
 	defer ss.mu.RUnlock()
-	
+
 	stats := map[string]interface{}{
-		"running":        ss.running,
-		"active_workers": len(ss.workers),
-		"task_runners":   len(ss.taskRunners),
-	}
-	
-	// Add worker status
-	workerStats := make(map[string]bool)
-	for name, worker := range ss.workers {
-		workerStats[name] = worker.IsRunning()
+		"running": ss.running,
 	}
-	stats["worker_status"] = workerStats
-	
-	// Add task runner status
-	runnerStats := make(map[string]bool)
-	for name, runner := range ss.taskRunners {
-		runnerStats[name] = runner.IsRunning()
+
+	// Add task queue stats
+	if taskStats, err := ss.storage.GetTaskStats(); err == nil {
+		stats["tasks"] = taskStats
 	}
-	stats["runner_status"] = runnerStats
-	
+	stats["task_types"] = ss.registry.Types()
+
 	// Add video cleanup stats
 	if videoStats, err := ss.videoCleanupService.GetStats(); err == nil {
 		stats["video_cleanup"] = videoStats
 	}
-	
-	return stats
-}
 
-// cleanupDispatcher handles dispatching cleanup tasks
-func (ss *SchedulerService) cleanupDispatcher(dataChan chan interface{}) error {
-	// Send a cleanup signal
-	dataChan <- "cleanup_old_tasks"
-	return nil
+	return stats
 }
-
-// cleanupExecutor handles executing cleanup tasks
-func (ss *SchedulerService) cleanupExecutor(dataChan chan interface{}) error {
-	// Process cleanup tasks
-	for {
-		select {
-		case task := <-dataChan:
-			if taskStr, ok := task.(string); ok && taskStr == "cleanup_old_tasks" {
-				if err := ss.videoCleanupService.CleanupOldTasks(); err != nil {
-					log.Printf("Failed to cleanup old tasks: %v", err)
-					return err
-				}
-				log.Println("Successfully cleaned up old tasks")
-			}
-		default:
-			return nil
-		}
-	}
-}
\ No newline at end of file
@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"standalone-stream-server/internal/services"
+)
+
+// SpriteTaskType is the task type for generating seek-preview sprite sheets
+const SpriteTaskType = "sprite"
+
+// ActionGenerateSprites queues sprite tasks for the videos whose sprite sheet is missing or stale
+const ActionGenerateSprites = "generate_sprites"
+
+// SpriteJob is the payload of sprite tasks
+type SpriteJob struct {
+	VideoID string `json:"video_id"`
+}
+
+// spriteTaskType returns the registration of sprite tasks; ffmpeg decodes the whole video, so one
+// sprite is generated at a time
+func (ss *SchedulerService) spriteTaskType() TaskType[SpriteJob] {
+	return TaskType[SpriteJob]{
+		Name:         SpriteTaskType,
+		Concurrency:  1,
+		PollInterval: 30 * time.Second,
+		Validate: func(job SpriteJob) error {
+			if job.VideoID == "" {
+				return errors.New("video_id is required")
+			}
+			return nil
+		},
+		Handle: ss.handleSprite,
+	}
+}
+
+// handleSprite generates the sprite sheet of a video unless it is already up to date
+func (ss *SchedulerService) handleSprite(tc *TaskContext, job SpriteJob) (interface{}, error) {
+	if ss.videoService == nil || ss.metadataService == nil {
+		return nil, errors.New("video services are not configured")
+	}
+	video, err := ss.videoService.FindVideoByID(job.VideoID)
+	if err != nil {
+		// The video was deleted or renamed since the task was queued
+		return nil, Permanent(err)
+	}
+
+	generated, err := ss.metadataService.EnsureSprite(*video)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]bool{"generated": generated}, nil
+}
+
+// AddSpriteTask schedules sprite generation for a video
+func (ss *SchedulerService) AddSpriteTask(videoID string) (TaskRecord, error) {
+	return EnqueueTask(ss.registry, SpriteTaskType, SpriteJob{VideoID: videoID})
+}
+
+// generateSprites queues a sprite task for every video of a directory (all directories by default)
+// whose sprite sheet is missing or was generated from an older version of the file
+func (ss *SchedulerService) generateSprites(ctx context.Context, params map[string]string) (interface{}, error) {
+	if !ss.config.Video.Sprites.Enabled {
+		return nil, services.ErrSpritesDisabled
+	}
+	videos, err := ss.listVideos(params["directory"])
+	if err != nil {
+		return nil, err
+	}
+
+	queued, err := ss.queuedSprites()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]int{"checked": len(videos), "queued": 0, "fresh": 0}
+	for _, video := range videos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if services.IsSpriteFresh(video) {
+			result["fresh"]++
+			continue
+		}
+		if queued[video.ID] {
+			continue
+		}
+		if _, err := ss.AddSpriteTask(video.ID); err != nil {
+			return result, fmt.Errorf("failed to queue sprite generation for %s: %w", video.ID, err)
+		}
+		queued[video.ID] = true
+		result["queued"]++
+	}
+	return result, nil
+}
+
+// queuedSprites returns the videos with a sprite task waiting or running
+func (ss *SchedulerService) queuedSprites() (map[string]bool, error) {
+	videoIDs := make(map[string]bool)
+	for _, status := range []string{StatusPending, StatusProcessing} {
+		tasks, err := ss.storage.ListTasks(TaskFilter{Type: SpriteTaskType, Status: status, Limit: math.MaxInt32})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			var job SpriteJob
+			if json.Unmarshal([]byte(task.Data), &job) == nil {
+				videoIDs[job.VideoID] = true
+			}
+		}
+	}
+	return videoIDs, nil
+}
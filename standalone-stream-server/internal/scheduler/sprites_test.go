@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+func TestSchedulerService_GenerateSprites(t *testing.T) {
+	tempDir := t.TempDir()
+	videoDir := filepath.Join(tempDir, "videos")
+	os.MkdirAll(videoDir, 0755)
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		if err := os.WriteFile(filepath.Join(videoDir, name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &models.Config{}
+	config.Video.SupportedFormats = []string{".mp4"}
+	config.Video.Directories = []models.VideoDirectory{{Name: "movies", Path: videoDir, Enabled: true}}
+	config.Video.Sprites = models.SpriteConfig{Enabled: true, Frames: 10, Columns: 5, Width: 160, Height: 90}
+	config.Scheduler.Queue.Path = filepath.Join(tempDir, "tasks", "tasks.db")
+	config.Scheduler.Jobs = []models.CronJobConfig{{Name: "sprites", Schedule: "@daily", Action: ActionGenerateSprites}}
+
+	ss, err := NewSchedulerService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+	ss.SetVideoServices(services.NewVideoService(config), services.NewMetadataService(config))
+
+	run, err := ss.cron.Run("sprites")
+	if err != nil || run.Error != "" {
+		t.Fatalf("Sprite job failed: %+v %v", run, err)
+	}
+	if result := run.Result.(map[string]int); result["checked"] != 2 || result["queued"] != 2 {
+		t.Errorf("Expected both videos to be queued, got %+v", result)
+	}
+
+	// Videos with a pending sprite task are not queued twice
+	run, _ = ss.cron.Run("sprites")
+	if result := run.Result.(map[string]int); result["queued"] != 0 {
+		t.Errorf("Expected no new tasks, got %+v", result)
+	}
+	if tasks, _ := ss.ListTasks(TaskFilter{Type: SpriteTaskType}); len(tasks) != 2 {
+		t.Errorf("Expected 2 sprite tasks, got %d", len(tasks))
+	}
+}
@@ -1,220 +1,773 @@
 package scheduler
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"standalone-stream-server/internal/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Task statuses
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusDead       = "dead" // exhausted its attempts or failed permanently; kept until retried or purged
+)
+
+// Task queue errors
+var (
+	ErrTaskNotFound   = errors.New("task not found")
+	ErrTaskProcessing = errors.New("task is being processed")
+	ErrLeaseLost      = errors.New("task lease lost") // the task was reclaimed after its visibility timeout
+)
+
+var (
+	tasksBucket  = []byte("tasks")  // id -> TaskRecord
+	readyBucket  = []byte("ready")  // type \x00 next run (8 bytes) id -> pending tasks in run order
+	leasesBucket = []byte("leases") // lease expiry (8 bytes) id -> processing tasks in expiry order
 )
 
 // TaskRecord represents a scheduled task
 type TaskRecord struct {
-	ID        string    `json:"id"`
-	Type      string    `json:"type"`
-	Data      string    `json:"data"`
-	CreatedAt time.Time `json:"created_at"`
-	Status    string    `json:"status"` // pending, processing, completed, failed
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	Data           string          `json:"data"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Status         string          `json:"status"`             // pending, processing, completed, dead
+	Progress       float64         `json:"progress,omitempty"` // 0-1, reported by long-running tasks
+	Result         json.RawMessage `json:"result,omitempty"`
+	Error          string          `json:"error,omitempty"` // error of the last failed attempt
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	NextRunAt      time.Time       `json:"next_run_at"`                // pending tasks are not claimed before this time
+	LeaseExpiresAt time.Time       `json:"lease_expires_at,omitempty"` // processing tasks are reclaimed after this time
+	UpdatedAt      time.Time       `json:"updated_at"`
 }
 
-// TaskStorage handles persistence of task records
+// TaskFilter selects tasks for ListTasks
+type TaskFilter struct {
+	Status string
+	Type   string
+	Limit  int
+}
+
+// TaskStorage is a durable task queue stored in an embedded database.
+// Workers claim tasks atomically and hold them under a lease; a task whose lease expires
+// (e.g. its process crashed) is reclaimed and retried with exponential backoff until it
+// runs out of attempts and becomes a dead letter.
 type TaskStorage struct {
-	dataDir string
-	mu      sync.RWMutex
+	mu        sync.RWMutex // held exclusively while Compact replaces the database
+	db        *bolt.DB
+	config    models.TaskQueueConfig
+	now       func() time.Time
+	lastStamp int64 // last ID timestamp, guarded by the database's write lock
+	notify    func(taskType string)
 }
 
-// NewTaskStorage creates a new task storage instance
-func NewTaskStorage(dataDir string) *TaskStorage {
-	// Ensure data directory exists
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create task storage directory: %v", err))
+// withQueueDefaults fills in unset queue settings
+func withQueueDefaults(config models.TaskQueueConfig) models.TaskQueueConfig {
+	if config.Path == "" {
+		config.Path = filepath.Join(".", "data", "tasks", "tasks.db")
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = 10 * time.Minute
 	}
-	
-	return &TaskStorage{
-		dataDir: dataDir,
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = 30 * time.Second
 	}
+	if config.BackoffMax < config.BackoffBase {
+		config.BackoffMax = time.Hour
+	}
+	if config.Retention <= 0 {
+		config.Retention = 24 * time.Hour
+	}
+	return config
 }
 
-// AddTask adds a new task to the storage
-func (ts *TaskStorage) AddTask(taskType, data string) error {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-	
-	task := TaskRecord{
-		ID:        fmt.Sprintf("%d_%s", time.Now().UnixNano(), taskType),
-		Type:      taskType,
-		Data:      data,
-		CreatedAt: time.Now(),
-		Status:    "pending",
-	}
-	
-	filename := filepath.Join(ts.dataDir, fmt.Sprintf("%s.json", task.ID))
-	file, err := os.Create(filename)
+// OpenTaskStorage opens (or creates) the task queue and imports task files left by the
+// previous one-JSON-file-per-task storage in the same directory
+func OpenTaskStorage(config models.TaskQueueConfig) (*TaskStorage, error) {
+	config = withQueueDefaults(config)
+
+	dir := filepath.Dir(config.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task storage directory: %w", err)
+	}
+
+	db, err := bolt.Open(config.Path, 0o600, &bolt.Options{Timeout: time.Second})
 	if err != nil {
-		return fmt.Errorf("failed to create task file: %w", err)
+		return nil, fmt.Errorf("failed to open task queue: %w", err)
 	}
-	defer file.Close()
-	
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(task); err != nil {
-		return fmt.Errorf("failed to encode task: %w", err)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{tasksBucket, readyBucket, leasesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize task queue: %w", err)
 	}
-	
-	return nil
+
+	ts := &TaskStorage{db: db, config: config, now: time.Now}
+	if err := ts.importLegacyTasks(dir); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ts, nil
 }
 
-// GetPendingTasks retrieves a limited number of pending tasks
-func (ts *TaskStorage) GetPendingTasks(taskType string, limit int) ([]TaskRecord, error) {
+// Close closes the task queue
+func (ts *TaskStorage) Close() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.db.Close()
+}
+
+// view runs a read-only transaction
+func (ts *TaskStorage) view(fn func(tx *bolt.Tx) error) error {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.db.View(fn)
+}
+
+// update runs a read-write transaction
+func (ts *TaskStorage) update(fn func(tx *bolt.Tx) error) error {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
-	
-	var tasks []TaskRecord
-	
-	// Read all files in the data directory
-	files, err := os.ReadDir(ts.dataDir)
+	return ts.db.Update(fn)
+}
+
+// CompactResult describes a storage compaction
+type CompactResult struct {
+	Removed    int   `json:"removed"` // completed tasks past the retention
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+}
+
+// Compact purges completed tasks past the retention and rewrites the database file, which
+// otherwise keeps the space of removed tasks. Queue operations wait while the file is rewritten.
+func (ts *TaskStorage) Compact() (CompactResult, error) {
+	var result CompactResult
+	removed, err := ts.PurgeTasks(StatusCompleted, ts.config.Retention)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read task directory: %w", err)
+		return result, err
 	}
-	
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
-			task, err := ts.readTaskFile(filepath.Join(ts.dataDir, file.Name()))
+	result.Removed = removed
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	path := ts.db.Path()
+	if info, err := os.Stat(path); err == nil {
+		result.SizeBefore = info.Size()
+	}
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return result, fmt.Errorf("failed to create compacted task queue: %w", err)
+	}
+	if err := bolt.Compact(dst, ts.db, 0); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return result, fmt.Errorf("failed to compact task queue: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return result, fmt.Errorf("failed to compact task queue: %w", err)
+	}
+
+	if err := ts.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return result, fmt.Errorf("failed to close task queue: %w", err)
+	}
+	// If the rename fails the original file is reopened unchanged
+	renameErr := os.Rename(tmpPath, path)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return result, fmt.Errorf("failed to reopen task queue: %w", err)
+	}
+	ts.db = db
+	if renameErr != nil {
+		os.Remove(tmpPath)
+		return result, fmt.Errorf("failed to replace task queue: %w", renameErr)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		result.SizeAfter = info.Size()
+	}
+	return result, nil
+}
+
+// SetNotifier registers a function called whenever a task becomes runnable, so workers can pick
+// it up without waiting for their next poll; call before tasks are created
+func (ts *TaskStorage) SetNotifier(notify func(taskType string)) {
+	ts.notify = notify
+}
+
+// notifyRunnable tells the notifier that a task of taskType can be claimed
+func (ts *TaskStorage) notifyRunnable(taskType string) {
+	if ts.notify != nil {
+		ts.notify(taskType)
+	}
+}
+
+// AddTask adds a new task to the storage
+func (ts *TaskStorage) AddTask(taskType, data string) error {
+	_, err := ts.CreateTask(taskType, data)
+	return err
+}
+
+// CreateTask adds a new task to the storage and returns the stored record
+func (ts *TaskStorage) CreateTask(taskType, data string) (TaskRecord, error) {
+	now := ts.now()
+	task := TaskRecord{
+		Type:        taskType,
+		Data:        data,
+		CreatedAt:   now,
+		Status:      StatusPending,
+		MaxAttempts: ts.config.MaxAttempts,
+		NextRunAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err := ts.update(func(tx *bolt.Tx) error {
+		tasks := tx.Bucket(tasksBucket)
+
+		// IDs start with a strictly increasing creation time so the tasks bucket is in creation order
+		stamp := now.UnixNano()
+		if stamp <= ts.lastStamp {
+			stamp = ts.lastStamp + 1
+		}
+		task.ID = fmt.Sprintf("%d_%s", stamp, taskType)
+		for tasks.Get([]byte(task.ID)) != nil {
+			stamp++
+			task.ID = fmt.Sprintf("%d_%s", stamp, taskType)
+		}
+		ts.lastStamp = stamp
+
+		if err := putTask(tx, task); err != nil {
+			return err
+		}
+		return tx.Bucket(readyBucket).Put(readyKey(task), nil)
+	})
+	if err != nil {
+		return task, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	ts.notifyRunnable(taskType)
+	return task, nil
+}
+
+// GetTask retrieves a single task by ID
+func (ts *TaskStorage) GetTask(taskID string) (TaskRecord, error) {
+	var task TaskRecord
+	err := ts.view(func(tx *bolt.Tx) error {
+		var err error
+		task, err = getTask(tx, taskID)
+		return err
+	})
+	return task, err
+}
+
+// Claim atomically takes up to limit runnable tasks of a type, marks them processing and
+// leases them for the visibility timeout. Expired leases are reclaimed first.
+func (ts *TaskStorage) Claim(taskType string, limit int) ([]TaskRecord, error) {
+	var claimed []TaskRecord
+
+	err := ts.update(func(tx *bolt.Tx) error {
+		now := ts.now()
+		if _, err := ts.reclaimExpired(tx, now); err != nil {
+			return err
+		}
+
+		prefix := append([]byte(taskType), 0)
+		var keys [][]byte
+		c := tx.Bucket(readyBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) && len(keys) < limit; k, _ = c.Next() {
+			runAt := int64(binary.BigEndian.Uint64(k[len(prefix):]))
+			if runAt > now.UnixNano() {
+				break // keys are ordered by run time
+			}
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		for _, k := range keys {
+			if err := tx.Bucket(readyBucket).Delete(k); err != nil {
+				return err
+			}
+
+			task, err := getTask(tx, string(k[len(prefix)+8:]))
 			if err != nil {
-				continue // Skip corrupted files
+				continue // index entry without a task
 			}
-			
-			if task.Type == taskType && task.Status == "pending" {
-				tasks = append(tasks, task)
-				if len(tasks) >= limit {
-					break
-				}
+
+			task.Status = StatusProcessing
+			task.Attempts++
+			task.Progress = 0
+			task.LeaseExpiresAt = now.Add(ts.config.VisibilityTimeout)
+			task.UpdatedAt = now
+			if err := putTask(tx, task); err != nil {
+				return err
 			}
+			if err := tx.Bucket(leasesBucket).Put(leaseKey(task), nil); err != nil {
+				return err
+			}
+			claimed = append(claimed, task)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim tasks: %w", err)
 	}
-	
-	return tasks, nil
+
+	return claimed, nil
 }
 
-// UpdateTaskStatus updates the status of a task
-func (ts *TaskStorage) UpdateTaskStatus(taskID, status string) error {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-	
-	filename := filepath.Join(ts.dataDir, fmt.Sprintf("%s.json", taskID))
-	
-	// Read current task
-	task, err := ts.readTaskFile(filename)
+// reclaimExpired retries (or buries) processing tasks whose lease has expired
+func (ts *TaskStorage) reclaimExpired(tx *bolt.Tx, now time.Time) (int, error) {
+	var keys [][]byte
+	c := tx.Bucket(leasesBucket).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if int64(binary.BigEndian.Uint64(k)) > now.UnixNano() {
+			break
+		}
+		keys = append(keys, append([]byte(nil), k...))
+	}
+
+	for _, k := range keys {
+		if err := tx.Bucket(leasesBucket).Delete(k); err != nil {
+			return 0, err
+		}
+		task, err := getTask(tx, string(k[8:]))
+		if err != nil || task.Status != StatusProcessing {
+			continue
+		}
+		log.Printf("Task %s was not finished within the visibility timeout; reclaiming it", task.ID)
+		if err := ts.retryOrBury(tx, &task, now, "visibility timeout expired"); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
+}
+
+// Ack marks a claimed task as completed with its result
+func (ts *TaskStorage) Ack(task TaskRecord, result interface{}) error {
+	encoded, err := encodeResult(result)
 	if err != nil {
-		return fmt.Errorf("failed to read task: %w", err)
-	}
-	
-	// Update status
-	task.Status = status
-	
-	// Write back to file
-	file, err := os.Create(filename)
+		return err
+	}
+
+	return ts.finishClaim(task, func(tx *bolt.Tx, record *TaskRecord, now time.Time) error {
+		record.Status = StatusCompleted
+		record.Progress = 1
+		record.Result = encoded
+		record.Error = ""
+		return nil
+	})
+}
+
+// Nack records a failed attempt of a claimed task: the task is retried after an exponential
+// backoff, or becomes a dead letter once it has used all its attempts
+func (ts *TaskStorage) Nack(task TaskRecord, result interface{}, cause error) error {
+	encoded, err := encodeResult(result)
 	if err != nil {
-		return fmt.Errorf("failed to update task file: %w", err)
+		return err
 	}
-	defer file.Close()
-	
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(task); err != nil {
-		return fmt.Errorf("failed to encode updated task: %w", err)
+
+	return ts.finishClaim(task, func(tx *bolt.Tx, record *TaskRecord, now time.Time) error {
+		record.Result = encoded
+		return ts.retryOrBury(tx, record, now, cause.Error())
+	})
+}
+
+// DeadLetter fails a claimed task permanently, e.g. when its payload is invalid
+func (ts *TaskStorage) DeadLetter(task TaskRecord, result interface{}, reason string) error {
+	encoded, err := encodeResult(result)
+	if err != nil {
+		return err
 	}
-	
-	return nil
+
+	return ts.finishClaim(task, func(tx *bolt.Tx, record *TaskRecord, now time.Time) error {
+		record.Status = StatusDead
+		record.Result = encoded
+		record.Error = reason
+		return nil
+	})
 }
 
-// RemoveTask removes a task from storage
-func (ts *TaskStorage) RemoveTask(taskID string) error {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-	
-	filename := filepath.Join(ts.dataDir, fmt.Sprintf("%s.json", taskID))
-	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove task: %w", err)
+// finishClaim ends the lease of a claimed task and applies its outcome. It fails with
+// ErrLeaseLost when the task has been reclaimed since it was claimed.
+func (ts *TaskStorage) finishClaim(task TaskRecord, finish func(tx *bolt.Tx, record *TaskRecord, now time.Time) error) error {
+	return ts.update(func(tx *bolt.Tx) error {
+		record, err := getTask(tx, task.ID)
+		if err != nil {
+			return err
+		}
+		if record.Status != StatusProcessing || record.Attempts != task.Attempts {
+			return fmt.Errorf("%w: %s", ErrLeaseLost, task.ID)
+		}
+
+		if err := tx.Bucket(leasesBucket).Delete(leaseKey(record)); err != nil {
+			return err
+		}
+
+		now := ts.now()
+		record.LeaseExpiresAt = time.Time{}
+		if err := finish(tx, &record, now); err != nil {
+			return err
+		}
+		record.UpdatedAt = now
+		return putTask(tx, record)
+	})
+}
+
+// retryOrBury schedules the next attempt of a failed task or makes it a dead letter
+func (ts *TaskStorage) retryOrBury(tx *bolt.Tx, task *TaskRecord, now time.Time, errMsg string) error {
+	task.Error = errMsg
+	task.LeaseExpiresAt = time.Time{}
+	task.UpdatedAt = now
+
+	if task.Attempts >= task.MaxAttempts {
+		task.Status = StatusDead
+		log.Printf("Task %s failed %d times and was moved to the dead letters: %s", task.ID, task.Attempts, errMsg)
+		return putTask(tx, *task)
 	}
-	
-	return nil
+
+	task.Status = StatusPending
+	task.NextRunAt = now.Add(ts.backoff(task.Attempts))
+	if err := putTask(tx, *task); err != nil {
+		return err
+	}
+	return tx.Bucket(readyBucket).Put(readyKey(*task), nil)
 }
 
-// CleanupCompletedTasks removes completed and failed tasks older than the specified duration
-func (ts *TaskStorage) CleanupCompletedTasks(olderThan time.Duration) error {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-	
-	cutoff := time.Now().Add(-olderThan)
-	
-	files, err := os.ReadDir(ts.dataDir)
-	if err != nil {
-		return fmt.Errorf("failed to read task directory: %w", err)
+// backoff returns the delay before retrying a task that has failed attempts times
+func (ts *TaskStorage) backoff(attempts int) time.Duration {
+	delay := ts.config.BackoffBase
+	for i := 1; i < attempts && delay < ts.config.BackoffMax; i++ {
+		delay *= 2
 	}
-	
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
-			task, err := ts.readTaskFile(filepath.Join(ts.dataDir, file.Name()))
-			if err != nil {
+	if delay > ts.config.BackoffMax {
+		delay = ts.config.BackoffMax
+	}
+	return delay
+}
+
+// UpdateTaskProgress records the progress (0-1) of a claimed task and renews its lease, so
+// long-running tasks that report progress are not reclaimed. It fails with ErrLeaseLost when the
+// task has been reclaimed since it was claimed.
+func (ts *TaskStorage) UpdateTaskProgress(task TaskRecord, progress float64) error {
+	return ts.update(func(tx *bolt.Tx) error {
+		record, err := getTask(tx, task.ID)
+		if err != nil {
+			return err
+		}
+		if record.Status != StatusProcessing || record.Attempts != task.Attempts {
+			return fmt.Errorf("%w: %s", ErrLeaseLost, task.ID)
+		}
+
+		if err := tx.Bucket(leasesBucket).Delete(leaseKey(record)); err != nil {
+			return err
+		}
+		now := ts.now()
+		record.Progress = progress
+		record.UpdatedAt = now
+		record.LeaseExpiresAt = now.Add(ts.config.VisibilityTimeout)
+		if err := tx.Bucket(leasesBucket).Put(leaseKey(record), nil); err != nil {
+			return err
+		}
+		return putTask(tx, record)
+	})
+}
+
+// RetryTask requeues a dead, completed or pending task to run immediately with a fresh set of attempts
+func (ts *TaskStorage) RetryTask(taskID string) (TaskRecord, error) {
+	var task TaskRecord
+	err := ts.update(func(tx *bolt.Tx) error {
+		var err error
+		task, err = getTask(tx, taskID)
+		if err != nil {
+			return err
+		}
+		if task.Status == StatusProcessing {
+			return fmt.Errorf("%w: %s", ErrTaskProcessing, taskID)
+		}
+		if task.Status == StatusPending {
+			if err := tx.Bucket(readyBucket).Delete(readyKey(task)); err != nil {
+				return err
+			}
+		}
+
+		now := ts.now()
+		task.Status = StatusPending
+		task.Attempts = 0
+		task.MaxAttempts = ts.config.MaxAttempts
+		task.Progress = 0
+		task.Result = nil
+		task.Error = ""
+		task.NextRunAt = now
+		task.UpdatedAt = now
+		if err := putTask(tx, task); err != nil {
+			return err
+		}
+		return tx.Bucket(readyBucket).Put(readyKey(task), nil)
+	})
+	if err == nil {
+		ts.notifyRunnable(task.Type)
+	}
+	return task, err
+}
+
+// RemoveTask removes a task from storage; tasks that are being processed cannot be removed
+func (ts *TaskStorage) RemoveTask(taskID string) error {
+	return ts.update(func(tx *bolt.Tx) error {
+		task, err := getTask(tx, taskID)
+		if errors.Is(err, ErrTaskNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if task.Status == StatusProcessing {
+			return fmt.Errorf("%w: %s", ErrTaskProcessing, taskID)
+		}
+		return deleteTask(tx, task)
+	})
+}
+
+// ListTasks returns tasks matching the filter, newest first
+func (ts *TaskStorage) ListTasks(filter TaskFilter) ([]TaskRecord, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	tasks := []TaskRecord{}
+	err := ts.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(tasksBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(tasks) < filter.Limit; k, v = c.Prev() {
+			var task TaskRecord
+			if err := json.Unmarshal(v, &task); err != nil {
 				continue
 			}
-			
-			if (task.Status == "completed" || task.Status == "failed") && task.CreatedAt.Before(cutoff) {
-				os.Remove(filepath.Join(ts.dataDir, file.Name()))
+			if (filter.Status == "" || task.Status == filter.Status) && (filter.Type == "" || task.Type == filter.Type) {
+				tasks = append(tasks, task)
 			}
 		}
+		return nil
+	})
+	return tasks, err
+}
+
+// PurgeTasks removes completed and/or dead tasks that finished more than olderThan ago;
+// an empty status purges both
+func (ts *TaskStorage) PurgeTasks(status string, olderThan time.Duration) (int, error) {
+	switch status {
+	case "", StatusCompleted, StatusDead:
+	default:
+		return 0, fmt.Errorf("only completed and dead tasks can be purged, not %s", status)
 	}
-	
-	return nil
+
+	cutoff := ts.now().Add(-olderThan)
+	removed := 0
+
+	err := ts.update(func(tx *bolt.Tx) error {
+		var purge []TaskRecord
+		err := tx.Bucket(tasksBucket).ForEach(func(k, v []byte) error {
+			var task TaskRecord
+			if err := json.Unmarshal(v, &task); err != nil {
+				return nil
+			}
+			finished := task.Status == StatusCompleted || task.Status == StatusDead
+			if finished && (status == "" || task.Status == status) && !task.UpdatedAt.After(cutoff) {
+				purge = append(purge, task)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, task := range purge {
+			if err := deleteTask(tx, task); err != nil {
+				return err
+			}
+		}
+		removed = len(purge)
+		return nil
+	})
+	return removed, err
+}
+
+// CleanupCompletedTasks removes completed tasks older than the specified duration
+func (ts *TaskStorage) CleanupCompletedTasks(olderThan time.Duration) error {
+	_, err := ts.PurgeTasks(StatusCompleted, olderThan)
+	return err
 }
 
 // GetTaskStats returns statistics about tasks
 func (ts *TaskStorage) GetTaskStats() (map[string]int, error) {
-	ts.mu.RLock()
-	defer ts.mu.RUnlock()
-	
 	stats := map[string]int{
-		"pending":    0,
-		"processing": 0,
-		"completed":  0,
-		"failed":     0,
-		"total":      0,
-	}
-	
-	files, err := os.ReadDir(ts.dataDir)
-	if err != nil {
-		return stats, fmt.Errorf("failed to read task directory: %w", err)
+		StatusPending:    0,
+		StatusProcessing: 0,
+		StatusCompleted:  0,
+		StatusDead:       0,
+		"total":          0,
 	}
-	
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
-			task, err := ts.readTaskFile(filepath.Join(ts.dataDir, file.Name()))
-			if err != nil {
-				continue
+
+	err := ts.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(k, v []byte) error {
+			var task TaskRecord
+			if err := json.Unmarshal(v, &task); err != nil {
+				return nil
 			}
-			
 			stats[task.Status]++
 			stats["total"]++
-		}
+			return nil
+		})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to read task queue: %w", err)
 	}
-	
+
 	return stats, nil
 }
 
-// readTaskFile reads a task from a JSON file
-func (ts *TaskStorage) readTaskFile(filename string) (TaskRecord, error) {
+// importLegacyTasks moves task files written by the previous file-based storage into the queue.
+// Tasks that were processing when the old process stopped are requeued; failed tasks become dead letters.
+func (ts *TaskStorage) importLegacyTasks(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	imported := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var task TaskRecord
+		if err := json.Unmarshal(data, &task); err != nil || task.ID == "" || task.ID != strings.TrimSuffix(filepath.Base(file), ".json") {
+			continue // Skip corrupted files
+		}
+
+		switch task.Status {
+		case StatusPending, StatusProcessing:
+			task.Status = StatusPending
+			task.NextRunAt = task.CreatedAt
+		case "failed":
+			task.Status = StatusDead
+		}
+		task.MaxAttempts = ts.config.MaxAttempts
+
+		err = ts.update(func(tx *bolt.Tx) error {
+			if tx.Bucket(tasksBucket).Get([]byte(task.ID)) != nil {
+				return nil
+			}
+			if err := putTask(tx, task); err != nil {
+				return err
+			}
+			if task.Status == StatusPending {
+				return tx.Bucket(readyBucket).Put(readyKey(task), nil)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to import task %s: %w", task.ID, err)
+		}
+
+		os.Remove(file)
+		imported++
+	}
+
+	if imported > 0 {
+		log.Printf("Imported %d tasks from the legacy task directory", imported)
+	}
+	return nil
+}
+
+// getTask reads a task within a transaction
+func getTask(tx *bolt.Tx, taskID string) (TaskRecord, error) {
 	var task TaskRecord
-	
-	file, err := os.Open(filename)
-	if err != nil {
-		return task, err
+	data := tx.Bucket(tasksBucket).Get([]byte(taskID))
+	if data == nil {
+		return task, fmt.Errorf("%w: %q", ErrTaskNotFound, taskID)
 	}
-	defer file.Close()
-	
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&task); err != nil {
-		return task, err
+	if err := json.Unmarshal(data, &task); err != nil {
+		return task, fmt.Errorf("failed to decode task %s: %w", taskID, err)
 	}
-	
 	return task, nil
-}
\ No newline at end of file
+}
+
+// putTask writes a task within a transaction
+func putTask(tx *bolt.Tx, task TaskRecord) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(tasksBucket).Put([]byte(task.ID), data)
+}
+
+// deleteTask removes a task and its index entries
+func deleteTask(tx *bolt.Tx, task TaskRecord) error {
+	switch task.Status {
+	case StatusPending:
+		if err := tx.Bucket(readyBucket).Delete(readyKey(task)); err != nil {
+			return err
+		}
+	case StatusProcessing:
+		if err := tx.Bucket(leasesBucket).Delete(leaseKey(task)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(tasksBucket).Delete([]byte(task.ID))
+}
+
+// readyKey orders pending tasks by type, then run time
+func readyKey(task TaskRecord) []byte {
+	key := make([]byte, 0, len(task.Type)+1+8+len(task.ID))
+	key = append(key, task.Type...)
+	key = append(key, 0)
+	key = binary.BigEndian.AppendUint64(key, uint64(task.NextRunAt.UnixNano()))
+	return append(key, task.ID...)
+}
+
+// leaseKey orders processing tasks by lease expiry
+func leaseKey(task TaskRecord) []byte {
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(task.ID)), uint64(task.LeaseExpiresAt.UnixNano()))
+	return append(key, task.ID...)
+}
+
+// encodeResult encodes a task result; nil stays empty
+func encodeResult(result interface{}) (json.RawMessage, error) {
+	if result == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode task result: %w", err)
+	}
+	return data, nil
+}
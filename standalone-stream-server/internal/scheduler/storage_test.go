@@ -0,0 +1,215 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// newTestQueue opens a queue whose clock is controlled by the returned pointer
+func newTestQueue(t *testing.T, config models.TaskQueueConfig) (*TaskStorage, *time.Time) {
+	t.Helper()
+	if config.Path == "" {
+		config.Path = filepath.Join(t.TempDir(), "tasks.db")
+	}
+	ts, err := OpenTaskStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to open task queue: %v", err)
+	}
+	t.Cleanup(func() { ts.Close() })
+
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+	return ts, &now
+}
+
+func TestTaskStorage_ClaimAndAck(t *testing.T) {
+	ts, _ := newTestQueue(t, models.TaskQueueConfig{})
+
+	first, _ := ts.CreateTask("video_deletion", "a.mp4")
+	second, _ := ts.CreateTask("video_deletion", "b.mp4")
+	ts.CreateTask(TranscodeTaskType, "{}")
+	if first.ID == second.ID {
+		t.Fatal("Expected unique task IDs")
+	}
+
+	claimed, err := ts.Claim("video_deletion", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 || claimed[0].ID != first.ID || claimed[0].Status != StatusProcessing || claimed[0].Attempts != 1 {
+		t.Fatalf("Expected both deletion tasks in creation order, got %+v", claimed)
+	}
+	if again, _ := ts.Claim("video_deletion", 10); len(again) != 0 {
+		t.Fatalf("Claimed tasks must not be claimed twice, got %d", len(again))
+	}
+
+	if err := ts.Ack(claimed[0], map[string]string{"deleted": "a.mp4"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Ack(claimed[0], nil); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost when acking twice, got %v", err)
+	}
+
+	record, _ := ts.GetTask(first.ID)
+	if record.Status != StatusCompleted || record.Progress != 1 || len(record.Result) == 0 {
+		t.Errorf("Unexpected completed task: %+v", record)
+	}
+
+	stats, _ := ts.GetTaskStats()
+	if stats[StatusCompleted] != 1 || stats[StatusProcessing] != 1 || stats[StatusPending] != 1 || stats["total"] != 3 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestTaskStorage_BackoffAndDeadLetter(t *testing.T) {
+	ts, now := newTestQueue(t, models.TaskQueueConfig{MaxAttempts: 3, BackoffBase: time.Second, BackoffMax: time.Hour})
+	task, _ := ts.CreateTask("video_deletion", "a.mp4")
+
+	for attempt, delay := range []time.Duration{time.Second, 2 * time.Second} {
+		claimed, _ := ts.Claim("video_deletion", 1)
+		if len(claimed) != 1 {
+			t.Fatalf("Attempt %d: expected task to be claimable", attempt+1)
+		}
+		if err := ts.Nack(claimed[0], nil, errors.New("disk busy")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Not runnable until the backoff has passed
+		if early, _ := ts.Claim("video_deletion", 1); len(early) != 0 {
+			t.Fatalf("Attempt %d: task claimed before its backoff elapsed", attempt+1)
+		}
+		*now = now.Add(delay)
+	}
+
+	claimed, _ := ts.Claim("video_deletion", 1)
+	if len(claimed) != 1 || claimed[0].Attempts != 3 {
+		t.Fatalf("Expected third attempt, got %+v", claimed)
+	}
+	ts.Nack(claimed[0], nil, errors.New("disk busy"))
+
+	record, _ := ts.GetTask(task.ID)
+	if record.Status != StatusDead || record.Error != "disk busy" {
+		t.Fatalf("Expected dead letter after 3 attempts, got status=%s error=%q", record.Status, record.Error)
+	}
+	*now = now.Add(24 * time.Hour)
+	if again, _ := ts.Claim("video_deletion", 1); len(again) != 0 {
+		t.Error("Dead letters must not be claimed")
+	}
+
+	retried, err := ts.RetryTask(task.ID)
+	if err != nil || retried.Status != StatusPending || retried.Attempts != 0 {
+		t.Fatalf("Expected retried task to be pending with no attempts, got %+v %v", retried, err)
+	}
+	if claimed, _ := ts.Claim("video_deletion", 1); len(claimed) != 1 {
+		t.Error("Expected retried task to be claimable")
+	}
+}
+
+func TestTaskStorage_VisibilityTimeout(t *testing.T) {
+	ts, now := newTestQueue(t, models.TaskQueueConfig{VisibilityTimeout: time.Minute, BackoffBase: time.Second})
+	task, _ := ts.CreateTask(TranscodeTaskType, "{}")
+
+	claimed, _ := ts.Claim(TranscodeTaskType, 1)
+
+	// Progress renews the lease
+	*now = now.Add(50 * time.Second)
+	if err := ts.UpdateTaskProgress(claimed[0], 0.5); err != nil {
+		t.Fatalf("Failed to report progress: %v", err)
+	}
+	*now = now.Add(50 * time.Second)
+	if again, _ := ts.Claim(TranscodeTaskType, 1); len(again) != 0 {
+		t.Fatal("Task with a renewed lease must not be reclaimed")
+	}
+
+	// The worker dies: after the lease and the backoff the task is claimed again
+	*now = now.Add(time.Minute + time.Second)
+	ts.Claim(TranscodeTaskType, 1) // reclaims the expired lease
+	*now = now.Add(time.Second)
+	reclaimed, _ := ts.Claim(TranscodeTaskType, 1)
+	if len(reclaimed) != 1 || reclaimed[0].Attempts != 2 {
+		t.Fatalf("Expected task to be reclaimed for a second attempt, got %+v", reclaimed)
+	}
+
+	// The original worker reporting progress late must not renew the new attempt's lease
+	if err := ts.UpdateTaskProgress(claimed[0], 0.9); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost for progress from a stale claim, got %v", err)
+	}
+	if current, _ := ts.GetTask(task.ID); current.Progress == 0.9 || !current.LeaseExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected the stale progress to be ignored, got %+v", current)
+	}
+
+	// The original worker finishing late must not overwrite the new attempt
+	if err := ts.Ack(claimed[0], nil); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost for a stale claim, got %v", err)
+	}
+	if err := ts.RemoveTask(task.ID); !errors.Is(err, ErrTaskProcessing) {
+		t.Errorf("Expected processing task removal to be refused, got %v", err)
+	}
+}
+
+func TestTaskStorage_ListAndPurge(t *testing.T) {
+	ts, now := newTestQueue(t, models.TaskQueueConfig{MaxAttempts: 1})
+	ts.CreateTask("video_deletion", "a.mp4")
+	ts.CreateTask("video_deletion", "b.mp4")
+	ts.CreateTask(TranscodeTaskType, "{}")
+
+	claimed, _ := ts.Claim("video_deletion", 2)
+	ts.Ack(claimed[0], nil)
+	ts.Nack(claimed[1], nil, errors.New("gone"))
+
+	dead, _ := ts.ListTasks(TaskFilter{Status: StatusDead})
+	if len(dead) != 1 || dead[0].ID != claimed[1].ID {
+		t.Fatalf("Expected one dead letter, got %+v", dead)
+	}
+	all, _ := ts.ListTasks(TaskFilter{})
+	if len(all) != 3 || all[0].Type != TranscodeTaskType {
+		t.Fatalf("Expected all tasks newest first, got %+v", all)
+	}
+
+	if removed, _ := ts.PurgeTasks("", time.Hour); removed != 0 {
+		t.Errorf("Recently finished tasks must be kept, removed %d", removed)
+	}
+	*now = now.Add(2 * time.Hour)
+	if removed, _ := ts.PurgeTasks(StatusDead, time.Hour); removed != 1 {
+		t.Errorf("Expected one dead letter to be purged, removed %d", removed)
+	}
+	if _, err := ts.PurgeTasks(StatusPending, 0); err == nil {
+		t.Error("Expected pending tasks to be refused for purging")
+	}
+	if stats, _ := ts.GetTaskStats(); stats["total"] != 2 {
+		t.Errorf("Expected 2 tasks left, got %v", stats)
+	}
+}
+
+func TestTaskStorage_ImportsLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := []TaskRecord{
+		{ID: "1_video_deletion", Type: "video_deletion", Data: "a.mp4", Status: "processing", CreatedAt: time.Now().Add(-time.Hour)},
+		{ID: "2_video_deletion", Type: "video_deletion", Data: "b.mp4", Status: "failed", CreatedAt: time.Now()},
+	}
+	for _, task := range legacy {
+		data, _ := json.Marshal(task)
+		if err := os.WriteFile(filepath.Join(dir, task.ID+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts, _ := newTestQueue(t, models.TaskQueueConfig{Path: filepath.Join(dir, "tasks.db")})
+
+	claimed, _ := ts.Claim("video_deletion", 10)
+	if len(claimed) != 1 || claimed[0].ID != "1_video_deletion" {
+		t.Fatalf("Expected interrupted legacy task to be requeued, got %+v", claimed)
+	}
+	if record, _ := ts.GetTask("2_video_deletion"); record.Status != StatusDead {
+		t.Errorf("Expected failed legacy task to become a dead letter, got %s", record.Status)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 {
+		t.Errorf("Expected legacy files to be removed, found %v", files)
+	}
+}
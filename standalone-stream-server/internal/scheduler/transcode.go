@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+// TranscodeTaskType is the task type for rendition ladder transcoding
+const TranscodeTaskType = "transcode"
+
+// TranscodeJob is the payload stored in TaskRecord.Data for transcode tasks
+type TranscodeJob struct {
+	VideoID    string  `json:"video_id"`
+	SourcePath string  `json:"source_path"`
+	Duration   float64 `json:"duration,omitempty"` // seconds, used for progress reporting
+	Height     int     `json:"height,omitempty"`   // source height, taller renditions are skipped
+}
+
+// RenditionResult describes the outcome of a single rendition
+type RenditionResult struct {
+	Quality string `json:"quality"`
+	Path    string `json:"path,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Skipped string `json:"skipped,omitempty"` // reason the rendition was not produced
+	Error   string `json:"error,omitempty"`
+}
+
+// TranscodeService produces rendition ladders for videos using a local ffmpeg
+type TranscodeService struct {
+	storage    *TaskStorage
+	ffmpegPath string
+	renditions []models.RenditionConfig
+	videoDirs  []string
+}
+
+// NewTranscodeService creates a new transcode service
+func NewTranscodeService(storage *TaskStorage, ffmpegPath string, renditions []models.RenditionConfig) *TranscodeService {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	return &TranscodeService{
+		storage:    storage,
+		ffmpegPath: ffmpegPath,
+		renditions: renditions,
+	}
+}
+
+// NewTranscodeJob builds a transcode job from a video
+func NewTranscodeJob(video *services.VideoInfo) TranscodeJob {
+	job := TranscodeJob{
+		VideoID:    video.ID,
+		SourcePath: video.Path,
+		Duration:   video.Metadata.Duration,
+	}
+
+	if parts := strings.SplitN(video.Metadata.Resolution, "x", 2); len(parts) == 2 {
+		job.Height, _ = strconv.Atoi(parts[1])
+	}
+
+	return job
+}
+
+// AddTranscodeTask queues a transcode task
+func (ts *TranscodeService) AddTranscodeTask(job TranscodeJob) (TaskRecord, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return TaskRecord{}, fmt.Errorf("failed to encode transcode job: %w", err)
+	}
+
+	return ts.storage.CreateTask(TranscodeTaskType, string(data))
+}
+
+// TaskType returns the registration of transcode tasks (one video is transcoded at a time)
+func (ts *TranscodeService) TaskType() TaskType[TranscodeJob] {
+	return TaskType[TranscodeJob]{
+		Name:         TranscodeTaskType,
+		Concurrency:  1,
+		PollInterval: 10 * time.Second,
+		Validate:     ts.validateJob,
+		Handle:       ts.handleTask,
+	}
+}
+
+// RestrictToDirectories only accepts transcode jobs for sources inside dirs
+func (ts *TranscodeService) RestrictToDirectories(dirs []string) {
+	ts.videoDirs = dirs
+}
+
+// validateJob checks that a job names a source video
+func (ts *TranscodeService) validateJob(job TranscodeJob) error {
+	if job.VideoID == "" || job.SourcePath == "" {
+		return errors.New("video_id and source_path are required")
+	}
+	if ts.videoDirs != nil {
+		return withinDirectories(job.SourcePath, ts.videoDirs)
+	}
+	return nil
+}
+
+// handleTask transcodes a single task; progress updates also renew the task's lease
+func (ts *TranscodeService) handleTask(tc *TaskContext, job TranscodeJob) (interface{}, error) {
+	results, err := ts.Transcode(job, tc.Progress)
+	if err != nil {
+		return results, fmt.Errorf("failed to transcode video %s: %w", job.VideoID, err)
+	}
+
+	log.Printf("Successfully transcoded video: %s", job.VideoID)
+	return results, nil
+}
+
+// Transcode produces every rendition of the ladder for a job, reporting overall progress (0-1)
+func (ts *TranscodeService) Transcode(job TranscodeJob, progress func(float64)) ([]RenditionResult, error) {
+	if _, err := os.Stat(job.SourcePath); err != nil {
+		return nil, fmt.Errorf("source video unavailable: %w", err)
+	}
+
+	if err := os.MkdirAll(services.RenditionDir(job.SourcePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rendition directory: %w", err)
+	}
+
+	results := make([]RenditionResult, 0, len(ts.renditions))
+	var failed []string
+
+	for i, rendition := range ts.renditions {
+		result := RenditionResult{Quality: rendition.Name}
+
+		// Never upscale: renditions taller than the source are skipped
+		if job.Height > 0 && rendition.Height > job.Height {
+			result.Skipped = fmt.Sprintf("source height %d is below %d", job.Height, rendition.Height)
+			results = append(results, result)
+			continue
+		}
+
+		base := float64(i) / float64(len(ts.renditions))
+		step := 1 / float64(len(ts.renditions))
+		outputPath := services.RenditionPath(job.SourcePath, rendition.Name, rendition.Height == 0)
+
+		err := ts.encodeRendition(job, rendition, outputPath, func(fraction float64) {
+			if progress != nil {
+				progress(base + fraction*step)
+			}
+		})
+		if err != nil {
+			result.Error = err.Error()
+			failed = append(failed, rendition.Name)
+			results = append(results, result)
+			continue
+		}
+
+		result.Path = outputPath
+		if info, err := os.Stat(outputPath); err == nil {
+			result.Size = info.Size()
+		}
+		results = append(results, result)
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("failed to produce renditions: %s", strings.Join(failed, ", "))
+	}
+
+	return results, nil
+}
+
+// encodeRendition runs ffmpeg for one rendition, writing to a temporary file that is renamed on success
+func (ts *TranscodeService) encodeRendition(job TranscodeJob, rendition models.RenditionConfig, outputPath string, progress func(float64)) error {
+	tmpPath := outputPath + ".part"
+	defer os.Remove(tmpPath)
+
+	cmd := exec.Command(ts.ffmpegPath, renditionArgs(job.SourcePath, tmpPath, rendition)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to attach to ffmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	// ffmpeg -progress writes key=value lines; out_time_us is the encoded position
+	lastReported := 0.0
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || (key != "out_time_us" && key != "out_time_ms") || job.Duration <= 0 {
+			continue
+		}
+
+		position, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		fraction := position / 1e6 / job.Duration
+		if fraction > 1 {
+			fraction = 1
+		}
+		if fraction-lastReported >= 0.01 {
+			lastReported = fraction
+			progress(fraction)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed for %s: %w: %s", rendition.Name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return fmt.Errorf("failed to store rendition %s: %w", rendition.Name, err)
+	}
+	progress(1)
+
+	return nil
+}
+
+// renditionArgs returns the ffmpeg arguments for a rendition
+func renditionArgs(sourcePath, outputPath string, rendition models.RenditionConfig) []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-nostats",
+		"-progress", "pipe:1",
+		"-i", sourcePath,
+	}
+
+	audioBitrate := rendition.AudioBitrate
+	if audioBitrate == "" {
+		audioBitrate = "128k"
+	}
+
+	if rendition.Height == 0 {
+		args = append(args, "-vn", "-map", "0:a:0")
+	} else {
+		args = append(args,
+			"-map", "0:v:0", "-map", "0:a:0?",
+			"-vf", fmt.Sprintf("scale=-2:%d", rendition.Height),
+			"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+		)
+		if rendition.VideoBitrate != "" {
+			args = append(args, "-b:v", rendition.VideoBitrate, "-maxrate", rendition.VideoBitrate)
+		}
+	}
+
+	args = append(args,
+		"-c:a", "aac", "-ac", "2", "-b:a", audioBitrate,
+		"-movflags", "+faststart",
+		"-f", "mp4", "-y", outputPath,
+	)
+
+	return args
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+// fakeFFmpeg writes a script that reports progress and writes its last argument
+const fakeFFmpeg = `#!/bin/sh
+for last; do :; done
+echo "out_time_us=5000000"
+echo "progress=continue"
+printf 'rendition' > "$last"
+echo "progress=end"
+`
+
+// TestTranscodeService_RenditionLadder tests the transcode task lifecycle with a fake ffmpeg
+func TestTranscodeService_RenditionLadder(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, _ := newTestQueue(t, models.TaskQueueConfig{Path: filepath.Join(tempDir, "tasks", "tasks.db")})
+
+	ffmpegPath := filepath.Join(tempDir, "ffmpeg")
+	if err := os.WriteFile(ffmpegPath, []byte(fakeFFmpeg), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sourcePath := filepath.Join(tempDir, "movie.mp4")
+	if err := os.WriteFile(sourcePath, []byte("source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewTranscodeService(storage, ffmpegPath, []models.RenditionConfig{
+		{Name: "1080p", Height: 1080, VideoBitrate: "5000k"},
+		{Name: "720p", Height: 720, VideoBitrate: "2800k"},
+		{Name: "audio", Height: 0},
+	})
+
+	task, err := service.AddTranscodeTask(NewTranscodeJob(&services.VideoInfo{
+		ID:   "default:movie",
+		Path: sourcePath,
+		Metadata: services.VideoMetadata{
+			Duration:   10,
+			Resolution: "1280x720",
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to add transcode task: %v", err)
+	}
+
+	registry := NewTaskRegistry(storage, nil)
+	if err := RegisterTaskType(registry, service.TaskType()); err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := registry.RunPending(TranscodeTaskType); err != nil || ran != 1 {
+		t.Fatalf("Expected one transcode task to run, got %d: %v", ran, err)
+	}
+
+	record, err := storage.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != "completed" || record.Progress != 1 {
+		t.Fatalf("Expected completed task with full progress, got status=%s progress=%v error=%s", record.Status, record.Progress, record.Error)
+	}
+
+	var results []RenditionResult
+	if err := json.Unmarshal(record.Result, &results); err != nil {
+		t.Fatalf("Failed to decode task result: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 rendition results, got %d", len(results))
+	}
+	if results[0].Skipped == "" {
+		t.Error("1080p rendition should be skipped for a 720p source")
+	}
+
+	for _, path := range []string{
+		services.RenditionPath(sourcePath, "720p", false),
+		services.RenditionPath(sourcePath, "audio", true),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected rendition %s: %v", path, err)
+		}
+		if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+			t.Errorf("Temporary file should be removed for %s", path)
+		}
+	}
+
+	// No pending tasks left
+	if ran, _ := registry.RunPending(TranscodeTaskType); ran != 0 {
+		t.Errorf("Expected no pending tasks, ran %d", ran)
+	}
+}
+
+// TestTranscodeService_FailureRecorded tests that ffmpeg failures are recorded and the task is retried later
+func TestTranscodeService_FailureRecorded(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, _ := newTestQueue(t, models.TaskQueueConfig{Path: filepath.Join(tempDir, "tasks", "tasks.db")})
+
+	sourcePath := filepath.Join(tempDir, "movie.mp4")
+	if err := os.WriteFile(sourcePath, []byte("source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewTranscodeService(storage, filepath.Join(tempDir, "missing-ffmpeg"), []models.RenditionConfig{
+		{Name: "480p", Height: 480},
+	})
+
+	task, err := service.AddTranscodeTask(TranscodeJob{VideoID: "default:movie", SourcePath: sourcePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewTaskRegistry(storage, nil)
+	RegisterTaskType(registry, service.TaskType())
+	registry.RunPending(TranscodeTaskType)
+
+	record, err := storage.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != StatusPending || record.Error == "" || record.Attempts != 1 {
+		t.Errorf("Expected task to be requeued with its error, got status=%s attempts=%d error=%q", record.Status, record.Attempts, record.Error)
+	}
+	if !record.NextRunAt.After(time.Now()) {
+		t.Errorf("Expected retry to be delayed by the backoff, next run at %v", record.NextRunAt)
+	}
+
+	if _, err := storage.GetTask("../" + task.ID); err == nil {
+		t.Error("Expected error for task ID containing a path")
+	}
+}
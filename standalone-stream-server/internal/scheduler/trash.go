@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// ActionPurgeTrash permanently deletes the trashed videos whose grace period has passed
+const ActionPurgeTrash = "purge_trash"
+
+// TrashPurgeJobName is the name of the job added when the trash is enabled and no configured job
+// uses the purge_trash action
+const TrashPurgeJobName = "trash-purge"
+
+// defaultTrashPurgeSchedule is used when video.trash.purge_schedule is empty
+const defaultTrashPurgeSchedule = "@hourly"
+
+// trashPurgeJob returns the built-in trash purge job, if the trash is enabled and the configuration
+// does not schedule purge_trash itself
+func trashPurgeJob(config *models.Config) (models.CronJobConfig, bool) {
+	if !config.Video.Trash.Enabled {
+		return models.CronJobConfig{}, false
+	}
+	for _, job := range config.Scheduler.Jobs {
+		if job.Action == ActionPurgeTrash {
+			return models.CronJobConfig{}, false
+		}
+	}
+
+	schedule := config.Video.Trash.PurgeSchedule
+	if schedule == "" {
+		schedule = defaultTrashPurgeSchedule
+	}
+	return models.CronJobConfig{Name: TrashPurgeJobName, Enabled: true, Schedule: schedule, Action: ActionPurgeTrash}, true
+}
+
+// purgeTrash permanently deletes expired trash entries
+func (ss *SchedulerService) purgeTrash(ctx context.Context, params map[string]string) (interface{}, error) {
+	if ss.trash == nil {
+		return nil, errors.New("trash is disabled")
+	}
+	return ss.trash.Purge(time.Now())
+}
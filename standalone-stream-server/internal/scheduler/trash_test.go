@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/services"
+)
+
+func TestSchedulerService_DeletionMovesToTrash(t *testing.T) {
+	tempDir := t.TempDir()
+	videoDir := filepath.Join(tempDir, "videos")
+	os.MkdirAll(videoDir, 0755)
+	videoPath := filepath.Join(videoDir, "movie.mp4")
+	if err := os.WriteFile(videoPath, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &models.Config{}
+	config.Video.Directories = []models.VideoDirectory{{Name: "movies", Path: videoDir, Enabled: true}}
+	config.Video.Trash = models.TrashConfig{Enabled: true, PurgeSchedule: "@daily"}
+	config.Scheduler.Queue.Path = filepath.Join(tempDir, "tasks", "tasks.db")
+
+	ss, err := NewSchedulerService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+
+	if job, err := ss.cron.Job(TrashPurgeJobName); err != nil || job.Schedule != "@daily" {
+		t.Fatalf("Expected the built-in trash purge job, got %+v %v", job, err)
+	}
+
+	if err := ss.QueueVideoDeletion(VideoDeletionJob{Path: videoPath, DeletedBy: "alice", Reason: "duplicate"}); err != nil {
+		t.Fatal(err)
+	}
+	if ran, _ := ss.registry.RunPending(VideoDeletionTaskType); ran != 1 {
+		t.Fatalf("Expected the deletion task to run, ran %d", ran)
+	}
+	if _, err := os.Stat(videoPath); !os.IsNotExist(err) {
+		t.Error("Expected the video to be moved out of the directory")
+	}
+
+	entries, _ := ss.Trash().List("")
+	if len(entries) != 1 || entries[0].DeletedBy != "alice" || entries[0].Reason != "duplicate" {
+		t.Fatalf("Expected the video in the trash, got %+v", entries)
+	}
+
+	// With a zero grace period the entry expires immediately
+	run, err := ss.cron.Run(TrashPurgeJobName)
+	if err != nil || run.Error != "" {
+		t.Fatalf("Trash purge failed: %+v %v", run, err)
+	}
+	if result := run.Result.(services.TrashPurgeResult); result.Purged != 1 {
+		t.Errorf("Expected one entry to be purged, got %+v", result)
+	}
+
+	// Without the trash, deletion removes the file and there is no purge job
+	disabled := *config
+	disabled.Video.Trash = models.TrashConfig{}
+	disabled.Scheduler.Queue.Path = filepath.Join(tempDir, "other", "tasks.db")
+	other, err := NewSchedulerService(&disabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if other.Trash() != nil {
+		t.Error("Expected no trash when it is disabled")
+	}
+	if _, err := other.cron.Job(TrashPurgeJobName); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected no trash purge job, got %v", err)
+	}
+}
@@ -1,18 +1,42 @@
 package scheduler
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"standalone-stream-server/internal/services"
+	"strings"
 	"sync"
 	"time"
 )
 
+// VideoDeletionTaskType is the task type for deleting video files
+const VideoDeletionTaskType = "video_deletion"
+
+// VideoDeletionJob is the payload of video deletion tasks
+type VideoDeletionJob struct {
+	Path      string `json:"path"`
+	DeletedBy string `json:"deleted_by,omitempty"` // user, API key or job that requested the deletion
+	Reason    string `json:"reason,omitempty"`
+}
+
+// decodeLegacy accepts the bare file path stored by deletion tasks queued before payloads were JSON
+func (job *VideoDeletionJob) decodeLegacy(data string) error {
+	if data == "" {
+		return errors.New("empty video path")
+	}
+	job.Path = data
+	return nil
+}
+
 // VideoCleanupService handles video file cleanup tasks
 type VideoCleanupService struct {
 	storage   *TaskStorage
 	videoDirs []string
+	trash     *services.Trash
 	mu        sync.RWMutex
 }
 
@@ -26,87 +50,63 @@ func NewVideoCleanupService(storage *TaskStorage, videoDirs []string) *VideoClea
 
 // AddVideoDeletionTask adds a video for deletion
 func (vcs *VideoCleanupService) AddVideoDeletionTask(videoPath string) error {
-	return vcs.storage.AddTask("video_deletion", videoPath)
-}
-
-// VideoClearDispatcher dispatches video deletion tasks
-func (vcs *VideoCleanupService) VideoClearDispatcher(dataChan chan interface{}) error {
-	tasks, err := vcs.storage.GetPendingTasks("video_deletion", 3)
+	data, err := json.Marshal(VideoDeletionJob{Path: videoPath})
 	if err != nil {
-		log.Printf("Video clear dispatcher error: %v", err)
-		return err
+		return fmt.Errorf("failed to encode video deletion job: %w", err)
 	}
-	
-	if len(tasks) == 0 {
-		return errors.New("no pending video deletion tasks")
+	return vcs.storage.AddTask(VideoDeletionTaskType, string(data))
+}
+
+// SetTrash makes deletion tasks move videos to the trash instead of removing them
+func (vcs *VideoCleanupService) SetTrash(trash *services.Trash) {
+	vcs.mu.Lock()
+	defer vcs.mu.Unlock()
+	vcs.trash = trash
+}
+
+// TaskType returns the registration of video deletion tasks (up to three files are deleted at once)
+func (vcs *VideoCleanupService) TaskType() TaskType[VideoDeletionJob] {
+	return TaskType[VideoDeletionJob]{
+		Name:         VideoDeletionTaskType,
+		Concurrency:  3,
+		PollInterval: 30 * time.Second,
+		Validate:     vcs.validateJob,
+		Handle:       vcs.handleDeletion,
 	}
-	
-	// Mark tasks as processing and send to executor
-	for _, task := range tasks {
-		if err := vcs.storage.UpdateTaskStatus(task.ID, "processing"); err != nil {
-			log.Printf("Failed to update task status: %v", err)
-			continue
-		}
-		
-		dataChan <- task
+}
+
+// validateJob only accepts files inside the configured video directories
+func (vcs *VideoCleanupService) validateJob(job VideoDeletionJob) error {
+	if job.Path == "" {
+		return errors.New("path is required")
 	}
-	
-	return nil
+	return withinDirectories(job.Path, vcs.videoDirs)
 }
 
-// VideoClearExecutor executes video deletion tasks
-func (vcs *VideoCleanupService) VideoClearExecutor(dataChan chan interface{}) error {
-	errorMap := &sync.Map{}
-	var wg sync.WaitGroup
+// handleDeletion moves the video file of a task to the trash, or deletes it when the trash is disabled
+func (vcs *VideoCleanupService) handleDeletion(tc *TaskContext, job VideoDeletionJob) (interface{}, error) {
+	vcs.mu.RLock()
+	trash := vcs.trash
+	vcs.mu.RUnlock()
 	
-	// Process all available tasks
-	for {
-		select {
-		case taskInterface := <-dataChan:
-			wg.Add(1)
-			go func(t interface{}) {
-				defer wg.Done()
-				
-				task, ok := t.(TaskRecord)
-				if !ok {
-					log.Printf("Invalid task type received")
-					return
-				}
-				
-				if err := vcs.deleteVideo(task.Data); err != nil {
-					log.Printf("Failed to delete video %s: %v", task.Data, err)
-					errorMap.Store(task.ID, err)
-					vcs.storage.UpdateTaskStatus(task.ID, "failed")
-					return
-				}
-				
-				// Successfully deleted, remove the task
-				if err := vcs.storage.RemoveTask(task.ID); err != nil {
-					log.Printf("Failed to remove completed task %s: %v", task.ID, err)
-					errorMap.Store(task.ID, err)
-					return
-				}
-				
-				log.Printf("Successfully deleted video: %s", task.Data)
-			}(taskInterface)
-			
-		default:
-			// No more tasks available
-			goto waitForCompletion
+	if trash != nil {
+		if _, err := os.Stat(job.Path); os.IsNotExist(err) {
+			return nil, nil
+		}
+		entry, err := trash.Move(job.Path, job.DeletedBy, job.Reason)
+		if err != nil {
+			return nil, err
 		}
+		log.Printf("Moved video to trash: %s (entry %s)", job.Path, entry.ID)
+		return entry, nil
 	}
 	
-waitForCompletion:
-	wg.Wait()
-	
-	// Check if any errors occurred
-	var lastError error
-	errorMap.Range(func(k, v interface{}) bool {
-		lastError = v.(error)
-		return true
-	})
+	if err := vcs.deleteVideo(job.Path); err != nil {
+		return nil, err
+	}
 	
-	return lastError
+	log.Printf("Successfully deleted video: %s", job.Path)
+	return nil, nil
 }
 
 // deleteVideo removes a video file from the filesystem
@@ -140,8 +140,23 @@ func (vcs *VideoCleanupService) GetStats() (map[string]interface{}, error) {
 	return stats, nil
 }
 
-// CleanupOldTasks removes old completed and failed tasks
-func (vcs *VideoCleanupService) CleanupOldTasks() error {
-	// Clean up tasks older than 24 hours
-	return vcs.storage.CleanupCompletedTasks(24 * time.Hour)
+// withinDirectories reports an error unless path is inside one of dirs
+func withinDirectories(path string, dirs []string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	
+	for _, dir := range dirs {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, absPath)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	
+	return fmt.Errorf("%s is not inside a configured video directory", path)
 }
\ No newline at end of file
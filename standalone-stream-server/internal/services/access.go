@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"slices"
+
+	"standalone-stream-server/internal/models"
+)
+
+// 目录访问模式
+const (
+	AccessPublic        = "public"
+	AccessAuthenticated = "authenticated"
+	AccessRestricted    = "restricted"
+)
+
+// 目录权限
+const (
+	RightRead   = "read"
+	RightUpload = "upload"
+	RightDelete = "delete"
+)
+
+// ErrAccessDenied 表示请求方没有目录的相应权限
+var ErrAccessDenied = errors.New("access denied")
+
+// CanAccess 检查请求方是否拥有目录的指定权限。
+// 认证未启用时不做限制；API 密钥先受自身作用域和目录限制；管理员拥有全部目录的全部权限；
+// principal 为 nil 表示匿名请求
+func (vs *VideoService) CanAccess(principal *Principal, directoryName, right string) bool {
+	if !AuthEnforced(vs.config) {
+		return true
+	}
+	if !principal.keyAllows(directoryName, right) {
+		return false
+	}
+	if principal != nil && principal.Role == RoleAdmin {
+		return true
+	}
+
+	dir := vs.findDirectory(directoryName)
+	if dir == nil {
+		return false
+	}
+	access := dir.Access
+
+	if right == RightRead {
+		switch access.Mode {
+		case AccessPublic:
+			return true
+		case "", AccessAuthenticated:
+			if principal != nil {
+				return true
+			}
+		}
+		return grantsAllow(access.Grants, principal, right)
+	}
+
+	if principal == nil {
+		return false
+	}
+	// 删除只能由管理员或被明确授予 delete 权限的请求方执行
+	if right == RightDelete {
+		return grantsAllow(access.Grants, principal, right)
+	}
+	// 上传首先受路由角色限制；目录配置了授权列表时还必须被明确授权
+	if access.Mode != AccessRestricted && len(access.Grants) == 0 {
+		return true
+	}
+	return grantsAllow(access.Grants, principal, right)
+}
+
+// ListVisibleVideos 返回请求方可读的全部视频
+func (vs *VideoService) ListVisibleVideos(principal *Principal) ([]VideoInfo, error) {
+	var visible []VideoInfo
+
+	for _, dir := range vs.config.Video.Directories {
+		if !dir.Enabled || !vs.CanAccess(principal, dir.Name, RightRead) {
+			continue
+		}
+
+		videos, err := vs.ListVideosInDirectory(dir.Name)
+		if err != nil {
+			continue
+		}
+		visible = append(visible, videos...)
+	}
+
+	return visible, nil
+}
+
+// VisibleDirectories 返回请求方可读的目录信息
+func (vs *VideoService) VisibleDirectories(principal *Principal) []DirectoryInfo {
+	directories := []DirectoryInfo{}
+	for _, dir := range vs.GetDirectoriesInfo() {
+		if vs.CanAccess(principal, dir.Name, RightRead) {
+			directories = append(directories, dir)
+		}
+	}
+	return directories
+}
+
+// grantsAllow 检查授权列表中是否有条目向请求方授予了权限
+func grantsAllow(grants []models.AccessGrant, principal *Principal, right string) bool {
+	if principal == nil {
+		return false
+	}
+
+	for _, grant := range grants {
+		if !slices.Contains(grant.Rights, right) {
+			continue
+		}
+		if principal.KeyID != "" {
+			if slices.Contains(grant.APIKeys, principal.KeyID) {
+				return true
+			}
+			continue
+		}
+		if slices.Contains(grant.Users, principal.Username) {
+			return true
+		}
+		for _, group := range principal.Groups {
+			if slices.Contains(grant.Groups, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newAccessTestService(t *testing.T) *VideoService {
+	t.Helper()
+
+	root := t.TempDir()
+	directories := []models.VideoDirectory{
+		{Name: "public", Enabled: true, Access: models.DirectoryAccess{Mode: AccessPublic}},
+		{Name: "members", Enabled: true},
+		{Name: "internal", Enabled: true, Access: models.DirectoryAccess{
+			Mode: AccessRestricted,
+			Grants: []models.AccessGrant{
+				{Users: []string{"alice"}, Rights: []string{RightRead, RightUpload}},
+				{Groups: []string{"editors"}, Rights: []string{RightRead, RightDelete}},
+				{APIKeys: []string{"ingest"}, Rights: []string{RightUpload}},
+				{APIKeys: []string{"cleanup"}, Rights: []string{RightDelete}},
+			},
+		}},
+	}
+	for i := range directories {
+		directories[i].Path = filepath.Join(root, directories[i].Name)
+		if err := os.MkdirAll(directories[i].Path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(directories[i].Path, directories[i].Name+".mp4"), []byte("video"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories:      directories,
+			SupportedFormats: []string{".mp4"},
+		},
+	}
+	config.Security.Auth.Enabled = true
+	config.Security.Auth.Type = "jwt"
+	return NewVideoService(config)
+}
+
+func TestVideoService_CanAccess(t *testing.T) {
+	service := newAccessTestService(t)
+
+	alice := &Principal{Username: "alice", Role: RoleUploader}
+	bob := &Principal{Username: "bob", Role: RoleViewer}
+	editor := &Principal{Username: "carol", Role: RoleUploader, Groups: []string{"editors"}}
+	ingest := &Principal{Username: "apikey:ingest", Role: RoleUploader, KeyID: "ingest", Scopes: []string{RightRead, RightUpload}}
+	deleteKey := &Principal{Username: "apikey:cleanup", Role: RoleViewer, KeyID: "cleanup", Scopes: []string{RightDelete}}
+	readOnlyKey := &Principal{Username: "apikey:reader", Role: RoleViewer, KeyID: "reader", Scopes: []string{RightRead}, Directories: []string{"members"}}
+	adminKey := &Principal{Username: "apikey:ops", Role: RoleAdmin, KeyID: "ops", Scopes: []string{ScopeAdmin}, Directories: []string{"public"}}
+	admin := &Principal{Username: "root", Role: RoleAdmin}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		directory string
+		right     string
+		expected  bool
+	}{
+		{"anonymous reads public", nil, "public", RightRead, true},
+		{"anonymous cannot read members", nil, "members", RightRead, false},
+		{"anonymous cannot upload to public", nil, "public", RightUpload, false},
+		{"user reads members", bob, "members", RightRead, true},
+		{"user uploads without grants", alice, "members", RightUpload, true},
+		{"user cannot delete without grants", alice, "members", RightDelete, false},
+		{"unlisted user cannot read restricted", bob, "internal", RightRead, false},
+		{"listed user reads restricted", alice, "internal", RightRead, true},
+		{"listed user uploads restricted", alice, "internal", RightUpload, true},
+		{"listed user lacks delete", alice, "internal", RightDelete, false},
+		{"group member reads restricted", editor, "internal", RightRead, true},
+		{"group member deletes restricted", editor, "internal", RightDelete, true},
+		{"group member lacks upload", editor, "internal", RightUpload, false},
+		{"api key uploads", ingest, "internal", RightUpload, true},
+		{"api key lacks read", ingest, "internal", RightRead, false},
+		{"delete key deletes granted directory", deleteKey, "internal", RightDelete, true},
+		{"delete key needs a grant", deleteKey, "members", RightDelete, false},
+		{"upload key cannot delete", ingest, "internal", RightDelete, false},
+		{"read-only key reads its directory", readOnlyKey, "members", RightRead, true},
+		{"read-only key cannot upload", readOnlyKey, "members", RightUpload, false},
+		{"key limited to other directories", readOnlyKey, "public", RightRead, false},
+		{"admin key deletes in its directory", adminKey, "public", RightDelete, true},
+		{"admin key limited to its directories", adminKey, "internal", RightRead, false},
+		{"admin bypasses acl", admin, "internal", RightDelete, true},
+		{"unknown directory", bob, "missing", RightRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.CanAccess(tt.principal, tt.directory, tt.right); got != tt.expected {
+				t.Errorf("CanAccess(%v, %s, %s) = %v, expected %v", tt.principal, tt.directory, tt.right, got, tt.expected)
+			}
+		})
+	}
+
+	// 未启用认证时不做限制
+	service.config.Security.Auth.Enabled = false
+	if !service.CanAccess(nil, "internal", RightDelete) {
+		t.Error("ACLs must not be enforced without authentication")
+	}
+}
+
+func TestVideoService_VisibleListings(t *testing.T) {
+	service := newAccessTestService(t)
+
+	directoryNames := func(principal *Principal) []string {
+		var names []string
+		for _, dir := range service.VisibleDirectories(principal) {
+			names = append(names, dir.Name)
+		}
+		return names
+	}
+
+	if names := directoryNames(nil); len(names) != 1 || names[0] != "public" {
+		t.Errorf("Anonymous callers should only see public directories, got %v", names)
+	}
+	if names := directoryNames(&Principal{Username: "bob", Role: RoleViewer}); len(names) != 2 {
+		t.Errorf("Expected public and members directories, got %v", names)
+	}
+
+	videos, err := service.ListVisibleVideos(&Principal{Username: "alice", Role: RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 {
+		t.Errorf("Expected alice to see all 3 videos, got %d", len(videos))
+	}
+
+	videos, err = service.ListVisibleVideos(&Principal{Username: "bob", Role: RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, video := range videos {
+		if video.Directory == "internal" {
+			t.Errorf("Listing must not return videos from restricted directories, got %v", video.ID)
+		}
+	}
+}
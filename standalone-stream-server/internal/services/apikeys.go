@@ -0,0 +1,531 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"standalone-stream-server/internal/utils"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ScopeAdmin 授予 API 密钥管理员权限；其余作用域与目录权限（read、upload、delete）同名
+const ScopeAdmin = "admin"
+
+// apiKeyPrefix 是生成的 API 密钥的前缀，便于在日志和代码中识别泄露的密钥
+const apiKeyPrefix = "ssk_"
+
+// API 密钥的错误
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyInvalid  = errors.New("invalid api key")
+	ErrAPIKeyExpired  = errors.New("api key expired")
+	ErrAPIKeyRevoked  = errors.New("api key revoked")
+)
+
+var (
+	apiKeysBucket      = []byte("keys")
+	apiKeyHashesBucket = []byte("hashes")
+)
+
+// APIKey 是存储的 API 密钥记录；密钥本身只在创建时返回一次，存储的是 SHA-256 哈希
+type APIKey struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Hash         string   `json:"hash"`
+	Scopes       []string `json:"scopes"`                // read、upload、delete、admin
+	Directories  []string `json:"directories,omitempty"` // 为空表示全部目录
+	CreatedBy    string   `json:"created_by,omitempty"`
+	CreatedAt    int64    `json:"created_at"`
+	ExpiresAt    int64    `json:"expires_at,omitempty"` // 0 表示永不过期
+	RevokedAt    int64    `json:"revoked_at,omitempty"`
+	LastUsedAt   int64    `json:"last_used_at,omitempty"`
+	RequestCount uint64   `json:"request_count"`
+}
+
+// Status 返回密钥状态：active、expired 或 revoked
+func (k *APIKey) Status() string {
+	switch {
+	case k.RevokedAt != 0:
+		return "revoked"
+	case k.ExpiresAt != 0 && time.Now().Unix() >= k.ExpiresAt:
+		return "expired"
+	}
+	return "active"
+}
+
+// Role 返回与作用域对应的角色，用于路由级别的角色检查。
+// delete 作用域不提升角色：删除路由只要求登录，由 CanAccess 按目录的 delete 授权检查
+func (k *APIKey) Role() string {
+	switch {
+	case slices.Contains(k.Scopes, ScopeAdmin):
+		return RoleAdmin
+	case slices.Contains(k.Scopes, RightUpload):
+		return RoleUploader
+	}
+	return RoleViewer
+}
+
+// APIKeySpec 描述新建或修改的密钥范围
+type APIKeySpec struct {
+	Name        string
+	Scopes      []string
+	Directories []string
+	ExpiresAt   time.Time // 零值表示永不过期
+}
+
+// keyUsage 是尚未写入数据库的使用统计
+type keyUsage struct {
+	count    atomic.Uint64
+	lastUsed atomic.Int64
+}
+
+// APIKeyStore 是持久化在磁盘上的 API 密钥库。使用统计先记录在内存中，由后台定期写入数据库
+type APIKeyStore struct {
+	db *bolt.DB
+
+	usageMu sync.Mutex
+	usage   map[string]*keyUsage
+
+	stopChan chan struct{}
+	stopped  chan struct{}
+}
+
+// ValidScope 检查作用域名是否有效
+func ValidScope(scope string) bool {
+	switch scope {
+	case RightRead, RightUpload, RightDelete, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// OpenAPIKeyStore 打开（必要时创建）指定路径的 API 密钥库
+func OpenAPIKeyStore(path string) (*APIKeyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create api key store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open api key store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{apiKeysBucket, apiKeyHashesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize api key store: %w", err)
+	}
+
+	store := &APIKeyStore{
+		db:    db,
+		usage: make(map[string]*keyUsage),
+	}
+
+	// 以持久化的累计值初始化指标
+	keys, err := store.List()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, key := range keys {
+		utils.RecordAPIKeyUsage(key.ID, key.Name, key.RequestCount, key.LastUsedAt)
+	}
+
+	return store, nil
+}
+
+// Start 启动后台任务，按间隔把使用统计写入数据库
+func (ks *APIKeyStore) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ks.stopChan = make(chan struct{})
+	ks.stopped = make(chan struct{})
+
+	go func() {
+		defer close(ks.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := ks.FlushUsage(); err != nil {
+					logIndexerError("api_key_usage_flush", err)
+				}
+			case <-ks.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止后台任务，写入剩余的使用统计并关闭数据库
+func (ks *APIKeyStore) Close() error {
+	if ks.stopChan != nil {
+		close(ks.stopChan)
+		<-ks.stopped
+		ks.stopChan = nil
+	}
+	if err := ks.FlushUsage(); err != nil {
+		logIndexerError("api_key_usage_flush", err)
+	}
+	return ks.db.Close()
+}
+
+// Create 创建新密钥，返回记录和只显示一次的密钥明文
+func (ks *APIKeyStore) Create(spec APIKeySpec, createdBy string) (*APIKey, string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = apiKeyPrefix + secret
+
+	key, err := ks.insert("", spec, createdBy, secret)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// ImportLegacyKey 在密钥库为空时导入配置文件中的静态 API 密钥（管理员权限，ID 为 "default"），返回是否导入
+func (ks *APIKeyStore) ImportLegacyKey(secret string) (bool, error) {
+	if secret == "" || ks.Count() > 0 {
+		return false, nil
+	}
+	spec := APIKeySpec{Name: "config", Scopes: []string{ScopeAdmin}}
+	if _, err := ks.insert("default", spec, "config", secret); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Get 按 ID 查找密钥，包含尚未写入数据库的使用统计
+func (ks *APIKeyStore) Get(id string) (*APIKey, error) {
+	var key *APIKey
+	err := ks.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(apiKeysBucket).Get([]byte(id))
+		if data == nil {
+			return ErrAPIKeyNotFound
+		}
+		key = &APIKey{}
+		return json.Unmarshal(data, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ks.applyUsage(key)
+	return key, nil
+}
+
+// List 返回按创建时间排序的全部密钥
+func (ks *APIKeyStore) List() ([]APIKey, error) {
+	keys := []APIKey{}
+	err := ks.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).ForEach(func(_, v []byte) error {
+			var key APIKey
+			if err := json.Unmarshal(v, &key); err != nil {
+				return nil // 跳过损坏的条目
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	for i := range keys {
+		ks.applyUsage(&keys[i])
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt != keys[j].CreatedAt {
+			return keys[i].CreatedAt < keys[j].CreatedAt
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// Count 返回密钥数量（包括已撤销的密钥）
+func (ks *APIKeyStore) Count() int {
+	count := 0
+	ks.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(apiKeysBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// Update 修改密钥的作用域、目录和过期时间；nil 字段表示不修改
+func (ks *APIKeyStore) Update(id string, scopes, directories *[]string, expiresAt *time.Time) (*APIKey, error) {
+	if scopes != nil {
+		if err := validateScopes(*scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	return ks.modify(id, func(key *APIKey) error {
+		if key.RevokedAt != 0 {
+			return ErrAPIKeyRevoked
+		}
+		if scopes != nil {
+			key.Scopes = slices.Clone(*scopes)
+		}
+		if directories != nil {
+			key.Directories = slices.Clone(*directories)
+		}
+		if expiresAt != nil {
+			key.ExpiresAt = unixOrZero(*expiresAt)
+		}
+		return nil
+	})
+}
+
+// Revoke 撤销密钥；记录保留以便审计
+func (ks *APIKeyStore) Revoke(id string) (*APIKey, error) {
+	return ks.modify(id, func(key *APIKey) error {
+		if key.RevokedAt == 0 {
+			key.RevokedAt = time.Now().Unix()
+		}
+		return nil
+	})
+}
+
+// Authenticate 校验密钥明文，成功时记录使用并返回密钥
+func (ks *APIKeyStore) Authenticate(secret string) (*APIKey, error) {
+	if secret == "" {
+		return nil, ErrAPIKeyInvalid
+	}
+	hash := hashAPIKey(secret)
+
+	var key *APIKey
+	err := ks.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(apiKeyHashesBucket).Get([]byte(hash))
+		if id == nil {
+			return ErrAPIKeyInvalid
+		}
+		data := tx.Bucket(apiKeysBucket).Get(id)
+		if data == nil {
+			return ErrAPIKeyInvalid
+		}
+		key = &APIKey{}
+		return json.Unmarshal(data, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.Status() {
+	case "revoked":
+		return nil, ErrAPIKeyRevoked
+	case "expired":
+		return nil, ErrAPIKeyExpired
+	}
+
+	ks.recordUse(key)
+	return key, nil
+}
+
+// FlushUsage 把内存中的使用统计写入数据库
+func (ks *APIKeyStore) FlushUsage() error {
+	ks.usageMu.Lock()
+	pending := ks.usage
+	ks.usage = make(map[string]*keyUsage)
+	ks.usageMu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return ks.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(apiKeysBucket)
+		for id, usage := range pending {
+			data := bucket.Get([]byte(id))
+			if data == nil {
+				continue
+			}
+			var key APIKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				continue
+			}
+			key.RequestCount += usage.count.Load()
+			if lastUsed := usage.lastUsed.Load(); lastUsed > key.LastUsedAt {
+				key.LastUsedAt = lastUsed
+			}
+			if err := putAPIKey(bucket, &key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insert 校验范围并写入新密钥
+func (ks *APIKeyStore) insert(id string, spec APIKeySpec, createdBy, secret string) (*APIKey, error) {
+	if spec.Name == "" {
+		return nil, errors.New("api key name cannot be empty")
+	}
+	if err := validateScopes(spec.Scopes); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		var err error
+		if id, err = randomHex(8); err != nil {
+			return nil, err
+		}
+	}
+
+	key := &APIKey{
+		ID:          id,
+		Name:        spec.Name,
+		Hash:        hashAPIKey(secret),
+		Scopes:      slices.Clone(spec.Scopes),
+		Directories: slices.Clone(spec.Directories),
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().Unix(),
+		ExpiresAt:   unixOrZero(spec.ExpiresAt),
+	}
+
+	err := ks.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(apiKeysBucket)
+		if keys.Get([]byte(key.ID)) != nil {
+			return fmt.Errorf("api key id already exists: %s", key.ID)
+		}
+		if err := putAPIKey(keys, key); err != nil {
+			return err
+		}
+		return tx.Bucket(apiKeyHashesBucket).Put([]byte(key.Hash), []byte(key.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.RecordAPIKeyUsage(key.ID, key.Name, 0, 0)
+	return key, nil
+}
+
+// modify 在事务中修改密钥
+func (ks *APIKeyStore) modify(id string, fn func(*APIKey) error) (*APIKey, error) {
+	var key APIKey
+	err := ks.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(apiKeysBucket)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return ErrAPIKeyNotFound
+		}
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+		if err := fn(&key); err != nil {
+			return err
+		}
+		return putAPIKey(bucket, &key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ks.applyUsage(&key)
+	return &key, nil
+}
+
+// recordUse 在内存中累计使用统计并更新指标
+func (ks *APIKeyStore) recordUse(key *APIKey) {
+	now := time.Now().Unix()
+
+	ks.usageMu.Lock()
+	usage, ok := ks.usage[key.ID]
+	if !ok {
+		usage = &keyUsage{}
+		ks.usage[key.ID] = usage
+	}
+	ks.usageMu.Unlock()
+
+	usage.count.Add(1)
+	usage.lastUsed.Store(now)
+	utils.RecordAPIKeyUsage(key.ID, key.Name, 1, now)
+}
+
+// applyUsage 把尚未写入数据库的使用统计合并到密钥记录
+func (ks *APIKeyStore) applyUsage(key *APIKey) {
+	ks.usageMu.Lock()
+	usage, ok := ks.usage[key.ID]
+	ks.usageMu.Unlock()
+	if !ok {
+		return
+	}
+
+	key.RequestCount += usage.count.Load()
+	if lastUsed := usage.lastUsed.Load(); lastUsed > key.LastUsedAt {
+		key.LastUsedAt = lastUsed
+	}
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+func putAPIKey(bucket *bolt.Bucket, key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key.ID), data)
+}
+
+// hashAPIKey 返回密钥的 SHA-256；密钥是高熵随机串，无需加盐的慢哈希
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
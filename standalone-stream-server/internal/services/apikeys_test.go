@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAPIKeyTestStore(t *testing.T) (*APIKeyStore, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "api_keys.db")
+	store, err := OpenAPIKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, path
+}
+
+func TestAPIKeyStore_CreateAndAuthenticate(t *testing.T) {
+	store, _ := newAPIKeyTestStore(t)
+	defer store.Close()
+
+	key, secret, err := store.Create(APIKeySpec{Name: "ingest", Scopes: []string{RightUpload}, Directories: []string{"movies"}}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		t.Errorf("Expected key with %q prefix, got %q", apiKeyPrefix, secret)
+	}
+	if key.Hash == secret || strings.Contains(key.Hash, secret) {
+		t.Error("Keys must be stored hashed")
+	}
+	if key.Role() != RoleUploader {
+		t.Errorf("Expected upload scope to map to uploader role, got %s", key.Role())
+	}
+
+	got, err := store.Authenticate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != key.ID || got.Directories[0] != "movies" {
+		t.Errorf("Authenticated wrong key: %+v", got)
+	}
+	if _, err := store.Authenticate(secret + "x"); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("Expected ErrAPIKeyInvalid, got %v", err)
+	}
+
+	if _, _, err := store.Create(APIKeySpec{Name: "bad", Scopes: []string{"superuser"}}, "admin"); err == nil {
+		t.Error("Expected invalid scope to be rejected")
+	}
+	if _, _, err := store.Create(APIKeySpec{Name: "empty"}, "admin"); err == nil {
+		t.Error("Expected keys without scopes to be rejected")
+	}
+}
+
+func TestAPIKeyStore_ExpiryAndRevocation(t *testing.T) {
+	store, _ := newAPIKeyTestStore(t)
+	defer store.Close()
+
+	expired, expiredSecret, err := store.Create(APIKeySpec{Name: "old", Scopes: []string{RightRead}, ExpiresAt: time.Now().Add(-time.Minute)}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(expiredSecret); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("Expected ErrAPIKeyExpired, got %v", err)
+	}
+
+	// 延长有效期后密钥恢复可用
+	future := time.Now().Add(time.Hour)
+	if _, err := store.Update(expired.ID, nil, nil, &future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(expiredSecret); err != nil {
+		t.Errorf("Expected extended key to authenticate, got %v", err)
+	}
+
+	if _, err := store.Revoke(expired.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(expiredSecret); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Expected ErrAPIKeyRevoked, got %v", err)
+	}
+	if _, err := store.Update(expired.ID, &[]string{RightUpload}, nil, nil); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Revoked keys must not be modified, got %v", err)
+	}
+	if _, err := store.Revoke("missing"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound, got %v", err)
+	}
+}
+
+func TestAPIKeyStore_UsagePersistence(t *testing.T) {
+	store, path := newAPIKeyTestStore(t)
+
+	key, secret, err := store.Create(APIKeySpec{Name: "reader", Scopes: []string{RightRead}}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := store.Authenticate(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 未写入数据库的统计也应反映在查询结果中
+	got, err := store.Get(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RequestCount != 3 || got.LastUsedAt == 0 {
+		t.Errorf("Expected 3 requests with last-used time, got %d at %d", got.RequestCount, got.LastUsedAt)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = OpenAPIKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RequestCount != 3 {
+		t.Errorf("Expected usage to persist across restarts, got %+v", keys)
+	}
+}
+
+func TestAPIKeyStore_ImportLegacyKey(t *testing.T) {
+	store, _ := newAPIKeyTestStore(t)
+	defer store.Close()
+
+	imported, err := store.ImportLegacyKey("legacy-secret")
+	if err != nil || !imported {
+		t.Fatalf("Expected legacy key to be imported, got %v %v", imported, err)
+	}
+	if imported, _ := store.ImportLegacyKey("another-secret"); imported {
+		t.Error("Legacy key must only be imported into an empty store")
+	}
+
+	key, err := store.Authenticate("legacy-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID != "default" || key.Role() != RoleAdmin {
+		t.Errorf("Expected admin key \"default\", got %s with role %s", key.ID, key.Role())
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 令牌类型
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// ErrInvalidToken 表示令牌无效、已过期或已被撤销
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Principal 是已认证的请求方；通过 API 密钥认证时 KeyID 为密钥标识，
+// Scopes 和 Directories 为密钥的作用域和目录限制
+type Principal struct {
+	Username    string   `json:"username"`
+	Role        string   `json:"role"`
+	Groups      []string `json:"groups,omitempty"`
+	KeyID       string   `json:"key_id,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+	Directories []string `json:"directories,omitempty"`
+}
+
+// PrincipalForAPIKey 返回通过 API 密钥认证的请求方
+func PrincipalForAPIKey(key *APIKey) *Principal {
+	return &Principal{
+		Username:    "apikey:" + key.ID,
+		Role:        key.Role(),
+		KeyID:       key.ID,
+		Scopes:      key.Scopes,
+		Directories: key.Directories,
+	}
+}
+
+// keyAllows 检查 API 密钥的作用域和目录限制是否允许访问；非密钥请求方不受限制
+func (p *Principal) keyAllows(directoryName, right string) bool {
+	if p == nil || p.KeyID == "" {
+		return true
+	}
+	if len(p.Directories) > 0 && !slices.Contains(p.Directories, directoryName) {
+		return false
+	}
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, right)
+}
+
+// AuthEnforced 返回是否启用了需要凭据的认证模式
+func AuthEnforced(config *models.Config) bool {
+	if !config.Security.Auth.Enabled {
+		return false
+	}
+	switch config.Security.Auth.Type {
+	case "api_key", "basic", "jwt":
+		return true
+	}
+	return false
+}
+
+// TokenPair 是登录或刷新时签发的访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`         // 访问令牌有效期（秒）
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // 刷新令牌有效期（秒）
+}
+
+// tokenClaims 是令牌中的声明；ver 与用户的令牌版本不一致时令牌失效
+type tokenClaims struct {
+	Role    string `json:"role"`
+	Type    string `json:"typ"`
+	Version int    `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+// AuthService 基于本地用户库校验凭据并签发、验证 JWT
+type AuthService struct {
+	users      *UserStore
+	secret     []byte
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewAuthService 创建新的认证服务
+func NewAuthService(config *models.Config, users *UserStore) *AuthService {
+	jwtConfig := config.Security.Auth.JWT
+
+	issuer := jwtConfig.Issuer
+	if issuer == "" {
+		issuer = "standalone-stream-server"
+	}
+	accessTTL := jwtConfig.AccessTTL
+	if accessTTL <= 0 {
+		accessTTL = 15 * time.Minute
+	}
+	refreshTTL := jwtConfig.RefreshTTL
+	if refreshTTL <= 0 {
+		refreshTTL = 7 * 24 * time.Hour
+	}
+
+	return &AuthService{
+		users:      users,
+		secret:     []byte(jwtConfig.Secret),
+		issuer:     issuer,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Users 返回用户库
+func (as *AuthService) Users() *UserStore {
+	return as.users
+}
+
+// AuthenticatePassword 校验用户名和密码（用于 Basic 认证）
+func (as *AuthService) AuthenticatePassword(username, password string) (*Principal, error) {
+	user, err := as.users.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return principalForUser(user), nil
+}
+
+// Login 校验凭据并签发令牌
+func (as *AuthService) Login(username, password string) (*TokenPair, error) {
+	user, err := as.users.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return as.issueTokens(user)
+}
+
+// Refresh 用刷新令牌换取新的令牌对；角色以用户库中的当前值为准
+func (as *AuthService) Refresh(refreshToken string) (*TokenPair, error) {
+	user, err := as.verify(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	return as.issueTokens(user)
+}
+
+// VerifyAccessToken 验证访问令牌并返回请求方
+func (as *AuthService) VerifyAccessToken(accessToken string) (*Principal, error) {
+	user, err := as.verify(accessToken, tokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	return principalForUser(user), nil
+}
+
+// Logout 撤销用户已签发的全部令牌
+func (as *AuthService) Logout(username string) error {
+	return as.users.RevokeTokens(username)
+}
+
+// issueTokens 为用户签发访问令牌和刷新令牌
+func (as *AuthService) issueTokens(user *User) (*TokenPair, error) {
+	if len(as.secret) == 0 {
+		return nil, errors.New("jwt secret is not configured")
+	}
+
+	access, err := as.sign(user, tokenTypeAccess, as.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := as.sign(user, tokenTypeRefresh, as.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(as.accessTTL.Seconds()),
+		RefreshExpiresIn: int64(as.refreshTTL.Seconds()),
+	}, nil
+}
+
+func (as *AuthService) sign(user *User, tokenType string, ttl time.Duration) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+
+	now := time.Now()
+	claims := tokenClaims{
+		Role:    user.Role,
+		Type:    tokenType,
+		Version: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    as.issuer,
+			Subject:   user.Username,
+			ID:        hex.EncodeToString(id),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(as.secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// verify 验证令牌的签名、有效期和类型，并确认用户仍存在且令牌未被撤销
+func (as *AuthService) verify(token, tokenType string) (*User, error) {
+	if len(as.secret) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return as.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(as.issuer), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := as.users.Get(claims.Subject)
+	if err != nil || user.TokenVersion != claims.Version {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+func principalForUser(user *User) *Principal {
+	return &Principal{Username: user.Username, Role: user.Role, Groups: user.Groups}
+}
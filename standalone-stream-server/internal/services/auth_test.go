@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newAuthTestService(t *testing.T) *AuthService {
+	t.Helper()
+
+	users, err := OpenUserStore(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { users.Close() })
+
+	config := &models.Config{}
+	config.Security.Auth.JWT.Secret = "0123456789abcdef0123456789abcdef"
+	return NewAuthService(config, users)
+}
+
+func TestUserStore_CreateAndAuthenticate(t *testing.T) {
+	users := newAuthTestService(t).Users()
+
+	created, err := users.EnsureAdmin("root", "bootstrap")
+	if err != nil || !created {
+		t.Fatalf("Expected initial admin to be created, got %v %v", created, err)
+	}
+	if created, _ := users.EnsureAdmin("other", "bootstrap"); created {
+		t.Error("Initial admin must only be created for an empty store")
+	}
+
+	if _, err := users.Create("alice", "wonderland", RoleUploader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Create("alice", "again", RoleViewer); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	if _, err := users.Create("bob", "secret", "superuser"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+
+	user, err := users.Authenticate("alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != RoleUploader || user.PasswordHash == "wonderland" {
+		t.Errorf("Unexpected user record: %+v", user)
+	}
+	if _, err := users.Authenticate("alice", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := users.Authenticate("nobody", "wonderland"); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials for unknown user, got %v", err)
+	}
+
+	list, err := users.List()
+	if err != nil || len(list) != 2 || list[0].Username != "alice" {
+		t.Errorf("Unexpected user list: %v %v", list, err)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		have, need string
+		expected   bool
+	}{
+		{RoleAdmin, RoleUploader, true},
+		{RoleUploader, RoleUploader, true},
+		{RoleViewer, RoleUploader, false},
+		{RoleUploader, RoleAdmin, false},
+		{"", RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		if got := RoleAllows(tt.have, tt.need); got != tt.expected {
+			t.Errorf("RoleAllows(%q, %q) = %v, expected %v", tt.have, tt.need, got, tt.expected)
+		}
+	}
+}
+
+func TestAuthService_TokensAndRevocation(t *testing.T) {
+	auth := newAuthTestService(t)
+	if _, err := auth.Users().Create("alice", "wonderland", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.Login("alice", "wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	tokens, err := auth.Login("alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.TokenType != "Bearer" || tokens.ExpiresIn != 15*60 {
+		t.Errorf("Unexpected token pair: %+v", tokens)
+	}
+
+	principal, err := auth.VerifyAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Username != "alice" || principal.Role != RoleViewer {
+		t.Errorf("Unexpected principal: %+v", principal)
+	}
+
+	// 令牌类型不能混用
+	if _, err := auth.VerifyAccessToken(tokens.RefreshToken); err != ErrInvalidToken {
+		t.Errorf("Refresh token must not be accepted as access token, got %v", err)
+	}
+	if _, err := auth.Refresh(tokens.AccessToken); err != ErrInvalidToken {
+		t.Errorf("Access token must not be accepted as refresh token, got %v", err)
+	}
+
+	// 其它密钥签发的令牌无效
+	other := newAuthTestService(t)
+	other.secret = []byte("fedcba9876543210fedcba9876543210")
+	if _, err := other.Users().Create("alice", "wonderland", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	forged, err := other.Login("alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyAccessToken(forged.AccessToken); err != ErrInvalidToken {
+		t.Errorf("Expected token signed with another secret to be rejected, got %v", err)
+	}
+
+	// 角色变更使旧令牌失效，刷新后得到新角色
+	if _, err := auth.Users().Update("alice", "", RoleUploader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyAccessToken(tokens.AccessToken); err != ErrInvalidToken {
+		t.Errorf("Expected token to be revoked after role change, got %v", err)
+	}
+	if _, err := auth.Refresh(tokens.RefreshToken); err != ErrInvalidToken {
+		t.Errorf("Expected refresh token to be revoked after role change, got %v", err)
+	}
+
+	tokens, err = auth.Login("alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := auth.Refresh(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err = auth.VerifyAccessToken(refreshed.AccessToken)
+	if err != nil || principal.Role != RoleUploader {
+		t.Errorf("Expected refreshed token with uploader role, got %+v %v", principal, err)
+	}
+
+	if err := auth.Logout("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyAccessToken(refreshed.AccessToken); err != ErrInvalidToken {
+		t.Errorf("Expected token to be revoked after logout, got %v", err)
+	}
+}
+
+func TestAuthService_RecreatedUserDoesNotReviveTokens(t *testing.T) {
+	auth := newAuthTestService(t)
+	if _, err := auth.Users().Create("alice", "wonderland", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := auth.Login("alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除后以相同用户名重建，旧令牌不能重新生效
+	if err := auth.Users().Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Users().Create("alice", "another", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyAccessToken(tokens.AccessToken); err != ErrInvalidToken {
+		t.Errorf("Expected token of the deleted user to stay revoked, got %v", err)
+	}
+	if _, err := auth.Refresh(tokens.RefreshToken); err != ErrInvalidToken {
+		t.Errorf("Expected refresh token of the deleted user to stay revoked, got %v", err)
+	}
+
+	tokens, err = auth.Login("alice", "another")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal, err := auth.VerifyAccessToken(tokens.AccessToken); err != nil || principal.Role != RoleViewer {
+		t.Errorf("Expected tokens of the recreated user to work, got %+v %v", principal, err)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	catalogVideosBucket      = []byte("videos")
+	catalogDirectoriesBucket = []byte("directories")
+)
+
+// Catalog 是持久化在磁盘上的视频索引，以 "directory:relative/path" 视频 ID 为键
+type Catalog struct {
+	db *bolt.DB
+}
+
+// CatalogDirectoryState 记录单个目录的索引状态
+type CatalogDirectoryState struct {
+	Name        string `json:"name"`
+	VideoCount  int    `json:"video_count"`
+	TotalSize   int64  `json:"total_size"`
+	LastIndexed int64  `json:"last_indexed"`
+}
+
+// OpenCatalog 打开（必要时创建）指定路径的索引数据库
+func OpenCatalog(path string) (*Catalog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create catalog directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{catalogVideosBucket, catalogDirectoriesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize catalog buckets: %w", err)
+	}
+
+	return &Catalog{db: db}, nil
+}
+
+// Close 关闭索引数据库
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// Get 按视频 ID 查找索引条目
+func (c *Catalog) Get(videoID string) (*VideoInfo, error) {
+	var video *VideoInfo
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(catalogVideosBucket).Get([]byte(videoID))
+		if data == nil {
+			return nil
+		}
+		video = &VideoInfo{}
+		return json.Unmarshal(data, video)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog entry %s: %w", videoID, err)
+	}
+	return video, nil
+}
+
+// Put 写入或更新单个视频条目，并同步目录统计
+func (c *Catalog) Put(video VideoInfo) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return putCatalogVideo(tx, video)
+	})
+}
+
+// Delete 从索引中移除单个视频条目
+func (c *Catalog) Delete(videoID string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return deleteCatalogVideo(tx, videoID)
+	})
+}
+
+// ListDirectory 返回目录下所有已索引的视频
+func (c *Catalog) ListDirectory(directory string) ([]VideoInfo, error) {
+	videos := []VideoInfo{}
+	prefix := catalogDirectoryPrefix(directory)
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(catalogVideosBucket).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			var video VideoInfo
+			if err := json.Unmarshal(v, &video); err != nil {
+				continue // 跳过损坏的条目
+			}
+			videos = append(videos, video)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalog directory %s: %w", directory, err)
+	}
+
+	return videos, nil
+}
+
+// ReplaceDirectory 用给定的视频列表原子地替换目录下的全部条目
+func (c *Catalog) ReplaceDirectory(directory string, videos []VideoInfo) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(catalogVideosBucket)
+		prefix := catalogDirectoryPrefix(directory)
+
+		var stale [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		state := CatalogDirectoryState{Name: directory}
+		for _, video := range videos {
+			data, err := json.Marshal(video)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(video.ID), data); err != nil {
+				return err
+			}
+			state.VideoCount++
+			state.TotalSize += video.Size
+		}
+
+		state.LastIndexed = time.Now().Unix()
+		return putCatalogDirectoryState(tx, state)
+	})
+}
+
+// MarkIndexed 更新目录的最后索引时间
+func (c *Catalog) MarkIndexed(directory string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		state, _, err := getCatalogDirectoryState(tx, directory)
+		if err != nil {
+			return err
+		}
+		state.LastIndexed = time.Now().Unix()
+		return putCatalogDirectoryState(tx, state)
+	})
+}
+
+// DirectoryState 返回目录的索引状态；目录从未索引过时 ok 为 false
+func (c *Catalog) DirectoryState(directory string) (state CatalogDirectoryState, ok bool, err error) {
+	err = c.db.View(func(tx *bolt.Tx) error {
+		state, ok, err = getCatalogDirectoryState(tx, directory)
+		return err
+	})
+	if ok && state.LastIndexed == 0 {
+		ok = false
+	}
+	return state, ok, err
+}
+
+// 辅助函数
+
+func catalogDirectoryPrefix(directory string) []byte {
+	return []byte(directory + ":")
+}
+
+func putCatalogVideo(tx *bolt.Tx, video VideoInfo) error {
+	bucket := tx.Bucket(catalogVideosBucket)
+
+	state, _, err := getCatalogDirectoryState(tx, video.Directory)
+	if err != nil {
+		return err
+	}
+
+	if existing := bucket.Get([]byte(video.ID)); existing != nil {
+		var old VideoInfo
+		if err := json.Unmarshal(existing, &old); err == nil {
+			state.VideoCount--
+			state.TotalSize -= old.Size
+		}
+	}
+
+	data, err := json.Marshal(video)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put([]byte(video.ID), data); err != nil {
+		return err
+	}
+
+	state.VideoCount++
+	state.TotalSize += video.Size
+	return putCatalogDirectoryState(tx, state)
+}
+
+func deleteCatalogVideo(tx *bolt.Tx, videoID string) error {
+	bucket := tx.Bucket(catalogVideosBucket)
+
+	existing := bucket.Get([]byte(videoID))
+	if existing == nil {
+		return nil
+	}
+
+	var old VideoInfo
+	if err := json.Unmarshal(existing, &old); err == nil {
+		state, _, err := getCatalogDirectoryState(tx, old.Directory)
+		if err != nil {
+			return err
+		}
+		state.VideoCount--
+		state.TotalSize -= old.Size
+		if err := putCatalogDirectoryState(tx, state); err != nil {
+			return err
+		}
+	}
+
+	return bucket.Delete([]byte(videoID))
+}
+
+func getCatalogDirectoryState(tx *bolt.Tx, directory string) (CatalogDirectoryState, bool, error) {
+	state := CatalogDirectoryState{Name: directory}
+	data := tx.Bucket(catalogDirectoriesBucket).Get([]byte(directory))
+	if data == nil {
+		return state, false, nil
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false, err
+	}
+	return state, true, nil
+}
+
+func putCatalogDirectoryState(tx *bolt.Tx, state CatalogDirectoryState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(catalogDirectoriesBucket).Put([]byte(state.Name), data)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newCatalogTestService(t *testing.T, testDir string) (*VideoService, *Catalog) {
+	t.Helper()
+
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{
+				{
+					Name:        "test",
+					Path:        testDir,
+					Description: "Test directory",
+					Enabled:     true,
+				},
+			},
+			SupportedFormats: []string{".mp4", ".avi", ".mov"},
+			MaxUploadSize:    1024 * 1024,
+		},
+	}
+
+	catalog, err := OpenCatalog(filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { catalog.Close() })
+
+	service := NewVideoService(config)
+	service.SetCatalog(catalog)
+	return service, catalog
+}
+
+func TestCatalogIndexer_RebuildAndRefresh(t *testing.T) {
+	testDir := t.TempDir()
+	for _, file := range []string{"a.mp4", "b.avi", "nested/c.mov"} {
+		path := filepath.Join(testDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("fake content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service, catalog := newCatalogTestService(t, testDir)
+	indexer := NewCatalogIndexer(service, catalog, 0)
+
+	result, err := indexer.Rebuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 3 {
+		t.Errorf("Expected 3 videos indexed, got %d", result.Added)
+	}
+
+	// 新文件在刷新前不应出现在列表中，证明列表读取的是索引
+	if err := os.WriteFile(filepath.Join(testDir, "d.mp4"), []byte("new content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	videos, err := service.ListAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 {
+		t.Errorf("Expected 3 videos from catalog before refresh, got %d", len(videos))
+	}
+
+	if err := os.Remove(filepath.Join(testDir, "b.avi")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = indexer.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 1 || result.Removed != 1 || result.Unchanged != 2 {
+		t.Errorf("Unexpected refresh result: %+v", result)
+	}
+
+	videos, err = service.ListAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 {
+		t.Errorf("Expected 3 videos after refresh, got %d", len(videos))
+	}
+
+	stats := service.GetStats()
+	if total, _ := stats["total_videos"].(int); total != 3 {
+		t.Errorf("Expected total_videos 3 from catalog state, got %v", stats["total_videos"])
+	}
+
+	// 多层级 ID 应能从索引中找到
+	video, err := service.FindVideoByID("test:nested/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.Name != "c.mov" {
+		t.Errorf("Expected c.mov, got %s", video.Name)
+	}
+
+	results, err := service.SearchVideos("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 search result, got %d", len(results))
+	}
+}
+
+func TestCatalog_PutDeleteKeepsDirectoryState(t *testing.T) {
+	catalog, err := OpenCatalog(filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer catalog.Close()
+
+	if err := catalog.ReplaceDirectory("movies", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Put(VideoInfo{ID: "movies:a", Directory: "movies", Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Put(VideoInfo{ID: "movies:a", Directory: "movies", Size: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Put(VideoInfo{ID: "movies:b", Directory: "movies", Size: 5}); err != nil {
+		t.Fatal(err)
+	}
+	// 前缀相近的其他目录不应被列出
+	if err := catalog.Put(VideoInfo{ID: "movies2:x", Directory: "movies2", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok, err := catalog.DirectoryState("movies")
+	if err != nil || !ok {
+		t.Fatalf("Expected directory state, got ok=%v err=%v", ok, err)
+	}
+	if state.VideoCount != 2 || state.TotalSize != 35 {
+		t.Errorf("Unexpected state after put: %+v", state)
+	}
+
+	if err := catalog.Delete("movies:a"); err != nil {
+		t.Fatal(err)
+	}
+	state, _, _ = catalog.DirectoryState("movies")
+	if state.VideoCount != 1 || state.TotalSize != 5 {
+		t.Errorf("Unexpected state after delete: %+v", state)
+	}
+
+	videos, err := catalog.ListDirectory("movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].ID != "movies:b" {
+		t.Errorf("Unexpected listing: %+v", videos)
+	}
+}
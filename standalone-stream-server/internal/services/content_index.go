@@ -0,0 +1,294 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 目录的去重策略
+const (
+	DedupOff    = "off"    // 不检查重复内容
+	DedupReject = "reject" // 拒绝与已有视频内容相同的上传
+	DedupLink   = "link"   // 用硬链接指向已有视频，不再保存第二份数据
+)
+
+// ErrDuplicateContent 表示上传内容与已有视频相同
+var ErrDuplicateContent = errors.New("duplicate video content")
+
+// DuplicateContentError 描述被拒绝的重复上传及其对应的已有视频
+type DuplicateContentError struct {
+	Hash       string
+	ExistingID string
+}
+
+func (e *DuplicateContentError) Error() string {
+	return fmt.Sprintf("%s: identical to %s (sha256 %s)", ErrDuplicateContent, e.ExistingID, e.Hash)
+}
+
+func (e *DuplicateContentError) Unwrap() error {
+	return ErrDuplicateContent
+}
+
+// ContentEntry 是内容哈希索引中的一个文件
+type ContentEntry struct {
+	Hash     string
+	VideoID  string
+	Path     string
+	Size     int64
+	Modified int64
+}
+
+// ContentIndex 是 SHA-256 内容哈希到视频文件的内存索引，由上传和目录索引器维护
+type ContentIndex struct {
+	mu     sync.RWMutex
+	byHash map[string]map[string]ContentEntry // hash -> path -> entry
+	byPath map[string]ContentEntry
+}
+
+// NewContentIndex 创建新的内容哈希索引
+func NewContentIndex() *ContentIndex {
+	return &ContentIndex{
+		byHash: make(map[string]map[string]ContentEntry),
+		byPath: make(map[string]ContentEntry),
+	}
+}
+
+// Add 记录文件的内容哈希，替换同一路径的旧记录
+func (ci *ContentIndex) Add(entry ContentEntry) {
+	if entry.Hash == "" || entry.Path == "" {
+		return
+	}
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	ci.removeLocked(entry.Path)
+	if ci.byHash[entry.Hash] == nil {
+		ci.byHash[entry.Hash] = make(map[string]ContentEntry)
+	}
+	ci.byHash[entry.Hash][entry.Path] = entry
+	ci.byPath[entry.Path] = entry
+}
+
+// AddVideo 记录已知哈希的视频
+func (ci *ContentIndex) AddVideo(video VideoInfo) {
+	ci.Add(ContentEntry{
+		Hash:     video.Hash,
+		VideoID:  video.ID,
+		Path:     video.Path,
+		Size:     video.Size,
+		Modified: video.Modified,
+	})
+}
+
+// Remove 移除路径对应的记录
+func (ci *ContentIndex) Remove(path string) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	ci.removeLocked(path)
+}
+
+func (ci *ContentIndex) removeLocked(path string) {
+	old, ok := ci.byPath[path]
+	if !ok {
+		return
+	}
+
+	delete(ci.byPath, path)
+	delete(ci.byHash[old.Hash], path)
+	if len(ci.byHash[old.Hash]) == 0 {
+		delete(ci.byHash, old.Hash)
+	}
+}
+
+// Lookup 返回指定目录中内容哈希相同且磁盘上未变化的已有文件，失效的记录会被移除。
+// 查找不跨越目录，避免上传者借此探测或链接到自己无权访问的目录中的文件
+func (ci *ContentIndex) Lookup(hash, directory string) (ContentEntry, bool) {
+	ci.mu.RLock()
+	candidates := make([]ContentEntry, 0, len(ci.byHash[hash]))
+	for _, entry := range ci.byHash[hash] {
+		if strings.HasPrefix(entry.VideoID, directory+":") {
+			candidates = append(candidates, entry)
+		}
+	}
+	ci.mu.RUnlock()
+
+	for _, entry := range candidates {
+		if entry.isFresh() {
+			return entry, true
+		}
+		ci.Remove(entry.Path)
+	}
+
+	return ContentEntry{}, false
+}
+
+// HashForFile 返回文件的已知哈希，仅当文件大小和修改时间与记录一致时有效
+func (ci *ContentIndex) HashForFile(path string, size, modified int64) string {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+
+	entry, ok := ci.byPath[path]
+	if !ok || entry.Size != size || entry.Modified != modified {
+		return ""
+	}
+	return entry.Hash
+}
+
+// Count 返回索引中的文件数
+func (ci *ContentIndex) Count() int {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+	return len(ci.byPath)
+}
+
+// isFresh 检查文件仍然存在且未被修改
+func (entry ContentEntry) isFresh() bool {
+	info, err := os.Stat(entry.Path)
+	return err == nil && info.Size() == entry.Size && info.ModTime().Unix() == entry.Modified
+}
+
+// StoredUpload 描述提交到视频目录的上传
+type StoredUpload struct {
+	VideoID  string
+	Path     string
+	Size     int64
+	Hash     string
+	LinkedTo string // link 策略下共享数据的已有视频 ID
+}
+
+// WriteUpload 把上传数据流式写入目标目录中的临时文件，同时计算 SHA-256，然后按目录的去重策略提交
+func (vs *VideoService) WriteUpload(directoryName, targetPath string, src io.Reader) (*StoredUpload, error) {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	// 隐藏的临时文件不会被扫描器和目录监听器收录
+	tmp, err := os.CreateTemp(filepath.Dir(targetPath), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create target file: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return vs.CommitUpload(directoryName, tmp.Name(), targetPath, hex.EncodeToString(hasher.Sum(nil)))
+}
+
+// CommitUpload 把已完整写入的临时文件提交为 targetPath。
+// 内容与同一目录中的已有视频相同时，reject 策略删除临时文件并返回 *DuplicateContentError，
+// link 策略把 targetPath 硬链接到已有文件；硬链接失败（如跨文件系统）时回退为保存副本
+func (vs *VideoService) CommitUpload(directoryName, tmpPath, targetPath, hash string) (*StoredUpload, error) {
+	dir := vs.findDirectory(directoryName)
+	if dir == nil || !dir.Enabled {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("directory not found or disabled: %s", directoryName)
+	}
+
+	rel, err := filepath.Rel(dir.Path, targetPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("path %s is not inside directory %s", targetPath, directoryName)
+	}
+
+	vs.commitMu.Lock()
+	defer vs.commitMu.Unlock()
+
+	if _, err := os.Stat(targetPath); err == nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("%w: %s", ErrUploadExists, filepath.Base(targetPath))
+	}
+
+	stored := &StoredUpload{
+		VideoID: vs.generateVideoID(directoryName, strings.TrimSuffix(rel, filepath.Ext(rel))),
+		Path:    targetPath,
+		Hash:    hash,
+	}
+
+	existing, duplicate := vs.contentIndex.Lookup(hash, directoryName)
+	if duplicate && dir.Deduplication == DedupReject {
+		os.Remove(tmpPath)
+		return nil, &DuplicateContentError{Hash: hash, ExistingID: existing.VideoID}
+	}
+	if duplicate && dir.Deduplication == DedupLink {
+		if err := os.Link(existing.Path, targetPath); err == nil {
+			os.Remove(tmpPath)
+			stored.LinkedTo = existing.VideoID
+		}
+	}
+	if stored.LinkedTo == "" {
+		if err := moveFile(tmpPath, targetPath); err != nil {
+			os.Remove(tmpPath)
+			return nil, fmt.Errorf("failed to move upload into place: %w", err)
+		}
+	}
+
+	info, err := os.Stat(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat stored upload: %w", err)
+	}
+	stored.Size = info.Size()
+
+	vs.contentIndex.Add(ContentEntry{
+		Hash:     hash,
+		VideoID:  stored.VideoID,
+		Path:     targetPath,
+		Size:     info.Size(),
+		Modified: info.ModTime().Unix(),
+	})
+
+	return stored, nil
+}
+
+// hashVideo 为视频设置内容哈希并登记到内容索引；文件未变化时复用已知哈希
+func (vs *VideoService) hashVideo(video *VideoInfo) {
+	if video.Hash == "" {
+		video.Hash = vs.contentIndex.HashForFile(video.Path, video.Size, video.Modified)
+	}
+	if video.Hash == "" {
+		hash, err := hashFile(video.Path)
+		if err != nil {
+			logIndexerError("content_hash", err, zap.String("path", video.Path))
+			return
+		}
+		video.Hash = hash
+	}
+
+	vs.contentIndex.AddVideo(*video)
+}
+
+// hashFile 计算文件的 SHA-256
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
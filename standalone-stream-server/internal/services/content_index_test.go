@@ -0,0 +1,207 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newDedupTestService(t *testing.T, policy string) (*VideoService, string) {
+	t.Helper()
+
+	videoDir := t.TempDir()
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories: []models.VideoDirectory{
+				{Name: "test", Path: videoDir, Enabled: true, Deduplication: policy},
+			},
+			SupportedFormats: []string{".mp4"},
+			MaxUploadSize:    1024 * 1024,
+		},
+	}
+
+	return NewVideoService(config), videoDir
+}
+
+func TestVideoService_WriteUploadDeduplication(t *testing.T) {
+	content := "same video bytes"
+	sum := sha256.Sum256([]byte(content))
+	expectedHash := hex.EncodeToString(sum[:])
+
+	t.Run("reject", func(t *testing.T) {
+		service, videoDir := newDedupTestService(t, DedupReject)
+
+		first, err := service.WriteUpload("test", filepath.Join(videoDir, "first.mp4"), strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Hash != expectedHash || first.VideoID != "test:first" {
+			t.Errorf("Unexpected stored upload: %+v", first)
+		}
+
+		_, err = service.WriteUpload("test", filepath.Join(videoDir, "second.mp4"), strings.NewReader(content))
+		var duplicate *DuplicateContentError
+		if !errors.As(err, &duplicate) || duplicate.ExistingID != "test:first" {
+			t.Fatalf("Expected duplicate error pointing at test:first, got %v", err)
+		}
+		if !errors.Is(err, ErrDuplicateContent) {
+			t.Error("Duplicate error should wrap ErrDuplicateContent")
+		}
+
+		entries, _ := os.ReadDir(videoDir)
+		if len(entries) != 1 {
+			t.Errorf("Rejected upload should leave no files behind, found %d entries", len(entries))
+		}
+
+		// 不同内容可以正常上传
+		if _, err := service.WriteUpload("test", filepath.Join(videoDir, "other.mp4"), strings.NewReader("other")); err != nil {
+			t.Errorf("Unexpected error for different content: %v", err)
+		}
+	})
+
+	t.Run("link", func(t *testing.T) {
+		service, videoDir := newDedupTestService(t, DedupLink)
+
+		if _, err := service.WriteUpload("test", filepath.Join(videoDir, "first.mp4"), strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		second, err := service.WriteUpload("test", filepath.Join(videoDir, "second.mp4"), strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if second.LinkedTo != "test:first" {
+			t.Errorf("Expected upload linked to test:first, got %q", second.LinkedTo)
+		}
+
+		firstInfo, _ := os.Stat(filepath.Join(videoDir, "first.mp4"))
+		secondInfo, _ := os.Stat(filepath.Join(videoDir, "second.mp4"))
+		if !os.SameFile(firstInfo, secondInfo) {
+			t.Error("Expected second upload to be a hard link to the first")
+		}
+
+		video, err := service.FindVideoByID("test:second")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if video.Hash != expectedHash {
+			t.Errorf("Expected hash %s on video info, got %q", expectedHash, video.Hash)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		service, videoDir := newDedupTestService(t, "")
+
+		for _, name := range []string{"first.mp4", "second.mp4"} {
+			stored, err := service.WriteUpload("test", filepath.Join(videoDir, name), strings.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.LinkedTo != "" {
+				t.Errorf("Deduplication is off, %s should not be linked", name)
+			}
+		}
+
+		if _, err := service.WriteUpload("test", filepath.Join(videoDir, "first.mp4"), strings.NewReader(content)); !errors.Is(err, ErrUploadExists) {
+			t.Errorf("Expected ErrUploadExists for existing target, got %v", err)
+		}
+	})
+}
+
+func TestVideoService_DeduplicationStaysInDirectory(t *testing.T) {
+	content := "same video bytes"
+
+	for _, policy := range []string{DedupReject, DedupLink} {
+		t.Run(policy, func(t *testing.T) {
+			privateDir, sharedDir := t.TempDir(), t.TempDir()
+			config := &models.Config{
+				Video: models.VideoConfig{
+					Directories: []models.VideoDirectory{
+						{Name: "private", Path: privateDir, Enabled: true, Deduplication: policy, Access: models.DirectoryAccess{Mode: AccessRestricted}},
+						{Name: "shared", Path: sharedDir, Enabled: true, Deduplication: policy},
+					},
+					SupportedFormats: []string{".mp4"},
+					MaxUploadSize:    1024 * 1024,
+				},
+			}
+			service := NewVideoService(config)
+
+			if _, err := service.WriteUpload("private", filepath.Join(privateDir, "original.mp4"), strings.NewReader(content)); err != nil {
+				t.Fatal(err)
+			}
+
+			// 其它目录中的相同内容既不拒绝也不链接
+			stored, err := service.WriteUpload("shared", filepath.Join(sharedDir, "copy.mp4"), strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("Upload must not be matched against another directory, got %v", err)
+			}
+			if stored.LinkedTo != "" {
+				t.Errorf("Upload must not be linked across directories, linked to %q", stored.LinkedTo)
+			}
+			originalInfo, _ := os.Stat(filepath.Join(privateDir, "original.mp4"))
+			copyInfo, _ := os.Stat(filepath.Join(sharedDir, "copy.mp4"))
+			if os.SameFile(originalInfo, copyInfo) {
+				t.Error("Upload must not share data with a file in another directory")
+			}
+
+			// 同一目录中仍按策略处理
+			again, err := service.WriteUpload("shared", filepath.Join(sharedDir, "again.mp4"), strings.NewReader(content))
+			switch policy {
+			case DedupReject:
+				var duplicate *DuplicateContentError
+				if !errors.As(err, &duplicate) || duplicate.ExistingID != "shared:copy" {
+					t.Errorf("Expected duplicate of shared:copy, got %v", err)
+				}
+			case DedupLink:
+				if err != nil || again.LinkedTo != "shared:copy" {
+					t.Errorf("Expected link to shared:copy, got %+v %v", again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCatalogIndexer_RegistersContentHashes(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "existing.mp4"), []byte("indexed content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	service, catalog := newCatalogTestService(t, testDir)
+	service.config.Video.Directories[0].Deduplication = DedupReject
+
+	indexer := NewCatalogIndexer(service, catalog, 0)
+	if _, err := indexer.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	video, err := catalog.Get("test:existing")
+	if err != nil || video == nil {
+		t.Fatalf("Expected indexed video, got %v", err)
+	}
+	if video.Hash == "" {
+		t.Error("Expected content hash in catalog entry")
+	}
+
+	_, err = service.WriteUpload("test", filepath.Join(testDir, "copy.mp4"), strings.NewReader("indexed content"))
+	var duplicate *DuplicateContentError
+	if !errors.As(err, &duplicate) || duplicate.ExistingID != "test:existing" {
+		t.Fatalf("Expected upload of indexed content to be rejected, got %v", err)
+	}
+
+	// 文件删除后不再视为重复
+	if err := os.Remove(video.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := indexer.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.WriteUpload("test", filepath.Join(testDir, "copy.mp4"), strings.NewReader("indexed content")); err != nil {
+		t.Errorf("Expected upload to succeed after original was removed: %v", err)
+	}
+}
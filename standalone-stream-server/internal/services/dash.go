@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DASH 清单和片段的文件名
+const (
+	DASHManifest     = "manifest.mpd"
+	DASHInitSegment  = "init.mp4"
+	dashMediaPattern = "segment_$Number%05d$.m4s"
+)
+
+// dashTimescale 是 SegmentTemplate 使用的时间刻度（毫秒）
+const dashTimescale = 1000
+
+// DASHService 为目录中的视频生成 MPEG-DASH 清单，fMP4 片段由 Segmenter 按需生成，与 HLS 共用缓存
+type DASHService struct {
+	segmenter *Segmenter
+}
+
+// NewDASHService 创建新的 DASH 服务
+func NewDASHService(segmenter *Segmenter) *DASHService {
+	return &DASHService{
+		segmenter: segmenter,
+	}
+}
+
+// mpd 是 MPD 清单的 XML 结构（ISO/IEC 23009-1 静态点播配置）
+type mpd struct {
+	XMLName                   xml.Name  `xml:"MPD"`
+	XMLNS                     string    `xml:"xmlns,attr"`
+	Profiles                  string    `xml:"profiles,attr"`
+	Type                      string    `xml:"type,attr"`
+	MediaPresentationDuration string    `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string    `xml:"minBufferTime,attr"`
+	Period                    mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID            string             `xml:"id,attr"`
+	Start         string             `xml:"start,attr"`
+	AdaptationSet []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	ContentType      string              `xml:"contentType,attr"`
+	MimeType         string              `xml:"mimeType,attr"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr"`
+	StartWithSAP     int                 `xml:"startWithSAP,attr"`
+	SegmentTemplate  mpdSegmentTemplate  `xml:"SegmentTemplate"`
+	Representation   []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdSegmentTemplate struct {
+	Timescale      int    `xml:"timescale,attr"`
+	Duration       int64  `xml:"duration,attr"`
+	StartNumber    int    `xml:"startNumber,attr"`
+	Initialization string `xml:"initialization,attr"`
+	Media          string `xml:"media,attr"`
+}
+
+type mpdRepresentation struct {
+	ID        string `xml:"id,attr"`
+	Codecs    string `xml:"codecs,attr"`
+	Bandwidth int64  `xml:"bandwidth,attr"`
+	Width     int    `xml:"width,attr,omitempty"`
+	Height    int    `xml:"height,attr,omitempty"`
+}
+
+// Manifest 生成静态点播 MPD 清单；query 非空时附加到片段模板 URL 上（签名 URL）
+func (ds *DASHService) Manifest(video *VideoInfo, query string) (string, error) {
+	if _, err := ds.segmenter.Segments(video); err != nil {
+		return "", err
+	}
+
+	representation := mpdRepresentation{
+		ID: "0",
+		// 片段统一编码为 H.264 High + AAC-LC
+		Codecs:    "avc1.640028,mp4a.40.2",
+		Bandwidth: estimateBandwidth(video),
+	}
+	representation.Width, representation.Height = parseResolution(video.Metadata.Resolution)
+
+	manifest := mpd{
+		XMLNS:                     "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:                  "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:                      "static",
+		MediaPresentationDuration: isoDuration(video.Metadata.Duration),
+		MinBufferTime:             isoDuration(ds.segmenter.SegmentDuration()),
+		Period: mpdPeriod{
+			ID:    "0",
+			Start: "PT0S",
+			AdaptationSet: []mpdAdaptationSet{{
+				ContentType:      "video",
+				MimeType:         "video/mp4",
+				SegmentAlignment: true,
+				StartWithSAP:     1,
+				SegmentTemplate: mpdSegmentTemplate{
+					Timescale:      dashTimescale,
+					Duration:       int64(ds.segmenter.SegmentDuration() * dashTimescale),
+					StartNumber:    0,
+					Initialization: withQuery(DASHInitSegment, query),
+					Media:          withQuery(dashMediaPattern, query),
+				},
+				Representation: []mpdRepresentation{representation},
+			}},
+		},
+	}
+
+	output, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode MPD: %w", err)
+	}
+
+	return xml.Header + string(output) + "\n", nil
+}
+
+// ParseSegmentName 解析媒体片段文件名，返回片段序号
+func (ds *DASHService) ParseSegmentName(name string) (int, bool) {
+	var index int
+	if _, err := fmt.Sscanf(name, "segment_%05d.m4s", &index); err != nil {
+		return 0, false
+	}
+	if name != fmt.Sprintf("segment_%05d.m4s", index) {
+		return 0, false
+	}
+	return index, true
+}
+
+// Segment 返回 fMP4 媒体段路径，必要时生成
+func (ds *DASHService) Segment(video *VideoInfo, index int) (string, error) {
+	return ds.segmenter.FMP4Segment(video, index)
+}
+
+// InitSegment 返回 fMP4 初始化段路径，必要时生成
+func (ds *DASHService) InitSegment(video *VideoInfo) (string, error) {
+	return ds.segmenter.FMP4Init(video)
+}
+
+// isoDuration 把秒数格式化为 ISO 8601 时长
+func isoDuration(seconds float64) string {
+	return "PT" + strconv.FormatFloat(seconds, 'f', 3, 64) + "S"
+}
+
+// parseResolution 解析 "1280x720" 格式的分辨率
+func parseResolution(resolution string) (int, int) {
+	parts := strings.SplitN(resolution, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0
+	}
+	return width, height
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestDASHService_Manifest(t *testing.T) {
+	dashService := NewDASHService(newTestSegmenter(t))
+	video := &VideoInfo{
+		ID:   "movies:trailer",
+		Size: 1000,
+		Metadata: VideoMetadata{
+			Duration:   10,
+			Bitrate:    3_000_000,
+			Resolution: "1280x720",
+		},
+	}
+
+	manifest, err := dashService.Manifest(video, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(manifest, "<?xml") {
+		t.Errorf("Manifest should start with an XML declaration:\n%s", manifest)
+	}
+
+	var parsed mpd
+	if err := xml.Unmarshal([]byte(manifest), &parsed); err != nil {
+		t.Fatalf("Manifest is not valid XML: %v", err)
+	}
+	if parsed.Type != "static" || parsed.MediaPresentationDuration != "PT10.000S" {
+		t.Errorf("Unexpected MPD attributes: type=%s duration=%s", parsed.Type, parsed.MediaPresentationDuration)
+	}
+	if len(parsed.Period.AdaptationSet) != 1 {
+		t.Fatalf("Expected one adaptation set, got %d", len(parsed.Period.AdaptationSet))
+	}
+
+	adaptationSet := parsed.Period.AdaptationSet[0]
+	template := adaptationSet.SegmentTemplate
+	if template.Duration != 4000 || template.Timescale != 1000 || template.StartNumber != 0 {
+		t.Errorf("Unexpected segment template: %+v", template)
+	}
+	if template.Initialization != DASHInitSegment || template.Media != "segment_$Number%05d$.m4s" {
+		t.Errorf("Unexpected segment template names: %+v", template)
+	}
+
+	representation := adaptationSet.Representation[0]
+	if representation.Bandwidth != 3_000_000 || representation.Width != 1280 || representation.Height != 720 {
+		t.Errorf("Unexpected representation: %+v", representation)
+	}
+
+	if _, err := dashService.Manifest(&VideoInfo{ID: "movies:unknown"}, ""); err == nil {
+		t.Error("Expected error for video without duration")
+	}
+}
+
+func TestDASHService_SegmentNames(t *testing.T) {
+	dashService := NewDASHService(newTestSegmenter(t))
+
+	if index, ok := dashService.ParseSegmentName("segment_00003.m4s"); !ok || index != 3 {
+		t.Errorf("Expected segment 3, got %d (ok=%v)", index, ok)
+	}
+	for _, name := range []string{"segment_00003.ts", "segment_3.m4s", "manifest.mpd"} {
+		if _, ok := dashService.ParseSegmentName(name); ok {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
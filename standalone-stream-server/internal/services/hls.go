@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// HLS 播放列表和片段的文件名
+const (
+	HLSMasterPlaylist = "index.m3u8"
+	HLSMediaPlaylist  = "media.m3u8"
+	HLSInitSegment    = "init.mp4"
+)
+
+// HLSService 为目录中的视频生成 HLS 播放列表，片段由 Segmenter 按需生成
+type HLSService struct {
+	segmenter     *Segmenter
+	segmentFormat string // mpegts, fmp4
+}
+
+// NewHLSService 创建新的 HLS 服务
+func NewHLSService(segmenter *Segmenter, segmentFormat string) *HLSService {
+	if segmentFormat != "fmp4" {
+		segmentFormat = "mpegts"
+	}
+
+	return &HLSService{
+		segmenter:     segmenter,
+		segmentFormat: segmentFormat,
+	}
+}
+
+// SegmentFormat 返回片段格式
+func (hs *HLSService) SegmentFormat() string {
+	return hs.segmentFormat
+}
+
+// SegmentName 返回指定序号片段的文件名
+func (hs *HLSService) SegmentName(index int) string {
+	if hs.segmentFormat == "fmp4" {
+		return fmt.Sprintf("segment_%05d.m4s", index)
+	}
+	return fmt.Sprintf("segment_%05d.ts", index)
+}
+
+// ParseSegmentName 解析片段文件名，返回片段序号
+func (hs *HLSService) ParseSegmentName(name string) (int, bool) {
+	var index int
+	if _, err := fmt.Sscanf(name, "segment_%05d", &index); err != nil {
+		return 0, false
+	}
+	if name != hs.SegmentName(index) {
+		return 0, false
+	}
+	return index, true
+}
+
+// MasterPlaylist 生成主播放列表；query 非空时附加到引用的 URL 上（签名 URL）
+func (hs *HLSService) MasterPlaylist(video *VideoInfo, query string) (string, error) {
+	if video.Metadata.Duration <= 0 {
+		return "", fmt.Errorf("video duration unknown, cannot package as HLS: %s", video.ID)
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	b.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", estimateBandwidth(video)))
+	if video.Metadata.Resolution != "" {
+		b.WriteString(",RESOLUTION=" + video.Metadata.Resolution)
+	}
+	b.WriteString("\n")
+	b.WriteString(withQuery(HLSMediaPlaylist, query) + "\n")
+
+	return b.String(), nil
+}
+
+// MediaPlaylist 生成包含所有片段的 VOD 媒体播放列表；query 非空时附加到片段 URL 上
+func (hs *HLSService) MediaPlaylist(video *VideoInfo, query string) (string, error) {
+	spans, err := hs.segmenter.Segments(video)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	b.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(hs.segmenter.SegmentDuration()))))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	if hs.segmentFormat == "fmp4" {
+		b.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", withQuery(HLSInitSegment, query)))
+	}
+
+	for _, span := range spans {
+		b.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n", span.Duration))
+		b.WriteString(withQuery(hs.SegmentName(span.Index), query) + "\n")
+	}
+
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String(), nil
+}
+
+// Segment 返回片段文件路径，必要时生成
+func (hs *HLSService) Segment(video *VideoInfo, index int) (string, error) {
+	if hs.segmentFormat == "fmp4" {
+		return hs.segmenter.FMP4Segment(video, index)
+	}
+	return hs.segmenter.TSSegment(video, index)
+}
+
+// InitSegment 返回 fMP4 初始化段路径，必要时生成
+func (hs *HLSService) InitSegment(video *VideoInfo) (string, error) {
+	if hs.segmentFormat != "fmp4" {
+		return "", fmt.Errorf("init segment is only available for fmp4 packaging")
+	}
+	return hs.segmenter.FMP4Init(video)
+}
+
+// withQuery 把查询字符串附加到相对 URL
+func withQuery(uri, query string) string {
+	if query == "" {
+		return uri
+	}
+	return uri + "?" + query
+}
+
+// EstimatedBitrate 根据元数据或文件大小估算码率（bps），无法估算时返回 0
+func (v *VideoInfo) EstimatedBitrate() int64 {
+	if v.Metadata.Bitrate > 0 {
+		return v.Metadata.Bitrate
+	}
+	if v.Metadata.Duration > 0 && v.Size > 0 {
+		return int64(float64(v.Size) * 8 / v.Metadata.Duration)
+	}
+	return 0
+}
+
+// estimateBandwidth 根据元数据或文件大小估算峰值码率（bps）
+func estimateBandwidth(video *VideoInfo) int64 {
+	if bitrate := video.EstimatedBitrate(); bitrate > 0 {
+		return bitrate
+	}
+	return 2_000_000
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newTestSegmenter(t *testing.T) *Segmenter {
+	t.Helper()
+	return NewSegmenter(&models.Config{
+		Video: models.VideoConfig{
+			Packaging: models.PackagingConfig{
+				SegmentDuration: 4 * time.Second,
+				CacheDir:        t.TempDir(),
+			},
+		},
+	})
+}
+
+func TestHLSService_Playlists(t *testing.T) {
+	hlsService := NewHLSService(newTestSegmenter(t), "mpegts")
+	video := &VideoInfo{
+		ID:   "movies:trailer",
+		Size: 1000,
+		Metadata: VideoMetadata{
+			Duration:   10,
+			Bitrate:    3_000_000,
+			Resolution: "1280x720",
+		},
+	}
+
+	master, err := hlsService.MasterPlaylist(video, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(master, "BANDWIDTH=3000000,RESOLUTION=1280x720") {
+		t.Errorf("Unexpected master playlist:\n%s", master)
+	}
+	if !strings.Contains(master, "\n"+HLSMediaPlaylist+"\n") {
+		t.Errorf("Master playlist should reference media playlist:\n%s", master)
+	}
+
+	media, err := hlsService.MediaPlaylist(video, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"#EXT-X-TARGETDURATION:4",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		"#EXTINF:4.000,\nsegment_00000.ts",
+		"#EXTINF:4.000,\nsegment_00001.ts",
+		"#EXTINF:2.000,\nsegment_00002.ts",
+		"#EXT-X-ENDLIST",
+	} {
+		if !strings.Contains(media, expected) {
+			t.Errorf("Media playlist missing %q:\n%s", expected, media)
+		}
+	}
+	if strings.Contains(media, "segment_00003") {
+		t.Errorf("Media playlist has too many segments:\n%s", media)
+	}
+
+	// 签名参数传递给片段 URL
+	signed, err := hlsService.MediaPlaylist(video, "exp=1&sig=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(signed, "\nsegment_00000.ts?exp=1&sig=abc\n") {
+		t.Errorf("Signed media playlist should carry the signature:\n%s", signed)
+	}
+
+	if _, err := hlsService.MasterPlaylist(&VideoInfo{ID: "movies:unknown"}, ""); err == nil {
+		t.Error("Expected error for video without duration")
+	}
+
+	if _, err := hlsService.Segment(video, 3); !errors.Is(err, ErrSegmentOutOfRange) {
+		t.Errorf("Expected ErrSegmentOutOfRange, got %v", err)
+	}
+}
+
+func TestHLSService_SegmentNames(t *testing.T) {
+	tsService := NewHLSService(newTestSegmenter(t), "")
+	fmp4Service := NewHLSService(newTestSegmenter(t), "fmp4")
+
+	if index, ok := tsService.ParseSegmentName("segment_00012.ts"); !ok || index != 12 {
+		t.Errorf("Expected segment 12, got %d (ok=%v)", index, ok)
+	}
+	if _, ok := tsService.ParseSegmentName("segment_00012.m4s"); ok {
+		t.Error("mpegts service should reject fmp4 segment names")
+	}
+	if _, ok := fmp4Service.ParseSegmentName("segment_00001.m4s"); !ok {
+		t.Error("fmp4 service should accept .m4s segments")
+	}
+	if _, ok := tsService.ParseSegmentName("../../etc/passwd"); ok {
+		t.Error("Invalid names must be rejected")
+	}
+}
+
+func mp4TestBox(boxType string, payload string) []byte {
+	box := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(len(box)))
+	copy(box[4:], boxType)
+	copy(box[8:], payload)
+	return box
+}
+
+func TestSplitFragmentedMP4(t *testing.T) {
+	var data []byte
+	data = append(data, mp4TestBox("ftyp", "isom")...)
+	data = append(data, mp4TestBox("moov", "tracks")...)
+	data = append(data, mp4TestBox("moof", "frag")...)
+	data = append(data, mp4TestBox("mdat", "samples")...)
+	data = append(data, mp4TestBox("mfra", "index")...)
+
+	initSegment, mediaSegment, err := splitFragmentedMP4(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedInit := append(mp4TestBox("ftyp", "isom"), mp4TestBox("moov", "tracks")...)
+	if string(initSegment) != string(expectedInit) {
+		t.Error("Init segment should contain exactly ftyp and moov")
+	}
+	expectedMedia := append(mp4TestBox("moof", "frag"), mp4TestBox("mdat", "samples")...)
+	if string(mediaSegment) != string(expectedMedia) {
+		t.Error("Media segment should contain exactly moof and mdat")
+	}
+
+	if _, _, err := splitFragmentedMP4(data[:len(data)-3]); err == nil {
+		t.Error("Expected error for truncated box")
+	}
+}
+
+// fragmentedTestMP4 构造 H.264 样本条目带有给定 avcC 的分片 MP4
+func fragmentedTestMP4(avcC, samples string) []byte {
+	avc1 := mp4TestBox("avc1", strings.Repeat("\x00", 78)+string(mp4TestBox("avcC", avcC)))
+	stsd := mp4TestBox("stsd", strings.Repeat("\x00", 8)+string(avc1))
+	trak := mp4TestBox("trak", string(mp4TestBox("mdia", string(mp4TestBox("minf", string(mp4TestBox("stbl", string(stsd))))))))
+
+	var data []byte
+	data = append(data, mp4TestBox("ftyp", "isom")...)
+	data = append(data, mp4TestBox("moov", string(trak))...)
+	data = append(data, mp4TestBox("moof", "frag")...)
+	data = append(data, mp4TestBox("mdat", samples)...)
+	return data
+}
+
+func TestSegmenter_FMP4DecoderConfig(t *testing.T) {
+	encodes := t.TempDir()
+	os.WriteFile(filepath.Join(encodes, "encode_0.000.mp4"), fragmentedTestMP4("sps-pps", "first"), 0o644)
+	os.WriteFile(filepath.Join(encodes, "encode_4.000.mp4"), fragmentedTestMP4("sps-pps", "second"), 0o644)
+	os.WriteFile(filepath.Join(encodes, "encode_8.000.mp4"), fragmentedTestMP4("other-sps-pps", "third"), 0o644)
+
+	// 假的 ffmpeg 按 -ss 的值输出预先准备的编码结果
+	ffmpegPath := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\nwhile [ $# -gt 1 ]; do\n  if [ \"$1\" = \"-ss\" ]; then start=\"$2\"; fi\n  shift\ndone\ncp \"" + encodes + "/encode_$start.mp4\" \"$1\"\n"
+	if err := os.WriteFile(ffmpegPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	segmenter := newTestSegmenter(t)
+	segmenter.config.Video.FFmpegPath = ffmpegPath
+	video := &VideoInfo{ID: "movies:avatar", Path: "/videos/avatar.mp4", Metadata: VideoMetadata{Duration: 12}}
+
+	// 先请求媒体段时，初始化段取自同一次编码
+	segmentPath, err := segmenter.FMP4Segment(video, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPath, err := segmenter.FMP4Init(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initSegment, _ := os.ReadFile(initPath)
+	media, _ := os.ReadFile(segmentPath)
+	expectedInit, expectedMedia, _ := splitFragmentedMP4(fragmentedTestMP4("sps-pps", "second"))
+	if string(initSegment) != string(expectedInit) || string(media) != string(expectedMedia) {
+		t.Error("Expected the init and media segments of the same encode")
+	}
+
+	// 参数集相同的其他片段可以共用初始化段
+	if _, err := segmenter.FMP4Segment(video, 0); err != nil {
+		t.Errorf("Expected a segment with the same SPS/PPS to be served, got %v", err)
+	}
+
+	// 参数集不同的片段不能与初始化段一起播放
+	if _, err := segmenter.FMP4Segment(video, 2); !errors.Is(err, ErrCodecMismatch) {
+		t.Errorf("Expected ErrCodecMismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(segmentPath), "segment_00002.m4s")); !os.IsNotExist(err) {
+		t.Error("A mismatching segment must not be cached")
+	}
+}
+
+func TestSegmentCache_SingleFlightAndEviction(t *testing.T) {
+	cacheDir := t.TempDir()
+	cache := NewSegmentCache(cacheDir, 25)
+
+	var calls int32
+	generate := func(tmpPath string) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return os.WriteFile(tmpPath, []byte("0123456789"), 0o644)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Get("a/segment_00000.ts", generate); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected a single generation for concurrent requests, got %d", calls)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(cacheDir, "a", "segment_00000.ts"), old, old)
+
+	cache.Get("a/segment_00001.ts", generate)
+	cache.Get("a/segment_00002.ts", generate)
+
+	// 淘汰在后台运行，轮询等待其完成
+	evicted := false
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		cache.Evict()
+		if _, err := os.Stat(filepath.Join(cacheDir, "a", "segment_00000.ts")); os.IsNotExist(err) {
+			evicted = true
+			break
+		}
+	}
+	if !evicted {
+		t.Error("Least recently used segment should have been evicted")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "a", "segment_00002.ts")); err != nil {
+		t.Error("Most recent segment should remain cached")
+	}
+
+	failing := func(tmpPath string) error { return errors.New("ffmpeg missing") }
+	if _, err := cache.Get("b/segment_00000.ts", failing); err == nil {
+		t.Error("Expected generation error to be returned")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "b", "segment_00000.ts")); !os.IsNotExist(err) {
+		t.Error("Failed generation must not leave a cached file")
+	}
+}
+
+func TestSegmenter_BusyEncoders(t *testing.T) {
+	segmenter := NewSegmenter(&models.Config{
+		Video: models.VideoConfig{
+			Packaging: models.PackagingConfig{
+				CacheDir:     t.TempDir(),
+				Workers:      1,
+				QueueTimeout: 20 * time.Millisecond,
+			},
+		},
+	})
+
+	// 占满编码进程池后，新的编码请求在等待超时后失败，且不会启动 ffmpeg
+	segmenter.workers <- struct{}{}
+	video := &VideoInfo{ID: "movies:avatar", Path: "/nonexistent.mp4", Metadata: VideoMetadata{Duration: 12}}
+	if _, err := segmenter.TSSegment(video, 0); !errors.Is(err, ErrSegmenterBusy) {
+		t.Fatalf("Expected ErrSegmenterBusy, got %v", err)
+	}
+	if _, err := segmenter.FMP4Segment(video, 0); !errors.Is(err, ErrSegmenterBusy) {
+		t.Fatalf("Expected ErrSegmenterBusy for DASH segments, got %v", err)
+	}
+
+	// 释放后重新可用（ffmpeg 不存在时返回编码错误而不是繁忙）
+	<-segmenter.workers
+	segmenter.config.Video.FFmpegPath = filepath.Join(t.TempDir(), "missing-ffmpeg")
+	if _, err := segmenter.TSSegment(video, 0); err == nil || errors.Is(err, ErrSegmenterBusy) {
+		t.Errorf("Expected an encoding error once a worker is free, got %v", err)
+	}
+}
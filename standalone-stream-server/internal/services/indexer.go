@@ -0,0 +1,428 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// ErrIndexingInProgress 表示已有索引任务在运行
+var ErrIndexingInProgress = errors.New("catalog indexing already in progress")
+
+// CatalogIndexer 在后台扫描视频目录并维护持久化索引
+type CatalogIndexer struct {
+	videoService *VideoService
+	catalog      *Catalog
+	interval     time.Duration
+
+	indexMu  sync.Mutex // 串行化重建与刷新
+	mu       sync.RWMutex
+	running  bool
+	indexing bool
+	stopChan chan struct{}
+	lastRun  *IndexResult
+	lastErr  error
+}
+
+// IndexResult 描述一次索引运行的结果
+type IndexResult struct {
+	Mode       string    `json:"mode"` // rebuild, refresh
+	Added      int       `json:"added"`
+	Updated    int       `json:"updated"`
+	Removed    int       `json:"removed"`
+	Unchanged  int       `json:"unchanged"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// NewCatalogIndexer 创建新的索引器，interval 为后台增量刷新间隔
+func NewCatalogIndexer(videoService *VideoService, catalog *Catalog, interval time.Duration) *CatalogIndexer {
+	return &CatalogIndexer{
+		videoService: videoService,
+		catalog:      catalog,
+		interval:     interval,
+	}
+}
+
+// Start 启动后台索引：立即执行一次增量刷新，然后按间隔周期刷新
+func (ci *CatalogIndexer) Start() {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if ci.running {
+		return
+	}
+	ci.running = true
+	ci.stopChan = make(chan struct{})
+
+	go ci.run(ci.stopChan)
+}
+
+// Stop 停止后台索引
+func (ci *CatalogIndexer) Stop() {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if !ci.running {
+		return
+	}
+	ci.running = false
+	close(ci.stopChan)
+}
+
+// IsRunning 返回后台索引是否在运行
+func (ci *CatalogIndexer) IsRunning() bool {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+	return ci.running
+}
+
+// Trigger 在后台异步执行一次重建或刷新；已有索引任务时返回 ErrIndexingInProgress
+func (ci *CatalogIndexer) Trigger(full bool) error {
+	if !ci.indexMu.TryLock() {
+		return ErrIndexingInProgress
+	}
+
+	go func() {
+		defer ci.indexMu.Unlock()
+		ci.index(full)
+	}()
+
+	return nil
+}
+
+// Rebuild 丢弃现有索引并对所有启用目录执行完整扫描
+func (ci *CatalogIndexer) Rebuild() (IndexResult, error) {
+	ci.indexMu.Lock()
+	defer ci.indexMu.Unlock()
+	return ci.index(true)
+}
+
+// Refresh 执行增量刷新：只为新增或变化的文件提取元数据，并移除已消失的条目
+func (ci *CatalogIndexer) Refresh() (IndexResult, error) {
+	ci.indexMu.Lock()
+	defer ci.indexMu.Unlock()
+	return ci.index(false)
+}
+
+// Status 返回索引器状态和各目录的索引信息
+func (ci *CatalogIndexer) Status() map[string]interface{} {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+
+	var directories []CatalogDirectoryState
+	for _, dir := range ci.videoService.config.Video.Directories {
+		if !dir.Enabled {
+			continue
+		}
+		if state, ok, err := ci.catalog.DirectoryState(dir.Name); err == nil && ok {
+			directories = append(directories, state)
+		}
+	}
+
+	status := map[string]interface{}{
+		"running":          ci.running,
+		"indexing":         ci.indexing,
+		"refresh_interval": ci.interval.String(),
+		"directories":      directories,
+		"last_run":         ci.lastRun,
+	}
+	if ci.lastErr != nil {
+		status["last_error"] = ci.lastErr.Error()
+	}
+
+	return status
+}
+
+// run 是后台索引循环
+func (ci *CatalogIndexer) run(stop chan struct{}) {
+	if _, err := ci.Refresh(); err != nil {
+		logIndexerError("catalog_refresh", err)
+	}
+
+	if ci.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ci.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := ci.Refresh(); err != nil {
+				logIndexerError("catalog_refresh", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// index 对所有启用目录执行重建或刷新，调用方必须持有 indexMu
+func (ci *CatalogIndexer) index(full bool) (IndexResult, error) {
+	result := IndexResult{Mode: "refresh", StartedAt: time.Now()}
+	if full {
+		result.Mode = "rebuild"
+	}
+
+	ci.mu.Lock()
+	ci.indexing = true
+	ci.mu.Unlock()
+
+	var lastErr error
+	for _, dir := range ci.videoService.config.Video.Directories {
+		if !dir.Enabled {
+			continue
+		}
+
+		var err error
+		if full {
+			err = ci.rebuildDirectory(dir.Name, dir.Path, &result)
+		} else {
+			err = ci.refreshDirectory(dir.Name, dir.Path, &result)
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("failed to index directory %s: %w", dir.Name, err)
+			logIndexerError("catalog_index_directory", err, zap.String("directory", dir.Name))
+			continue
+		}
+
+		if state, ok, err := ci.catalog.DirectoryState(dir.Name); err == nil && ok {
+			utils.UpdateVideoFilesCount(dir.Name, state.VideoCount)
+		}
+	}
+
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+
+	ci.mu.Lock()
+	ci.indexing = false
+	ci.lastRun = &result
+	ci.lastErr = lastErr
+	ci.mu.Unlock()
+
+	if utils.Logger != nil {
+		utils.Logger.Info("Catalog indexing completed",
+			zap.String("mode", result.Mode),
+			zap.Int("added", result.Added),
+			zap.Int("updated", result.Updated),
+			zap.Int("removed", result.Removed),
+			zap.Int("unchanged", result.Unchanged),
+			zap.Int64("duration_ms", result.DurationMs),
+		)
+	}
+
+	return result, lastErr
+}
+
+// rebuildDirectory 完整扫描目录并替换其全部索引条目
+func (ci *CatalogIndexer) rebuildDirectory(name, path string, result *IndexResult) error {
+	videos, err := ci.videoService.scanDirectoryRecursive(path, name, "", 0, true)
+	if err != nil {
+		return err
+	}
+	for i := range videos {
+		ci.videoService.hashVideo(&videos[i])
+	}
+
+	if err := ci.catalog.ReplaceDirectory(name, videos); err != nil {
+		return err
+	}
+
+	result.Added += len(videos)
+	return nil
+}
+
+// refreshDirectory 将磁盘上的文件与索引比较，只处理变化部分
+func (ci *CatalogIndexer) refreshDirectory(name, path string, result *IndexResult) error {
+	onDisk, err := ci.videoService.scanDirectoryRecursive(path, name, "", 0, false)
+	if err != nil {
+		return err
+	}
+
+	indexed, err := ci.catalog.ListDirectory(name)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]VideoInfo, len(indexed))
+	for _, video := range indexed {
+		existing[video.ID] = video
+	}
+
+	for _, video := range onDisk {
+		old, found := existing[video.ID]
+		delete(existing, video.ID)
+
+		if found && isSameCatalogFile(old, video) {
+			if err := ci.registerUnchanged(old); err != nil {
+				return err
+			}
+			result.Unchanged++
+			continue
+		}
+
+		video.Metadata = ci.videoService.extractVideoMetadata(video.Path, video.Extension)
+		ci.videoService.hashVideo(&video)
+		if err := ci.catalog.Put(video); err != nil {
+			return err
+		}
+
+		if found {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+
+	// 剩余的条目已从磁盘上消失
+	for videoID, video := range existing {
+		if err := ci.catalog.Delete(videoID); err != nil {
+			return err
+		}
+		ci.videoService.contentIndex.Remove(video.Path)
+		result.Removed++
+	}
+
+	return ci.catalog.MarkIndexed(name)
+}
+
+// IndexPath 增量索引目录中的单个文件或子目录；路径已不存在时移除对应条目
+func (ci *CatalogIndexer) IndexPath(directoryName, fullPath string) error {
+	dir, rel, err := ci.resolvePath(directoryName, fullPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(fullPath)
+	if os.IsNotExist(err) {
+		return ci.RemovePath(directoryName, fullPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", fullPath, err)
+	}
+
+	ci.indexMu.Lock()
+	defer ci.indexMu.Unlock()
+
+	var candidates []VideoInfo
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return nil
+	case info.IsDir():
+		candidates, err = ci.videoService.scanDirectoryRecursive(dir.Path, directoryName, rel, relativePathDepth(rel), false)
+		if err != nil {
+			return err
+		}
+	default:
+		if !ci.videoService.isVideoFile(strings.ToLower(filepath.Ext(rel))) {
+			return nil
+		}
+		candidates = []VideoInfo{ci.videoService.buildVideoInfo(directoryName, rel, filepath.Join(dir.Path, rel), info, false)}
+	}
+
+	for _, video := range candidates {
+		old, err := ci.catalog.Get(video.ID)
+		if err != nil {
+			return err
+		}
+		if old != nil && isSameCatalogFile(*old, video) {
+			if err := ci.registerUnchanged(*old); err != nil {
+				return err
+			}
+			continue
+		}
+
+		video.Metadata = ci.videoService.extractVideoMetadata(video.Path, video.Extension)
+		ci.videoService.hashVideo(&video)
+		if err := ci.catalog.Put(video); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovePath 从索引中移除文件，或移除子目录下的全部条目
+func (ci *CatalogIndexer) RemovePath(directoryName, fullPath string) error {
+	dir, rel, err := ci.resolvePath(directoryName, fullPath)
+	if err != nil {
+		return err
+	}
+
+	ci.indexMu.Lock()
+	defer ci.indexMu.Unlock()
+
+	indexed, err := ci.catalog.ListDirectory(directoryName)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(dir.Path, rel)
+	for _, video := range indexed {
+		if video.Path == target || strings.HasPrefix(video.Path, target+string(filepath.Separator)) {
+			if err := ci.catalog.Delete(video.ID); err != nil {
+				return err
+			}
+			ci.videoService.contentIndex.Remove(video.Path)
+		}
+	}
+
+	return nil
+}
+
+// registerUnchanged 把未变化的索引条目登记到内容哈希索引；
+// 早于内容哈希功能的条目会在此补算哈希
+func (ci *CatalogIndexer) registerUnchanged(video VideoInfo) error {
+	if video.Hash != "" {
+		ci.videoService.contentIndex.AddVideo(video)
+		return nil
+	}
+
+	ci.videoService.hashVideo(&video)
+	if video.Hash == "" {
+		return nil
+	}
+	return ci.catalog.Put(video)
+}
+
+// resolvePath 返回路径所属的已启用目录以及相对于该目录的路径
+func (ci *CatalogIndexer) resolvePath(directoryName, fullPath string) (*models.VideoDirectory, string, error) {
+	dir := ci.videoService.findDirectory(directoryName)
+	if dir == nil || !dir.Enabled {
+		return nil, "", fmt.Errorf("directory not found or disabled: %s", directoryName)
+	}
+
+	rel, err := filepath.Rel(dir.Path, fullPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, "", fmt.Errorf("path %s is not inside directory %s", fullPath, directoryName)
+	}
+
+	return dir, rel, nil
+}
+
+// isSameCatalogFile 判断索引条目与扫描结果是否对应磁盘上未变化的同一文件
+func isSameCatalogFile(old, current VideoInfo) bool {
+	return old.Size == current.Size && old.Modified == current.Modified && old.Path == current.Path
+}
+
+// relativePathDepth 返回相对路径的目录深度（与 scanDirectoryRecursive 的 depth 一致）
+func relativePathDepth(rel string) int {
+	return len(strings.Split(rel, string(filepath.Separator)))
+}
+
+// logIndexerError 在日志器已初始化时记录索引错误
+func logIndexerError(operation string, err error, fields ...zap.Field) {
+	if utils.Logger != nil {
+		utils.LogError(operation, err, fields...)
+	}
+}
@@ -1,12 +1,15 @@
 package services
 
 import (
+"context"
 "encoding/json"
 "fmt"
+"os"
 "os/exec"
 "path/filepath"
 "strconv"
 "strings"
+"sync"
 "time"
 
 "standalone-stream-server/internal/models"
@@ -15,16 +18,64 @@ import (
 "go.uber.org/zap"
 )
 
+// Defaults for metadata probing when the configuration leaves them unset
+const (
+defaultMetadataWorkers = 2
+defaultMetadataTimeout = 30 * time.Second
+)
+
 // MetadataService handles video metadata extraction
 type MetadataService struct {
-config *models.Config
+config     *models.Config
+thumbnails *ThumbnailCache
+cache      *MetadataCache
+probes     chan struct{} // limits concurrent probes
+timeout    time.Duration
+
+flightsMu sync.Mutex
+flights   map[string]*metadataFlight
+}
+
+// metadataFlight is an in-progress probe; other callers for the same file wait for its result
+type metadataFlight struct {
+done     chan struct{}
+metadata VideoMetadata
+probed   bool
 }
 
 // NewMetadataService creates a new metadata service
 func NewMetadataService(config *models.Config) *MetadataService {
+workers := config.Video.Metadata.Workers
+if workers <= 0 {
+workers = defaultMetadataWorkers
+}
+timeout := config.Video.Metadata.Timeout
+if timeout <= 0 {
+timeout = defaultMetadataTimeout
+}
+
 return &MetadataService{
-config: config,
+config:     config,
+thumbnails: NewThumbnailCache(config.Video.Thumbnails),
+probes:     make(chan struct{}, workers),
+timeout:    timeout,
+flights:    make(map[string]*metadataFlight),
+}
+}
+
+// SetCache sets the persistent metadata cache; without it every call probes the file
+func (ms *MetadataService) SetCache(cache *MetadataCache) {
+ms.cache = cache
 }
+
+// Cache returns the persistent metadata cache, or nil when caching is disabled
+func (ms *MetadataService) Cache() *MetadataCache {
+return ms.cache
+}
+
+// ProbeWorkers returns the maximum number of concurrent probes
+func (ms *MetadataService) ProbeWorkers() int {
+return cap(ms.probes)
 }
 
 // FFProbeOutput represents the output structure from ffprobe
@@ -52,11 +103,81 @@ BitRate    string `json:"bit_rate"`
 } `json:"format"`
 }
 
-// ExtractMetadata extracts video metadata using FFprobe
+// ExtractMetadata returns the cached metadata while the file is unchanged, and probes it otherwise
 func (ms *MetadataService) ExtractMetadata(videoPath string) (VideoMetadata, error) {
+metadata, _ := ms.extract(videoPath, false)
+return metadata, nil
+}
+
+// ReprobeMetadata probes the file even when its cached metadata is still valid, and replaces the cache entry
+func (ms *MetadataService) ReprobeMetadata(videoPath string) (VideoMetadata, error) {
+metadata, _ := ms.extract(videoPath, true)
+return metadata, nil
+}
+
+// extract returns the metadata of the file and whether it was served from the cache
+func (ms *MetadataService) extract(videoPath string, force bool) (VideoMetadata, bool) {
+info, err := os.Stat(videoPath)
+if err != nil || ms.cache == nil {
+metadata, _ := ms.probe(videoPath)
+return metadata, false
+}
+
+identity := FileIdentityOf(info)
+if !force {
+if metadata, ok := ms.cache.Get(videoPath, identity); ok {
+return metadata, true
+}
+}
+
+metadata, probed := ms.probe(videoPath)
+if probed {
+if err := ms.cache.Put(videoPath, identity, metadata); err != nil && utils.Logger != nil {
+utils.Logger.Warn("Failed to cache video metadata",
+zap.String("video_path", videoPath),
+zap.Error(err),
+)
+}
+}
+return metadata, false
+}
+
+// probe runs one probe per file at a time; concurrent callers for the same file share its result
+func (ms *MetadataService) probe(videoPath string) (VideoMetadata, bool) {
+ms.flightsMu.Lock()
+if flight, ok := ms.flights[videoPath]; ok {
+ms.flightsMu.Unlock()
+<-flight.done
+return flight.metadata, flight.probed
+}
+flight := &metadataFlight{done: make(chan struct{})}
+ms.flights[videoPath] = flight
+ms.flightsMu.Unlock()
+
+flight.metadata, flight.probed = ms.runProbe(videoPath)
+
+ms.flightsMu.Lock()
+delete(ms.flights, videoPath)
+ms.flightsMu.Unlock()
+close(flight.done)
+return flight.metadata, flight.probed
+}
+
+// runProbe waits for a free probe slot, then tries FFprobe, the container prober and the extension fallback in turn.
+// probed is false when only the extension fallback produced metadata, so the result is not worth caching
+func (ms *MetadataService) runProbe(videoPath string) (VideoMetadata, bool) {
+ms.probes <- struct{}{}
+utils.UpdateMetadataProbesBusy(len(ms.probes))
+defer func() {
+<-ms.probes
+utils.UpdateMetadataProbesBusy(len(ms.probes))
+}()
+
 // First try FFprobe for detailed metadata
+start := time.Now()
 if ffprobeMetadata, err := ms.extractWithFFprobe(videoPath); err == nil {
-return ffprobeMetadata, nil
+utils.RecordMetadataProbe("ffprobe", time.Since(start))
+return ffprobeMetadata, true
 } else {
 if utils.Logger != nil {
 utils.Logger.Warn("FFprobe extraction failed, using fallback",
@@ -66,13 +187,29 @@ zap.Error(err),
 }
 }
 
+// Without FFprobe, read duration, resolution and codecs from the container headers
+start = time.Now()
+if probedMetadata, err := ProbeContainer(videoPath); err == nil {
+utils.RecordMetadataProbe("container", time.Since(start))
+return probedMetadata, true
+} else if utils.Logger != nil {
+utils.Logger.Debug("Container probing failed, using extension fallback",
+zap.String("video_path", videoPath),
+zap.Error(err),
+)
+}
+
 // Fallback to basic metadata based on file extension
-return ms.extractFallbackMetadata(videoPath), nil
+utils.RecordMetadataProbe("extension", time.Since(start))
+return ms.extractFallbackMetadata(videoPath), false
 }
 
 // extractWithFFprobe uses FFprobe to extract detailed metadata
 func (ms *MetadataService) extractWithFFprobe(videoPath string) (VideoMetadata, error) {
-cmd := exec.Command("ffprobe", 
+ctx, cancel := context.WithTimeout(context.Background(), ms.timeout)
+defer cancel()
+
+cmd := exec.CommandContext(ctx, "ffprobe",
 "-v", "quiet",
 "-print_format", "json",
 "-show_format",
@@ -173,43 +310,28 @@ return 0
 return numerator / denominator
 }
 
-// extractFallbackMetadata provides basic metadata when FFprobe is not available
+// extractFallbackMetadata provides the container format when neither FFprobe nor
+// container probing can read the file; codecs and duration are left unknown
 func (ms *MetadataService) extractFallbackMetadata(videoPath string) VideoMetadata {
 ext := strings.ToLower(filepath.Ext(videoPath))
 
 metadata := VideoMetadata{}
 
-// Set basic codec information based on file extension
+// Set the container format based on file extension
 switch ext {
-case ".mp4":
-metadata.Codec = "H.264"
-metadata.AudioCodec = "AAC"
+case ".mp4", ".m4v":
 metadata.Format = "mp4"
-metadata.Duration = 1 // Placeholder
-metadata.Bitrate = 1000 * 144 // 144 kbps placeholder
 case ".avi":
-metadata.Codec = "Various"
-metadata.AudioCodec = "Various"
 metadata.Format = "avi"
 case ".mov":
-metadata.Codec = "H.264"
-metadata.AudioCodec = "AAC"
 metadata.Format = "quicktime"
 case ".mkv":
-metadata.Codec = "H.264"
-metadata.AudioCodec = "AAC"
 metadata.Format = "matroska"
 case ".webm":
-metadata.Codec = "VP8"
-metadata.AudioCodec = "Vorbis"
 metadata.Format = "webm"
 case ".flv":
-metadata.Codec = "H.264"
-metadata.AudioCodec = "AAC"
 metadata.Format = "flv"
 default:
-metadata.Codec = "Unknown"
-metadata.AudioCodec = "Unknown"
 metadata.Format = "unknown"
 }
 
@@ -218,6 +340,11 @@ return metadata
 
 // GenerateThumbnail generates a thumbnail for a video file
 func (ms *MetadataService) GenerateThumbnail(videoPath string, outputPath string, timestamp time.Duration) error {
+return ms.GenerateThumbnailContext(context.Background(), videoPath, outputPath, timestamp)
+}
+
+// GenerateThumbnailContext generates a thumbnail for a video file, killing ffmpeg when ctx is done
+func (ms *MetadataService) GenerateThumbnailContext(ctx context.Context, videoPath string, outputPath string, timestamp time.Duration) error {
 // Create output directory if it doesn't exist
 outputDir := filepath.Dir(outputPath)
 if err := exec.Command("mkdir", "-p", outputDir).Run(); err != nil {
@@ -226,7 +353,7 @@ return fmt.Errorf("failed to create thumbnail directory: %w", err)
 
 // Use FFmpeg to generate thumbnail
 timestampStr := fmt.Sprintf("%.2f", timestamp.Seconds())
-cmd := exec.Command("ffmpeg",
+cmd := exec.CommandContext(ctx, "ffmpeg",
 "-i", videoPath,
 "-ss", timestampStr,
 "-vframes", "1",
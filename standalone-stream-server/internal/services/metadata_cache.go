@@ -0,0 +1,178 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"standalone-stream-server/internal/utils"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var metadataCacheBucket = []byte("metadata")
+
+// FileIdentity 标识磁盘上某个版本的文件：路径相同但大小、修改时间或 inode 变化时视为不同的文件，
+// 因此原地覆盖和用新文件替换（rename）都会使缓存失效
+type FileIdentity struct {
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"` // 修改时间（纳秒）
+	Inode    uint64 `json:"inode,omitempty"`
+}
+
+// FileIdentityOf 返回文件信息对应的身份；不支持 inode 的平台上 Inode 为 0
+func FileIdentityOf(info os.FileInfo) FileIdentity {
+	return FileIdentity{
+		Size:     info.Size(),
+		Modified: info.ModTime().UnixNano(),
+		Inode:    fileInode(info),
+	}
+}
+
+// metadataCacheEntry 是缓存数据库中以文件路径为键的条目
+type metadataCacheEntry struct {
+	Identity FileIdentity  `json:"identity"`
+	Metadata VideoMetadata `json:"metadata"`
+	ProbedAt int64         `json:"probed_at"`
+}
+
+// MetadataCache 是持久化在磁盘上的元数据缓存，以文件路径为键，仅当文件身份一致时命中
+type MetadataCache struct {
+	db *bolt.DB
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// MetadataCacheStats 是缓存的当前状态
+type MetadataCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// OpenMetadataCache 打开（必要时创建）指定路径的缓存数据库
+func OpenMetadataCache(path string) (*MetadataCache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata cache database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(metadataCacheBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize metadata cache bucket: %w", err)
+	}
+
+	return &MetadataCache{db: db}, nil
+}
+
+// Close 关闭缓存数据库
+func (mc *MetadataCache) Close() error {
+	return mc.db.Close()
+}
+
+// Get 返回文件的缓存元数据，仅当记录的文件身份与 identity 一致时有效
+func (mc *MetadataCache) Get(path string, identity FileIdentity) (VideoMetadata, bool) {
+	var entry *metadataCacheEntry
+	mc.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metadataCacheBucket).Get([]byte(path))
+		if data == nil {
+			return nil
+		}
+		entry = &metadataCacheEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			entry = nil // 损坏的条目按未命中处理，下次探测时覆盖
+		}
+		return nil
+	})
+
+	switch {
+	case entry == nil:
+		utils.RecordMetadataCacheLookup("miss")
+	case entry.Identity != identity:
+		utils.RecordMetadataCacheLookup("stale")
+	default:
+		mc.hits.Add(1)
+		utils.RecordMetadataCacheLookup("hit")
+		return entry.Metadata, true
+	}
+	mc.misses.Add(1)
+	return VideoMetadata{}, false
+}
+
+// Put 写入或替换文件的元数据
+func (mc *MetadataCache) Put(path string, identity FileIdentity, metadata VideoMetadata) error {
+	data, err := json.Marshal(metadataCacheEntry{
+		Identity: identity,
+		Metadata: metadata,
+		ProbedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return mc.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metadataCacheBucket).Put([]byte(path), data)
+	})
+}
+
+// Delete 移除文件的缓存条目
+func (mc *MetadataCache) Delete(path string) error {
+	return mc.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metadataCacheBucket).Delete([]byte(path))
+	})
+}
+
+// Prune 移除文件已不存在的条目，返回移除的数量
+func (mc *MetadataCache) Prune() (int, error) {
+	var stale [][]byte
+	err := mc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(metadataCacheBucket).ForEach(func(k, _ []byte) error {
+			if _, err := os.Stat(string(k)); os.IsNotExist(err) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+	})
+	if err != nil || len(stale) == 0 {
+		return 0, err
+	}
+
+	err = mc.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(metadataCacheBucket)
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
+
+// Stats 返回缓存的条目数和本次运行以来的命中统计
+func (mc *MetadataCache) Stats() MetadataCacheStats {
+	stats := MetadataCacheStats{
+		Hits:   mc.hits.Load(),
+		Misses: mc.misses.Load(),
+	}
+	mc.db.View(func(tx *bolt.Tx) error {
+		stats.Entries = tx.Bucket(metadataCacheBucket).Stats().KeyN
+		return nil
+	})
+	return stats
+}
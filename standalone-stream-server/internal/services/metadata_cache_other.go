@@ -0,0 +1,10 @@
+//go:build !unix
+
+package services
+
+import "os"
+
+// fileInode 在没有 inode 的平台上返回 0，文件身份只由大小和修改时间决定
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
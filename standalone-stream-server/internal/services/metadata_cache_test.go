@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// copyProbeFixture copies a container fixture from testdata/probe into dir
+func copyProbeFixture(t *testing.T, fixture, dir, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "probe", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMetadataCache(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "metadata.db")
+	cache, err := OpenMetadataCache(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := copyProbeFixture(t, "sample.mp4", t.TempDir(), "movie.mp4")
+	info, _ := os.Stat(path)
+	identity := FileIdentityOf(info)
+
+	if _, ok := cache.Get(path, identity); ok {
+		t.Fatal("Expected a miss on an empty cache")
+	}
+	metadata := VideoMetadata{Duration: 10, Resolution: "640x360", Codec: "H264"}
+	if err := cache.Put(path, identity, metadata); err != nil {
+		t.Fatal(err)
+	}
+	if cached, ok := cache.Get(path, identity); !ok || cached != metadata {
+		t.Errorf("Expected a hit with %+v, got %+v (hit %v)", metadata, cached, ok)
+	}
+
+	// A different size, mtime or inode means a different file
+	changed := identity
+	changed.Modified += int64(time.Second)
+	if _, ok := cache.Get(path, changed); ok {
+		t.Error("Expected a miss after the file was modified")
+	}
+	changed = identity
+	changed.Inode++
+	if _, ok := cache.Get(path, changed); ok {
+		t.Error("Expected a miss after the file was replaced")
+	}
+
+	// Entries survive a restart
+	cache.Close()
+	cache, err = OpenMetadataCache(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if cached, ok := cache.Get(path, identity); !ok || cached != metadata {
+		t.Errorf("Expected the entry to persist, got %+v (hit %v)", cached, ok)
+	}
+
+	os.Remove(path)
+	if pruned, err := cache.Prune(); err != nil || pruned != 1 {
+		t.Errorf("Expected 1 pruned entry, got %d (%v)", pruned, err)
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Hits != 1 {
+		t.Errorf("Unexpected stats after pruning: %+v", stats)
+	}
+}
+
+func TestMetadataServiceUsesCache(t *testing.T) {
+	cache, err := OpenMetadataCache(filepath.Join(t.TempDir(), "metadata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	ms := NewMetadataService(&models.Config{})
+	ms.SetCache(cache)
+	if ms.ProbeWorkers() != defaultMetadataWorkers {
+		t.Errorf("Expected %d probe workers by default, got %d", defaultMetadataWorkers, ms.ProbeWorkers())
+	}
+
+	dir := t.TempDir()
+	path := copyProbeFixture(t, "sample.mp4", dir, "movie.mp4")
+
+	first, cached := ms.extract(path, false)
+	if cached || first.Resolution != "640x360" {
+		t.Fatalf("Expected a probed 640x360 video, got %+v (cached %v)", first, cached)
+	}
+	if second, cached := ms.extract(path, false); !cached || second != first {
+		t.Errorf("Expected the second lookup to hit the cache, got %+v (cached %v)", second, cached)
+	}
+	if _, cached := ms.extract(path, true); cached {
+		t.Error("Expected a forced reprobe to bypass the cache")
+	}
+
+	// Replacing the file with different content invalidates the entry
+	copyProbeFixture(t, "sample.mkv", dir, "movie.mp4")
+	if replaced, cached := ms.extract(path, false); cached || replaced.Resolution != "1920x1080" {
+		t.Errorf("Expected the replaced file to be probed again, got %+v (cached %v)", replaced, cached)
+	}
+
+	// Renaming another file over it with the same size and mtime is caught by the inode
+	info, _ := os.Stat(path)
+	if FileIdentityOf(info).Inode != 0 {
+		data, _ := os.ReadFile(path)
+		other := filepath.Join(dir, "other.mkv")
+		os.WriteFile(other, data, 0o644)
+		os.Chtimes(other, info.ModTime(), info.ModTime())
+		if err := os.Rename(other, path); err != nil {
+			t.Fatal(err)
+		}
+		if _, cached := ms.extract(path, false); cached {
+			t.Error("Expected a file replaced by rename to be probed again")
+		}
+	}
+
+	// Files only described by their extension are not cached
+	text := filepath.Join(dir, "broken.flv")
+	os.WriteFile(text, []byte("not a video"), 0o644)
+	ms.extract(text, false)
+	if _, cached := ms.extract(text, false); cached {
+		t.Error("Expected extension fallback metadata not to be cached")
+	}
+}
+
+func TestMetadataWarmer(t *testing.T) {
+	testDir := t.TempDir()
+	copyProbeFixture(t, "sample.mp4", testDir, "a.mp4")
+	os.MkdirAll(filepath.Join(testDir, "nested"), 0o755)
+	copyProbeFixture(t, "sample.avi", testDir, "nested/b.avi")
+
+	service, catalog := newCatalogTestService(t, testDir)
+	cache, err := OpenMetadataCache(filepath.Join(t.TempDir(), "metadata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	metadataService := NewMetadataService(service.config)
+	metadataService.SetCache(cache)
+	service.SetMetadataService(metadataService)
+
+	warmer := NewMetadataWarmer(service)
+	defer warmer.Stop()
+
+	result, err := warmer.ProbeDirectory("test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 2 || result.Probed != 2 || result.Cached != 0 {
+		t.Errorf("Expected 2 probed files on the first warmup, got %+v", result)
+	}
+	if result, _ = warmer.ProbeDirectory("test", false); result.Cached != 2 || result.Probed != 0 {
+		t.Errorf("Expected 2 cached files on the second warmup, got %+v", result)
+	}
+
+	// A forced reprobe ignores the cache and refreshes stale catalog entries
+	if _, err := NewCatalogIndexer(service, catalog, 0).Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := catalog.Get("test:a")
+	entry.Metadata = VideoMetadata{Format: "mp4"}
+	catalog.Put(*entry)
+
+	if result, _ = warmer.ProbeDirectory("test", true); result.Probed != 2 || result.Mode != "reprobe" {
+		t.Errorf("Expected 2 reprobed files, got %+v", result)
+	}
+	if entry, _ = catalog.Get("test:a"); entry.Metadata.Resolution != "640x360" {
+		t.Errorf("Expected the catalog entry to be updated, got %+v", entry.Metadata)
+	}
+
+	if err := warmer.Reprobe("missing"); !errors.Is(err, ErrDirectoryUnavailable) {
+		t.Errorf("Expected ErrDirectoryUnavailable, got %v", err)
+	}
+	warmer.running["test"] = "warmup"
+	if err := warmer.Reprobe("test"); !errors.Is(err, ErrMetadataProbeInProgress) {
+		t.Errorf("Expected ErrMetadataProbeInProgress, got %v", err)
+	}
+	delete(warmer.running, "test")
+
+	status := warmer.Status()
+	if runs := status["last_runs"].([]MetadataProbeResult); len(runs) != 1 || runs[0].Mode != "reprobe" {
+		t.Errorf("Expected the last reprobe in the status, got %+v", status["last_runs"])
+	}
+	if stats := status["cache"].(MetadataCacheStats); stats.Entries != 2 {
+		t.Errorf("Expected 2 cache entries, got %+v", stats)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package services
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode 返回文件的 inode 编号
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
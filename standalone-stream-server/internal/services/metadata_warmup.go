@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrMetadataProbeInProgress 表示目录已有进行中的元数据探测
+	ErrMetadataProbeInProgress = errors.New("metadata probe already in progress")
+	// ErrDirectoryUnavailable 表示目录不存在或已禁用
+	ErrDirectoryUnavailable = errors.New("directory not found or disabled")
+)
+
+// MetadataProbeResult 描述一次目录元数据探测的结果
+type MetadataProbeResult struct {
+	Directory  string    `json:"directory"`
+	Mode       string    `json:"mode"` // warmup, reprobe
+	Files      int       `json:"files"`
+	Cached     int       `json:"cached"` // 缓存仍然有效、无需探测的文件数
+	Probed     int       `json:"probed"`
+	Canceled   bool      `json:"canceled,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// MetadataWarmer 在启动后为视频目录预热元数据缓存，并按需强制重新探测整个目录。
+// 探测通过元数据服务的探测池进行，与扫描和缩略图共用同一并发上限
+type MetadataWarmer struct {
+	videoService *VideoService
+	ctx          context.Context
+	cancel       context.CancelFunc
+
+	mu       sync.Mutex
+	running  map[string]string // 正在探测的目录 -> 模式
+	lastRuns map[string]MetadataProbeResult
+}
+
+// NewMetadataWarmer 创建新的元数据预热器
+func NewMetadataWarmer(videoService *VideoService) *MetadataWarmer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MetadataWarmer{
+		videoService: videoService,
+		ctx:          ctx,
+		cancel:       cancel,
+		running:      make(map[string]string),
+		lastRuns:     make(map[string]MetadataProbeResult),
+	}
+}
+
+// Start 在后台预热所有启用目录
+func (mw *MetadataWarmer) Start() {
+	go mw.Warmup()
+}
+
+// Stop 取消进行中的预热和重新探测；正在运行的单个探测会完成
+func (mw *MetadataWarmer) Stop() {
+	mw.cancel()
+}
+
+// Warmup 依次探测所有启用目录中没有有效缓存的视频，然后移除已删除文件的缓存条目
+func (mw *MetadataWarmer) Warmup() {
+	start := time.Now()
+	total := MetadataProbeResult{Mode: "warmup", StartedAt: start}
+
+	for _, dir := range mw.videoService.config.Video.Directories {
+		if !dir.Enabled || mw.ctx.Err() != nil {
+			continue
+		}
+
+		result, err := mw.ProbeDirectory(dir.Name, false)
+		total.Files += result.Files
+		total.Cached += result.Cached
+		total.Probed += result.Probed
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logIndexerError("metadata_warmup", err, zap.String("directory", dir.Name))
+		}
+	}
+
+	pruned := 0
+	if cache := mw.videoService.metadataService.Cache(); cache != nil && mw.ctx.Err() == nil {
+		var err error
+		if pruned, err = cache.Prune(); err != nil {
+			logIndexerError("metadata_cache_prune", err)
+		}
+	}
+
+	if utils.Logger != nil {
+		utils.Logger.Info("Metadata warmup completed",
+			zap.Int("files", total.Files),
+			zap.Int("cached", total.Cached),
+			zap.Int("probed", total.Probed),
+			zap.Int("pruned", pruned),
+			zap.Bool("canceled", mw.ctx.Err() != nil),
+			zap.Int64("duration_ms", time.Since(start).Milliseconds()),
+		)
+	}
+}
+
+// ProbeDirectory 同步探测目录中的全部视频；force 为 true 时忽略缓存并更新索引中的元数据
+func (mw *MetadataWarmer) ProbeDirectory(directoryName string, force bool) (MetadataProbeResult, error) {
+	dir, err := mw.begin(directoryName, force)
+	if err != nil {
+		return MetadataProbeResult{Directory: directoryName}, err
+	}
+	defer mw.finish(directoryName)
+
+	return mw.probeDirectory(dir, force)
+}
+
+// Reprobe 在后台强制重新探测目录中的全部视频；目录已有探测在进行时返回 ErrMetadataProbeInProgress
+func (mw *MetadataWarmer) Reprobe(directoryName string) error {
+	dir, err := mw.begin(directoryName, true)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer mw.finish(directoryName)
+		if _, err := mw.probeDirectory(dir, true); err != nil && !errors.Is(err, context.Canceled) {
+			logIndexerError("metadata_reprobe", err, zap.String("directory", directoryName))
+		}
+	}()
+
+	return nil
+}
+
+// Status 返回进行中的探测、各目录最近一次探测的结果和缓存状态
+func (mw *MetadataWarmer) Status() map[string]interface{} {
+	mw.mu.Lock()
+	running := make([]string, 0, len(mw.running))
+	for name := range mw.running {
+		running = append(running, name)
+	}
+	lastRuns := make([]MetadataProbeResult, 0, len(mw.lastRuns))
+	for _, result := range mw.lastRuns {
+		lastRuns = append(lastRuns, result)
+	}
+	mw.mu.Unlock()
+
+	sort.Strings(running)
+	sort.Slice(lastRuns, func(i, j int) bool { return lastRuns[i].Directory < lastRuns[j].Directory })
+
+	metadataService := mw.videoService.metadataService
+	status := map[string]interface{}{
+		"running":   running,
+		"last_runs": lastRuns,
+		"workers":   metadataService.ProbeWorkers(),
+	}
+	if cache := metadataService.Cache(); cache != nil {
+		status["cache"] = cache.Stats()
+	}
+	return status
+}
+
+// begin 检查目录并将其标记为正在探测
+func (mw *MetadataWarmer) begin(directoryName string, force bool) (models.VideoDirectory, error) {
+	dir := mw.videoService.findDirectory(directoryName)
+	if dir == nil || !dir.Enabled {
+		return models.VideoDirectory{}, fmt.Errorf("%w: %s", ErrDirectoryUnavailable, directoryName)
+	}
+
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if _, ok := mw.running[directoryName]; ok {
+		return models.VideoDirectory{}, ErrMetadataProbeInProgress
+	}
+	mw.running[directoryName] = metadataProbeMode(force)
+	return *dir, nil
+}
+
+// finish 清除目录的探测标记
+func (mw *MetadataWarmer) finish(directoryName string) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	delete(mw.running, directoryName)
+}
+
+// probeDirectory 扫描目录并以探测池的并发数探测每个视频，调用方必须已通过 begin 标记目录
+func (mw *MetadataWarmer) probeDirectory(dir models.VideoDirectory, force bool) (MetadataProbeResult, error) {
+	result := MetadataProbeResult{Directory: dir.Name, Mode: metadataProbeMode(force), StartedAt: time.Now()}
+
+	videos, err := mw.videoService.scanDirectoryRecursive(dir.Path, dir.Name, "", 0, false)
+	if err != nil {
+		return result, err
+	}
+	result.Files = len(videos)
+
+	metadataService := mw.videoService.metadataService
+	processed := make([]bool, len(videos))
+	var countMu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for i := 0; i < metadataService.ProbeWorkers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				metadata, cached := metadataService.extract(videos[index].Path, force)
+				videos[index].Metadata = metadata
+				processed[index] = true
+
+				countMu.Lock()
+				if cached {
+					result.Cached++
+				} else {
+					result.Probed++
+				}
+				countMu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range videos {
+		select {
+		case jobs <- i:
+		case <-mw.ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 重新探测的结果写回索引，使列表和详情立即使用新的元数据
+	if catalog := mw.videoService.catalog; force && catalog != nil {
+		for i, video := range videos {
+			if !processed[i] {
+				continue
+			}
+			entry, err := catalog.Get(video.ID)
+			if err != nil || entry == nil || !isSameCatalogFile(*entry, video) {
+				continue
+			}
+			entry.Metadata = video.Metadata
+			if err := catalog.Put(*entry); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	result.Canceled = mw.ctx.Err() != nil
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+
+	mw.mu.Lock()
+	mw.lastRuns[dir.Name] = result
+	mw.mu.Unlock()
+
+	if result.Canceled {
+		return result, mw.ctx.Err()
+	}
+	return result, nil
+}
+
+// metadataProbeMode 返回探测模式的名称
+func metadataProbeMode(force bool) string {
+	if force {
+		return "reprobe"
+	}
+	return "warmup"
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// mp4Box 表示 ISO-BMFF 文件中的一个顶层 box
+type mp4Box struct {
+	Type   string
+	Offset int64
+	Size   int64
+}
+
+// parseMP4BoxHeader 解析 box 头部，remaining 是从 box 起点到数据末尾的长度；
+// 返回 box 类型、总大小和头部长度
+func parseMP4BoxHeader(header []byte, remaining int64) (string, int64, int64, error) {
+	if remaining < 8 || len(header) < 8 {
+		return "", 0, 0, fmt.Errorf("truncated box header")
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	boxType := string(header[4:8])
+	headerSize := int64(8)
+
+	switch size {
+	case 0:
+		// box 延伸到文件末尾
+		size = remaining
+	case 1:
+		if remaining < 16 || len(header) < 16 {
+			return "", 0, 0, fmt.Errorf("truncated largesize header for box %q", boxType)
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:16]))
+		headerSize = 16
+	}
+
+	if size < headerSize || size > remaining {
+		return "", 0, 0, fmt.Errorf("invalid size %d for box %q", size, boxType)
+	}
+	return boxType, size, headerSize, nil
+}
+
+// readMP4Boxes 解析缓冲区中的顶层 box
+func readMP4Boxes(data []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	offset := int64(0)
+	length := int64(len(data))
+
+	for offset < length {
+		boxType, size, _, err := parseMP4BoxHeader(data[offset:min(offset+16, length)], length-offset)
+		if err != nil {
+			return nil, fmt.Errorf("%w at offset %d", err, offset)
+		}
+
+		boxes = append(boxes, mp4Box{Type: boxType, Offset: offset, Size: size})
+		offset += size
+	}
+
+	return boxes, nil
+}
+
+// splitFragmentedMP4 将分片 MP4 拆分为初始化段（ftyp+moov）和媒体段（moof/mdat 等）
+func splitFragmentedMP4(data []byte) (initSegment, mediaSegment []byte, err error) {
+	boxes, err := readMP4Boxes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, box := range boxes {
+		content := data[box.Offset : box.Offset+box.Size]
+		switch box.Type {
+		case "ftyp", "moov":
+			initSegment = append(initSegment, content...)
+		case "mfra":
+			// 随机访问索引只对完整文件有意义
+		default:
+			mediaSegment = append(mediaSegment, content...)
+		}
+	}
+
+	if len(initSegment) == 0 {
+		return nil, nil, fmt.Errorf("fragmented mp4 has no moov box")
+	}
+
+	return initSegment, mediaSegment, nil
+}
+
+// mp4EntryHeaders 是子 box 之前的固定字段长度：stsd 的版本和条目数，avc1 的视频样本条目字段
+var mp4EntryHeaders = map[string]int{"stsd": 8, "avc1": 78}
+
+// mp4BoxPayloads 沿 path 逐层查找 box，返回所有匹配的最内层 box 的内容（不含头部）
+func mp4BoxPayloads(data []byte, path ...string) ([][]byte, error) {
+	boxes, err := readMP4Boxes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads [][]byte
+	for _, box := range boxes {
+		if box.Type != path[0] {
+			continue
+		}
+		content := data[box.Offset : box.Offset+box.Size]
+		_, _, headerSize, err := parseMP4BoxHeader(content, box.Size)
+		if err != nil {
+			return nil, err
+		}
+		payload := content[headerSize:]
+
+		if len(path) == 1 {
+			payloads = append(payloads, payload)
+			continue
+		}
+		skip := mp4EntryHeaders[box.Type]
+		if len(payload) < skip {
+			return nil, fmt.Errorf("truncated %q box", box.Type)
+		}
+		children, err := mp4BoxPayloads(payload[skip:], path[1:]...)
+		if err != nil {
+			return nil, fmt.Errorf("in %q box: %w", box.Type, err)
+		}
+		payloads = append(payloads, children...)
+	}
+
+	return payloads, nil
+}
+
+// avcDecoderConfigs 返回初始化段中 H.264 轨道的解码配置记录（avcC，包含 SPS/PPS）
+func avcDecoderConfigs(initSegment []byte) ([][]byte, error) {
+	return mp4BoxPayloads(initSegment, "moov", "trak", "mdia", "minf", "stbl", "stsd", "avc1", "avcC")
+}
@@ -0,0 +1,734 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// 本文件在没有 ffprobe 时直接解析容器头部：ISO-BMFF（MP4/MOV）、Matroska/WebM 和 AVI。
+// 格式名和编码名与 ffprobe 的输出保持一致（如 "mov,mp4,m4a,3gp,3g2,mj2"、"H264"），
+// 因此两种来源的元数据可以互换
+
+// ErrUnsupportedContainer 在文件不是可识别的容器格式时返回
+var ErrUnsupportedContainer = errors.New("unsupported container format")
+
+// 读取头部时的上限，防止损坏的文件导致过量读取
+const (
+	maxProbeMoovSize   = 64 << 20
+	maxProbeHeaderSize = 4 << 20
+)
+
+// ProbeContainer 解析视频文件的容器头部，提取时长、分辨率、编码、帧率和码率
+func ProbeContainer(path string) (VideoMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return VideoMetadata{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return VideoMetadata{}, err
+	}
+
+	magic := make([]byte, 12)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return VideoMetadata{}, fmt.Errorf("%w: %v", ErrUnsupportedContainer, err)
+	}
+
+	var metadata VideoMetadata
+	switch {
+	case bytes.Equal(magic[0:4], []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		metadata, err = probeMatroska(file, info.Size())
+	case string(magic[0:4]) == "RIFF" && string(magic[8:12]) == "AVI ":
+		metadata, err = probeAVI(file, info.Size())
+	case isMP4BoxType(string(magic[4:8])):
+		metadata, err = probeMP4(file, info.Size())
+	default:
+		return VideoMetadata{}, ErrUnsupportedContainer
+	}
+	if err != nil {
+		return VideoMetadata{}, err
+	}
+
+	if metadata.Bitrate == 0 && metadata.Duration > 0 {
+		metadata.Bitrate = int64(float64(info.Size()) * 8 / metadata.Duration)
+	}
+	return metadata, nil
+}
+
+// isMP4BoxType 检查文件开头的 box 类型是否属于 ISO-BMFF
+func isMP4BoxType(boxType string) bool {
+	switch boxType {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot":
+		return true
+	}
+	return false
+}
+
+// probeTrack 是从容器中读出的一条轨道
+type probeTrack struct {
+	kind      string // video 或 audio
+	codec     string
+	width     int
+	height    int
+	frameRate float64
+}
+
+// applyTracks 用第一条视频轨和第一条音频轨填充元数据
+func applyTracks(metadata *VideoMetadata, tracks []probeTrack) {
+	var video, audio *probeTrack
+	for i := range tracks {
+		switch {
+		case tracks[i].kind == "video" && video == nil:
+			video = &tracks[i]
+		case tracks[i].kind == "audio" && audio == nil:
+			audio = &tracks[i]
+		}
+	}
+
+	if video != nil {
+		metadata.Codec = video.codec
+		if video.width > 0 && video.height > 0 {
+			metadata.Resolution = fmt.Sprintf("%dx%d", video.width, video.height)
+		}
+		metadata.FrameRate = roundFrameRate(video.frameRate)
+	}
+	if audio != nil {
+		metadata.AudioCodec = audio.codec
+	}
+}
+
+// roundFrameRate 将帧率保留三位小数，如 29.97
+func roundFrameRate(fps float64) float64 {
+	if fps <= 0 || math.IsInf(fps, 0) || math.IsNaN(fps) {
+		return 0
+	}
+	return math.Round(fps*1000) / 1000
+}
+
+// ---- ISO-BMFF ----
+
+// mp4Codecs 将 sample entry 类型映射为 ffprobe 的编码名
+var mp4Codecs = map[string]string{
+	"avc1": "H264", "avc3": "H264",
+	"hvc1": "HEVC", "hev1": "HEVC",
+	"av01": "AV1",
+	"vp08": "VP8", "vp09": "VP9",
+	"mp4v": "MPEG4",
+	"mp4a": "AAC",
+	"ac-3": "AC3", "ec-3": "EAC3",
+	"Opus": "OPUS", "fLaC": "FLAC",
+	".mp3": "MP3",
+}
+
+// probeMP4 找到 moov box（可能位于 mdat 之后）并解析其中的时长和轨道
+func probeMP4(r io.ReaderAt, size int64) (VideoMetadata, error) {
+	header := make([]byte, 16)
+	for offset := int64(0); offset < size; {
+		n, err := r.ReadAt(header, offset)
+		if n < 8 {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return VideoMetadata{}, fmt.Errorf("failed to read box header at offset %d: %w", offset, err)
+		}
+		boxType, boxSize, headerSize, err := parseMP4BoxHeader(header[:n], size-offset)
+		if err != nil {
+			return VideoMetadata{}, fmt.Errorf("%w at offset %d", err, offset)
+		}
+
+		if boxType == "moov" {
+			if boxSize > maxProbeMoovSize {
+				return VideoMetadata{}, fmt.Errorf("moov box too large: %d bytes", boxSize)
+			}
+			moov := make([]byte, boxSize-headerSize)
+			if _, err := r.ReadAt(moov, offset+headerSize); err != nil {
+				return VideoMetadata{}, fmt.Errorf("failed to read moov box: %w", err)
+			}
+			return parseMP4Moov(moov)
+		}
+		offset += boxSize
+	}
+	return VideoMetadata{}, fmt.Errorf("no moov box found")
+}
+
+// mp4Children 返回 box 内容中的子 box，以类型为键（同类型只保留第一个），trak 单独返回
+func mp4Children(data []byte) (map[string][]byte, [][]byte) {
+	children := make(map[string][]byte)
+	var traks [][]byte
+	boxes, err := readMP4Boxes(data)
+	if err != nil {
+		return children, nil
+	}
+	for _, box := range boxes {
+		_, _, headerSize, _ := parseMP4BoxHeader(data[box.Offset:min(box.Offset+16, int64(len(data)))], box.Size)
+		content := data[box.Offset+headerSize : box.Offset+box.Size]
+		if box.Type == "trak" {
+			traks = append(traks, content)
+		}
+		if _, ok := children[box.Type]; !ok {
+			children[box.Type] = content
+		}
+	}
+	return children, traks
+}
+
+// parseMP4Moov 解析 moov box 的内容
+func parseMP4Moov(moov []byte) (VideoMetadata, error) {
+	children, traks := mp4Children(moov)
+	metadata := VideoMetadata{Format: "mov,mp4,m4a,3gp,3g2,mj2"}
+
+	timescale, duration, ok := parseMP4Duration(children["mvhd"])
+	if !ok {
+		return metadata, fmt.Errorf("missing or invalid mvhd box")
+	}
+	if duration == 0 {
+		// 分片 MP4 的总时长在 mvex/mehd 中
+		if mvex, _ := mp4Children(children["mvex"]); mvex["mehd"] != nil {
+			duration = parseMP4FullBoxUint(mvex["mehd"], 0)
+		}
+	}
+	if timescale > 0 {
+		metadata.Duration = float64(duration) / float64(timescale)
+	}
+
+	var tracks []probeTrack
+	for _, trak := range traks {
+		if track, ok := parseMP4Track(trak); ok {
+			tracks = append(tracks, track)
+		}
+	}
+	applyTracks(&metadata, tracks)
+	return metadata, nil
+}
+
+// parseMP4Duration 解析 mvhd 或 mdhd 的时间刻度和时长；两者在 creation/modification 时间之后布局相同
+func parseMP4Duration(box []byte) (uint32, uint64, bool) {
+	if len(box) < 4 {
+		return 0, 0, false
+	}
+	if box[0] == 1 {
+		if len(box) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(box[20:24]), binary.BigEndian.Uint64(box[24:32]), true
+	}
+	if len(box) < 20 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(box[12:16]), uint64(binary.BigEndian.Uint32(box[16:20])), true
+}
+
+// parseMP4FullBoxUint 读取 full box 版本字段之后第 index 个整数，版本 1 时为 64 位
+func parseMP4FullBoxUint(box []byte, index int) uint64 {
+	if len(box) < 4 {
+		return 0
+	}
+	if box[0] == 1 {
+		offset := 4 + index*8
+		if len(box) < offset+8 {
+			return 0
+		}
+		return binary.BigEndian.Uint64(box[offset : offset+8])
+	}
+	offset := 4 + index*4
+	if len(box) < offset+4 {
+		return 0
+	}
+	return uint64(binary.BigEndian.Uint32(box[offset : offset+4]))
+}
+
+// parseMP4Track 解析 trak box：hdlr 给出轨道类型，stsd 给出编码，tkhd 或 sample entry 给出尺寸，stts 给出帧率
+func parseMP4Track(trak []byte) (probeTrack, bool) {
+	trakChildren, _ := mp4Children(trak)
+	mdia, _ := mp4Children(trakChildren["mdia"])
+	minf, _ := mp4Children(mdia["minf"])
+	stbl, _ := mp4Children(minf["stbl"])
+
+	hdlr := mdia["hdlr"]
+	if len(hdlr) < 12 {
+		return probeTrack{}, false
+	}
+	var track probeTrack
+	switch string(hdlr[8:12]) {
+	case "vide":
+		track.kind = "video"
+	case "soun":
+		track.kind = "audio"
+	default:
+		return probeTrack{}, false
+	}
+
+	// stsd: full box 头部、条目数，然后是 sample entry box
+	stsd := stbl["stsd"]
+	var entry []byte
+	if len(stsd) >= 16 {
+		entryType, entrySize, _, err := parseMP4BoxHeader(stsd[8:min(24, len(stsd))], int64(len(stsd)-8))
+		if err == nil {
+			track.codec = mp4Codecs[entryType]
+			if track.codec == "" {
+				track.codec = strings.ToUpper(strings.TrimSpace(entryType))
+			}
+			entry = stsd[16 : 8+entrySize]
+		}
+	}
+
+	if track.kind == "video" {
+		// tkhd 末尾是 16.16 定点数的显示宽高
+		if tkhd := trakChildren["tkhd"]; len(tkhd) >= 8 {
+			track.width = int(binary.BigEndian.Uint32(tkhd[len(tkhd)-8:]) >> 16)
+			track.height = int(binary.BigEndian.Uint32(tkhd[len(tkhd)-4:]) >> 16)
+		}
+		if (track.width == 0 || track.height == 0) && len(entry) >= 28 {
+			track.width = int(binary.BigEndian.Uint16(entry[24:26]))
+			track.height = int(binary.BigEndian.Uint16(entry[26:28]))
+		}
+
+		timescale, duration, _ := parseMP4Duration(mdia["mdhd"])
+		if samples := mp4SampleCount(stbl["stts"]); samples > 0 && duration > 0 {
+			track.frameRate = float64(samples) * float64(timescale) / float64(duration)
+		}
+	}
+	return track, true
+}
+
+// mp4SampleCount 返回 stts 中的样本总数
+func mp4SampleCount(stts []byte) uint64 {
+	if len(stts) < 8 {
+		return 0
+	}
+	count := int(binary.BigEndian.Uint32(stts[4:8]))
+	var samples uint64
+	for i := 0; i < count && 8+i*8+8 <= len(stts); i++ {
+		samples += uint64(binary.BigEndian.Uint32(stts[8+i*8 : 12+i*8]))
+	}
+	return samples
+}
+
+// ---- Matroska / WebM ----
+
+// Matroska 元素 ID
+const (
+	ebmlIDHeader          = 0x1a45dfa3
+	ebmlIDDocType         = 0x4282
+	ebmlIDSegment         = 0x18538067
+	ebmlIDInfo            = 0x1549a966
+	ebmlIDTimecodeScale   = 0x2ad7b1
+	ebmlIDDuration        = 0x4489
+	ebmlIDTracks          = 0x1654ae6b
+	ebmlIDTrackEntry      = 0xae
+	ebmlIDTrackType       = 0x83
+	ebmlIDCodecID         = 0x86
+	ebmlIDDefaultDuration = 0x23e383
+	ebmlIDVideo           = 0xe0
+	ebmlIDPixelWidth      = 0xb0
+	ebmlIDPixelHeight     = 0xba
+	ebmlIDCluster         = 0x1f43b675
+)
+
+// ebmlUnknownSize 表示长度未知的元素（直播流写出的 Segment 和 Cluster）
+const ebmlUnknownSize = -1
+
+// matroskaCodecs 将 CodecID 映射为 ffprobe 的编码名
+var matroskaCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "H264",
+	"V_MPEGH/ISO/HEVC": "HEVC",
+	"V_VP8":            "VP8",
+	"V_VP9":            "VP9",
+	"V_AV1":            "AV1",
+	"V_MPEG4/ISO/ASP":  "MPEG4",
+	"A_AAC":            "AAC",
+	"A_OPUS":           "OPUS",
+	"A_VORBIS":         "VORBIS",
+	"A_MPEG/L3":        "MP3",
+	"A_AC3":            "AC3",
+	"A_EAC3":           "EAC3",
+	"A_FLAC":           "FLAC",
+}
+
+// ebmlElement 是一个 EBML 元素的头部
+type ebmlElement struct {
+	id         uint32
+	dataOffset int64
+	size       int64 // ebmlUnknownSize 表示长度未知
+}
+
+// readEBMLVint 读取一个变长整数；keepMarker 为 true 时保留长度标记位（用于元素 ID）
+func readEBMLVint(r io.ReaderAt, offset int64, keepMarker bool) (uint64, int, error) {
+	first := make([]byte, 1)
+	if _, err := r.ReadAt(first, offset); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, fmt.Errorf("invalid EBML variable-length integer at offset %d", offset)
+	}
+
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return 0, 0, err
+	}
+	if !keepMarker {
+		buf[0] &= 0xff >> length
+	}
+	var value uint64
+	for _, b := range buf {
+		value = value<<8 | uint64(b)
+	}
+	return value, length, nil
+}
+
+// readEBMLElement 读取 offset 处元素的 ID 和长度
+func readEBMLElement(r io.ReaderAt, offset int64) (ebmlElement, error) {
+	id, idLength, err := readEBMLVint(r, offset, true)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	size, sizeLength, err := readEBMLVint(r, offset+int64(idLength), false)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+
+	element := ebmlElement{id: uint32(id), dataOffset: offset + int64(idLength+sizeLength), size: int64(size)}
+	if size == 1<<(7*sizeLength)-1 {
+		element.size = ebmlUnknownSize
+	}
+	return element, nil
+}
+
+// readEBMLData 读取元素的数据
+func readEBMLData(r io.ReaderAt, element ebmlElement) ([]byte, error) {
+	if element.size < 0 || element.size > maxProbeHeaderSize {
+		return nil, fmt.Errorf("invalid size %d for EBML element %#x", element.size, element.id)
+	}
+	data := make([]byte, element.size)
+	if _, err := r.ReadAt(data, element.dataOffset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ebmlChildren 遍历缓冲区中的子元素
+func ebmlChildren(data []byte, visit func(id uint32, value []byte)) {
+	r := bytes.NewReader(data)
+	for offset := int64(0); offset < int64(len(data)); {
+		element, err := readEBMLElement(r, offset)
+		if err != nil || element.size < 0 || element.dataOffset+element.size > int64(len(data)) {
+			return
+		}
+		visit(element.id, data[element.dataOffset:element.dataOffset+element.size])
+		offset = element.dataOffset + element.size
+	}
+}
+
+// ebmlUint 解析无符号整数元素
+func ebmlUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// ebmlFloat 解析 4 或 8 字节的浮点数元素
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+// probeMatroska 解析 EBML 头部和 Segment 中的 Info、Tracks 元素，遇到第一个 Cluster 时停止
+func probeMatroska(r io.ReaderAt, size int64) (VideoMetadata, error) {
+	header, err := readEBMLElement(r, 0)
+	if err != nil || header.id != ebmlIDHeader {
+		return VideoMetadata{}, fmt.Errorf("invalid EBML header: %v", err)
+	}
+	headerData, err := readEBMLData(r, header)
+	if err != nil {
+		return VideoMetadata{}, err
+	}
+	docType := "matroska"
+	ebmlChildren(headerData, func(id uint32, value []byte) {
+		if id == ebmlIDDocType {
+			docType = string(bytes.TrimRight(value, "\x00"))
+		}
+	})
+	if docType != "matroska" && docType != "webm" {
+		return VideoMetadata{}, fmt.Errorf("%w: EBML document type %q", ErrUnsupportedContainer, docType)
+	}
+
+	segment, err := readEBMLElement(r, header.dataOffset+header.size)
+	if err != nil || segment.id != ebmlIDSegment {
+		return VideoMetadata{}, fmt.Errorf("missing matroska segment: %v", err)
+	}
+	end := size
+	if segment.size != ebmlUnknownSize && segment.dataOffset+segment.size < end {
+		end = segment.dataOffset + segment.size
+	}
+
+	metadata := VideoMetadata{Format: "matroska,webm"}
+	var tracks []probeTrack
+	foundInfo, foundTracks := false, false
+	for offset := segment.dataOffset; offset < end && !(foundInfo && foundTracks); {
+		element, err := readEBMLElement(r, offset)
+		if err != nil || element.id == ebmlIDCluster || element.size == ebmlUnknownSize {
+			break
+		}
+
+		switch element.id {
+		case ebmlIDInfo:
+			data, err := readEBMLData(r, element)
+			if err != nil {
+				return metadata, err
+			}
+			metadata.Duration = parseMatroskaInfo(data)
+			foundInfo = true
+		case ebmlIDTracks:
+			data, err := readEBMLData(r, element)
+			if err != nil {
+				return metadata, err
+			}
+			tracks = parseMatroskaTracks(data)
+			foundTracks = true
+		}
+		offset = element.dataOffset + element.size
+	}
+
+	if !foundInfo && !foundTracks {
+		return metadata, fmt.Errorf("no segment info or tracks found")
+	}
+	applyTracks(&metadata, tracks)
+	return metadata, nil
+}
+
+// parseMatroskaInfo 返回以秒为单位的时长；Duration 以 TimecodeScale 纳秒为单位
+func parseMatroskaInfo(info []byte) float64 {
+	timecodeScale := uint64(1000000)
+	var duration float64
+	ebmlChildren(info, func(id uint32, value []byte) {
+		switch id {
+		case ebmlIDTimecodeScale:
+			if scale := ebmlUint(value); scale > 0 {
+				timecodeScale = scale
+			}
+		case ebmlIDDuration:
+			duration = ebmlFloat(value)
+		}
+	})
+	return duration * float64(timecodeScale) / 1e9
+}
+
+// parseMatroskaTracks 解析 Tracks 中的轨道条目
+func parseMatroskaTracks(data []byte) []probeTrack {
+	var tracks []probeTrack
+	ebmlChildren(data, func(id uint32, entry []byte) {
+		if id != ebmlIDTrackEntry {
+			return
+		}
+		var track probeTrack
+		ebmlChildren(entry, func(id uint32, value []byte) {
+			switch id {
+			case ebmlIDTrackType:
+				switch ebmlUint(value) {
+				case 1:
+					track.kind = "video"
+				case 2:
+					track.kind = "audio"
+				}
+			case ebmlIDCodecID:
+				codecID := string(bytes.TrimRight(value, "\x00"))
+				track.codec = matroskaCodecs[codecID]
+				if track.codec == "" {
+					track.codec = codecID
+				}
+			case ebmlIDDefaultDuration:
+				if ns := ebmlUint(value); ns > 0 {
+					track.frameRate = 1e9 / float64(ns)
+				}
+			case ebmlIDVideo:
+				ebmlChildren(value, func(id uint32, value []byte) {
+					switch id {
+					case ebmlIDPixelWidth:
+						track.width = int(ebmlUint(value))
+					case ebmlIDPixelHeight:
+						track.height = int(ebmlUint(value))
+					}
+				})
+			}
+		})
+		if track.kind != "" {
+			tracks = append(tracks, track)
+		}
+	})
+	return tracks
+}
+
+// ---- AVI ----
+
+// aviVideoCodecs 将 BITMAPINFOHEADER 中的 FourCC 映射为 ffprobe 的编码名
+var aviVideoCodecs = map[string]string{
+	"H264": "H264", "X264": "H264", "AVC1": "H264",
+	"HEVC": "HEVC", "H265": "HEVC",
+	"XVID": "MPEG4", "DIVX": "MPEG4", "DX50": "MPEG4", "FMP4": "MPEG4", "MP4V": "MPEG4",
+	"MJPG": "MJPEG",
+}
+
+// aviAudioCodecs 将 WAVEFORMATEX 的格式标签映射为 ffprobe 的编码名
+var aviAudioCodecs = map[uint16]string{
+	0x0001: "PCM_S16LE",
+	0x0050: "MP2",
+	0x0055: "MP3",
+	0x00ff: "AAC",
+	0x1610: "AAC",
+	0x2000: "AC3",
+}
+
+// probeAVI 解析 RIFF 头部中的 hdrl 列表：avih 给出总帧数和尺寸，strh/strf 给出各流的编码和帧率
+func probeAVI(r io.ReaderAt, size int64) (VideoMetadata, error) {
+	// hdrl 是 RIFF AVI 中的第一个 LIST
+	listHeader := make([]byte, 12)
+	if _, err := r.ReadAt(listHeader, 12); err != nil {
+		return VideoMetadata{}, fmt.Errorf("failed to read AVI header list: %w", err)
+	}
+	listSize := int64(binary.LittleEndian.Uint32(listHeader[4:8]))
+	if string(listHeader[0:4]) != "LIST" || string(listHeader[8:12]) != "hdrl" || listSize < 4 || listSize > maxProbeHeaderSize || 20+listSize-4 > size {
+		return VideoMetadata{}, fmt.Errorf("missing AVI hdrl list")
+	}
+	hdrl := make([]byte, listSize-4)
+	if _, err := r.ReadAt(hdrl, 24); err != nil {
+		return VideoMetadata{}, fmt.Errorf("failed to read AVI hdrl list: %w", err)
+	}
+
+	metadata := VideoMetadata{Format: "avi"}
+	var tracks []probeTrack
+	var totalFrames, microSecPerFrame uint32
+	var videoWidth, videoHeight int
+	var streamDuration float64
+
+	riffChunks(hdrl, func(id string, data []byte) {
+		switch id {
+		case "avih":
+			if len(data) >= 40 {
+				microSecPerFrame = binary.LittleEndian.Uint32(data[0:4])
+				totalFrames = binary.LittleEndian.Uint32(data[16:20])
+				videoWidth = int(binary.LittleEndian.Uint32(data[32:36]))
+				videoHeight = int(binary.LittleEndian.Uint32(data[36:40]))
+			}
+		case "LIST:strl":
+			track, duration := parseAVIStream(data)
+			if track.kind == "video" {
+				if track.width == 0 {
+					track.width, track.height = videoWidth, videoHeight
+				}
+				if streamDuration == 0 {
+					streamDuration = duration
+				}
+			}
+			if track.kind != "" {
+				tracks = append(tracks, track)
+			}
+		}
+	})
+
+	metadata.Duration = streamDuration
+	if metadata.Duration == 0 && microSecPerFrame > 0 {
+		metadata.Duration = float64(totalFrames) * float64(microSecPerFrame) / 1e6
+	}
+	for i := range tracks {
+		if tracks[i].kind == "video" && tracks[i].frameRate == 0 && microSecPerFrame > 0 {
+			tracks[i].frameRate = 1e6 / float64(microSecPerFrame)
+		}
+	}
+	applyTracks(&metadata, tracks)
+	return metadata, nil
+}
+
+// parseAVIStream 解析 strl 列表，返回流信息和按 strh 计算的时长
+func parseAVIStream(strl []byte) (probeTrack, float64) {
+	var track probeTrack
+	var duration float64
+	riffChunks(strl, func(id string, data []byte) {
+		switch id {
+		case "strh":
+			if len(data) < 36 {
+				return
+			}
+			switch string(data[0:4]) {
+			case "vids":
+				track.kind = "video"
+			case "auds":
+				track.kind = "audio"
+			}
+			scale := binary.LittleEndian.Uint32(data[20:24])
+			rate := binary.LittleEndian.Uint32(data[24:28])
+			length := binary.LittleEndian.Uint32(data[32:36])
+			if scale > 0 && rate > 0 {
+				duration = float64(length) * float64(scale) / float64(rate)
+				if track.kind == "video" {
+					track.frameRate = float64(rate) / float64(scale)
+				}
+			}
+		case "strf":
+			switch track.kind {
+			case "video":
+				// BITMAPINFOHEADER
+				if len(data) >= 20 {
+					track.width = int(int32(binary.LittleEndian.Uint32(data[4:8])))
+					track.height = int(int32(binary.LittleEndian.Uint32(data[8:12])))
+					if track.height < 0 {
+						track.height = -track.height // 自上而下存储的位图
+					}
+					fourCC := strings.ToUpper(string(data[16:20]))
+					track.codec = aviVideoCodecs[fourCC]
+					if track.codec == "" {
+						track.codec = strings.TrimSpace(fourCC)
+					}
+				}
+			case "audio":
+				// WAVEFORMATEX
+				if len(data) >= 2 {
+					tag := binary.LittleEndian.Uint16(data[0:2])
+					track.codec = aviAudioCodecs[tag]
+					if track.codec == "" {
+						track.codec = fmt.Sprintf("0x%04X", tag)
+					}
+				}
+			}
+		}
+	})
+	return track, duration
+}
+
+// riffChunks 遍历 RIFF 子块；LIST 块以 "LIST:<类型>" 为 ID，数据不含列表类型
+func riffChunks(data []byte, visit func(id string, data []byte)) {
+	for offset := 0; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		start := offset + 8
+		if size < 0 || start+size > len(data) {
+			return
+		}
+		chunk := data[start : start+size]
+		if id == "LIST" && len(chunk) >= 4 {
+			visit("LIST:"+string(chunk[0:4]), chunk[4:])
+		} else {
+			visit(id, chunk)
+		}
+		// 块按 2 字节对齐
+		offset = start + size + size&1
+	}
+}
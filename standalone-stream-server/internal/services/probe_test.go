@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fixtures in testdata/probe are written by testdata/probe/generate.go
+func TestProbeContainer(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected VideoMetadata
+	}{
+		{"sample.mp4", VideoMetadata{Duration: 10, Resolution: "640x360", Codec: "H264", AudioCodec: "AAC", FrameRate: 30, Format: "mov,mp4,m4a,3gp,3g2,mj2"}},
+		{"sample.webm", VideoMetadata{Duration: 12.5, Resolution: "854x480", Codec: "VP9", AudioCodec: "OPUS", FrameRate: 23.976, Format: "matroska,webm"}},
+		{"sample.mkv", VideoMetadata{Duration: 10, Resolution: "1920x1080", Codec: "H264", AudioCodec: "AAC", FrameRate: 25, Format: "matroska,webm"}},
+		{"sample.avi", VideoMetadata{Duration: 5.005, Resolution: "320x240", Codec: "H264", AudioCodec: "MP3", FrameRate: 29.97, Format: "avi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", "probe", tt.file)
+			metadata, err := ProbeContainer(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			info, _ := os.Stat(path)
+			tt.expected.Bitrate = int64(float64(info.Size()) * 8 / metadata.Duration)
+			if math.Abs(metadata.Duration-tt.expected.Duration) > 0.001 {
+				t.Errorf("Expected duration %v, got %v", tt.expected.Duration, metadata.Duration)
+			}
+			metadata.Duration = tt.expected.Duration
+			if metadata != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, metadata)
+			}
+		})
+	}
+}
+
+func TestProbeContainerInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	text := filepath.Join(dir, "notes.txt")
+	os.WriteFile(text, []byte("this is not a video file"), 0o644)
+	if _, err := ProbeContainer(text); !errors.Is(err, ErrUnsupportedContainer) {
+		t.Errorf("Expected ErrUnsupportedContainer for a text file, got %v", err)
+	}
+
+	// Truncated fixtures fail instead of reporting partial metadata
+	for _, file := range []string{"sample.mp4", "sample.mkv", "sample.avi"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "probe", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		truncated := filepath.Join(dir, file)
+		os.WriteFile(truncated, data[:64], 0o644)
+		if metadata, err := ProbeContainer(truncated); err == nil {
+			t.Errorf("Expected an error for truncated %s, got %+v", file, metadata)
+		}
+	}
+
+	if _, err := ProbeContainer(filepath.Join(dir, "missing.mp4")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// renditionsDirName 是保存转码版本的隐藏目录，扫描和监听会跳过隐藏目录
+const renditionsDirName = ".renditions"
+
+// Rendition 表示转码生成的一个清晰度版本
+type Rendition struct {
+	Quality     string `json:"quality"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Path        string `json:"path"`
+	StreamURL   string `json:"stream_url"`
+}
+
+// RenditionDir 返回源视频的转码版本目录：<视频所在目录>/.renditions/<文件名>/
+func RenditionDir(sourcePath string) string {
+	return filepath.Join(filepath.Dir(sourcePath), renditionsDirName, filepath.Base(sourcePath))
+}
+
+// RenditionPath 返回指定清晰度的转码文件路径，仅音频版本使用 .m4a
+func RenditionPath(sourcePath, quality string, audioOnly bool) string {
+	ext := ".mp4"
+	if audioOnly {
+		ext = ".m4a"
+	}
+	return filepath.Join(RenditionDir(sourcePath), quality+ext)
+}
+
+// loadRenditions 从磁盘读取视频已有的转码版本，按文件大小从高到低排序
+func loadRenditions(video *VideoInfo) []Rendition {
+	entries, err := os.ReadDir(RenditionDir(video.Path))
+	if err != nil {
+		return nil
+	}
+
+	var renditions []Rendition
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		contentType := ""
+		switch ext {
+		case ".mp4":
+			contentType = "video/mp4"
+		case ".m4a":
+			contentType = "audio/mp4"
+		default:
+			// 跳过转码中的临时文件
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		quality := strings.TrimSuffix(name, ext)
+		renditions = append(renditions, Rendition{
+			Quality:     quality,
+			Size:        info.Size(),
+			ContentType: contentType,
+			Path:        filepath.Join(RenditionDir(video.Path), name),
+			StreamURL:   video.StreamURL + "?quality=" + url.QueryEscape(quality),
+		})
+	}
+
+	sort.Slice(renditions, func(i, j int) bool {
+		return renditions[i].Size > renditions[j].Size
+	})
+
+	return renditions
+}
+
+// Rendition 返回指定清晰度的转码版本
+func (v *VideoInfo) Rendition(quality string) (*Rendition, bool) {
+	for i := range v.Renditions {
+		if v.Renditions[i].Quality == quality {
+			return &v.Renditions[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// 可续传上传的错误
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadExceedsLength  = errors.New("upload exceeds declared length")
+	ErrUploadLocked         = errors.New("upload is being written by another request")
+	ErrUploadExists         = errors.New("target file already exists")
+)
+
+// ResumableUpload 描述一个可续传（tus）上传的状态
+type ResumableUpload struct {
+	ID        string            `json:"id"`
+	Directory string            `json:"directory"`
+	VideoID   string            `json:"video_id"`
+	Filename  string            `json:"filename"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Completed bool              `json:"completed"`
+	Path      string            `json:"path,omitempty"` // 完成后的目标文件路径
+	Hash      string            `json:"sha256,omitempty"`
+	HashState []byte            `json:"hash_state,omitempty"` // 已接收数据的 SHA-256 中间状态
+}
+
+// ResumableUploadStore 在磁盘上保存未完成的上传：<id>.info 为状态，<id>.bin 为已接收的数据
+type ResumableUploadStore struct {
+	config       *models.Config
+	videoService *VideoService
+	dir          string
+	expiration   time.Duration
+	mu           sync.Mutex
+	busy         map[string]bool
+}
+
+// NewResumableUploadStore 创建新的可续传上传存储
+func NewResumableUploadStore(config *models.Config, videoService *VideoService) (*ResumableUploadStore, error) {
+	dir := config.Video.ResumableUpload.Dir
+	if dir == "" {
+		dir = filepath.Join(".", "data", "uploads")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	expiration := config.Video.ResumableUpload.Expiration
+	if expiration <= 0 {
+		expiration = 24 * time.Hour
+	}
+
+	return &ResumableUploadStore{
+		config:       config,
+		videoService: videoService,
+		dir:          dir,
+		expiration:   expiration,
+		busy:         make(map[string]bool),
+	}, nil
+}
+
+// Create 校验目标目录、扩展名和大小后创建新的上传
+func (rs *ResumableUploadStore) Create(directory, filename, videoID string, length int64, metadata map[string]string) (*ResumableUpload, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid upload length: %d", length)
+	}
+	if err := rs.videoService.SaveUploadedVideo(directory, filename, length); err != nil {
+		return nil, err
+	}
+
+	if videoID == "" {
+		videoID = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if videoID == "" || videoID != filepath.Base(videoID) || strings.HasPrefix(videoID, ".") {
+		return nil, fmt.Errorf("invalid video ID: %q", videoID)
+	}
+
+	targetPath, err := rs.targetPath(directory, filename, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(targetPath); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUploadExists, filepath.Base(targetPath))
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upload := &ResumableUpload{
+		ID:        id,
+		Directory: directory,
+		VideoID:   videoID,
+		Filename:  filepath.Base(filename),
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: now,
+		ExpiresAt: now.Add(rs.expiration),
+	}
+
+	if err := os.WriteFile(rs.dataPath(id), nil, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	if err := rs.save(upload); err != nil {
+		os.Remove(rs.dataPath(id))
+		return nil, err
+	}
+
+	// 空文件在创建时即完成
+	if length == 0 {
+		if err := rs.finalize(upload); err != nil {
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// Get 返回上传状态
+func (rs *ResumableUploadStore) Get(id string) (*ResumableUpload, error) {
+	if !isValidUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(rs.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload state: %w", err)
+	}
+
+	var upload ResumableUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, fmt.Errorf("failed to decode upload state: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// Append 在指定偏移处追加数据块；接收到全部数据后把文件移动到目标视频目录
+func (rs *ResumableUploadStore) Append(id string, offset int64, body io.Reader) (*ResumableUpload, error) {
+	if err := rs.lock(id); err != nil {
+		return nil, err
+	}
+	defer rs.unlock(id)
+
+	upload, err := rs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Completed || offset != upload.Offset {
+		return upload, ErrUploadOffsetMismatch
+	}
+
+	file, err := os.OpenFile(rs.dataPath(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload file: %w", err)
+	}
+
+	// 以磁盘上的实际长度为准，丢弃上次中断时未记录的尾部数据
+	if err := file.Truncate(upload.Offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to prepare upload file: %w", err)
+	}
+	if _, err := file.Seek(upload.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to prepare upload file: %w", err)
+	}
+
+	// 从上次保存的中间状态继续计算哈希，避免完成时重新读取整个文件
+	hasher := resumeHash(upload)
+
+	// 多读一个字节以检测超出声明长度的数据
+	remaining := upload.Length - upload.Offset
+	var dst io.Writer = file
+	if hasher != nil {
+		dst = io.MultiWriter(file, hasher)
+	}
+	written, copyErr := io.Copy(dst, io.LimitReader(body, remaining+1))
+	if written > remaining {
+		file.Truncate(upload.Offset)
+		file.Close()
+		return upload, ErrUploadExceedsLength
+	}
+	if err := file.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	// 即使连接中断，也保存已接收的数据，客户端可以从新的偏移继续；
+	// 中断时无法确认哈希与磁盘数据一致，丢弃中间状态，完成时重新计算
+	upload.Offset += written
+	upload.HashState = nil
+	if hasher != nil && copyErr == nil {
+		if state, err := hasher.(encoding.BinaryMarshaler).MarshalBinary(); err == nil {
+			upload.HashState = state
+		}
+	}
+	upload.ExpiresAt = time.Now().Add(rs.expiration)
+	if err := rs.save(upload); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		return upload, fmt.Errorf("upload interrupted at offset %d: %w", upload.Offset, copyErr)
+	}
+
+	if upload.Offset == upload.Length {
+		if err := rs.finalize(upload); err != nil {
+			return upload, err
+		}
+	}
+
+	return upload, nil
+}
+
+// Terminate 删除上传及其数据
+func (rs *ResumableUploadStore) Terminate(id string) error {
+	if err := rs.lock(id); err != nil {
+		return err
+	}
+	defer rs.unlock(id)
+
+	if _, err := rs.Get(id); err != nil {
+		return err
+	}
+
+	os.Remove(rs.dataPath(id))
+	if err := os.Remove(rs.infoPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
+	return nil
+}
+
+// ExpireStale 删除已过期的上传，返回删除的数量
+func (rs *ResumableUploadStore) ExpireStale() (int, error) {
+	entries, err := os.ReadDir(rs.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read upload directory: %w", err)
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".info" {
+			continue
+		}
+
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		upload, err := rs.Get(id)
+		if err != nil || !now.After(upload.ExpiresAt) {
+			continue
+		}
+
+		if err := rs.Terminate(id); err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// finalize 按目标目录的去重策略把已完成的上传提交到视频目录；
+// 重复内容被拒绝时上传随之删除
+func (rs *ResumableUploadStore) finalize(upload *ResumableUpload) error {
+	targetPath, err := rs.targetPath(upload.Directory, upload.Filename, upload.VideoID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+	if _, err := os.Stat(targetPath); err == nil {
+		return fmt.Errorf("%w: %s", ErrUploadExists, filepath.Base(targetPath))
+	}
+
+	hash, err := rs.uploadHash(upload)
+	if err != nil {
+		return err
+	}
+
+	stored, err := rs.videoService.CommitUpload(upload.Directory, rs.dataPath(upload.ID), targetPath, hash)
+	if errors.Is(err, ErrDuplicateContent) {
+		os.Remove(rs.infoPath(upload.ID))
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move completed upload: %w", err)
+	}
+
+	upload.Completed = true
+	upload.Path = stored.Path
+	upload.Hash = stored.Hash
+	upload.HashState = nil
+	return rs.save(upload)
+}
+
+// uploadHash 返回已接收数据的 SHA-256，没有可用的中间状态时重新读取数据文件
+func (rs *ResumableUploadStore) uploadHash(upload *ResumableUpload) (string, error) {
+	if hasher := resumeHash(upload); hasher != nil {
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	hash, err := hashFile(rs.dataPath(upload.ID))
+	if err != nil {
+		return "", fmt.Errorf("failed to hash completed upload: %w", err)
+	}
+	return hash, nil
+}
+
+// resumeHash 恢复上传的哈希中间状态；偏移为 0 时返回新的哈希，状态缺失或无效时返回 nil
+func resumeHash(upload *ResumableUpload) hash.Hash {
+	hasher := sha256.New()
+	if upload.Offset == 0 {
+		return hasher
+	}
+	if len(upload.HashState) == 0 {
+		return nil
+	}
+	if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(upload.HashState); err != nil {
+		return nil
+	}
+	return hasher
+}
+
+// targetPath 返回上传完成后视频文件的路径
+func (rs *ResumableUploadStore) targetPath(directory, filename, videoID string) (string, error) {
+	dir := rs.videoService.findDirectory(directory)
+	if dir == nil || !dir.Enabled {
+		return "", fmt.Errorf("directory not found or disabled: %s", directory)
+	}
+	return filepath.Join(dir.Path, videoID+strings.ToLower(filepath.Ext(filename))), nil
+}
+
+// save 原子地写入上传状态
+func (rs *ResumableUploadStore) save(upload *ResumableUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload state: %w", err)
+	}
+
+	tmpPath := rs.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write upload state: %w", err)
+	}
+	if err := os.Rename(tmpPath, rs.infoPath(upload.ID)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write upload state: %w", err)
+	}
+	return nil
+}
+
+// lock 防止同一上传被并发写入
+func (rs *ResumableUploadStore) lock(id string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.busy[id] {
+		return ErrUploadLocked
+	}
+	rs.busy[id] = true
+	return nil
+}
+
+func (rs *ResumableUploadStore) unlock(id string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.busy, id)
+}
+
+func (rs *ResumableUploadStore) infoPath(id string) string {
+	return filepath.Join(rs.dir, id+".info")
+}
+
+func (rs *ResumableUploadStore) dataPath(id string) string {
+	return filepath.Join(rs.dir, id+".bin")
+}
+
+// newUploadID 生成随机上传 ID
+func newUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isValidUploadID 检查 ID 是否为 newUploadID 生成的格式，避免路径穿越
+func isValidUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// moveFile 移动文件，跨文件系统时回退为复制
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
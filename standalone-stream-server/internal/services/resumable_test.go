@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newResumableTestStore(t *testing.T) (*ResumableUploadStore, string) {
+	t.Helper()
+
+	videoDir := t.TempDir()
+	config := &models.Config{
+		Video: models.VideoConfig{
+			Directories:      []models.VideoDirectory{{Name: "test", Path: videoDir, Enabled: true}},
+			MaxUploadSize:    1024,
+			SupportedFormats: []string{".mp4"},
+			ResumableUpload: models.ResumableUploadConfig{
+				Dir:        t.TempDir(),
+				Expiration: time.Hour,
+			},
+		},
+	}
+
+	store, err := NewResumableUploadStore(config, NewVideoService(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, videoDir
+}
+
+func TestResumableUploadStore_AppendAndFinalize(t *testing.T) {
+	store, videoDir := newResumableTestStore(t)
+
+	upload, err := store.Create("test", "Camera.MP4", "", 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.VideoID != "Camera" {
+		t.Errorf("Expected video ID derived from filename, got %q", upload.VideoID)
+	}
+
+	if _, err := store.Append(upload.ID, 0, strings.NewReader("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Append(upload.ID, 0, strings.NewReader("abcd")); !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Errorf("Expected ErrUploadOffsetMismatch, got %v", err)
+	}
+	if _, err := store.Append(upload.ID, 4, strings.NewReader("efghij")); !errors.Is(err, ErrUploadExceedsLength) {
+		t.Errorf("Expected ErrUploadExceedsLength, got %v", err)
+	}
+
+	// 超长的数据块被丢弃，偏移保持不变
+	current, err := store.Get(upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Offset != 4 {
+		t.Errorf("Expected offset 4 after rejected chunk, got %d", current.Offset)
+	}
+
+	completed, err := store.Append(upload.ID, 4, strings.NewReader("efgh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !completed.Completed || completed.Path != filepath.Join(videoDir, "Camera.mp4") {
+		t.Errorf("Unexpected completed upload: %+v", completed)
+	}
+	if data, _ := os.ReadFile(completed.Path); string(data) != "abcdefgh" {
+		t.Errorf("Unexpected file content: %q", data)
+	}
+
+	// 哈希在各数据块之间增量计算
+	if sum := sha256.Sum256([]byte("abcdefgh")); completed.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected content hash: %q", completed.Hash)
+	}
+
+	// 目标文件已存在时拒绝创建
+	if _, err := store.Create("test", "Camera.mp4", "", 8, nil); !errors.Is(err, ErrUploadExists) {
+		t.Errorf("Expected ErrUploadExists, got %v", err)
+	}
+}
+
+func TestResumableUploadStore_Validation(t *testing.T) {
+	store, _ := newResumableTestStore(t)
+
+	if _, err := store.Create("test", "clip.txt", "", 8, nil); err == nil {
+		t.Error("Expected error for unsupported extension")
+	}
+	if _, err := store.Create("test", "clip.mp4", "", 4096, nil); err == nil {
+		t.Error("Expected error for size above limit")
+	}
+	if _, err := store.Create("missing", "clip.mp4", "", 8, nil); err == nil {
+		t.Error("Expected error for unknown directory")
+	}
+	if _, err := store.Create("test", "clip.mp4", "../escape", 8, nil); err == nil {
+		t.Error("Expected error for video ID containing a path")
+	}
+	if _, err := store.Get("../../etc/passwd"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound for invalid ID, got %v", err)
+	}
+}
+
+func TestResumableUploadStore_ExpireStale(t *testing.T) {
+	store, _ := newResumableTestStore(t)
+
+	stale, err := store.Create("test", "stale.mp4", "", 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := store.Create("test", "active.mp4", "", 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := store.save(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.ExpireStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 expired upload, got %d", removed)
+	}
+	if _, err := store.Get(stale.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Error("Expired upload should be removed")
+	}
+	if _, err := store.Get(active.ID); err != nil {
+		t.Errorf("Active upload should remain: %v", err)
+	}
+}
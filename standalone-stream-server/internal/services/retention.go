@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// 保留规则选中视频的原因
+const (
+	RetentionReasonMaxAge       = "max_age"
+	RetentionReasonKeepLast     = "keep_last"
+	RetentionReasonMaxTotalSize = "max_total_size"
+)
+
+// RetentionCandidate 是保留规则选中、待删除的视频
+type RetentionCandidate struct {
+	VideoID    string `json:"video_id"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Modified   int64  `json:"modified"`
+	LastAccess int64  `json:"last_access,omitempty"` // 最近一次播放时间，仅记录本次启动以来的播放
+	Reason     string `json:"reason"`                // max_age, keep_last, max_total_size
+}
+
+// RetentionPlan 是按目录保留规则计算出的删除计划
+type RetentionPlan struct {
+	Directory  string                 `json:"directory"`
+	Policy     models.RetentionPolicy `json:"policy"`
+	Videos     int                    `json:"videos"`
+	TotalSize  int64                  `json:"total_size"`
+	SizeAfter  int64                  `json:"size_after"` // 删除全部候选视频后的目录总大小
+	Excluded   int                    `json:"excluded"`
+	Candidates []RetentionCandidate   `json:"candidates"`
+}
+
+// HasRetentionPolicy 判断保留规则是否会删除任何视频
+func HasRetentionPolicy(policy models.RetentionPolicy) bool {
+	return policy.MaxAge > 0 || policy.MaxTotalSize > 0 || policy.KeepLast > 0
+}
+
+// AccessTracker 在内存中记录视频最近一次被播放的时间，供 lru 淘汰顺序使用
+type AccessTracker struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewAccessTracker 创建空的播放记录
+func NewAccessTracker() *AccessTracker {
+	return &AccessTracker{last: make(map[string]time.Time)}
+}
+
+// Record 记录视频在 at 时刻被播放
+func (tracker *AccessTracker) Record(videoID string, at time.Time) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if at.After(tracker.last[videoID]) {
+		tracker.last[videoID] = at
+	}
+}
+
+// LastAccess 返回视频最近一次被播放的时间，未播放过时返回零值
+func (tracker *AccessTracker) LastAccess(videoID string) time.Time {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.last[videoID]
+}
+
+// RecordAccess 记录视频被播放，用于按最近播放时间淘汰
+func (vs *VideoService) RecordAccess(videoID string) {
+	vs.access.Record(videoID, time.Now())
+}
+
+// PlanRetention 按目录的保留规则计算删除计划，不会删除任何文件
+func (vs *VideoService) PlanRetention(directoryName string, now time.Time) (RetentionPlan, error) {
+	dir := vs.findDirectory(directoryName)
+	if dir == nil {
+		return RetentionPlan{}, fmt.Errorf("directory not found: %s", directoryName)
+	}
+
+	videos, err := vs.ListVideosInDirectory(directoryName)
+	if err != nil {
+		return RetentionPlan{}, err
+	}
+	return planRetention(*dir, videos, now, vs.access.LastAccess), nil
+}
+
+// planRetention 依次应用 max_age、keep_last 和 max_total_size 规则；
+// 被 exclude 匹配的视频计入目录总大小，但永远不会被选中
+func planRetention(dir models.VideoDirectory, videos []VideoInfo, now time.Time, lastAccess func(string) time.Time) RetentionPlan {
+	policy := dir.Retention
+	plan := RetentionPlan{
+		Directory:  dir.Name,
+		Policy:     policy,
+		Videos:     len(videos),
+		Candidates: []RetentionCandidate{},
+	}
+
+	var eligible []VideoInfo
+	for _, video := range videos {
+		plan.TotalSize += video.Size
+		if isRetentionExcluded(policy.Exclude, dir, video) {
+			plan.Excluded++
+			continue
+		}
+		eligible = append(eligible, video)
+	}
+
+	selected := make(map[string]bool)
+	selectVideo := func(video VideoInfo, reason string) {
+		if selected[video.ID] {
+			return
+		}
+		selected[video.ID] = true
+		candidate := RetentionCandidate{
+			VideoID:  video.ID,
+			Path:     video.Path,
+			Size:     video.Size,
+			Modified: video.Modified,
+			Reason:   reason,
+		}
+		if accessed := lastAccess(video.ID); !accessed.IsZero() {
+			candidate.LastAccess = accessed.Unix()
+		}
+		plan.Candidates = append(plan.Candidates, candidate)
+	}
+
+	// 最新的视频排在前面，相同修改时间按 ID 排序以保证结果稳定
+	sort.Slice(eligible, func(i, j int) bool {
+		if eligible[i].Modified != eligible[j].Modified {
+			return eligible[i].Modified > eligible[j].Modified
+		}
+		return eligible[i].ID < eligible[j].ID
+	})
+
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge).Unix()
+		for _, video := range eligible {
+			if video.Modified < cutoff {
+				selectVideo(video, RetentionReasonMaxAge)
+			}
+		}
+	}
+
+	if policy.KeepLast > 0 {
+		kept := make(map[string]int)
+		for _, video := range eligible {
+			folder := path.Dir(relativeVideoPath(dir, video))
+			if kept[folder] < policy.KeepLast {
+				kept[folder]++
+				continue
+			}
+			selectVideo(video, RetentionReasonKeepLast)
+		}
+	}
+
+	size := plan.TotalSize
+	for _, candidate := range plan.Candidates {
+		size -= candidate.Size
+	}
+
+	if policy.MaxTotalSize > 0 && size > policy.MaxTotalSize {
+		// 按淘汰顺序排列：最早修改（oldest）或最久未播放（lru，未播放过的视频按修改时间计）
+		order := make([]VideoInfo, 0, len(eligible))
+		for _, video := range eligible {
+			if !selected[video.ID] {
+				order = append(order, video)
+			}
+		}
+		lastUsed := func(video VideoInfo) int64 {
+			used := video.Modified
+			if policy.Eviction == "lru" {
+				if accessed := lastAccess(video.ID); accessed.Unix() > used {
+					used = accessed.Unix()
+				}
+			}
+			return used
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return lastUsed(order[i]) < lastUsed(order[j])
+		})
+
+		for _, video := range order {
+			if size <= policy.MaxTotalSize {
+				break
+			}
+			selectVideo(video, RetentionReasonMaxTotalSize)
+			size -= video.Size
+		}
+	}
+
+	plan.SizeAfter = size
+	return plan
+}
+
+// isRetentionExcluded 判断视频的相对路径或文件名是否匹配任一 exclude 模式
+func isRetentionExcluded(patterns []string, dir models.VideoDirectory, video VideoInfo) bool {
+	relative := relativeVideoPath(dir, video)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, relative); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, video.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// relativeVideoPath 返回视频文件在所属目录中的相对路径（含扩展名）
+func relativeVideoPath(dir models.VideoDirectory, video VideoInfo) string {
+	if relative, err := filepath.Rel(dir.Path, video.Path); err == nil && !strings.HasPrefix(relative, "..") {
+		return filepath.ToSlash(relative)
+	}
+	return strings.TrimPrefix(video.ID, dir.Name+":") + video.Extension
+}
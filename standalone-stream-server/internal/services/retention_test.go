@@ -0,0 +1,140 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// retentionVideo builds a video of the "movies" directory modified daysAgo days before now
+func retentionVideo(relativePath string, size int64, now time.Time, daysAgo int) VideoInfo {
+	ext := filepath.Ext(relativePath)
+	return VideoInfo{
+		ID:        "movies:" + relativePath[:len(relativePath)-len(ext)],
+		Name:      filepath.Base(relativePath),
+		Size:      size,
+		Modified:  now.AddDate(0, 0, -daysAgo).Unix(),
+		Directory: "movies",
+		Path:      filepath.Join("/videos/movies", relativePath),
+		Extension: ext,
+	}
+}
+
+func candidateReasons(plan RetentionPlan) map[string]string {
+	reasons := make(map[string]string, len(plan.Candidates))
+	for _, candidate := range plan.Candidates {
+		reasons[candidate.VideoID] = candidate.Reason
+	}
+	return reasons
+}
+
+func TestPlanRetention_Rules(t *testing.T) {
+	now := time.Now()
+	noAccess := func(string) time.Time { return time.Time{} }
+	videos := []VideoInfo{
+		retentionVideo("ancient.mp4", 100, now, 120),
+		retentionVideo("favorites/classic.mp4", 100, now, 400),
+		retentionVideo("show/e1.mp4", 100, now, 30),
+		retentionVideo("show/e2.mp4", 100, now, 20),
+		retentionVideo("show/e3.mp4", 100, now, 10),
+		retentionVideo("new.mp4", 100, now, 1),
+	}
+	dir := models.VideoDirectory{
+		Name: "movies",
+		Path: "/videos/movies",
+		Retention: models.RetentionPolicy{
+			MaxAge:   90 * 24 * time.Hour,
+			KeepLast: 2,
+			Exclude:  []string{"favorites/*"},
+		},
+	}
+
+	plan := planRetention(dir, videos, now, noAccess)
+	expected := map[string]string{
+		"movies:ancient": RetentionReasonMaxAge,
+		"movies:show/e1": RetentionReasonKeepLast,
+	}
+	if reasons := candidateReasons(plan); len(reasons) != len(expected) {
+		t.Fatalf("Expected candidates %v, got %v", expected, reasons)
+	} else {
+		for id, reason := range expected {
+			if reasons[id] != reason {
+				t.Errorf("Expected %s to be selected by %s, got %q", id, reason, reasons[id])
+			}
+		}
+	}
+	if plan.Excluded != 1 || plan.TotalSize != 600 || plan.SizeAfter != 400 {
+		t.Errorf("Unexpected plan totals: %+v", plan)
+	}
+}
+
+func TestPlanRetention_MaxTotalSize(t *testing.T) {
+	now := time.Now()
+	videos := []VideoInfo{
+		retentionVideo("a.mp4", 400, now, 30),
+		retentionVideo("b.mp4", 300, now, 20),
+		retentionVideo("c.mp4", 200, now, 10),
+		retentionVideo("keep.mp4", 500, now, 40),
+	}
+	dir := models.VideoDirectory{
+		Name:      "movies",
+		Path:      "/videos/movies",
+		Retention: models.RetentionPolicy{MaxTotalSize: 900, Exclude: []string{"keep.*"}},
+	}
+
+	// Oldest first: excluded videos count toward the total but are never evicted
+	plan := planRetention(dir, videos, now, func(string) time.Time { return time.Time{} })
+	if reasons := candidateReasons(plan); len(reasons) != 2 || reasons["movies:a"] == "" || reasons["movies:b"] == "" {
+		t.Errorf("Expected the two oldest videos to be evicted, got %v", reasons)
+	}
+	if plan.SizeAfter != 700 {
+		t.Errorf("Expected 700 bytes to remain, got %d", plan.SizeAfter)
+	}
+
+	// LRU: a recently streamed old video survives, an unplayed newer one goes first
+	dir.Retention.Eviction = "lru"
+	streamed := func(videoID string) time.Time {
+		if videoID == "movies:a" {
+			return now
+		}
+		return time.Time{}
+	}
+	plan = planRetention(dir, videos, now, streamed)
+	reasons := candidateReasons(plan)
+	if len(reasons) != 2 || reasons["movies:b"] != RetentionReasonMaxTotalSize || reasons["movies:c"] != RetentionReasonMaxTotalSize {
+		t.Errorf("Expected the least recently used videos to be evicted, got %v", reasons)
+	}
+}
+
+func TestVideoService_PlanRetention(t *testing.T) {
+	videoDir := t.TempDir()
+	for _, name := range []string{"old.mp4", "recent.mp4"} {
+		if err := os.WriteFile(filepath.Join(videoDir, name), []byte("video"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aYearAgo := time.Now().AddDate(-1, 0, 0)
+	os.Chtimes(filepath.Join(videoDir, "old.mp4"), aYearAgo, aYearAgo)
+
+	config := &models.Config{}
+	config.Video.SupportedFormats = []string{".mp4"}
+	config.Video.Directories = []models.VideoDirectory{
+		{Name: "movies", Path: videoDir, Enabled: true, Retention: models.RetentionPolicy{MaxAge: 30 * 24 * time.Hour}},
+	}
+	vs := NewVideoService(config)
+	vs.RecordAccess("movies:old")
+
+	plan, err := vs.PlanRetention("movies", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Candidates) != 1 || plan.Candidates[0].Path != filepath.Join(videoDir, "old.mp4") || plan.Candidates[0].LastAccess == 0 {
+		t.Errorf("Expected only the old video to be selected, got %+v", plan.Candidates)
+	}
+	if _, err := vs.PlanRetention("missing", time.Now()); err == nil {
+		t.Error("Expected unknown directory to fail")
+	}
+}
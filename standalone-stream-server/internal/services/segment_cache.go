@@ -0,0 +1,161 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SegmentCache 是按需生成的媒体片段在磁盘上的缓存，超过容量时按最近访问时间淘汰
+type SegmentCache struct {
+	dir     string
+	maxSize int64
+
+	mu       sync.Mutex
+	inflight map[string]*segmentCall
+	evictMu  sync.Mutex
+	hits     int64
+	misses   int64
+	evicted  int64
+}
+
+// segmentCall 表示一次正在进行的片段生成，同一 key 的并发请求共享结果
+type segmentCall struct {
+	done chan struct{}
+	err  error
+}
+
+// SegmentGenerator 将片段写入给定的临时路径
+type SegmentGenerator func(tmpPath string) error
+
+// NewSegmentCache 创建新的片段缓存；maxSize <= 0 表示不限制大小
+func NewSegmentCache(dir string, maxSize int64) *SegmentCache {
+	return &SegmentCache{
+		dir:      dir,
+		maxSize:  maxSize,
+		inflight: make(map[string]*segmentCall),
+	}
+}
+
+// Get 返回 key 对应的缓存文件路径，不存在时调用 generate 生成
+func (sc *SegmentCache) Get(key string, generate SegmentGenerator) (string, error) {
+	path := filepath.Join(sc.dir, filepath.FromSlash(key))
+
+	sc.mu.Lock()
+	if _, err := os.Stat(path); err == nil {
+		sc.hits++
+		sc.mu.Unlock()
+		// 更新访问时间，供 LRU 淘汰使用
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return path, nil
+	}
+
+	if call, exists := sc.inflight[key]; exists {
+		sc.mu.Unlock()
+		<-call.done
+		return path, call.err
+	}
+
+	call := &segmentCall{done: make(chan struct{})}
+	sc.inflight[key] = call
+	sc.misses++
+	sc.mu.Unlock()
+
+	call.err = sc.generate(path, generate)
+
+	sc.mu.Lock()
+	delete(sc.inflight, key)
+	sc.mu.Unlock()
+	close(call.done)
+
+	if call.err == nil {
+		go sc.Evict()
+	}
+
+	return path, call.err
+}
+
+// generate 先写入临时文件再原子重命名，避免读取到生成一半的片段
+func (sc *SegmentCache) generate(path string, generate SegmentGenerator) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create segment cache directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := generate(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to store segment: %w", err)
+	}
+
+	return nil
+}
+
+// Evict 删除最久未访问的文件，直到缓存总大小不超过上限
+func (sc *SegmentCache) Evict() {
+	if sc.maxSize <= 0 || !sc.evictMu.TryLock() {
+		return
+	}
+	defer sc.evictMu.Unlock()
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []cachedFile
+	var total int64
+	filepath.Walk(sc.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) == ".tmp" {
+			return nil
+		}
+		files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+
+	if total <= sc.maxSize {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, file := range files {
+		if total <= sc.maxSize {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			continue
+		}
+		total -= file.size
+		sc.mu.Lock()
+		sc.evicted++
+		sc.mu.Unlock()
+	}
+}
+
+// GetStats 返回缓存统计信息
+func (sc *SegmentCache) GetStats() map[string]interface{} {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return map[string]interface{}{
+		"directory":  sc.dir,
+		"max_size":   sc.maxSize,
+		"hits":       sc.hits,
+		"misses":     sc.misses,
+		"evicted":    sc.evicted,
+		"generating": len(sc.inflight),
+	}
+}
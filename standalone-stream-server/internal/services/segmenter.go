@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/utils"
+)
+
+// segmentEncodeTimeout 是生成单个片段的 ffmpeg 超时时间
+const segmentEncodeTimeout = 2 * time.Minute
+
+// 片段编码进程池的默认值
+const (
+	defaultSegmentWorkers      = 2
+	defaultSegmentQueueTimeout = 5 * time.Second
+)
+
+// ErrSegmentOutOfRange 表示请求的片段序号超出视频范围
+var ErrSegmentOutOfRange = errors.New("segment index out of range")
+
+// ErrSegmenterBusy 在等待空闲编码进程超时时返回
+var ErrSegmenterBusy = errors.New("all segment encoders are busy")
+
+// ErrCodecMismatch 表示单独编码的媒体段与共用的初始化段 SPS/PPS 不一致，不能一起播放
+var ErrCodecMismatch = errors.New("segment decoder configuration differs from the init segment")
+
+// SegmentSpan 描述一个片段在源视频中的时间范围（秒）
+type SegmentSpan struct {
+	Index    int
+	Start    float64
+	Duration float64
+}
+
+// Segmenter 使用本地 ffmpeg 按需切分视频片段，并缓存在磁盘上，供 HLS 和 DASH 共用。
+// 同时运行的 ffmpeg 进程数受 workers 限制
+type Segmenter struct {
+	config          *models.Config
+	cache           *SegmentCache
+	segmentDuration float64
+	workers         chan struct{}
+	queueTimeout    time.Duration
+}
+
+// NewSegmenter 根据打包配置创建新的切片器
+func NewSegmenter(config *models.Config) *Segmenter {
+	segmentDuration := config.Video.Packaging.SegmentDuration.Seconds()
+	if segmentDuration <= 0 {
+		segmentDuration = 6
+	}
+	workers := config.Video.Packaging.Workers
+	if workers <= 0 {
+		workers = defaultSegmentWorkers
+	}
+	queueTimeout := config.Video.Packaging.QueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = defaultSegmentQueueTimeout
+	}
+
+	return &Segmenter{
+		config:          config,
+		cache:           NewSegmentCache(config.Video.Packaging.CacheDir, config.Video.Packaging.CacheMaxSize),
+		segmentDuration: segmentDuration,
+		workers:         make(chan struct{}, workers),
+		queueTimeout:    queueTimeout,
+	}
+}
+
+// SegmentDuration 返回目标片段时长（秒）
+func (s *Segmenter) SegmentDuration() float64 {
+	return s.segmentDuration
+}
+
+// Cache 返回底层片段缓存
+func (s *Segmenter) Cache() *SegmentCache {
+	return s.cache
+}
+
+// Segments 根据视频时长计算所有片段的时间范围
+func (s *Segmenter) Segments(video *VideoInfo) ([]SegmentSpan, error) {
+	duration := video.Metadata.Duration
+	if duration <= 0 {
+		return nil, fmt.Errorf("video duration unknown, cannot segment: %s", video.ID)
+	}
+
+	count := int(math.Ceil(duration / s.segmentDuration))
+	spans := make([]SegmentSpan, 0, count)
+	for i := 0; i < count; i++ {
+		start := float64(i) * s.segmentDuration
+		spans = append(spans, SegmentSpan{
+			Index:    i,
+			Start:    start,
+			Duration: math.Min(s.segmentDuration, duration-start),
+		})
+	}
+
+	return spans, nil
+}
+
+// TSSegment 返回 MPEG-TS 片段的缓存路径，必要时生成
+func (s *Segmenter) TSSegment(video *VideoInfo, index int) (string, error) {
+	span, err := s.span(video, index)
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s/ts/segment_%05d.ts", videoCacheKey(video), index)
+	return s.cache.Get(key, func(tmpPath string) error {
+		args := s.encodeArgs(video.Path, span)
+		args = append(args, "-muxdelay", "0", "-f", "mpegts", "-y", tmpPath)
+		return s.runFFmpeg(args)
+	})
+}
+
+// FMP4Init 返回 fMP4 初始化段（ftyp+moov）的缓存路径，必要时取自第一个片段的编码结果。
+// 初始化段总是某个媒体段同一次编码产生的 moov，其他媒体段生成时会核对解码配置
+func (s *Segmenter) FMP4Init(video *VideoInfo) (string, error) {
+	span, err := s.span(video, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return s.cache.Get(fmp4InitKey(video), func(tmpPath string) error {
+		initSegment, _, err := s.encodeFragmented(video, span)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(tmpPath, initSegment, 0o644)
+	})
+}
+
+// FMP4Segment 返回 fMP4 媒体段（moof+mdat）的缓存路径，必要时生成。
+// 还没有初始化段时使用本次编码的 moov；已有时要求本次编码的 SPS/PPS 与之相同，
+// 否则返回 ErrCodecMismatch，避免播放器用不匹配的参数集解码
+func (s *Segmenter) FMP4Segment(video *VideoInfo, index int) (string, error) {
+	span, err := s.span(video, index)
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s/fmp4/segment_%05d.m4s", videoCacheKey(video), index)
+	return s.cache.Get(key, func(tmpPath string) error {
+		initSegment, mediaSegment, err := s.encodeFragmented(video, span)
+		if err != nil {
+			return err
+		}
+
+		initPath, err := s.cache.Get(fmp4InitKey(video), func(initTmpPath string) error {
+			return os.WriteFile(initTmpPath, initSegment, 0o644)
+		})
+		if err != nil {
+			return err
+		}
+		shared, err := os.ReadFile(initPath)
+		if err != nil {
+			return fmt.Errorf("failed to read init segment: %w", err)
+		}
+		if err := matchDecoderConfig(shared, initSegment); err != nil {
+			return fmt.Errorf("segment %d: %w", index, err)
+		}
+
+		return os.WriteFile(tmpPath, mediaSegment, 0o644)
+	})
+}
+
+// fmp4InitKey 返回 fMP4 初始化段的缓存 key
+func fmp4InitKey(video *VideoInfo) string {
+	return fmt.Sprintf("%s/fmp4/init.mp4", videoCacheKey(video))
+}
+
+// matchDecoderConfig 检查两个初始化段的 H.264 解码配置（SPS/PPS）是否相同
+func matchDecoderConfig(shared, own []byte) error {
+	sharedConfigs, err := avcDecoderConfigs(shared)
+	if err != nil {
+		return fmt.Errorf("invalid init segment: %w", err)
+	}
+	ownConfigs, err := avcDecoderConfigs(own)
+	if err != nil {
+		return fmt.Errorf("invalid encoded segment: %w", err)
+	}
+
+	if len(sharedConfigs) != len(ownConfigs) {
+		return ErrCodecMismatch
+	}
+	for i := range sharedConfigs {
+		if !bytes.Equal(sharedConfigs[i], ownConfigs[i]) {
+			return ErrCodecMismatch
+		}
+	}
+	return nil
+}
+
+// span 返回指定序号的片段范围
+func (s *Segmenter) span(video *VideoInfo, index int) (SegmentSpan, error) {
+	spans, err := s.Segments(video)
+	if err != nil {
+		return SegmentSpan{}, err
+	}
+	if index < 0 || index >= len(spans) {
+		return SegmentSpan{}, fmt.Errorf("%w: %d", ErrSegmentOutOfRange, index)
+	}
+	return spans[index], nil
+}
+
+// encodeFragmented 编码一段分片 MP4 并拆分为初始化段和媒体段
+func (s *Segmenter) encodeFragmented(video *VideoInfo, span SegmentSpan) ([]byte, []byte, error) {
+	tmpFile, err := os.CreateTemp("", "segment-*.mp4")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	args := s.encodeArgs(video.Path, span)
+	args = append(args,
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof+frag_discont",
+		"-f", "mp4", "-y", tmpFile.Name(),
+	)
+	if err := s.runFFmpeg(args); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(tmpFile.Name())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read encoded segment: %w", err)
+	}
+
+	return splitFragmentedMP4(data)
+}
+
+// encodeArgs 返回把源视频一段时间范围编码为 H.264/AAC 的 ffmpeg 参数；
+// 输出时间戳偏移到片段起点，使独立生成的片段可以连续播放
+func (s *Segmenter) encodeArgs(sourcePath string, span SegmentSpan) []string {
+	start := strconv.FormatFloat(span.Start, 'f', 3, 64)
+	return []string{
+		"-hide_banner", "-loglevel", "error",
+		"-ss", start,
+		"-i", sourcePath,
+		"-t", strconv.FormatFloat(span.Duration, 'f', 3, 64),
+		"-map", "0:v:0?", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+		"-force_key_frames", "expr:eq(n,0)",
+		"-c:a", "aac", "-ac", "2", "-b:a", "128k",
+		"-output_ts_offset", start,
+	}
+}
+
+// runFFmpeg 在编码进程池中以超时运行 ffmpeg；queueTimeout 内没有空闲进程时返回 ErrSegmenterBusy
+func (s *Segmenter) runFFmpeg(args []string) error {
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+	select {
+	case s.workers <- struct{}{}:
+	case <-timer.C:
+		return fmt.Errorf("%w: waited %s", ErrSegmenterBusy, s.queueTimeout)
+	}
+	utils.UpdateSegmentEncodersBusy(len(s.workers))
+	defer func() {
+		<-s.workers
+		utils.UpdateSegmentEncodersBusy(len(s.workers))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), segmentEncodeTimeout)
+	defer cancel()
+
+	ffmpegPath := s.config.Video.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	output, err := exec.CommandContext(ctx, ffmpegPath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg segment encoding failed: %w: %s", err, output)
+	}
+	return nil
+}
+
+// videoCacheKey 返回随视频内容变化的缓存目录名
+func videoCacheKey(video *VideoInfo) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d", video.ID, video.Size, video.Modified)))
+	return hex.EncodeToString(sum[:10])
+}
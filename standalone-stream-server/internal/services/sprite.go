@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// SpriteDir 是预览图集和 WebVTT 轨道的缓存目录，位于缩略图目录下
+var SpriteDir = filepath.Join(ThumbnailDir, "sprites")
+
+// spriteEncodeTimeout 是生成一个图集的最长时间（含等待缩略图工作线程的时间）
+const spriteEncodeTimeout = 10 * time.Minute
+
+// spriteSourceNote 是 WebVTT 中记录源文件版本的 NOTE 前缀，源文件变化后图集失效
+const spriteSourceNote = "NOTE source "
+
+// 图集布局的默认值，配置未设置时使用
+var defaultSpriteConfig = models.SpriteConfig{Frames: 100, Columns: 10, Width: 160, Height: 90}
+
+// ErrSpritesDisabled 在预览图集未启用时返回
+var ErrSpritesDisabled = errors.New("sprite sheets are disabled")
+
+// SpriteLayout 描述图集中各帧的排列和对应的时间
+type SpriteLayout struct {
+	Frames   int     `json:"frames"`
+	Columns  int     `json:"columns"`
+	Rows     int     `json:"rows"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	Interval float64 `json:"interval"` // 相邻两帧的间隔（秒）
+	Duration float64 `json:"duration"`
+}
+
+// SpriteImagePath 返回视频预览图集的路径
+func SpriteImagePath(videoID string) string {
+	return spritePath(videoID, ".jpg")
+}
+
+// SpriteVTTPath 返回视频 WebVTT 缩略图轨道的路径
+func SpriteVTTPath(videoID string) string {
+	return spritePath(videoID, ".vtt")
+}
+
+func spritePath(videoID, ext string) string {
+	directory, filename, _ := strings.Cut(videoID, ":")
+	return filepath.Join(SpriteDir, fmt.Sprintf("%s_%s%s", directory, filename, ext))
+}
+
+// NewSpriteLayout 按配置计算时长为 duration 秒的视频的图集布局；短视频的帧数不超过其秒数
+func NewSpriteLayout(config models.SpriteConfig, duration float64) SpriteLayout {
+	if config.Frames <= 0 {
+		config.Frames = defaultSpriteConfig.Frames
+	}
+	if config.Columns <= 0 {
+		config.Columns = defaultSpriteConfig.Columns
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		config.Width, config.Height = defaultSpriteConfig.Width, defaultSpriteConfig.Height
+	}
+
+	frames := config.Frames
+	if seconds := int(math.Ceil(duration)); seconds < frames {
+		frames = seconds
+	}
+	if frames < 1 {
+		frames = 1
+	}
+	columns := config.Columns
+	if columns > frames {
+		columns = frames
+	}
+
+	return SpriteLayout{
+		Frames:   frames,
+		Columns:  columns,
+		Rows:     (frames + columns - 1) / columns,
+		Width:    config.Width,
+		Height:   config.Height,
+		Interval: duration / float64(frames),
+		Duration: duration,
+	}
+}
+
+// BuildSpriteVTT 生成 WebVTT 缩略图轨道：每个时间段对应图集中的一帧（#xywh= 片段）
+func BuildSpriteVTT(layout SpriteLayout, imageURL string, source string) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	if source != "" {
+		b.WriteString(spriteSourceNote + source + "\n\n")
+	}
+
+	for i := 0; i < layout.Frames; i++ {
+		start := float64(i) * layout.Interval
+		end := start + layout.Interval
+		if i == layout.Frames-1 || end > layout.Duration {
+			end = layout.Duration
+		}
+		x := (i % layout.Columns) * layout.Width
+		y := (i / layout.Columns) * layout.Height
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n", i+1, formatVTTTime(start), formatVTTTime(end), imageURL, x, y, layout.Width, layout.Height)
+	}
+	return b.String()
+}
+
+// formatVTTTime 将秒数格式化为 WebVTT 时间戳 hh:mm:ss.mmm
+func formatVTTTime(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// spriteSource 返回源文件版本，文件大小或修改时间变化后已有图集失效
+func spriteSource(video VideoInfo) string {
+	return fmt.Sprintf("%d %d", video.Size, video.Modified)
+}
+
+// IsSpriteFresh 检查视频的图集和轨道是否存在且由当前版本的源文件生成
+func IsSpriteFresh(video VideoInfo) bool {
+	if _, err := os.Stat(SpriteImagePath(video.ID)); err != nil {
+		return false
+	}
+
+	file, err := os.Open(SpriteVTTPath(video.ID))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// NOTE 行紧跟在 WEBVTT 头之后
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < 3 && scanner.Scan(); i++ {
+		if source, ok := strings.CutPrefix(scanner.Text(), spriteSourceNote); ok {
+			return source == spriteSource(video)
+		}
+	}
+	return false
+}
+
+// EnsureSprite 在图集缺失或源文件变化时重新生成，返回是否生成了新的图集；同一视频的并发请求只生成一次
+func (ms *MetadataService) EnsureSprite(video VideoInfo) (bool, error) {
+	if !ms.config.Video.Sprites.Enabled {
+		return false, ErrSpritesDisabled
+	}
+	paths := []string{SpriteImagePath(video.ID), SpriteVTTPath(video.ID)}
+	return ms.thumbnails.Ensure(paths, spriteEncodeTimeout, func() bool { return IsSpriteFresh(video) }, func(ctx context.Context) error {
+		return ms.GenerateSprite(ctx, video)
+	})
+}
+
+// GenerateSprite 用 ffmpeg 截取均匀分布的帧拼成图集，并写入对应的 WebVTT 轨道；
+// 两个文件都先写入临时文件再重命名，轨道最后写入，因此不会读到不一致的图集
+func (ms *MetadataService) GenerateSprite(ctx context.Context, video VideoInfo) error {
+	metadata, err := ms.ExtractMetadata(video.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read duration of %s: %w", video.ID, err)
+	}
+	if metadata.Duration <= 0 {
+		return fmt.Errorf("unknown duration for %s", video.ID)
+	}
+	layout := NewSpriteLayout(ms.config.Video.Sprites, metadata.Duration)
+
+	imagePath := SpriteImagePath(video.ID)
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create sprite directory: %w", err)
+	}
+
+	tmpImage := fmt.Sprintf("%s.%d.tmp.jpg", imagePath, time.Now().UnixNano())
+	defer os.Remove(tmpImage)
+	if err := ms.runSpriteFFmpeg(ctx, spriteArgs(video.Path, tmpImage, layout)); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpImage, imagePath); err != nil {
+		return fmt.Errorf("failed to store sprite sheet: %w", err)
+	}
+
+	vttPath := SpriteVTTPath(video.ID)
+	tmpVTT := fmt.Sprintf("%s.%d.tmp", vttPath, time.Now().UnixNano())
+	// 轨道与图集位于同一 URL 目录下，使用相对地址引用图集
+	vtt := BuildSpriteVTT(layout, "sprite.jpg", spriteSource(video))
+	if err := os.WriteFile(tmpVTT, []byte(vtt), 0o644); err != nil {
+		return fmt.Errorf("failed to write sprite track: %w", err)
+	}
+	if err := os.Rename(tmpVTT, vttPath); err != nil {
+		os.Remove(tmpVTT)
+		return fmt.Errorf("failed to store sprite track: %w", err)
+	}
+	return nil
+}
+
+// spriteArgs 构建生成图集的 ffmpeg 参数：按间隔取帧，缩放并补边到固定尺寸后拼接
+func spriteArgs(videoPath, outputPath string, layout SpriteLayout) []string {
+	filter := fmt.Sprintf(
+		"fps=1/%.6f,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,tile=%dx%d",
+		layout.Interval, layout.Width, layout.Height, layout.Width, layout.Height, layout.Columns, layout.Rows,
+	)
+	return []string{
+		"-v", "error",
+		"-i", videoPath,
+		"-vf", filter,
+		"-frames:v", "1",
+		"-q:v", "5",
+		"-y",
+		outputPath,
+	}
+}
+
+// runSpriteFFmpeg 运行 ffmpeg，ctx 结束时终止
+func (ms *MetadataService) runSpriteFFmpeg(ctx context.Context, args []string) error {
+	ffmpegPath := ms.config.Video.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	output, err := exec.CommandContext(ctx, ffmpegPath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg sprite generation failed: %w: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"standalone-stream-server/internal/models"
+)
+
+func TestNewSpriteLayout(t *testing.T) {
+	config := models.SpriteConfig{Frames: 100, Columns: 10, Width: 160, Height: 90}
+
+	layout := NewSpriteLayout(config, 600)
+	if layout.Frames != 100 || layout.Columns != 10 || layout.Rows != 10 || layout.Interval != 6 {
+		t.Errorf("Unexpected layout for a long video: %+v", layout)
+	}
+
+	// Short videos get at most one frame per second
+	layout = NewSpriteLayout(config, 4.5)
+	if layout.Frames != 5 || layout.Columns != 5 || layout.Rows != 1 || layout.Interval != 0.9 {
+		t.Errorf("Unexpected layout for a short video: %+v", layout)
+	}
+
+	layout = NewSpriteLayout(models.SpriteConfig{Frames: 25, Columns: 10, Width: 160, Height: 90}, 100)
+	if layout.Rows != 3 {
+		t.Errorf("Expected a partial last row, got %d rows", layout.Rows)
+	}
+}
+
+func TestBuildSpriteVTT(t *testing.T) {
+	layout := NewSpriteLayout(models.SpriteConfig{Frames: 4, Columns: 2, Width: 160, Height: 90}, 10)
+	vtt := BuildSpriteVTT(layout, "sprite.jpg", "5 100")
+
+	if !strings.HasPrefix(vtt, "WEBVTT\n\nNOTE source 5 100\n\n") {
+		t.Errorf("Expected the header and source note, got %q", vtt)
+	}
+	for _, cue := range []string{
+		"1\n00:00:00.000 --> 00:00:02.500\nsprite.jpg#xywh=0,0,160,90\n",
+		"2\n00:00:02.500 --> 00:00:05.000\nsprite.jpg#xywh=160,0,160,90\n",
+		"4\n00:00:07.500 --> 00:00:10.000\nsprite.jpg#xywh=160,90,160,90\n",
+	} {
+		if !strings.Contains(vtt, cue) {
+			t.Errorf("Expected cue %q in %q", cue, vtt)
+		}
+	}
+
+	if got := formatVTTTime(3725.5); got != "01:02:05.500" {
+		t.Errorf("Expected 01:02:05.500, got %s", got)
+	}
+}
+
+func TestIsSpriteFresh(t *testing.T) {
+	original := SpriteDir
+	SpriteDir = t.TempDir()
+	defer func() { SpriteDir = original }()
+
+	video := VideoInfo{ID: "movies:movie", Size: 5, Modified: 100}
+	if IsSpriteFresh(video) {
+		t.Fatal("Expected a missing sprite not to be fresh")
+	}
+
+	layout := NewSpriteLayout(models.SpriteConfig{Frames: 2, Columns: 2, Width: 160, Height: 90}, 10)
+	os.WriteFile(SpriteImagePath(video.ID), []byte("jpeg"), 0o644)
+	os.WriteFile(SpriteVTTPath(video.ID), []byte(BuildSpriteVTT(layout, "sprite.jpg", spriteSource(video))), 0o644)
+	if filepath.Base(SpriteVTTPath(video.ID)) != "movies_movie.vtt" {
+		t.Errorf("Unexpected track path %s", SpriteVTTPath(video.ID))
+	}
+	if !IsSpriteFresh(video) {
+		t.Error("Expected the sprite to be fresh")
+	}
+
+	// A modified source invalidates the sprite
+	video.Modified = 200
+	if IsSpriteFresh(video) {
+		t.Error("Expected the sprite to be stale after the source changed")
+	}
+
+	ms := NewMetadataService(&models.Config{})
+	if _, err := ms.EnsureSprite(video); !errors.Is(err, ErrSpritesDisabled) {
+		t.Errorf("Expected ErrSpritesDisabled, got %v", err)
+	}
+}
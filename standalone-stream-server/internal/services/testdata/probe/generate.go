@@ -0,0 +1,208 @@
+//go:build ignore
+
+// generate writes the container fixtures used by probe_test.go. The files hold
+// only the headers the prober reads plus a little padding in place of media
+// data, so they stay small enough to commit.
+//
+//	go run generate.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"os"
+)
+
+func main() {
+	fixtures := map[string][]byte{
+		"sample.mp4":  buildMP4(),
+		"sample.webm": buildWebM(),
+		"sample.mkv":  buildMKV(),
+		"sample.avi":  buildAVI(),
+	}
+	for name, data := range fixtures {
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+func join(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+// ---- ISO-BMFF ----
+
+func box(boxType string, parts ...[]byte) []byte {
+	content := join(parts...)
+	return join(u32(uint32(8+len(content))), []byte(boxType), content)
+}
+
+func fullBox(boxType string, version byte, flags uint32, parts ...[]byte) []byte {
+	return box(boxType, append([]byte{version}, u32(flags)[1:]...), join(parts...))
+}
+
+var identityMatrix = join(u32(0x10000), u32(0), u32(0), u32(0), u32(0x10000), u32(0), u32(0), u32(0), u32(0x40000000))
+
+func trak(id uint32, width, height uint16, handler string, timescale, duration uint32, entry, stts []byte) []byte {
+	tkhd := fullBox("tkhd", 0, 3,
+		u32(0), u32(0), u32(id), u32(0), u32(duration/timescale*1000),
+		make([]byte, 8), u16(0), u16(0), u16(0x0100), u16(0), identityMatrix,
+		u32(uint32(width)<<16), u32(uint32(height)<<16))
+	mdhd := fullBox("mdhd", 0, 0, u32(0), u32(0), u32(timescale), u32(duration), u16(0x55c4), u16(0))
+	hdlr := fullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 12), []byte("Handler\x00"))
+	stsd := fullBox("stsd", 0, 0, u32(1), entry)
+	stbl := box("stbl", stsd, stts)
+	return box("trak", tkhd, box("mdia", mdhd, hdlr, box("minf", stbl)))
+}
+
+// buildMP4 writes a 10s file with mdat ahead of moov: H.264 640x360 at 30fps and AAC audio.
+func buildMP4() []byte {
+	ftyp := box("ftyp", []byte("isom"), u32(512), []byte("isomiso2avc1mp41"))
+	mdat := box("mdat", make([]byte, 4096))
+
+	mvhd := fullBox("mvhd", 0, 0,
+		u32(0), u32(0), u32(1000), u32(10000), u32(0x10000), u16(0x0100), make([]byte, 10),
+		identityMatrix, make([]byte, 24), u32(3))
+
+	avc1 := box("avc1", make([]byte, 6), u16(1), make([]byte, 16),
+		u16(640), u16(360), u32(0x480000), u32(0x480000), u32(0), u16(1), make([]byte, 32), u16(24), u16(0xffff))
+	videoSTTS := fullBox("stts", 0, 0, u32(1), u32(300), u32(512))
+	video := trak(1, 640, 360, "vide", 15360, 153600, avc1, videoSTTS)
+
+	mp4a := box("mp4a", make([]byte, 6), u16(1), make([]byte, 8), u16(2), u16(16), u16(0), u16(0), u32(48000<<16))
+	audioSTTS := fullBox("stts", 0, 0, u32(1), u32(469), u32(1024))
+	audio := trak(2, 0, 0, "soun", 48000, 480000, mp4a, audioSTTS)
+
+	return join(ftyp, mdat, box("moov", mvhd, video, audio))
+}
+
+// ---- Matroska ----
+
+func ebmlSize(size int) []byte {
+	switch {
+	case size < 0x7f:
+		return []byte{0x80 | byte(size)}
+	case size < 0x3fff:
+		return []byte{0x40 | byte(size>>8), byte(size)}
+	default:
+		return append([]byte{0x10}, u32(uint32(size))[1:]...)
+	}
+}
+
+func element(id []byte, parts ...[]byte) []byte {
+	content := join(parts...)
+	return join(id, ebmlSize(len(content)), content)
+}
+
+func ebmlUint(v uint64) []byte {
+	data := binary.BigEndian.AppendUint64(nil, v)
+	for len(data) > 1 && data[0] == 0 {
+		data = data[1:]
+	}
+	return data
+}
+
+var (
+	idEBML            = []byte{0x1a, 0x45, 0xdf, 0xa3}
+	idDocType         = []byte{0x42, 0x82}
+	idSegment         = []byte{0x18, 0x53, 0x80, 0x67}
+	idInfo            = []byte{0x15, 0x49, 0xa9, 0x66}
+	idTimecodeScale   = []byte{0x2a, 0xd7, 0xb1}
+	idDuration        = []byte{0x44, 0x89}
+	idTracks          = []byte{0x16, 0x54, 0xae, 0x6b}
+	idTrackEntry      = []byte{0xae}
+	idTrackNumber     = []byte{0xd7}
+	idTrackType       = []byte{0x83}
+	idCodecID         = []byte{0x86}
+	idDefaultDuration = []byte{0x23, 0xe3, 0x83}
+	idVideo           = []byte{0xe0}
+	idPixelWidth      = []byte{0xb0}
+	idPixelHeight     = []byte{0xba}
+	idAudio           = []byte{0xe1}
+	idSamplingRate    = []byte{0xb5}
+	idCluster         = []byte{0x1f, 0x43, 0xb6, 0x75}
+	unknownSize       = []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+func videoTrack(number uint64, codec string, width, height, defaultDuration uint64) []byte {
+	return element(idTrackEntry,
+		element(idTrackNumber, ebmlUint(number)),
+		element(idTrackType, ebmlUint(1)),
+		element(idCodecID, []byte(codec)),
+		element(idDefaultDuration, ebmlUint(defaultDuration)),
+		element(idVideo, element(idPixelWidth, ebmlUint(width)), element(idPixelHeight, ebmlUint(height))))
+}
+
+func audioTrack(number uint64, codec string) []byte {
+	return element(idTrackEntry,
+		element(idTrackNumber, ebmlUint(number)),
+		element(idTrackType, ebmlUint(2)),
+		element(idCodecID, []byte(codec)),
+		element(idAudio, element(idSamplingRate, binary.BigEndian.AppendUint64(nil, math.Float64bits(48000)))))
+}
+
+// buildWebM writes a live-style file whose Segment and Cluster have unknown sizes:
+// 12.5s, VP9 854x480 at 23.976fps and Opus audio.
+func buildWebM() []byte {
+	header := element(idEBML, element(idDocType, []byte("webm")))
+	info := element(idInfo,
+		element(idTimecodeScale, ebmlUint(1000000)),
+		element(idDuration, binary.BigEndian.AppendUint64(nil, math.Float64bits(12500))))
+	tracks := element(idTracks, videoTrack(1, "V_VP9", 854, 480, 41708333), audioTrack(2, "A_OPUS"))
+	cluster := join(idCluster, unknownSize, make([]byte, 2048))
+	return join(header, idSegment, unknownSize, info, tracks, cluster)
+}
+
+// buildMKV writes a 10s file with a 4-byte float duration in microsecond
+// timecodes: H.264 1920x1080 at 25fps and AAC audio.
+func buildMKV() []byte {
+	header := element(idEBML, element(idDocType, []byte("matroska")))
+	info := element(idInfo,
+		element(idTimecodeScale, ebmlUint(1000)),
+		element(idDuration, binary.BigEndian.AppendUint32(nil, math.Float32bits(10000000))))
+	tracks := element(idTracks, videoTrack(1, "V_MPEG4/ISO/AVC", 1920, 1080, 40000000), audioTrack(2, "A_AAC"))
+	cluster := element(idCluster, make([]byte, 4096))
+	return join(header, element(idSegment, info, tracks, cluster))
+}
+
+// ---- AVI ----
+
+func le16(v uint16) []byte { return binary.LittleEndian.AppendUint16(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func chunk(id string, parts ...[]byte) []byte {
+	content := join(parts...)
+	data := join([]byte(id), le32(uint32(len(content))), content)
+	if len(content)%2 == 1 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+func list(listType string, parts ...[]byte) []byte {
+	return chunk("LIST", []byte(listType), join(parts...))
+}
+
+func strh(kind, handler string, scale, rate, length uint32) []byte {
+	return chunk("strh", []byte(kind), []byte(handler), le32(0), le16(0), le16(0), le32(0),
+		le32(scale), le32(rate), le32(0), le32(length), le32(0), le32(0xffffffff), le32(0), make([]byte, 8))
+}
+
+// buildAVI writes a 5.005s file: H.264 320x240 at 29.97fps and MP3 audio.
+func buildAVI() []byte {
+	avih := chunk("avih", le32(33367), le32(0), le32(0), le32(0x10), le32(150), le32(0), le32(2), le32(0),
+		le32(320), le32(240), make([]byte, 16))
+	bitmapInfo := chunk("strf", le32(40), le32(320), le32(240), le16(1), le16(24), []byte("H264"), le32(320*240*3),
+		le32(0), le32(0), le32(0), le32(0))
+	waveFormat := chunk("strf", le16(0x55), le16(2), le32(44100), le32(16000), le16(1), le16(0), le16(0))
+
+	hdrl := list("hdrl", avih,
+		list("strl", strh("vids", "H264", 1001, 30000, 150), bitmapInfo),
+		list("strl", strh("auds", "\x00\x00\x00\x00", 1152, 44100, 192), waveFormat))
+	movi := list("movi", chunk("00dc", make([]byte, 2048)))
+	return chunk("RIFF", []byte("AVI "), hdrl, movi)
+}
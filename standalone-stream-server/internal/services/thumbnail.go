@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ThumbnailDir 是生成的缩略图所在目录，可通过 SetThumbnailDir 按配置修改
+var ThumbnailDir = "./thumbnails"
+
+// ThumbnailPath 返回视频（ID 格式为 目录:文件名）的缩略图路径
+func ThumbnailPath(videoID string) string {
+	directory, filename, _ := strings.Cut(videoID, ":")
+	return filepath.Join(ThumbnailDir, fmt.Sprintf("%s_%s.jpg", directory, filename))
+}
+
+// ThumbnailCache 返回缩略图缓存
+func (ms *MetadataService) ThumbnailCache() *ThumbnailCache {
+	return ms.thumbnails
+}
+
+// EnsureThumbnail 在缩略图不存在时为视频生成缩略图，返回是否生成了新的缩略图；
+// 同一视频的并发请求只生成一次
+func (ms *MetadataService) EnsureThumbnail(video VideoInfo) (bool, error) {
+	thumbnailPath := ThumbnailPath(video.ID)
+	return ms.thumbnails.Ensure([]string{thumbnailPath}, 0, nil, func(ctx context.Context) error {
+		// 元数据提取失败时使用默认时间点
+		metadata, _ := ms.ExtractMetadata(video.Path)
+		timestamp := ms.GetOptimalThumbnailTimestamp(metadata.Duration)
+		return ms.GenerateThumbnailContext(ctx, video.Path, thumbnailPath, timestamp)
+	})
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+	"standalone-stream-server/internal/utils"
+)
+
+// 缩略图缓存的默认值，配置未设置时使用
+const (
+	defaultThumbnailWorkers = 2
+	defaultThumbnailTimeout = 60 * time.Second
+)
+
+// ErrThumbnailTimeout 在生成缩略图（含等待工作线程）超时时返回
+var ErrThumbnailTimeout = errors.New("thumbnail generation timed out")
+
+// ThumbnailCache 管理缩略图目录：按最近最少使用淘汰超出上限的文件，合并对同一缩略图的并发生成，
+// 并限制同时运行的 ffmpeg 任务数
+type ThumbnailCache struct {
+	maxSize int64
+	timeout time.Duration
+	workers chan struct{}
+
+	mu      sync.Mutex
+	loaded  bool
+	lru     *list.List // 队首为最近使用的文件
+	entries map[string]*list.Element
+	size    int64
+	flights map[string]*thumbnailFlight
+}
+
+// thumbnailCacheEntry 是缓存中的一个文件
+type thumbnailCacheEntry struct {
+	path string
+	size int64
+}
+
+// thumbnailFlight 是一次进行中的生成，同一键的其他请求等待它完成
+type thumbnailFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// ThumbnailCacheStats 是缓存的当前状态
+type ThumbnailCacheStats struct {
+	Files       int   `json:"files"`
+	Size        int64 `json:"size"`
+	MaxSize     int64 `json:"max_size"`
+	Workers     int   `json:"workers"`
+	BusyWorkers int   `json:"busy_workers"`
+	InFlight    int   `json:"in_flight"`
+}
+
+// SetThumbnailDir 设置缩略图缓存目录，预览图集和变体目录随之改变；应在创建服务之前调用
+func SetThumbnailDir(dir string) {
+	if dir == "" {
+		return
+	}
+	ThumbnailDir = dir
+	SpriteDir = filepath.Join(dir, "sprites")
+	ThumbnailVariantDir = filepath.Join(dir, "variants")
+}
+
+// NewThumbnailCache 创建缩略图缓存；目录中已有的文件在第一次使用时载入
+func NewThumbnailCache(config models.ThumbnailConfig) *ThumbnailCache {
+	workers := config.Workers
+	if workers <= 0 {
+		workers = defaultThumbnailWorkers
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultThumbnailTimeout
+	}
+
+	return &ThumbnailCache{
+		maxSize: config.MaxCacheSize,
+		timeout: timeout,
+		workers: make(chan struct{}, workers),
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		flights: make(map[string]*thumbnailFlight),
+	}
+}
+
+// Ensure 返回 paths 对应的缓存文件，必要时调用 generate 生成，返回是否生成了新文件。
+// fresh 判断已有文件是否可用，为 nil 时只检查文件是否都存在；paths[0] 是合并并发请求的键。
+// generate 在工作线程中以 timeout 为限运行（为 0 时使用配置的超时），超时包括等待工作线程的时间
+func (tc *ThumbnailCache) Ensure(paths []string, timeout time.Duration, fresh func() bool, generate func(ctx context.Context) error) (bool, error) {
+	if fresh == nil {
+		fresh = func() bool { return filesExist(paths) }
+	}
+
+	tc.mu.Lock()
+	tc.load()
+	if fresh() {
+		tc.touch(paths)
+		tc.mu.Unlock()
+		utils.RecordThumbnailCacheLookup("hit")
+		return false, nil
+	}
+	if flight, ok := tc.flights[paths[0]]; ok {
+		tc.mu.Unlock()
+		utils.RecordThumbnailCacheLookup("shared")
+		<-flight.done
+		return false, flight.err
+	}
+	flight := &thumbnailFlight{done: make(chan struct{})}
+	tc.flights[paths[0]] = flight
+	tc.mu.Unlock()
+	utils.RecordThumbnailCacheLookup("miss")
+
+	flight.err = tc.generate(paths, timeout, generate)
+
+	tc.mu.Lock()
+	delete(tc.flights, paths[0])
+	if flight.err == nil {
+		tc.add(paths)
+	}
+	tc.mu.Unlock()
+	close(flight.done)
+	return flight.err == nil, flight.err
+}
+
+// generate 等待空闲的工作线程并运行 generate
+func (tc *ThumbnailCache) generate(paths []string, timeout time.Duration, generate func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		timeout = tc.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	select {
+	case tc.workers <- struct{}{}:
+	case <-ctx.Done():
+		utils.RecordThumbnailGeneration("timeout", time.Since(start))
+		return fmt.Errorf("%w: no worker available for %s within %s", ErrThumbnailTimeout, filepath.Base(paths[0]), timeout)
+	}
+	utils.UpdateThumbnailWorkersBusy(len(tc.workers))
+	defer func() {
+		<-tc.workers
+		utils.UpdateThumbnailWorkersBusy(len(tc.workers))
+	}()
+
+	err := generate(ctx)
+	switch {
+	case err == nil:
+		utils.RecordThumbnailGeneration("success", time.Since(start))
+	case ctx.Err() != nil:
+		utils.RecordThumbnailGeneration("timeout", time.Since(start))
+		return fmt.Errorf("%w after %s: %v", ErrThumbnailTimeout, timeout, err)
+	default:
+		utils.RecordThumbnailGeneration("error", time.Since(start))
+	}
+	return err
+}
+
+// Stats 返回缓存的当前状态
+func (tc *ThumbnailCache) Stats() ThumbnailCacheStats {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.load()
+	return ThumbnailCacheStats{
+		Files:       len(tc.entries),
+		Size:        tc.size,
+		MaxSize:     tc.maxSize,
+		Workers:     cap(tc.workers),
+		BusyWorkers: len(tc.workers),
+		InFlight:    len(tc.flights),
+	}
+}
+
+// load 在第一次使用时载入缓存目录中已有的文件，按修改时间排列使用顺序；调用方持有 tc.mu
+func (tc *ThumbnailCache) load() {
+	if tc.loaded {
+		return
+	}
+	tc.loaded = true
+
+	var files []thumbnailCacheEntry
+	modified := make(map[string]time.Time)
+	filepath.WalkDir(ThumbnailDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.Contains(d.Name(), ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, thumbnailCacheEntry{path: path, size: info.Size()})
+		modified[path] = info.ModTime()
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return modified[files[i].path].After(modified[files[j].path]) })
+
+	for _, file := range files {
+		entry := file
+		tc.entries[entry.path] = tc.lru.PushBack(&entry)
+		tc.size += entry.size
+	}
+	tc.evict(nil)
+}
+
+// touch 将文件移到使用顺序的最前面；调用方持有 tc.mu
+func (tc *ThumbnailCache) touch(paths []string) {
+	for _, path := range paths {
+		if element, ok := tc.entries[filepath.Clean(path)]; ok {
+			tc.lru.MoveToFront(element)
+		}
+	}
+}
+
+// add 记录新生成的文件并淘汰超出上限的旧文件；调用方持有 tc.mu
+func (tc *ThumbnailCache) add(paths []string) {
+	keep := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		keep[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if element, ok := tc.entries[path]; ok {
+			entry := element.Value.(*thumbnailCacheEntry)
+			tc.size += info.Size() - entry.size
+			entry.size = info.Size()
+			tc.lru.MoveToFront(element)
+			continue
+		}
+		tc.entries[path] = tc.lru.PushFront(&thumbnailCacheEntry{path: path, size: info.Size()})
+		tc.size += info.Size()
+	}
+	tc.evict(keep)
+}
+
+// evict 从最久未使用的文件开始删除，直到总大小不超过上限；keep 中的文件不会被删除。调用方持有 tc.mu
+func (tc *ThumbnailCache) evict(keep map[string]bool) {
+	evicted := 0
+	for element := tc.lru.Back(); tc.maxSize > 0 && tc.size > tc.maxSize && element != nil; {
+		previous := element.Prev()
+		entry := element.Value.(*thumbnailCacheEntry)
+		if !keep[entry.path] {
+			// 文件可能已被其他途径删除（如移入回收站），同样从索引中移除
+			if err := os.Remove(entry.path); err == nil || os.IsNotExist(err) {
+				tc.lru.Remove(element)
+				delete(tc.entries, entry.path)
+				tc.size -= entry.size
+				evicted++
+			}
+		}
+		element = previous
+	}
+
+	if evicted > 0 {
+		utils.RecordThumbnailCacheEvictions(evicted)
+	}
+	utils.UpdateThumbnailCacheSize(len(tc.entries), tc.size)
+}
+
+// filesExist 检查所有文件是否都存在
+func filesExist(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+func useThumbnailDir(t *testing.T) string {
+	t.Helper()
+	original := ThumbnailDir
+	dir := t.TempDir()
+	SetThumbnailDir(dir)
+	t.Cleanup(func() { SetThumbnailDir(original) })
+	return dir
+}
+
+func writeThumbnail(path string, size int) func(context.Context) error {
+	return func(ctx context.Context) error {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		return os.WriteFile(path, make([]byte, size), 0o644)
+	}
+}
+
+func TestThumbnailCache_SingleFlight(t *testing.T) {
+	dir := useThumbnailDir(t)
+	cache := NewThumbnailCache(models.ThumbnailConfig{Workers: 2})
+	path := filepath.Join(dir, "movies_a.jpg")
+
+	var calls int32
+	release := make(chan struct{})
+	generate := func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return writeThumbnail(path, 10)(ctx)
+	}
+
+	var wg sync.WaitGroup
+	var generated int32
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, err := cache.Ensure([]string{path}, 0, nil, generate)
+			if created {
+				atomic.AddInt32(&generated, 1)
+			}
+			errs <- err
+		}()
+	}
+	// Let every request reach the cache before the generation finishes
+	for deadline := time.Now().Add(time.Second); cache.Stats().InFlight == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 || generated != 1 {
+		t.Errorf("Expected one generation, got %d calls and %d generated", calls, generated)
+	}
+	if stats := cache.Stats(); stats.Files != 1 || stats.Size != 10 || stats.InFlight != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Failures are shared too, and nothing is cached
+	failing := filepath.Join(dir, "movies_b.jpg")
+	if _, err := cache.Ensure([]string{failing}, 0, nil, func(context.Context) error { return errors.New("ffmpeg failed") }); err == nil {
+		t.Error("Expected the generation error")
+	}
+	if stats := cache.Stats(); stats.Files != 1 {
+		t.Errorf("Expected failed generations not to be cached, got %+v", stats)
+	}
+}
+
+func TestThumbnailCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := useThumbnailDir(t)
+
+	// Files already on disk are loaded oldest first
+	old := filepath.Join(dir, "movies_old.jpg")
+	writeThumbnail(old, 10)(context.Background())
+	os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+
+	cache := NewThumbnailCache(models.ThumbnailConfig{MaxCacheSize: 25})
+	a := filepath.Join(dir, "movies_a.jpg")
+	b := filepath.Join(dir, "sprites", "movies_b.jpg")
+	c := filepath.Join(dir, "variants", "movies_c", "key.webp")
+
+	for _, path := range []string{a, b} {
+		if _, err := cache.Ensure([]string{path}, 0, nil, writeThumbnail(path, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("Expected the oldest file to be evicted")
+	}
+
+	// A hit makes a the most recently used file, so b is evicted for c
+	if created, err := cache.Ensure([]string{a}, 0, nil, writeThumbnail(a, 10)); err != nil || created {
+		t.Fatalf("Expected a cache hit, got %v %v", created, err)
+	}
+	if _, err := cache.Ensure([]string{c}, 0, nil, writeThumbnail(c, 10)); err != nil {
+		t.Fatal(err)
+	}
+	for path, exists := range map[string]bool{a: true, b: false, c: true} {
+		if _, err := os.Stat(path); (err == nil) != exists {
+			t.Errorf("Expected %s to exist: %v", path, exists)
+		}
+	}
+	if stats := cache.Stats(); stats.Files != 2 || stats.Size != 20 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestThumbnailCache_Timeout(t *testing.T) {
+	dir := useThumbnailDir(t)
+	cache := NewThumbnailCache(models.ThumbnailConfig{Workers: 1, Timeout: 50 * time.Millisecond})
+
+	// A generation that outlives its timeout
+	slow := filepath.Join(dir, "movies_slow.jpg")
+	_, err := cache.Ensure([]string{slow}, 0, nil, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, ErrThumbnailTimeout) {
+		t.Errorf("Expected ErrThumbnailTimeout, got %v", err)
+	}
+
+	// The only worker is busy for longer than the timeout
+	release := make(chan struct{})
+	busy := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Ensure([]string{filepath.Join(dir, "movies_busy.jpg")}, time.Minute, nil, func(ctx context.Context) error {
+			close(busy)
+			<-release
+			return nil
+		})
+	}()
+	<-busy
+	if _, err := cache.Ensure([]string{filepath.Join(dir, "movies_queued.jpg")}, 0, nil, writeThumbnail(slow, 1)); !errors.Is(err, ErrThumbnailTimeout) {
+		t.Errorf("Expected ErrThumbnailTimeout while waiting for a worker, got %v", err)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailVariantDir 是缩略图变体的缓存目录，每个视频一个子目录
+var ThumbnailVariantDir = filepath.Join(ThumbnailDir, "variants")
+
+// 缩略图格式
+const (
+	ThumbnailFormatJPEG = "jpeg"
+	ThumbnailFormatWebP = "webp"
+	ThumbnailFormatPNG  = "png"
+)
+
+// 缩放方式：fit 完整显示在目标尺寸内，fill 按目标宽高比居中裁剪后铺满
+const (
+	ThumbnailFitContain = "fit"
+	ThumbnailFitFill    = "fill"
+)
+
+// AutoTimestamp 表示由服务按视频时长选择截帧时间点
+const AutoTimestamp time.Duration = -1
+
+// 缩略图变体限制的默认值，配置未设置时使用
+var defaultThumbnailConfig = models.ThumbnailConfig{MaxWidth: 1920, MaxHeight: 1080, Quality: 85, CacheMaxAge: 24 * time.Hour}
+
+var (
+	// ErrInvalidThumbnailOptions 在缩略图查询参数无效时返回
+	ErrInvalidThumbnailOptions = errors.New("invalid thumbnail options")
+	// ErrTimestampOutOfRange 在 t 超出视频时长时返回
+	ErrTimestampOutOfRange = errors.New("timestamp is beyond the end of the video")
+)
+
+// ThumbnailOptions 描述一个缩略图变体；宽高为 0 表示按原始比例由另一边推算，都为 0 时保持原尺寸
+type ThumbnailOptions struct {
+	Width     int
+	Height    int
+	Fit       string
+	Format    string
+	Quality   int // 仅用于 JPEG；WebP 为无损编码
+	Timestamp time.Duration
+}
+
+// ThumbnailLimits 返回补全默认值后的缩略图变体配置
+func ThumbnailLimits(config models.ThumbnailConfig) models.ThumbnailConfig {
+	if config.MaxWidth <= 0 {
+		config.MaxWidth = defaultThumbnailConfig.MaxWidth
+	}
+	if config.MaxHeight <= 0 {
+		config.MaxHeight = defaultThumbnailConfig.MaxHeight
+	}
+	if config.Quality <= 0 {
+		config.Quality = defaultThumbnailConfig.Quality
+	}
+	if config.CacheMaxAge <= 0 {
+		config.CacheMaxAge = defaultThumbnailConfig.CacheMaxAge
+	}
+	return config
+}
+
+// HasThumbnailOptions 检查请求是否带有缩略图变体参数；不带参数时使用默认缩略图
+func HasThumbnailOptions(query func(key string) string) bool {
+	for _, key := range []string{"w", "h", "fit", "format", "q", "t"} {
+		if query(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseThumbnailOptions 解析 w、h、fit、format、q 和 t 查询参数
+func ParseThumbnailOptions(query func(key string) string, config models.ThumbnailConfig) (ThumbnailOptions, error) {
+	limits := ThumbnailLimits(config)
+	options := ThumbnailOptions{
+		Fit:       ThumbnailFitContain,
+		Format:    ThumbnailFormatJPEG,
+		Quality:   limits.Quality,
+		Timestamp: AutoTimestamp,
+	}
+
+	var err error
+	if options.Width, err = parseDimension(query("w"), "w", limits.MaxWidth); err != nil {
+		return options, err
+	}
+	if options.Height, err = parseDimension(query("h"), "h", limits.MaxHeight); err != nil {
+		return options, err
+	}
+
+	switch fit := strings.ToLower(query("fit")); fit {
+	case "", ThumbnailFitContain:
+	case ThumbnailFitFill:
+		if options.Width == 0 || options.Height == 0 {
+			return options, fmt.Errorf("%w: fit=fill requires both w and h", ErrInvalidThumbnailOptions)
+		}
+		options.Fit = ThumbnailFitFill
+	default:
+		return options, fmt.Errorf("%w: fit must be fit or fill", ErrInvalidThumbnailOptions)
+	}
+
+	switch format := strings.ToLower(query("format")); format {
+	case "", ThumbnailFormatJPEG, "jpg":
+	case ThumbnailFormatWebP, ThumbnailFormatPNG:
+		options.Format = format
+	default:
+		return options, fmt.Errorf("%w: format must be jpeg, webp or png", ErrInvalidThumbnailOptions)
+	}
+
+	if q := query("q"); q != "" {
+		quality, err := strconv.Atoi(q)
+		if err != nil || quality < 1 || quality > 100 {
+			return options, fmt.Errorf("%w: q must be between 1 and 100", ErrInvalidThumbnailOptions)
+		}
+		options.Quality = quality
+	}
+	if options.Format != ThumbnailFormatJPEG {
+		// 无损格式不使用质量参数，归一化后同一变体只缓存一份
+		options.Quality = 0
+	}
+
+	if t := query("t"); t != "" {
+		timestamp, err := parseTimestamp(t)
+		if err != nil {
+			return options, fmt.Errorf("%w: %v", ErrInvalidThumbnailOptions, err)
+		}
+		options.Timestamp = timestamp
+	}
+	return options, nil
+}
+
+// parseDimension 解析宽度或高度，空值表示未指定
+func parseDimension(value, name string, max int) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("%w: %s must be between 1 and %d", ErrInvalidThumbnailOptions, name, max)
+	}
+	return n, nil
+}
+
+// parseTimestamp 解析秒数（如 12.5）或时长（如 1m30s）
+func parseTimestamp(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+			return 0, fmt.Errorf("t must be a non-negative number of seconds")
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	timestamp, err := time.ParseDuration(value)
+	if err != nil || timestamp < 0 {
+		return 0, fmt.Errorf("t must be seconds or a duration such as 1m30s")
+	}
+	return timestamp, nil
+}
+
+// ThumbnailVariantKey 返回变体的缓存键：由源文件版本和全部参数确定，源文件变化后键随之改变
+func ThumbnailVariantKey(video VideoInfo, options ThumbnailOptions) string {
+	timestamp := "auto"
+	if options.Timestamp != AutoTimestamp {
+		timestamp = strconv.FormatInt(options.Timestamp.Milliseconds(), 10)
+	}
+	source := fmt.Sprintf("%s|%s|%dx%d|%s|%s|%d|%s",
+		video.ID, spriteSource(video), options.Width, options.Height, options.Fit, options.Format, options.Quality, timestamp)
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ThumbnailVariantPath 返回变体的缓存路径
+func ThumbnailVariantPath(videoID, key, format string) string {
+	directory, filename, _ := strings.Cut(videoID, ":")
+	return filepath.Join(ThumbnailVariantDir, fmt.Sprintf("%s_%s", directory, filename), key+"."+thumbnailExtension(format))
+}
+
+func thumbnailExtension(format string) string {
+	if format == ThumbnailFormatJPEG {
+		return "jpg"
+	}
+	return format
+}
+
+// EnsureThumbnailVariant 返回变体的缓存路径和缓存键，缓存不存在时截帧、缩放并编码
+func (ms *MetadataService) EnsureThumbnailVariant(video VideoInfo, options ThumbnailOptions) (string, string, error) {
+	key := ThumbnailVariantKey(video, options)
+	variantPath := ThumbnailVariantPath(video.ID, key, options.Format)
+	_, err := ms.thumbnails.Ensure([]string{variantPath}, 0, nil, func(ctx context.Context) error {
+		return ms.generateThumbnailVariant(ctx, video, options, variantPath)
+	})
+	if err != nil {
+		return "", key, err
+	}
+	return variantPath, key, nil
+}
+
+// generateThumbnailVariant 截帧、缩放、编码并写入 variantPath
+func (ms *MetadataService) generateThumbnailVariant(ctx context.Context, video VideoInfo, options ThumbnailOptions, variantPath string) error {
+	// 元数据提取失败时使用默认时间点，且不检查 t 是否越界
+	metadata, _ := ms.ExtractMetadata(video.Path)
+	timestamp := options.Timestamp
+	if timestamp == AutoTimestamp {
+		timestamp = ms.GetOptimalThumbnailTimestamp(metadata.Duration)
+	} else if metadata.Duration > 0 && timestamp.Seconds() >= metadata.Duration {
+		return fmt.Errorf("%w: %s >= %.2fs", ErrTimestampOutOfRange, timestamp, metadata.Duration)
+	}
+
+	frame, err := ms.ExtractFrame(ctx, video.Path, timestamp)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeThumbnail(&buf, ResizeThumbnail(frame, options), options); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(variantPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create thumbnail variant directory: %w", err)
+	}
+	tmpPath := fmt.Sprintf("%s.%d.tmp", variantPath, time.Now().UnixNano())
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write thumbnail variant: %w", err)
+	}
+	if err := os.Rename(tmpPath, variantPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to store thumbnail variant: %w", err)
+	}
+	return nil
+}
+
+// ExtractFrame 用 ffmpeg 截取指定时间点的一帧，以 PNG 格式通过管道读回；缩放和编码在 Go 中完成
+func (ms *MetadataService) ExtractFrame(ctx context.Context, videoPath string, timestamp time.Duration) (image.Image, error) {
+	ffmpegPath := ms.config.Video.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.3f", timestamp.Seconds()),
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"-",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg frame extraction failed: %w: %s", err, stderr.String())
+	}
+	if stdout.Len() == 0 {
+		// 时间点位于最后一个关键帧之后时 ffmpeg 不输出任何帧
+		return nil, fmt.Errorf("%w: no frame at %s", ErrTimestampOutOfRange, timestamp)
+	}
+
+	frame, err := png.Decode(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode extracted frame: %w", err)
+	}
+	return frame, nil
+}
+
+// ThumbnailSize 计算变体的输出尺寸和需要使用的源图区域
+func ThumbnailSize(srcWidth, srcHeight int, options ThumbnailOptions) (int, int, image.Rectangle) {
+	crop := image.Rect(0, 0, srcWidth, srcHeight)
+	width, height := options.Width, options.Height
+
+	switch {
+	case width == 0 && height == 0:
+		return srcWidth, srcHeight, crop
+	case height == 0:
+		height = scaleDimension(srcHeight, width, srcWidth)
+	case width == 0:
+		width = scaleDimension(srcWidth, height, srcHeight)
+	case options.Fit == ThumbnailFitFill:
+		// 从源图中央裁出与目标宽高比相同的区域
+		if srcWidth*height > width*srcHeight {
+			cropWidth := scaleDimension(srcHeight, width, height)
+			x := (srcWidth - cropWidth) / 2
+			crop = image.Rect(x, 0, x+cropWidth, srcHeight)
+		} else {
+			cropHeight := scaleDimension(srcWidth, height, width)
+			y := (srcHeight - cropHeight) / 2
+			crop = image.Rect(0, y, srcWidth, y+cropHeight)
+		}
+	default:
+		// fit：按较小的缩放比例缩放，另一边小于目标尺寸
+		if srcWidth*height > width*srcHeight {
+			height = scaleDimension(srcHeight, width, srcWidth)
+		} else {
+			width = scaleDimension(srcWidth, height, srcHeight)
+		}
+	}
+	return width, height, crop
+}
+
+// scaleDimension 返回 value * num / den 四舍五入的结果，至少为 1
+func scaleDimension(value, num, den int) int {
+	if den <= 0 {
+		return 1
+	}
+	n := int(math.Round(float64(value) * float64(num) / float64(den)))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// ResizeThumbnail 按选项缩放截取的帧
+func ResizeThumbnail(frame image.Image, options ThumbnailOptions) image.Image {
+	bounds := frame.Bounds()
+	width, height, crop := ThumbnailSize(bounds.Dx(), bounds.Dy(), options)
+	crop = crop.Add(bounds.Min)
+	if crop == bounds && width == bounds.Dx() && height == bounds.Dy() {
+		return frame
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), frame, crop, draw.Src, nil)
+	return resized
+}
+
+// EncodeThumbnail 按选项的格式编码缩略图
+func EncodeThumbnail(buf *bytes.Buffer, img image.Image, options ThumbnailOptions) error {
+	switch options.Format {
+	case ThumbnailFormatPNG:
+		return png.Encode(buf, img)
+	case ThumbnailFormatWebP:
+		return EncodeWebP(buf, img)
+	default:
+		quality := options.Quality
+		if quality <= 0 {
+			quality = defaultThumbnailConfig.Quality
+		}
+		return jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	}
+}
+
+// ThumbnailContentType 返回格式对应的 MIME 类型
+func ThumbnailContentType(format string) string {
+	return "image/" + format
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"golang.org/x/image/webp"
+)
+
+func queryOf(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestParseThumbnailOptions(t *testing.T) {
+	config := models.ThumbnailConfig{MaxWidth: 640, MaxHeight: 480, Quality: 80}
+
+	if HasThumbnailOptions(queryOf(nil)) {
+		t.Error("Expected no options without query parameters")
+	}
+
+	options, err := ParseThumbnailOptions(queryOf(map[string]string{"w": "320"}), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Width != 320 || options.Height != 0 || options.Fit != ThumbnailFitContain ||
+		options.Format != ThumbnailFormatJPEG || options.Quality != 80 || options.Timestamp != AutoTimestamp {
+		t.Errorf("Unexpected defaults: %+v", options)
+	}
+
+	options, err = ParseThumbnailOptions(queryOf(map[string]string{
+		"w": "320", "h": "180", "fit": "fill", "format": "WEBP", "q": "50", "t": "1m30s",
+	}), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Quality only applies to JPEG, so it is dropped from lossless variants
+	if options.Fit != ThumbnailFitFill || options.Format != ThumbnailFormatWebP || options.Quality != 0 || options.Timestamp != 90*time.Second {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if options, _ := ParseThumbnailOptions(queryOf(map[string]string{"t": "12.5"}), config); options.Timestamp != 12500*time.Millisecond {
+		t.Errorf("Expected t in seconds, got %v", options.Timestamp)
+	}
+
+	for _, query := range []map[string]string{
+		{"w": "0"},
+		{"w": "641"},
+		{"h": "abc"},
+		{"w": "320", "fit": "fill"},
+		{"fit": "stretch"},
+		{"format": "gif"},
+		{"q": "101"},
+		{"t": "-5"},
+		{"t": "soon"},
+	} {
+		if _, err := ParseThumbnailOptions(queryOf(query), config); !errors.Is(err, ErrInvalidThumbnailOptions) {
+			t.Errorf("Expected %v to be rejected, got %v", query, err)
+		}
+	}
+}
+
+func TestThumbnailVariantKey(t *testing.T) {
+	video := VideoInfo{ID: "movies:action/movie", Size: 5, Modified: 100}
+	options := ThumbnailOptions{Width: 320, Fit: ThumbnailFitContain, Format: ThumbnailFormatJPEG, Quality: 85, Timestamp: AutoTimestamp}
+
+	key := ThumbnailVariantKey(video, options)
+	if key != ThumbnailVariantKey(video, options) {
+		t.Error("Expected the key to be deterministic")
+	}
+
+	other := options
+	other.Timestamp = 0
+	if ThumbnailVariantKey(video, other) == key {
+		t.Error("Expected an explicit timestamp to change the key")
+	}
+	modified := video
+	modified.Modified = 200
+	if ThumbnailVariantKey(modified, options) == key {
+		t.Error("Expected a modified source to change the key")
+	}
+
+	path := ThumbnailVariantPath(video.ID, key, ThumbnailFormatJPEG)
+	if path != filepath.Join(ThumbnailVariantDir, "movies_action", "movie", key+".jpg") {
+		t.Errorf("Unexpected variant path %s", path)
+	}
+}
+
+func TestThumbnailSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       ThumbnailOptions
+		width, height int
+		crop          image.Rectangle
+	}{
+		{"original", ThumbnailOptions{}, 1920, 1080, image.Rect(0, 0, 1920, 1080)},
+		{"width only", ThumbnailOptions{Width: 320}, 320, 180, image.Rect(0, 0, 1920, 1080)},
+		{"height only", ThumbnailOptions{Height: 90}, 160, 90, image.Rect(0, 0, 1920, 1080)},
+		{"fit", ThumbnailOptions{Width: 300, Height: 300, Fit: ThumbnailFitContain}, 300, 169, image.Rect(0, 0, 1920, 1080)},
+		{"fill", ThumbnailOptions{Width: 300, Height: 300, Fit: ThumbnailFitFill}, 300, 300, image.Rect(420, 0, 1500, 1080)},
+	}
+	for _, tt := range tests {
+		width, height, crop := ThumbnailSize(1920, 1080, tt.options)
+		if width != tt.width || height != tt.height || crop != tt.crop {
+			t.Errorf("%s: expected %dx%d %v, got %dx%d %v", tt.name, tt.width, tt.height, tt.crop, width, height, crop)
+		}
+	}
+}
+
+func TestResizeAndEncodeThumbnail(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	for y := 0; y < 36; y++ {
+		for x := 0; x < 64; x++ {
+			frame.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 7), B: 128, A: 255})
+		}
+	}
+
+	decoders := map[string]func(*bytes.Buffer) (image.Image, error){
+		ThumbnailFormatJPEG: func(b *bytes.Buffer) (image.Image, error) { return jpeg.Decode(b) },
+		ThumbnailFormatPNG:  func(b *bytes.Buffer) (image.Image, error) { return png.Decode(b) },
+		ThumbnailFormatWebP: func(b *bytes.Buffer) (image.Image, error) { return webp.Decode(b) },
+	}
+	for format, decode := range decoders {
+		options := ThumbnailOptions{Width: 16, Height: 16, Fit: ThumbnailFitFill, Format: format, Quality: 90}
+		var buf bytes.Buffer
+		if err := EncodeThumbnail(&buf, ResizeThumbnail(frame, options), options); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		img, err := decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", format, err)
+		}
+		if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 16 {
+			t.Errorf("%s: expected 16x16, got %v", format, img.Bounds())
+		}
+	}
+}
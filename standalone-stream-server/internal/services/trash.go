@@ -0,0 +1,339 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+// trashDirName 是每个视频目录下的回收站子目录；以 . 开头，扫描和监听时会被跳过
+const trashDirName = ".trash"
+
+// 回收站条目中的文件名
+const (
+	trashEntryFile     = "entry.json"
+	trashThumbnailFile = "thumbnail.jpg"
+	trashRenditionsDir = "renditions"
+)
+
+// 回收站错误
+var (
+	ErrTrashEntryNotFound = errors.New("trash entry not found")
+	ErrRestoreConflict    = errors.New("a file already exists at the original path")
+)
+
+// TrashEntry 描述回收站中的一个视频
+type TrashEntry struct {
+	ID           string    `json:"id"`
+	VideoID      string    `json:"video_id"`
+	Directory    string    `json:"directory"`
+	OriginalPath string    `json:"original_path"`
+	FileName     string    `json:"file_name"`
+	Size         int64     `json:"size"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	DeletedBy    string    `json:"deleted_by,omitempty"` // 发起删除的用户、API 密钥或作业
+	Reason       string    `json:"reason,omitempty"`
+	PurgeAt      time.Time `json:"purge_at"` // 此后被定期清除任务永久删除
+	Thumbnail    bool      `json:"thumbnail"`
+	Renditions   bool      `json:"renditions"`
+}
+
+// TrashPurgeResult 汇总一次回收站清除
+type TrashPurgeResult struct {
+	Purged int   `json:"purged"`
+	Freed  int64 `json:"freed"`
+	Failed int   `json:"failed"`
+}
+
+// Trash 管理各视频目录的回收站：删除的视频连同缩略图和转码版本一起移入 <目录>/.trash/<条目 ID>/
+type Trash struct {
+	config *models.Config
+	mu     sync.Mutex // 串行化移动、恢复和清除，避免同一条目被并发操作
+}
+
+// NewTrash 创建回收站
+func NewTrash(config *models.Config) *Trash {
+	return &Trash{config: config}
+}
+
+// Move 将视频文件及其缩略图和转码版本移入所在目录的回收站
+func (t *Trash) Move(videoPath, deletedBy, reason string) (TrashEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dir, relative, err := t.directoryOf(videoPath)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	id, err := newTrashID()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	now := time.Now()
+	entry := TrashEntry{
+		ID:           id,
+		VideoID:      dir.Name + ":" + strings.TrimSuffix(relative, filepath.Ext(relative)),
+		Directory:    dir.Name,
+		OriginalPath: videoPath,
+		FileName:     filepath.Base(videoPath),
+		Size:         info.Size(),
+		DeletedAt:    now,
+		DeletedBy:    deletedBy,
+		Reason:       reason,
+		PurgeAt:      now.Add(t.config.Video.Trash.GracePeriod),
+	}
+
+	entryDir := filepath.Join(dir.Path, trashDirName, id)
+	if err := os.MkdirAll(entryDir, 0o755); err != nil {
+		return TrashEntry{}, fmt.Errorf("failed to create trash entry: %w", err)
+	}
+	// 先写入元数据，移动中途失败时视频仍可从回收站恢复
+	if err := writeTrashEntry(entryDir, entry); err != nil {
+		os.RemoveAll(entryDir)
+		return TrashEntry{}, err
+	}
+	if err := moveFile(videoPath, filepath.Join(entryDir, entry.FileName)); err != nil {
+		os.RemoveAll(entryDir)
+		return TrashEntry{}, fmt.Errorf("failed to move %s to trash: %w", videoPath, err)
+	}
+
+	// 缩略图和转码版本是派生文件，移动失败不影响删除
+	if err := moveFile(ThumbnailPath(entry.VideoID), filepath.Join(entryDir, trashThumbnailFile)); err == nil {
+		entry.Thumbnail = true
+	}
+	if err := os.Rename(RenditionDir(videoPath), filepath.Join(entryDir, trashRenditionsDir)); err == nil {
+		entry.Renditions = true
+	}
+	if entry.Thumbnail || entry.Renditions {
+		if err := writeTrashEntry(entryDir, entry); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// List 返回回收站中的条目，directory 为空时列出所有目录，最近删除的排在前面
+func (t *Trash) List(directory string) ([]TrashEntry, error) {
+	entries := []TrashEntry{}
+	for _, dir := range t.config.Video.Directories {
+		if directory != "" && dir.Name != directory {
+			continue
+		}
+
+		items, err := os.ReadDir(filepath.Join(dir.Path, trashDirName))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, item := range items {
+			if !item.IsDir() {
+				continue
+			}
+			entry, err := readTrashEntry(filepath.Join(dir.Path, trashDirName, item.Name()))
+			if err != nil {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// Get 返回回收站条目
+func (t *Trash) Get(id string) (TrashEntry, error) {
+	entry, _, err := t.find(id)
+	return entry, err
+}
+
+// Restore 将视频及其缩略图和转码版本移回原位置；原位置已有文件时返回 ErrRestoreConflict
+func (t *Trash) Restore(id string) (TrashEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, entryDir, err := t.find(id)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	if _, err := os.Stat(entry.OriginalPath); err == nil {
+		return entry, fmt.Errorf("%w: %s", ErrRestoreConflict, entry.OriginalPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(entry.OriginalPath), 0o755); err != nil {
+		return entry, fmt.Errorf("failed to recreate %s: %w", filepath.Dir(entry.OriginalPath), err)
+	}
+	if err := moveFile(filepath.Join(entryDir, entry.FileName), entry.OriginalPath); err != nil {
+		return entry, fmt.Errorf("failed to restore %s: %w", entry.OriginalPath, err)
+	}
+
+	// 派生文件只在原位置不存在时恢复，其余随条目一起删除
+	if entry.Thumbnail {
+		thumbnailPath := ThumbnailPath(entry.VideoID)
+		if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
+			os.MkdirAll(filepath.Dir(thumbnailPath), 0o755)
+			moveFile(filepath.Join(entryDir, trashThumbnailFile), thumbnailPath)
+		}
+	}
+	if entry.Renditions {
+		renditionDir := RenditionDir(entry.OriginalPath)
+		if _, err := os.Stat(renditionDir); os.IsNotExist(err) {
+			os.MkdirAll(filepath.Dir(renditionDir), 0o755)
+			os.Rename(filepath.Join(entryDir, trashRenditionsDir), renditionDir)
+		}
+	}
+
+	if err := os.RemoveAll(entryDir); err != nil {
+		return entry, fmt.Errorf("failed to remove trash entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Delete 永久删除回收站条目
+func (t *Trash) Delete(id string) (TrashEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, entryDir, err := t.find(id)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	if err := os.RemoveAll(entryDir); err != nil {
+		return entry, fmt.Errorf("failed to delete trash entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Purge 永久删除宽限期已过的条目
+func (t *Trash) Purge(now time.Time) (TrashPurgeResult, error) {
+	result := TrashPurgeResult{}
+	entries, err := t.List("")
+	if err != nil {
+		return result, err
+	}
+
+	for _, entry := range entries {
+		if entry.PurgeAt.After(now) {
+			continue
+		}
+		if _, err := t.Delete(entry.ID); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Purged++
+		result.Freed += entry.Size
+	}
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("failed to purge %d trash entries", result.Failed)
+	}
+	return result, nil
+}
+
+// find 在所有目录的回收站中查找条目，返回条目及其目录
+func (t *Trash) find(id string) (TrashEntry, string, error) {
+	if !isValidTrashID(id) {
+		return TrashEntry{}, "", fmt.Errorf("%w: %s", ErrTrashEntryNotFound, id)
+	}
+
+	for _, dir := range t.config.Video.Directories {
+		entryDir := filepath.Join(dir.Path, trashDirName, id)
+		entry, err := readTrashEntry(entryDir)
+		if err == nil {
+			return entry, entryDir, nil
+		}
+	}
+	return TrashEntry{}, "", fmt.Errorf("%w: %s", ErrTrashEntryNotFound, id)
+}
+
+// directoryOf 返回包含视频文件的已配置目录，以及文件在目录中的相对路径
+func (t *Trash) directoryOf(videoPath string) (models.VideoDirectory, string, error) {
+	absPath, err := filepath.Abs(videoPath)
+	if err != nil {
+		return models.VideoDirectory{}, "", err
+	}
+
+	for _, dir := range t.config.Video.Directories {
+		root, err := filepath.Abs(dir.Path)
+		if err != nil {
+			continue
+		}
+		relative, err := filepath.Rel(root, absPath)
+		if err == nil && relative != "." && !strings.HasPrefix(relative, "..") {
+			if strings.SplitN(filepath.ToSlash(relative), "/", 2)[0] == trashDirName {
+				return models.VideoDirectory{}, "", fmt.Errorf("%s is already in the trash", videoPath)
+			}
+			return dir, relative, nil
+		}
+	}
+	return models.VideoDirectory{}, "", fmt.Errorf("%s is not inside a configured video directory", videoPath)
+}
+
+// writeTrashEntry 原子地写入条目元数据
+func writeTrashEntry(entryDir string, entry TrashEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trash entry: %w", err)
+	}
+
+	path := filepath.Join(entryDir, trashEntryFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write trash entry: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to write trash entry: %w", err)
+	}
+	return nil
+}
+
+// readTrashEntry 读取条目元数据
+func readTrashEntry(entryDir string) (TrashEntry, error) {
+	data, err := os.ReadFile(filepath.Join(entryDir, trashEntryFile))
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return TrashEntry{}, fmt.Errorf("invalid trash entry %s: %w", entryDir, err)
+	}
+	return entry, nil
+}
+
+// newTrashID 生成随机条目 ID
+func newTrashID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate trash entry ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isValidTrashID 检查 ID 是否为 newTrashID 生成的格式，避免路径穿越
+func isValidTrashID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
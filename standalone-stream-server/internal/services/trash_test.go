@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"standalone-stream-server/internal/models"
+)
+
+func newTestTrash(t *testing.T) (*Trash, string) {
+	t.Helper()
+	videoDir := t.TempDir()
+	config := &models.Config{}
+	config.Video.Directories = []models.VideoDirectory{{Name: "movies", Path: videoDir, Enabled: true}}
+	config.Video.Trash = models.TrashConfig{Enabled: true, GracePeriod: time.Hour}
+	return NewTrash(config), videoDir
+}
+
+func TestTrash_MoveAndRestore(t *testing.T) {
+	trash, videoDir := newTestTrash(t)
+
+	videoPath := filepath.Join(videoDir, "action", "movie.mp4")
+	renditionPath := RenditionPath(videoPath, "720p", false)
+	for _, path := range []string{videoPath, renditionPath} {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte("video"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry, err := trash.Move(videoPath, "alice", "mistake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.VideoID != "movies:action/movie" || entry.DeletedBy != "alice" || entry.Size != 5 || !entry.Renditions {
+		t.Errorf("Unexpected trash entry: %+v", entry)
+	}
+	if !entry.PurgeAt.Equal(entry.DeletedAt.Add(time.Hour)) {
+		t.Errorf("Expected purge after the grace period, got %v", entry.PurgeAt)
+	}
+	for _, path := range []string{videoPath, RenditionDir(videoPath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be moved to the trash", path)
+		}
+	}
+	if _, err := trash.Move(filepath.Join(videoDir, ".trash", entry.ID, "movie.mp4"), "", ""); err == nil {
+		t.Error("Expected a trashed file not to be trashed again")
+	}
+
+	entries, err := trash.List("movies")
+	if err != nil || len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Fatalf("Expected the entry to be listed, got %+v %v", entries, err)
+	}
+	if entries, _ := trash.List("series"); len(entries) != 0 {
+		t.Errorf("Expected no entries for another directory, got %d", len(entries))
+	}
+
+	// A new file at the original path blocks the restore
+	os.WriteFile(videoPath, []byte("replacement"), 0o644)
+	if _, err := trash.Restore(entry.ID); !errors.Is(err, ErrRestoreConflict) {
+		t.Fatalf("Expected ErrRestoreConflict, got %v", err)
+	}
+	os.Remove(videoPath)
+
+	if _, err := trash.Restore(entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(videoPath); err != nil || string(data) != "video" {
+		t.Errorf("Expected the video to be restored, got %q %v", data, err)
+	}
+	if _, err := os.Stat(renditionPath); err != nil {
+		t.Errorf("Expected the renditions to be restored: %v", err)
+	}
+	if _, err := trash.Get(entry.ID); !errors.Is(err, ErrTrashEntryNotFound) {
+		t.Errorf("Expected the entry to be gone after restoring, got %v", err)
+	}
+	if _, err := trash.Get("../../etc"); !errors.Is(err, ErrTrashEntryNotFound) {
+		t.Errorf("Expected an invalid ID to be rejected, got %v", err)
+	}
+}
+
+func TestTrash_Purge(t *testing.T) {
+	trash, videoDir := newTestTrash(t)
+
+	var ids []string
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		path := filepath.Join(videoDir, name)
+		os.WriteFile(path, []byte("video"), 0o644)
+		entry, err := trash.Move(path, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.ID)
+	}
+
+	if result, err := trash.Purge(time.Now()); err != nil || result.Purged != 0 {
+		t.Fatalf("Expected nothing to be purged within the grace period, got %+v %v", result, err)
+	}
+
+	if _, err := trash.Delete(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	result, err := trash.Purge(time.Now().Add(2 * time.Hour))
+	if err != nil || result.Purged != 1 || result.Freed != 5 {
+		t.Errorf("Expected the remaining entry to be purged, got %+v %v", result, err)
+	}
+	if entries, _ := trash.List(""); len(entries) != 0 {
+		t.Errorf("Expected an empty trash, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 用户角色，权限依次递增
+const (
+	RoleViewer   = "viewer"
+	RoleUploader = "uploader"
+	RoleAdmin    = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer:   1,
+	RoleUploader: 2,
+	RoleAdmin:    3,
+}
+
+// 用户存储的错误
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidRole        = errors.New("invalid role")
+)
+
+var (
+	usersBucket = []byte("users")
+	// tombstonesBucket 记录已删除用户的下一个令牌版本，同名用户重建后旧令牌不会重新生效
+	tombstonesBucket = []byte("tombstones")
+)
+
+// dummyPasswordHash 用于用户不存在时仍执行一次 bcrypt 比较，避免通过响应时间枚举用户名
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// User 是本地存储的用户账户，密码以 bcrypt 哈希保存
+type User struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password_hash"`
+	Role         string   `json:"role"`
+	Groups       []string `json:"groups,omitempty"` // 用于目录访问控制
+	TokenVersion int      `json:"token_version"`    // 修改密码、角色或注销时递增，使已签发的令牌失效
+	CreatedAt    int64    `json:"created_at"`
+	UpdatedAt    int64    `json:"updated_at"`
+}
+
+// UserStore 是持久化在磁盘上的用户数据库
+type UserStore struct {
+	db *bolt.DB
+}
+
+// ValidRole 检查角色名是否有效
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows 检查角色 have 是否满足 need 要求的权限
+func RoleAllows(have, need string) bool {
+	rank, ok := roleRanks[have]
+	return ok && rank >= roleRanks[need]
+}
+
+// OpenUserStore 打开（必要时创建）指定路径的用户数据库
+func OpenUserStore(path string) (*UserStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create user store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{usersBucket, tombstonesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize user store: %w", err)
+	}
+
+	return &UserStore{db: db}, nil
+}
+
+// Close 关闭用户数据库
+func (us *UserStore) Close() error {
+	return us.db.Close()
+}
+
+// Create 创建新用户；重建已删除的用户时令牌版本从删除时的版本之后继续
+func (us *UserStore) Create(username, password, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || strings.Contains(username, ":") {
+		return nil, fmt.Errorf("invalid username: %q", username)
+	}
+	if password == "" {
+		return nil, errors.New("password cannot be empty")
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now().Unix()
+	user := &User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err = us.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket.Get([]byte(username)) != nil {
+			return ErrUserExists
+		}
+
+		tombstones := tx.Bucket(tombstonesBucket)
+		if data := tombstones.Get([]byte(username)); data != nil {
+			if version, err := strconv.Atoi(string(data)); err == nil {
+				user.TokenVersion = version
+			}
+			if err := tombstones.Delete([]byte(username)); err != nil {
+				return err
+			}
+		}
+		return putUser(bucket, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Get 按用户名查找用户
+func (us *UserStore) Get(username string) (*User, error) {
+	var user *User
+	err := us.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(usersBucket).Get([]byte(username))
+		if data == nil {
+			return ErrUserNotFound
+		}
+		user = &User{}
+		return json.Unmarshal(data, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// List 返回按用户名排序的全部用户
+func (us *UserStore) List() ([]User, error) {
+	users := []User{}
+	err := us.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(_, v []byte) error {
+			var user User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return nil // 跳过损坏的条目
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// Count 返回用户数量
+func (us *UserStore) Count() int {
+	count := 0
+	us.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(usersBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// Update 修改用户的密码和/或角色（空字符串表示不修改），并使已签发的令牌失效
+func (us *UserStore) Update(username, password, role string) (*User, error) {
+	if role != "" && !ValidRole(role) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+
+	var hash []byte
+	if password != "" {
+		var err error
+		hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+	}
+
+	return us.modify(username, func(user *User) {
+		if hash != nil {
+			user.PasswordHash = string(hash)
+		}
+		if role != "" {
+			user.Role = role
+		}
+	})
+}
+
+// SetGroups 设置用户所属的组，并使已签发的令牌失效
+func (us *UserStore) SetGroups(username string, groups []string) (*User, error) {
+	return us.modify(username, func(user *User) {
+		user.Groups = normalizeGroups(groups)
+	})
+}
+
+// RevokeTokens 使用户已签发的全部令牌失效
+func (us *UserStore) RevokeTokens(username string) error {
+	_, err := us.modify(username, func(*User) {})
+	return err
+}
+
+// Delete 删除用户，并保留其下一个令牌版本，使已签发的令牌在同名用户重建后仍然无效
+func (us *UserStore) Delete(username string) error {
+	return us.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		data := bucket.Get([]byte(username))
+		if data == nil {
+			return ErrUserNotFound
+		}
+
+		var user User
+		if err := json.Unmarshal(data, &user); err != nil {
+			return err
+		}
+		next := strconv.Itoa(user.TokenVersion + 1)
+		if err := tx.Bucket(tombstonesBucket).Put([]byte(username), []byte(next)); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(username))
+	})
+}
+
+// Authenticate 校验用户名和密码，成功时返回用户
+func (us *UserStore) Authenticate(username, password string) (*User, error) {
+	user, err := us.Get(username)
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// EnsureAdmin 在用户库为空时创建初始管理员账户，返回是否创建了账户
+func (us *UserStore) EnsureAdmin(username, password string) (bool, error) {
+	if username == "" || password == "" || us.Count() > 0 {
+		return false, nil
+	}
+	if _, err := us.Create(username, password, RoleAdmin); err != nil {
+		if errors.Is(err, ErrUserExists) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// modify 在事务中修改用户并递增令牌版本
+func (us *UserStore) modify(username string, fn func(*User)) (*User, error) {
+	var user User
+	err := us.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		data := bucket.Get([]byte(username))
+		if data == nil {
+			return ErrUserNotFound
+		}
+		if err := json.Unmarshal(data, &user); err != nil {
+			return err
+		}
+
+		fn(&user)
+		user.TokenVersion++
+		user.UpdatedAt = time.Now().Unix()
+		return putUser(bucket, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// normalizeGroups 去除空白和重复的组名
+func normalizeGroups(groups []string) []string {
+	seen := make(map[string]bool, len(groups))
+	result := make([]string, 0, len(groups))
+	for _, group := range groups {
+		group = strings.TrimSpace(group)
+		if group == "" || seen[group] {
+			continue
+		}
+		seen[group] = true
+		result = append(result, group)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func putUser(bucket *bolt.Bucket, user *User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(user.Username), data)
+}
@@ -5,15 +5,23 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"standalone-stream-server/internal/models"
 )
 
+// maxScanDepth 是递归扫描和监听视频目录时允许的最大子目录深度
+const maxScanDepth = 10
+
 // VideoService 处理视频相关操作
 type VideoService struct {
 	config          *models.Config
 	metadataService *MetadataService
+	catalog         *Catalog
+	contentIndex    *ContentIndex
+	access          *AccessTracker // 最近播放时间，供保留规则按 lru 顺序淘汰
+	commitMu        sync.Mutex // 串行化上传提交，避免相同内容的并发上传绕过去重
 }
 
 // NewVideoService 创建新的视频服务
@@ -21,9 +29,21 @@ func NewVideoService(config *models.Config) *VideoService {
 	return &VideoService{
 		config:          config,
 		metadataService: NewMetadataService(config),
+		contentIndex:    NewContentIndex(),
+		access:          NewAccessTracker(),
 	}
 }
 
+// SetCatalog 设置持久化索引；设置后列表、搜索和统计优先读取已索引的目录
+func (vs *VideoService) SetCatalog(catalog *Catalog) {
+	vs.catalog = catalog
+}
+
+// SetMetadataService 替换元数据服务，使扫描、缩略图和调度任务共用同一个探测池和元数据缓存
+func (vs *VideoService) SetMetadataService(metadataService *MetadataService) {
+	vs.metadataService = metadataService
+}
+
 // VideoInfo 表示视频文件信息
 type VideoInfo struct {
 	ID          string        `json:"id"`
@@ -37,6 +57,8 @@ type VideoInfo struct {
 	Metadata    VideoMetadata `json:"metadata,omitempty"`
 	StreamURL   string        `json:"stream_url"`
 	Available   bool          `json:"available"`
+	Renditions  []Rendition   `json:"renditions,omitempty"`
+	Hash        string        `json:"sha256,omitempty"` // 文件内容的 SHA-256，可用作强 ETag
 }
 
 // VideoMetadata 保存额外的视频信息
@@ -93,15 +115,21 @@ func (vs *VideoService) ListVideosInDirectory(directoryName string) ([]VideoInfo
 		return nil, fmt.Errorf("directory is disabled: %s", directoryName)
 	}
 
-	return vs.scanDirectoryRecursive(dir.Path, directoryName, "", 0)
+	// 目录已被索引时直接读取索引，避免遍历磁盘
+	if vs.catalog != nil {
+		if _, indexed, err := vs.catalog.DirectoryState(directoryName); err == nil && indexed {
+			return vs.catalog.ListDirectory(directoryName)
+		}
+	}
+
+	return vs.scanDirectoryRecursive(dir.Path, directoryName, "", 0, true)
 }
 
-// scanDirectoryRecursive 递归扫描目录以查找视频文件
-func (vs *VideoService) scanDirectoryRecursive(basePath, dirName, currentPath string, depth int) ([]VideoInfo, error) {
+// scanDirectoryRecursive 递归扫描目录以查找视频文件，withMetadata 为 false 时跳过元数据提取
+func (vs *VideoService) scanDirectoryRecursive(basePath, dirName, currentPath string, depth int, withMetadata bool) ([]VideoInfo, error) {
 	// 限制递归深度，防止无限递归或性能问题
-	const maxDepth = 10
-	if depth > maxDepth {
-		return nil, fmt.Errorf("directory depth exceeds maximum allowed depth (%d)", maxDepth)
+	if depth > maxScanDepth {
+		return nil, fmt.Errorf("directory depth exceeds maximum allowed depth (%d)", maxScanDepth)
 	}
 
 	fullPath := filepath.Join(basePath, currentPath)
@@ -133,7 +161,7 @@ func (vs *VideoService) scanDirectoryRecursive(basePath, dirName, currentPath st
 
 		if file.IsDir() {
 			// 递归处理子目录
-			subVideos, err := vs.scanDirectoryRecursive(basePath, dirName, filePath, depth+1)
+			subVideos, err := vs.scanDirectoryRecursive(basePath, dirName, filePath, depth+1, withMetadata)
 			if err == nil {
 				videos = append(videos, subVideos...)
 			}
@@ -151,31 +179,36 @@ func (vs *VideoService) scanDirectoryRecursive(basePath, dirName, currentPath st
 			continue
 		}
 
-		// 生成相对路径（用于ID和URL）
-		relativeVideoPath := strings.TrimSuffix(filePath, ext)
-		if currentPath == "" {
-			// 根目录下的文件，保持原有格式兼容性
-			relativeVideoPath = strings.TrimSuffix(fileName, ext)
-		}
+		videos = append(videos, vs.buildVideoInfo(dirName, filePath, fullFilePath, info, withMetadata))
+	}
 
-		video := VideoInfo{
-			ID:          vs.generateVideoID(dirName, relativeVideoPath),
-			Name:        fileName,
-			Size:        info.Size(),
-			Modified:    info.ModTime().Unix(),
-			ContentType: vs.getContentType(ext),
-			Directory:   dirName,
-			Path:        fullFilePath,
-			Extension:   ext,
-			StreamURL:   vs.generateStreamURL(dirName, relativeVideoPath),
-			Available:   true,
-			Metadata:    vs.extractVideoMetadata(fullFilePath, ext),
-		}
+	return videos, nil
+}
+
+// buildVideoInfo 根据目录内的相对文件路径构建视频信息
+func (vs *VideoService) buildVideoInfo(dirName, relativeFilePath, fullFilePath string, info os.FileInfo, withMetadata bool) VideoInfo {
+	ext := strings.ToLower(filepath.Ext(relativeFilePath))
 
-		videos = append(videos, video)
+	// 生成相对路径（用于ID和URL），根目录下的文件即为去掉扩展名的文件名
+	relativeVideoPath := strings.TrimSuffix(relativeFilePath, ext)
+
+	video := VideoInfo{
+		ID:          vs.generateVideoID(dirName, relativeVideoPath),
+		Name:        filepath.Base(relativeFilePath),
+		Size:        info.Size(),
+		Modified:    info.ModTime().Unix(),
+		ContentType: vs.getContentType(ext),
+		Directory:   dirName,
+		Path:        fullFilePath,
+		Extension:   ext,
+		StreamURL:   vs.generateStreamURL(dirName, relativeVideoPath),
+		Available:   true,
+	}
+	if withMetadata {
+		video.Metadata = vs.extractVideoMetadata(fullFilePath, ext)
 	}
 
-	return videos, nil
+	return video
 }
 
 // GetDirectoriesInfo 返回所有目录的信息
@@ -191,6 +224,13 @@ func (vs *VideoService) GetDirectoriesInfo() []DirectoryInfo {
 		}
 
 		if dir.Enabled {
+			if state, ok := vs.catalogDirectoryState(dir.Name); ok {
+				dirInfo.VideoCount = state.VideoCount
+				dirInfo.TotalSize = state.TotalSize
+				directories = append(directories, dirInfo)
+				continue
+			}
+
 			videos, err := vs.ListVideosInDirectory(dir.Name)
 			if err == nil {
 				dirInfo.VideoCount = len(videos)
@@ -208,8 +248,19 @@ func (vs *VideoService) GetDirectoriesInfo() []DirectoryInfo {
 	return directories
 }
 
-// FindVideoByID 通过 ID 查找视频（支持多层级路径）
+// FindVideoByID 通过 ID 查找视频（支持多层级路径），并附带已有的转码版本
 func (vs *VideoService) FindVideoByID(videoID string) (*VideoInfo, error) {
+	video, err := vs.findVideoByID(videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	video.Renditions = loadRenditions(video)
+	return video, nil
+}
+
+// findVideoByID 通过 ID 查找视频文件
+func (vs *VideoService) findVideoByID(videoID string) (*VideoInfo, error) {
 	// Parse video ID to extract directory and relative path
 	parts := strings.SplitN(videoID, ":", 2)
 	if len(parts) != 2 {
@@ -225,6 +276,13 @@ func (vs *VideoService) FindVideoByID(videoID string) (*VideoInfo, error) {
 		return nil, fmt.Errorf("directory not found or disabled: %s", directoryName)
 	}
 
+	// 优先使用索引条目，但仅当文件在磁盘上没有变化时
+	if vs.catalog != nil {
+		if video, err := vs.catalog.Get(videoID); err == nil && video != nil && vs.isCatalogEntryFresh(video) {
+			return video, nil
+		}
+	}
+
 	// 尝试直接查找文件（支持多层级路径）
 	videoPath := vs.findVideoFileByRelativePath(dir.Path, relativePath)
 	if videoPath == "" {
@@ -250,6 +308,7 @@ func (vs *VideoService) FindVideoByID(videoID string) (*VideoInfo, error) {
 		Available:   true,
 		Metadata:    vs.extractVideoMetadata(videoPath, ext),
 	}
+	video.Hash = vs.contentIndex.HashForFile(video.Path, video.Size, video.Modified)
 
 	return video, nil
 }
@@ -286,6 +345,27 @@ func (vs *VideoService) findDirectory(name string) *models.VideoDirectory {
 	return nil
 }
 
+// catalogDirectoryState 返回目录的索引状态（仅当索引可用且目录已被索引时）
+func (vs *VideoService) catalogDirectoryState(directoryName string) (CatalogDirectoryState, bool) {
+	if vs.catalog == nil {
+		return CatalogDirectoryState{}, false
+	}
+	state, indexed, err := vs.catalog.DirectoryState(directoryName)
+	if err != nil {
+		return CatalogDirectoryState{}, false
+	}
+	return state, indexed
+}
+
+// isCatalogEntryFresh 检查索引条目与磁盘上的文件是否仍然一致
+func (vs *VideoService) isCatalogEntryFresh(video *VideoInfo) bool {
+	stat, err := os.Stat(video.Path)
+	if err != nil {
+		return false
+	}
+	return stat.Size() == video.Size && stat.ModTime().Unix() == video.Modified
+}
+
 func (vs *VideoService) findVideoInAllDirectories(videoID string) (*VideoInfo, error) {
 	for _, dir := range vs.config.Video.Directories {
 		if !dir.Enabled {
@@ -387,6 +467,12 @@ func (vs *VideoService) GetStats() map[string]interface{} {
 		}
 
 		enabledDirs++
+		if state, ok := vs.catalogDirectoryState(dir.Name); ok {
+			totalVideos += state.VideoCount
+			totalSize += state.TotalSize
+			continue
+		}
+
 		videos, err := vs.ListVideosInDirectory(dir.Name)
 		if err != nil {
 			continue
@@ -411,47 +497,14 @@ func (vs *VideoService) GetStats() map[string]interface{} {
 
 // extractVideoMetadata 提取视频文件的基本元数据
 func (vs *VideoService) extractVideoMetadata(filePath, ext string) VideoMetadata {
-	// Use the new metadata service for enhanced extraction
+	// 依次尝试 FFprobe、容器头部解析和按扩展名推断
 	if metadata, err := vs.metadataService.ExtractMetadata(filePath); err == nil {
 		return metadata
 	}
-	
-	// Fallback to basic metadata based on file extension if service fails
-	metadata := VideoMetadata{
-		Format: strings.TrimPrefix(ext, "."),
-	}
 
-	// 现在，我们将提取基本的文件元数据  
-	// This is now a fallback when the metadata service fails
-	if stat, err := os.Stat(filePath); err == nil {
-		// 根据文件大小和编码器估计持续时间(非常粗略的估计)
-		switch ext {
-		case ".mp4", ".mov", ".m4v":
-			metadata.Codec = "H.264"
-			metadata.AudioCodec = "AAC"
-			metadata.Duration = 1 // Placeholder
-			metadata.Bitrate = 1000 * 144 // 144 kbps placeholder
-		case ".webm":
-			metadata.Codec = "VP8/VP9"
-			metadata.AudioCodec = "Vorbis/Opus"
-		case ".mkv":
-			metadata.Codec = "Various"
-			metadata.AudioCodec = "Various"
-		case ".avi":
-			metadata.Codec = "Various"
-			metadata.AudioCodec = "Various"
-		}
-
-		// 设置常见默认值
-		if metadata.Duration > 0 && metadata.Duration < 1 {
-			metadata.Duration = 1 // 最小 1 秒
-		}
-		if metadata.Bitrate == 0 && metadata.Duration > 0 {
-			metadata.Bitrate = int64(float64(stat.Size()) * 8 / metadata.Duration) // 每秒比特数
-		}
+	return VideoMetadata{
+		Format: strings.TrimPrefix(ext, "."),
 	}
-
-	return metadata
 }
 
 // ValidateVideoFile 检查视频文件是否可以正确访问且有效
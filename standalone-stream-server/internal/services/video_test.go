@@ -245,6 +245,57 @@ func TestVideoService_GetVideoPath(t *testing.T) {
 	}
 }
 
+func TestVideoService_FindVideoRenditions(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "movie.mp4")
+	if err := os.WriteFile(testFile, []byte("fake video content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	renditionDir := RenditionDir(testFile)
+	if err := os.MkdirAll(renditionDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(RenditionPath(testFile, "720p", false), []byte("medium quality"), 0o644)
+	os.WriteFile(RenditionPath(testFile, "audio", true), []byte("audio"), 0o644)
+	os.WriteFile(RenditionPath(testFile, "480p", false)+".part", []byte("in progress"), 0o644)
+
+	service := NewVideoService(&models.Config{
+		Video: models.VideoConfig{
+			Directories:      []models.VideoDirectory{{Name: "test", Path: tmpDir, Enabled: true}},
+			SupportedFormats: []string{".mp4"},
+		},
+	})
+
+	video, err := service.FindVideoByID("test:movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(video.Renditions) != 2 {
+		t.Fatalf("Expected 2 renditions, got %+v", video.Renditions)
+	}
+
+	rendition, ok := video.Rendition("audio")
+	if !ok {
+		t.Fatal("Expected audio rendition")
+	}
+	if rendition.ContentType != "audio/mp4" || rendition.StreamURL != video.StreamURL+"?quality=audio" {
+		t.Errorf("Unexpected audio rendition: %+v", rendition)
+	}
+	if _, ok := video.Rendition("480p"); ok {
+		t.Error("Partially written renditions must not be listed")
+	}
+
+	// 转码版本位于隐藏目录中，不应作为独立视频出现
+	videos, err := service.ListVideosInDirectory("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 {
+		t.Errorf("Expected only the source video to be listed, got %d", len(videos))
+	}
+}
+
 func TestVideoService_SearchVideos(t *testing.T) {
 	tmpDir := t.TempDir()
 	testDir := filepath.Join(tmpDir, "test_videos")
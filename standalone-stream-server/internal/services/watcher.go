@@ -0,0 +1,261 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"standalone-stream-server/internal/models"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// CatalogWatcher 监听视频目录的文件系统事件，并增量更新视频索引
+type CatalogWatcher struct {
+	config   *models.Config
+	indexer  *CatalogIndexer
+	debounce time.Duration
+
+	mu        sync.Mutex
+	watcher   *fsnotify.Watcher
+	pending   map[string]*time.Timer
+	running   bool
+	stopChan  chan struct{}
+	processed int64
+}
+
+// NewCatalogWatcher 创建新的目录监听器，debounce 为文件停止变化后等待的时间
+func NewCatalogWatcher(config *models.Config, indexer *CatalogIndexer, debounce time.Duration) *CatalogWatcher {
+	return &CatalogWatcher{
+		config:   config,
+		indexer:  indexer,
+		debounce: debounce,
+		pending:  make(map[string]*time.Timer),
+	}
+}
+
+// Start 为所有启用目录（递归）注册监听并开始处理事件
+func (cw *CatalogWatcher) Start() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.running {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create filesystem watcher: %w", err)
+	}
+	cw.watcher = watcher
+
+	for _, dir := range cw.config.Video.Directories {
+		if !dir.Enabled {
+			continue
+		}
+		cw.addTree(dir.Path, "", 0)
+	}
+
+	cw.running = true
+	cw.stopChan = make(chan struct{})
+	go cw.run(watcher, cw.stopChan)
+
+	return nil
+}
+
+// Stop 停止监听并取消所有尚未处理的事件
+func (cw *CatalogWatcher) Stop() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if !cw.running {
+		return nil
+	}
+	cw.running = false
+	close(cw.stopChan)
+
+	for path, timer := range cw.pending {
+		timer.Stop()
+		delete(cw.pending, path)
+	}
+
+	return cw.watcher.Close()
+}
+
+// IsRunning 返回监听器是否在运行
+func (cw *CatalogWatcher) IsRunning() bool {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.running
+}
+
+// GetStats 返回监听器统计信息
+func (cw *CatalogWatcher) GetStats() map[string]interface{} {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	watched := 0
+	if cw.running {
+		watched = len(cw.watcher.WatchList())
+	}
+
+	return map[string]interface{}{
+		"running":             cw.running,
+		"watched_directories": watched,
+		"pending_events":      len(cw.pending),
+		"processed_events":    cw.processed,
+		"debounce":            cw.debounce.String(),
+	}
+}
+
+// run 是事件处理循环
+func (cw *CatalogWatcher) run(watcher *fsnotify.Watcher, stop chan struct{}) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			cw.handleEvent(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logIndexerError("catalog_watcher", err)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handleEvent 将文件系统事件转换为索引更新
+func (cw *CatalogWatcher) handleEvent(event fsnotify.Event) {
+	dir, rel, ok := cw.resolveDirectory(event.Name)
+	if !ok || isHiddenRelativePath(rel) {
+		return
+	}
+
+	switch {
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		// 重命名会在新路径上产生单独的 Create 事件
+		cw.cancel(event.Name)
+		if err := cw.indexer.RemovePath(dir.Name, event.Name); err != nil {
+			logIndexerError("catalog_watcher_remove", err, zap.String("path", event.Name))
+		}
+		cw.markProcessed()
+
+	case event.Has(fsnotify.Create):
+		if info, err := os.Lstat(event.Name); err == nil && info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+			cw.mu.Lock()
+			if cw.running {
+				cw.addTree(dir.Path, rel, relativePathDepth(rel))
+			}
+			cw.mu.Unlock()
+		}
+		cw.schedule(dir.Name, event.Name)
+
+	case event.Has(fsnotify.Write):
+		cw.schedule(dir.Name, event.Name)
+	}
+}
+
+// schedule 在路径停止变化 debounce 时长后再索引，避免读取写入一半的文件
+func (cw *CatalogWatcher) schedule(directoryName, path string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if !cw.running {
+		return
+	}
+
+	if timer, exists := cw.pending[path]; exists {
+		timer.Reset(cw.debounce)
+		return
+	}
+
+	cw.pending[path] = time.AfterFunc(cw.debounce, func() {
+		cw.mu.Lock()
+		delete(cw.pending, path)
+		cw.mu.Unlock()
+
+		if err := cw.indexer.IndexPath(directoryName, path); err != nil {
+			logIndexerError("catalog_watcher_index", err, zap.String("path", path))
+		}
+		cw.markProcessed()
+	})
+}
+
+// cancel 取消路径上尚未触发的索引
+func (cw *CatalogWatcher) cancel(path string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if timer, exists := cw.pending[path]; exists {
+		timer.Stop()
+		delete(cw.pending, path)
+	}
+}
+
+func (cw *CatalogWatcher) markProcessed() {
+	cw.mu.Lock()
+	cw.processed++
+	cw.mu.Unlock()
+}
+
+// addTree 递归注册目录监听，遵循与扫描相同的深度、隐藏目录和符号链接规则；调用方必须持有 mu
+func (cw *CatalogWatcher) addTree(basePath, currentPath string, depth int) {
+	if depth > maxScanDepth {
+		return
+	}
+
+	fullPath := filepath.Join(basePath, currentPath)
+	if info, err := os.Lstat(fullPath); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+
+	if err := cw.watcher.Add(fullPath); err != nil {
+		logIndexerError("catalog_watcher_add", err, zap.String("path", fullPath))
+		return
+	}
+
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		cw.addTree(basePath, filepath.Join(currentPath, entry.Name()), depth+1)
+	}
+}
+
+// resolveDirectory 找到事件路径所属的启用目录
+func (cw *CatalogWatcher) resolveDirectory(path string) (models.VideoDirectory, string, bool) {
+	for _, dir := range cw.config.Video.Directories {
+		if !dir.Enabled {
+			continue
+		}
+		rel, err := filepath.Rel(dir.Path, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		return dir, rel, true
+	}
+	return models.VideoDirectory{}, "", false
+}
+
+// isHiddenRelativePath 检查相对路径中是否有以 "." 开头的部分（扫描时会被跳过）
+func isHiddenRelativePath(rel string) bool {
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
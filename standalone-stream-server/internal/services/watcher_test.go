@@ -0,0 +1,96 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForVideoCount 轮询直到目录中的视频数量达到预期
+func waitForVideoCount(t *testing.T, service *VideoService, expected int) []VideoInfo {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		videos, err := service.ListAllVideos()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) == expected {
+			return videos
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d videos, got %d", expected, len(videos))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCatalogWatcher_TracksFilesystemChanges(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "existing.mp4"), []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	service, catalog := newCatalogTestService(t, testDir)
+	indexer := NewCatalogIndexer(service, catalog, 0)
+	if _, err := indexer.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewCatalogWatcher(service.config, indexer, 50*time.Millisecond)
+	if err := watcher.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	// 新文件
+	if err := os.WriteFile(filepath.Join(testDir, "new.mp4"), []byte("new content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForVideoCount(t, service, 2)
+
+	// 新子目录及其中的文件
+	nested := filepath.Join(testDir, "season1")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "ep1.mov"), []byte("episode"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForVideoCount(t, service, 3)
+
+	// 隐藏文件和非视频文件应被忽略
+	if err := os.WriteFile(filepath.Join(testDir, ".partial.mp4"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重命名
+	if err := os.Rename(filepath.Join(testDir, "new.mp4"), filepath.Join(testDir, "renamed.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	videos := waitForVideoCount(t, service, 3)
+	found := false
+	for _, video := range videos {
+		if video.ID == "test:new" {
+			t.Error("Renamed video should no longer be indexed under its old ID")
+		}
+		if video.ID == "test:renamed" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected renamed video to be indexed")
+	}
+
+	// 删除子目录
+	if err := os.RemoveAll(nested); err != nil {
+		t.Fatal(err)
+	}
+	waitForVideoCount(t, service, 2)
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// 本文件实现一个纯 Go 的无损 WebP（VP8L）编码器，只使用减绿和预测两种变换，不使用
+// LZ77 和颜色缓存；缩略图尺寸较小，压缩率足够，且无需依赖 cgo 或外部程序
+
+const (
+	vp8lSignature        = 0x2f
+	vp8lPredictorBits    = 4 // 预测块大小为 16x16
+	vp8lMaxCodeLength    = 15
+	vp8lMaxCLCodeLength  = 7
+	vp8lGreenAlphabet    = 256 + 24 // 字面量加长度前缀，不使用颜色缓存
+	vp8lDistanceAlphabet = 40
+)
+
+// vp8lCodeLengthOrder 是码长码的码长在比特流中的写入顺序
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// errInvalidWebPSize 在图像尺寸超出 VP8L 的限制时返回
+var errInvalidWebPSize = errors.New("webp: image size must be between 1 and 16384 pixels")
+
+// vp8lPredictorModes 是按块选择的预测模式：左、上、左与上的平均值
+var vp8lPredictorModes = [...]uint32{1, 2, 7}
+
+// EncodeWebP 将图像编码为无损 WebP
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return errInvalidWebPSize
+	}
+
+	pixels := make([]uint32, width*height)
+	alphaUsed := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// VP8L 使用非预乘的 ARGB
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A != 0xff {
+				alphaUsed = true
+			}
+			pixels[y*width+x] = uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+		}
+	}
+
+	bw := &vp8lBitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if alphaUsed {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3) // 版本号
+
+	// 减绿变换：红、蓝通道减去绿通道，去除通道间的相关性
+	bw.writeBits(1, 1)
+	bw.writeBits(2, 2)
+	for i, p := range pixels {
+		green := (p >> 8) & 0xff
+		red := ((p>>16)&0xff - green) & 0xff
+		blue := (p&0xff - green) & 0xff
+		pixels[i] = p&0xff00ff00 | red<<16 | blue
+	}
+
+	// 预测变换：每个块选择残差最小的预测模式，模式表作为子图像写入
+	bw.writeBits(1, 1)
+	bw.writeBits(0, 2)
+	bw.writeBits(vp8lPredictorBits-2, 3)
+	modes, residuals := vp8lPredict(pixels, width, height)
+	writeVP8LImage(bw, modes, false)
+
+	bw.writeBits(0, 1) // 没有更多变换
+	writeVP8LImage(bw, residuals, true)
+
+	data := bw.bytes()
+	chunkSize := len(data)
+	padding := chunkSize & 1
+
+	header := make([]byte, 20)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(4+8+chunkSize+padding))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(chunkSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// vp8lPredict 为每个块选择预测模式，返回模式子图像和残差图像
+func vp8lPredict(pixels []uint32, width, height int) ([]uint32, []uint32) {
+	blockSize := 1 << vp8lPredictorBits
+	blocksX := (width + blockSize - 1) / blockSize
+	blocksY := (height + blockSize - 1) / blockSize
+	modes := make([]uint32, blocksX*blocksY)
+	residuals := make([]uint32, len(pixels))
+
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			best, bestCost := vp8lPredictorModes[0], -1
+			for _, mode := range vp8lPredictorModes {
+				cost := 0
+				for y := by * blockSize; y < height && y < (by+1)*blockSize; y++ {
+					for x := bx * blockSize; x < width && x < (bx+1)*blockSize; x++ {
+						cost += vp8lResidualCost(pixels[y*width+x], vp8lPrediction(pixels, width, x, y, mode))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[by*blocksX+bx] = best << 8
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			mode := modes[(y>>vp8lPredictorBits)*blocksX+(x>>vp8lPredictorBits)] >> 8
+			residuals[y*width+x] = vp8lSubPixels(pixels[y*width+x], vp8lPrediction(pixels, width, x, y, mode))
+		}
+	}
+	return modes, residuals
+}
+
+// vp8lPrediction 返回 (x, y) 处像素的预测值；首行和首列使用规范规定的固定模式
+func vp8lPrediction(pixels []uint32, width, x, y int, mode uint32) uint32 {
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return pixels[x-1]
+	case x == 0:
+		return pixels[(y-1)*width]
+	}
+
+	left := pixels[y*width+x-1]
+	top := pixels[(y-1)*width+x]
+	switch mode {
+	case 1:
+		return left
+	case 2:
+		return top
+	default:
+		return vp8lAverage(left, top)
+	}
+}
+
+// vp8lAverage 按通道计算两个像素的平均值（向下取整）
+func vp8lAverage(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+// vp8lSubPixels 按通道计算 a - b（模 256）
+func vp8lSubPixels(a, b uint32) uint32 {
+	alphaGreen := 0x00ff00ff + (a & 0xff00ff00) - (b & 0xff00ff00)
+	redBlue := 0xff00ff00 + (a & 0x00ff00ff) - (b & 0x00ff00ff)
+	return alphaGreen&0xff00ff00 | redBlue&0x00ff00ff
+}
+
+// vp8lResidualCost 估算残差的编码代价：各通道残差绝对值之和
+func vp8lResidualCost(pixel, prediction uint32) int {
+	residual := vp8lSubPixels(pixel, prediction)
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(int8(residual >> shift))
+		if v < 0 {
+			v = -v
+		}
+		cost += v
+	}
+	return cost
+}
+
+// writeVP8LImage 写入一个熵编码图像：五个前缀码后跟逐像素的字面量
+func writeVP8LImage(bw *vp8lBitWriter, pixels []uint32, main bool) {
+	bw.writeBits(0, 1) // 不使用颜色缓存
+	if main {
+		bw.writeBits(0, 1) // 只有一组前缀码
+	}
+
+	histograms := [5][]int{
+		make([]int, vp8lGreenAlphabet),
+		make([]int, 256),
+		make([]int, 256),
+		make([]int, 256),
+		make([]int, vp8lDistanceAlphabet),
+	}
+	for _, p := range pixels {
+		histograms[0][(p>>8)&0xff]++
+		histograms[1][(p>>16)&0xff]++
+		histograms[2][p&0xff]++
+		histograms[3][p>>24]++
+	}
+
+	var codes [5]vp8lPrefixCode
+	for i, histogram := range histograms {
+		codes[i] = writeVP8LPrefixCode(bw, histogram)
+	}
+
+	for _, p := range pixels {
+		codes[0].write(bw, (p>>8)&0xff)
+		codes[1].write(bw, (p>>16)&0xff)
+		codes[2].write(bw, p&0xff)
+		codes[3].write(bw, p>>24)
+	}
+}
+
+// vp8lPrefixCode 是一个规范霍夫曼码，码字已按比特流顺序反转
+type vp8lPrefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (c vp8lPrefixCode) write(bw *vp8lBitWriter, symbol uint32) {
+	if c.lengths == nil {
+		return // 单符号的码不占用比特
+	}
+	bw.writeBits(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writeVP8LPrefixCode 写入直方图对应的前缀码并返回它；少于两个符号时使用简单码
+func writeVP8LPrefixCode(bw *vp8lBitWriter, histogram []int) vp8lPrefixCode {
+	used, symbol := 0, 0
+	for i, count := range histogram {
+		if count > 0 {
+			used++
+			symbol = i
+		}
+	}
+
+	if used < 2 {
+		bw.writeBits(1, 1) // 简单码
+		bw.writeBits(0, 1) // 一个符号
+		if symbol < 2 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(symbol), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(symbol), 8)
+		}
+		return vp8lPrefixCode{}
+	}
+
+	lengths := huffmanLengths(histogram, vp8lMaxCodeLength)
+
+	// 码长本身用码长码编码，这里只使用字面量 0-15，不使用重复码
+	clHistogram := make([]int, len(vp8lCodeLengthOrder))
+	for _, length := range lengths {
+		clHistogram[length]++
+	}
+	clLengths := huffmanLengths(clHistogram, vp8lMaxCLCodeLength)
+	clCode := vp8lPrefixCode{lengths: clLengths, codes: canonicalCodes(clLengths)}
+	if countNonZero(clLengths) == 1 {
+		// 只有一个码长时解码器不读取任何比特
+		clCode = vp8lPrefixCode{}
+	}
+
+	numCodes := 4
+	for i, symbol := range vp8lCodeLengthOrder {
+		if clLengths[symbol] > 0 && i+1 > numCodes {
+			numCodes = i + 1
+		}
+	}
+	bw.writeBits(0, 1) // 普通码
+	bw.writeBits(uint32(numCodes-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:numCodes] {
+		bw.writeBits(uint32(clLengths[symbol]), 3)
+	}
+	bw.writeBits(0, 1) // 码长覆盖整个字母表
+	for _, length := range lengths {
+		clCode.write(bw, uint32(length))
+	}
+
+	return vp8lPrefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+func countNonZero(values []int) int {
+	n := 0
+	for _, v := range values {
+		if v != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// huffmanLengths 计算不超过 maxLength 的霍夫曼码长；超长时将频数减半后重建，直到满足限制
+func huffmanLengths(histogram []int, maxLength int) []int {
+	counts := append([]int(nil), histogram...)
+	for {
+		lengths := buildHuffmanLengths(counts)
+		longest := 0
+		for _, length := range lengths {
+			if length > longest {
+				longest = length
+			}
+		}
+		if longest <= maxLength {
+			return lengths
+		}
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	count int
+	index int
+}
+
+type huffmanHeap []huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].index < h[j].index
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
+
+// buildHuffmanLengths 构建霍夫曼树并返回每个符号的深度；只有一个符号时其码长为 1
+func buildHuffmanLengths(counts []int) []int {
+	lengths := make([]int, len(counts))
+	parents := make([]int, len(counts), 2*len(counts))
+	h := &huffmanHeap{}
+	for i, count := range counts {
+		parents[i] = -1
+		if count > 0 {
+			*h = append(*h, huffmanNode{count: count, index: i})
+		}
+	}
+	if h.Len() == 1 {
+		lengths[(*h)[0].index] = 1
+		return lengths
+	}
+
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(huffmanNode)
+		b := heap.Pop(h).(huffmanNode)
+		parent := len(parents)
+		parents = append(parents, -1)
+		parents[a.index] = parent
+		parents[b.index] = parent
+		heap.Push(h, huffmanNode{count: a.count + b.count, index: parent})
+	}
+
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		for node := i; parents[node] >= 0; node = parents[node] {
+			lengths[i]++
+		}
+	}
+	return lengths
+}
+
+// canonicalCodes 按码长分配规范霍夫曼码，并反转比特顺序以便低位优先写入
+func canonicalCodes(lengths []int) []uint32 {
+	var lengthCount [vp8lMaxCodeLength + 1]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			lengthCount[length]++
+		}
+	}
+
+	var next [vp8lMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for bits := 1; bits <= vp8lMaxCodeLength; bits++ {
+		code = (code + lengthCount[bits-1]) << 1
+		next[bits] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		c := next[length]
+		next[length]++
+		reversed := uint32(0)
+		for i := 0; i < length; i++ {
+			reversed = reversed<<1 | (c>>i)&1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
+
+// vp8lBitWriter 按低位优先的顺序写入比特
+type vp8lBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+func (w *vp8lBitWriter) writeBits(value uint32, n uint) {
+	w.acc |= uint64(value&(1<<n-1)) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *vp8lBitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nBits = 0, 0
+	}
+	return w.buf
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP_RoundTrip(t *testing.T) {
+	// A gradient with noise exercises every predictor mode and multi-symbol codes;
+	// a flat image exercises the single-symbol codes
+	gradient := image.NewNRGBA(image.Rect(0, 0, 67, 41))
+	for y := 0; y < 41; y++ {
+		for x := 0; x < 67; x++ {
+			gradient.Set(x, y, color.NRGBA{R: uint8(x * 3), G: uint8(y*5 + x), B: uint8((x * y) ^ 0x5a), A: 255})
+		}
+	}
+	flat := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range flat.Pix {
+		flat.Pix[i] = 200
+	}
+	translucent := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	for i := range translucent.Pix {
+		translucent.Pix[i] = uint8(i * 7)
+	}
+
+	for name, img := range map[string]*image.NRGBA{"gradient": gradient, "flat": flat, "translucent": translucent} {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, img); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		decoded, err := webp.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", name, err)
+		}
+		if decoded.Bounds() != img.Bounds() {
+			t.Fatalf("%s: expected bounds %v, got %v", name, img.Bounds(), decoded.Bounds())
+		}
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				want := img.NRGBAAt(x, y)
+				if got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA); got != want {
+					t.Fatalf("%s: pixel (%d,%d) expected %v, got %v", name, x, y, want, got)
+				}
+			}
+		}
+	}
+}
@@ -76,6 +76,136 @@ Help: "Scheduler worker status (1=running, 0=stopped)",
 },
 []string{"worker_name"},
 )
+
+// API key metrics
+APIKeyRequestsTotal = promauto.NewCounterVec(
+prometheus.CounterOpts{
+Name: "api_key_requests_total",
+Help: "Total number of requests authenticated by each API key",
+},
+[]string{"key_id", "name"},
+)
+
+APIKeyLastUsed = promauto.NewGaugeVec(
+prometheus.GaugeOpts{
+Name: "api_key_last_used_timestamp_seconds",
+Help: "Unix time of the last request authenticated by each API key",
+},
+[]string{"key_id", "name"},
+)
+
+// Stream admission queue metrics
+StreamQueueLength = promauto.NewGauge(
+prometheus.GaugeOpts{
+Name: "stream_admission_queue_length",
+Help: "Number of stream requests waiting for admission",
+},
+)
+
+StreamQueueDepth = promauto.NewHistogram(
+prometheus.HistogramOpts{
+Name:    "stream_admission_queue_depth",
+Help:    "Admission queue length seen by stream requests that had to queue",
+Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+},
+)
+
+StreamQueueWait = promauto.NewHistogramVec(
+prometheus.HistogramOpts{
+Name:    "stream_admission_wait_seconds",
+Help:    "Time stream requests spent in the admission queue",
+Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+},
+[]string{"outcome"},
+)
+
+// Limiter backend metrics
+LimiterBackendErrors = promauto.NewCounterVec(
+prometheus.CounterOpts{
+Name: "limiter_backend_errors_total",
+Help: "Total number of limiter backend errors; requests are allowed while the backend is unavailable",
+},
+[]string{"backend", "operation"},
+)
+
+// Thumbnail cache metrics
+ThumbnailCacheLookups = promauto.NewCounterVec(
+prometheus.CounterOpts{
+Name: "thumbnail_cache_lookups_total",
+Help: "Thumbnail cache lookups by result (hit, miss, or shared when waiting for an in-progress generation)",
+},
+[]string{"result"},
+)
+
+ThumbnailCacheEvictions = promauto.NewCounter(
+prometheus.CounterOpts{
+Name: "thumbnail_cache_evictions_total",
+Help: "Total number of cached thumbnail files evicted to stay under the size cap",
+},
+)
+
+ThumbnailCacheSize = promauto.NewGauge(
+prometheus.GaugeOpts{
+Name: "thumbnail_cache_size_bytes",
+Help: "Total size of cached thumbnails, sprite sheets and variants",
+},
+)
+
+ThumbnailCacheFiles = promauto.NewGauge(
+prometheus.GaugeOpts{
+Name: "thumbnail_cache_files",
+Help: "Number of cached thumbnail files",
+},
+)
+
+ThumbnailGenerationDuration = promauto.NewHistogramVec(
+prometheus.HistogramOpts{
+Name:    "thumbnail_generation_duration_seconds",
+Help:    "Time spent generating thumbnails, including the wait for a worker",
+Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+},
+[]string{"outcome"},
+)
+
+ThumbnailWorkersBusy = promauto.NewGauge(
+prometheus.GaugeOpts{
+Name: "thumbnail_workers_busy",
+Help: "Number of thumbnail workers running ffmpeg",
+},
+)
+
+// Metadata cache metrics
+MetadataCacheLookups = promauto.NewCounterVec(
+prometheus.CounterOpts{
+Name: "metadata_cache_lookups_total",
+Help: "Metadata cache lookups by result (hit, miss, or stale when the file changed since it was probed)",
+},
+[]string{"result"},
+)
+
+MetadataProbeDuration = promauto.NewHistogramVec(
+prometheus.HistogramOpts{
+Name:    "metadata_probe_duration_seconds",
+Help:    "Time spent probing video metadata by source (ffprobe, container or extension)",
+Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+},
+[]string{"source"},
+)
+
+MetadataProbesBusy = promauto.NewGauge(
+prometheus.GaugeOpts{
+Name: "metadata_probes_busy",
+Help: "Number of metadata probes running",
+},
+)
+
+// Segment packaging metrics
+SegmentEncodersBusy = promauto.NewGauge(
+prometheus.GaugeOpts{
+Name: "segment_encoders_busy",
+Help: "Number of ffmpeg processes encoding HLS/DASH segments",
+},
+)
 )
 
 // RecordHTTPRequest records an HTTP request metric
@@ -115,3 +245,72 @@ value = 1
 }
 SchedulerWorkerStatus.WithLabelValues(workerName).Set(value)
 }
+
+// RecordAPIKeyUsage adds count requests for an API key and updates its last-used time (0 leaves it unchanged)
+func RecordAPIKeyUsage(keyID, name string, count uint64, lastUsed int64) {
+APIKeyRequestsTotal.WithLabelValues(keyID, name).Add(float64(count))
+if lastUsed > 0 {
+APIKeyLastUsed.WithLabelValues(keyID, name).Set(float64(lastUsed))
+}
+}
+
+// RecordStreamAdmission records how long a queued stream request waited and whether it was admitted
+func RecordStreamAdmission(outcome string, wait time.Duration) {
+StreamQueueWait.WithLabelValues(outcome).Observe(wait.Seconds())
+}
+
+// UpdateStreamQueueLength updates the admission queue length gauge
+func UpdateStreamQueueLength(length int) {
+StreamQueueLength.Set(float64(length))
+}
+
+// RecordLimiterBackendError records a failed limiter backend operation
+func RecordLimiterBackendError(backend, operation string) {
+LimiterBackendErrors.WithLabelValues(backend, operation).Inc()
+}
+
+// RecordThumbnailCacheLookup records a thumbnail cache hit, miss or shared generation
+func RecordThumbnailCacheLookup(result string) {
+ThumbnailCacheLookups.WithLabelValues(result).Inc()
+}
+
+// RecordThumbnailCacheEvictions records evicted thumbnail files
+func RecordThumbnailCacheEvictions(count int) {
+ThumbnailCacheEvictions.Add(float64(count))
+}
+
+// UpdateThumbnailCacheSize updates the thumbnail cache size gauges
+func UpdateThumbnailCacheSize(files int, bytes int64) {
+ThumbnailCacheFiles.Set(float64(files))
+ThumbnailCacheSize.Set(float64(bytes))
+}
+
+// RecordThumbnailGeneration records the outcome and duration of a thumbnail generation
+func RecordThumbnailGeneration(outcome string, duration time.Duration) {
+ThumbnailGenerationDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// UpdateThumbnailWorkersBusy updates the number of busy thumbnail workers
+func UpdateThumbnailWorkersBusy(count int) {
+ThumbnailWorkersBusy.Set(float64(count))
+}
+
+// RecordMetadataCacheLookup records a metadata cache hit, miss or stale entry
+func RecordMetadataCacheLookup(result string) {
+MetadataCacheLookups.WithLabelValues(result).Inc()
+}
+
+// RecordMetadataProbe records the source and duration of a metadata probe
+func RecordMetadataProbe(source string, duration time.Duration) {
+MetadataProbeDuration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+// UpdateMetadataProbesBusy updates the number of running metadata probes
+func UpdateMetadataProbesBusy(count int) {
+MetadataProbesBusy.Set(float64(count))
+}
+
+// UpdateSegmentEncodersBusy updates the number of running segment encodes
+func UpdateSegmentEncodersBusy(count int) {
+SegmentEncodersBusy.Set(float64(count))
+}
@@ -122,7 +122,7 @@ func setupTestServer(t *testing.T) (*fiber.App, *models.Config, string) {
 	}
 
 	// 连接限制中间件
-	middleware.SetupConnectionLimiting(app, cfg)
+	middleware.SetupConnectionLimiting(app, cfg, middleware.NewMemoryLimiterBackend())
 
 	// 健康检查路由
 	app.Get("/health", healthHandler.Health)